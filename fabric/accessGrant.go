@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"edu/model"
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+)
+
+// ===================== 访问授权 =====================
+
+// recordReadOwnerArg 按记录所属学生检查权限的查询交易，值为参数中用户ID的位置
+var recordReadOwnerArg = map[string]int{
+	"GetEvaluationByID":    1,
+	"GetEvaluationByUser":  0,
+	"GetTestResultsByID":   0,
+	"GetTestResultsByUser": 0,
+	"GetJudgementByID":     0,
+	"GetJudgementByUser":   0,
+}
+
+// 授权结构定义在 edu/model 中，与链码共用
+type (
+	AccessScope    = model.AccessScope
+	AccessGrant    = model.AccessGrant
+	AccessLogEntry = model.AccessLogEntry
+)
+
+// grantStatus 授权在 at 时刻的状态：有效 / 已过期 / 已撤销
+func grantStatus(g *AccessGrant, at time.Time) string {
+	if g.RevokedAt != "" {
+		return "已撤销"
+	}
+	if expires, err := time.Parse(time.RFC3339, g.ExpiresAt); err == nil && !at.Before(expires) {
+		return "已过期"
+	}
+	return "有效"
+}
+
+// GrantAccess 以当前身份（学生）授权 grantee 在 expiresAt 之前读取 scope 内的记录
+// 授权ID即回执中的交易ID；客户端身份须为带 edu.role=student 属性的学生证书，通常通过 Client.As 切换
+func (c *Client) GrantAccess(grantee string, scope AccessScope, expiresAt time.Time) (*TxReceipt, error) {
+	return c.GrantAccessWithContext(context.Background(), grantee, scope, expiresAt)
+}
+
+// GrantAccessWithContext 授权第三方读取记录，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) GrantAccessWithContext(ctx context.Context, grantee string, scope AccessScope, expiresAt time.Time, opts ...CallOption) (receipt *TxReceipt, err error) {
+	ctx, end, err := c.startCall(ctx, "GrantAccess", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	scopeJSON, err := json.Marshal(scope)
+	if err != nil {
+		return nil, fmt.Errorf("序列化授权范围失败: %v", err)
+	}
+	return c.submit(ctx, "GrantAccess", grantee, string(scopeJSON), expiresAt.UTC().Format(time.RFC3339))
+}
+
+// RevokeAccess 撤销当前身份（学生）授出的授权
+func (c *Client) RevokeAccess(grantID string) (*TxReceipt, error) {
+	return c.RevokeAccessWithContext(context.Background(), grantID)
+}
+
+// RevokeAccessWithContext 撤销授权，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) RevokeAccessWithContext(ctx context.Context, grantID string, opts ...CallOption) (receipt *TxReceipt, err error) {
+	ctx, end, err := c.startCall(ctx, "RevokeAccess", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	return c.submit(ctx, "RevokeAccess", grantID)
+}
+
+// GetAccessGrants 查询学生的全部授权，调用者须为学生本人或学校组织的管理员或教师
+func (c *Client) GetAccessGrants(userID string) ([]AccessGrant, error) {
+	return c.GetAccessGrantsWithContext(context.Background(), userID)
+}
+
+// GetAccessGrantsWithContext 查询学生的授权，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) GetAccessGrantsWithContext(ctx context.Context, userID string, opts ...CallOption) (_ []AccessGrant, err error) {
+	ctx, end, err := c.startCall(ctx, "GetAccessGrants", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	result, err := c.evaluate(ctx, "GetAccessGrants", userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
+	}
+
+	var grants []AccessGrant
+	if err := json.Unmarshal(result, &grants); err != nil {
+		return nil, fmt.Errorf("解析结果失败: %v", err)
+	}
+	return grants, nil
+}
+
+// GetAccessLog 查询学生记录被第三方通过授权读取的日志，调用者须为学生本人或学校组织的管理员或教师
+// 客户端以提交方式执行授权读取（见 readsByGrant），绕过客户端以查询方式读取的访问不会出现在日志中
+func (c *Client) GetAccessLog(userID string) ([]AccessLogEntry, error) {
+	return c.GetAccessLogWithContext(context.Background(), userID)
+}
+
+// GetAccessLogWithContext 查询授权访问日志，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) GetAccessLogWithContext(ctx context.Context, userID string, opts ...CallOption) (_ []AccessLogEntry, err error) {
+	ctx, end, err := c.startCall(ctx, "GetAccessLog", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	result, err := c.evaluate(ctx, "GetAccessLog", userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
+	}
+
+	var entries []AccessLogEntry
+	if err := json.Unmarshal(result, &entries); err != nil {
+		return nil, fmt.Errorf("解析结果失败: %v", err)
+	}
+	return entries, nil
+}
+
+// ===================== 授权读取 =====================
+// 链码在通过授权读取时写入访问日志，以查询方式调用时交易不提交，日志不会进入账本。
+// 因此客户端按当前身份判断读取是否依靠授权，依靠授权的读取以提交方式调用查询交易，并且不使用读缓存
+
+// readsByGrant 当前身份读取 userID 的记录是否依靠学生授权，规则与链码的访问授权一致
+// 证书无法解析时按直接读取处理，由链码判断权限
+func (c *Client) readsByGrant(userID string) bool {
+	id, _ := c.identity.credentials()
+	cert, err := identity.CertificateFromPEM(id.Credentials())
+	if err != nil {
+		return false
+	}
+	if id.MspID() != model.InstitutionMSPID {
+		return true
+	}
+	switch model.CertRole(cert) {
+	case model.RoleAdmin, model.RoleTeacher:
+		return false
+	case model.RoleStudent:
+		return cert.Subject.CommonName != userID
+	}
+	return true
+}
+
+// submitRead 以提交方式调用查询交易，等待交易有效后返回背书时的查询结果
+func (c *Client) submitRead(ctx context.Context, txName string, args ...string) ([]byte, error) {
+	commit, result, err := c.endorseAndSubmit(ctx, txName, args...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := receiptFromCommit(ctx, commit); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// read 调用查询交易，依靠授权的读取以提交方式调用
+func (c *Client) read(ctx context.Context, txName string, args ...string) ([]byte, error) {
+	if index, ok := recordReadOwnerArg[txName]; ok && index < len(args) && c.readsByGrant(args[index]) {
+		return c.submitRead(ctx, txName, args...)
+	}
+	return c.evaluate(ctx, txName, args...)
+}
+
+// ===================== 命令行 =====================
+
+// runGrant 管理访问授权，-as 指定钱包中的学生身份，不指定时使用客户端默认身份
+func runGrant(args []string) error {
+	flags := flag.NewFlagSet("grant", flag.ExitOnError)
+	walletDir := flags.String("wallet", "wallet", "身份钱包目录")
+	as := flags.String("as", "", "以钱包中该用户的身份调用")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		return fmt.Errorf("缺少子命令（issue/revoke/list/log）")
+	}
+
+	var opts []ClientOption
+	if *as != "" {
+		wallet, err := NewFileSystemWallet(*walletDir)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, WithWallet(wallet))
+	}
+	base, err := NewClient(opts...)
+	if err != nil {
+		return err
+	}
+	defer base.Close()
+	client := base
+	if *as != "" {
+		if client, err = base.As(*as); err != nil {
+			return err
+		}
+	}
+
+	sub, subArgs := flags.Arg(0), flags.Args()[1:]
+	switch sub {
+	case "issue":
+		issueFlags := flag.NewFlagSet("grant issue", flag.ExitOnError)
+		grantee := issueFlags.String("to", "", "被授权身份，<MSP ID>/<证书CN>")
+		types := issueFlags.String("types", "", "授权的记录类型，逗号分隔")
+		records := issueFlags.String("records", "", "授权的单条记录，逗号分隔的 <记录类型>-<记录ID>")
+		expires := issueFlags.String("expires", "720h", "过期时间（RFC3339）或有效时长")
+		issueFlags.Parse(subArgs)
+
+		expiresAt, err := parseGrantExpiry(*expires)
+		if err != nil {
+			return err
+		}
+		var scope AccessScope
+		if *types != "" {
+			scope.DocTypes = strings.Split(*types, ",")
+		}
+		if *records != "" {
+			scope.Records = strings.Split(*records, ",")
+		}
+		receipt, err := client.GrantAccess(*grantee, scope, expiresAt)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("授权ID: %s（区块 %d，有效期至 %s）\n", receipt.TransactionID, receipt.BlockNumber, expiresAt.Format(time.RFC3339))
+
+	case "revoke":
+		if len(subArgs) != 1 {
+			return fmt.Errorf("用法: grant revoke <授权ID>")
+		}
+		if _, err := client.RevokeAccess(subArgs[0]); err != nil {
+			return err
+		}
+		fmt.Printf("已撤销授权 %s\n", subArgs[0])
+
+	case "list":
+		if len(subArgs) != 1 {
+			return fmt.Errorf("用法: grant list <用户ID>")
+		}
+		grants, err := client.GetAccessGrants(subArgs[0])
+		if err != nil {
+			return err
+		}
+		// 每行一个授权，附带当前状态
+		encoder := json.NewEncoder(os.Stdout)
+		now := time.Now()
+		for _, g := range grants {
+			encoder.Encode(struct {
+				AccessGrant
+				Status string `json:"status"`
+			}{g, grantStatus(&g, now)})
+		}
+
+	case "log":
+		if len(subArgs) != 1 {
+			return fmt.Errorf("用法: grant log <用户ID>")
+		}
+		entries, err := client.GetAccessLog(subArgs[0])
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		for _, e := range entries {
+			encoder.Encode(e)
+		}
+
+	default:
+		return fmt.Errorf("未知子命令 %s（可选 issue/revoke/list/log）", sub)
+	}
+	return nil
+}
+
+// parseGrantExpiry 解析过期时间，可以是 RFC3339 时间或从现在起的时长
+func parseGrantExpiry(value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("过期时间格式错误 %q（RFC3339 时间或时长，如 720h）", value)
+	}
+	return t, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"edu/model"
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+)
+
+// withTestIdentity 返回以指定身份调用的客户端视图，role 为空时证书不带角色属性
+func withTestIdentity(t *testing.T, c *Client, msp, commonName, role string) *Client {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName, OrganizationalUnit: []string{"client"}},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if role != "" {
+		template.ExtraExtensions = []pkix.Extension{
+			{Id: model.AttrsExtensionOID, Value: []byte(`{"attrs":{"` + model.RoleAttribute + `":"` + role + `"}}`)},
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := identity.NewX509Identity(msp, cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign, err := identity.NewPrivateKeySign(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view := *c
+	view.identity = &gatewayIdentity{label: commonName, id: id, sign: sign}
+	return &view
+}
+
+func TestReadsByGrant(t *testing.T) {
+	c := newTestEmbeddedClient(t)
+	tests := []struct {
+		name, msp, commonName, role string
+		want                        bool
+	}{
+		{"管理员", model.InstitutionMSPID, "admin", model.RoleAdmin, false},
+		{"教师", model.InstitutionMSPID, "teacher_001", model.RoleTeacher, false},
+		{"学生本人", model.InstitutionMSPID, "user_001", model.RoleStudent, false},
+		{"其他学生", model.InstitutionMSPID, "user_002", model.RoleStudent, true},
+		{"无角色的学校身份", model.InstitutionMSPID, "service", "", true},
+		{"其他组织", "Org2MSP", "employer", model.RoleAdmin, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			view := withTestIdentity(t, c, tt.msp, tt.commonName, tt.role)
+			if got := view.readsByGrant("user_001"); got != tt.want {
+				t.Errorf("readsByGrant = %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+	if c.readsByGrant("user_001") {
+		t.Error("进程内默认身份应为学校管理员")
+	}
+}
+
+func TestGrantReadsRecordedInAccessLog(t *testing.T) {
+	c := newTestEmbeddedClient(t, WithReadCache(time.Minute))
+	evaluation := testEvaluation("eval_001", "user_001")
+	if _, err := c.UploadEvaluation(evaluation); err != nil {
+		t.Fatal(err)
+	}
+	student := withTestIdentity(t, c, model.InstitutionMSPID, "user_001", model.RoleStudent)
+	employer := withTestIdentity(t, c, "Org2MSP", "employer", "")
+
+	if _, err := employer.GetEvaluationByID("eval_001", "user_001"); !errors.Is(err, model.CodeForbidden) {
+		t.Fatalf("授权前读取的错误 = %v，期望 FORBIDDEN", err)
+	}
+	receipt, err := student.GrantAccess("Org2MSP/employer", AccessScope{DocTypes: []string{"Evaluation"}}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("授权失败: %v", err)
+	}
+
+	got, err := employer.GetEvaluationByID("eval_001", "user_001")
+	if err != nil {
+		t.Fatalf("通过授权读取失败: %v", err)
+	}
+	if got.Feedback != evaluation.Feedback {
+		t.Errorf("反馈 = %q，期望 %q", got.Feedback, evaluation.Feedback)
+	}
+	// 授权读取不使用缓存，第二次读取同样留下日志
+	if _, err := employer.GetEvaluationByID("eval_001", "user_001"); err != nil {
+		t.Fatal(err)
+	}
+	if list, err := employer.GetEvaluationByUser("user_001"); err != nil || len(list) != 1 {
+		t.Fatalf("按用户读取 = %+v, %v", list, err)
+	}
+
+	entries, err := student.GetAccessLog("user_001")
+	if err != nil {
+		t.Fatalf("查询访问日志失败: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("访问日志 %d 条，期望 3 条: %+v", len(entries), entries)
+	}
+	for _, entry := range entries {
+		if entry.GrantID != receipt.TransactionID || entry.Accessor != "Org2MSP/employer" || entry.Record != "Evaluation-eval_001" {
+			t.Errorf("访问日志 = %+v", entry)
+		}
+	}
+
+	// 学生本人和管理员直接读取，不写访问日志
+	if _, err := student.GetEvaluationByID("eval_001", "user_001"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetEvaluationByUser("user_001"); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := c.GetAccessLog("user_001"); err != nil || len(entries) != 3 {
+		t.Errorf("直接读取后访问日志 %d 条, %v，期望仍为 3 条", len(entries), err)
+	}
+}
+
+func TestStaffRequiresRoleAttribute(t *testing.T) {
+	c := newTestEmbeddedClient(t)
+	if _, err := c.UploadEvaluation(testEvaluation("eval_001", "user_001")); err != nil {
+		t.Fatal(err)
+	}
+
+	teacher := withTestIdentity(t, c, model.InstitutionMSPID, "teacher_001", model.RoleTeacher)
+	if list, err := teacher.GetEvaluationByUser("user_001"); err != nil || len(list) != 1 {
+		t.Errorf("教师读取 = %+v, %v，期望读到一条记录", list, err)
+	}
+
+	// 学校组织中不带角色属性的身份没有授权时不能读取，也不能导出
+	service := withTestIdentity(t, c, model.InstitutionMSPID, "service", "")
+	if _, err := service.GetEvaluationByUser("user_001"); errorKey(err) != "user_records.forbidden" {
+		t.Errorf("无角色身份读取的错误 = %v，期望 user_records.forbidden", err)
+	}
+	if _, err := service.EraseUserData("user_001"); errorKey(err) != "user_data.erase_forbidden" {
+		t.Errorf("无角色身份擦除的错误 = %v，期望 user_data.erase_forbidden", err)
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"edu/model"
+)
+
+// ===================== REST 接口 =====================
+
+// openapi.json 由 SmartContract 生成，修改链码后需重新生成；Swagger UI 静态资源不在仓库中，
+// 构建前运行 go generate 下载后才会嵌入，否则 /docs/ 只提供 openapi.json 的链接
+//go:generate go run -C model ./cmd/openapigen -src ../chaincode/atcc/atcc.go,../txReceipt.go -out ../openapi.json
+//go:generate sh fetchSwaggerUI.sh
+
+//go:embed openapi.json
+var openAPIDocument []byte
+
+//go:embed swaggerui
+var swaggerUIFiles embed.FS
+
+// 请求体大小上限
+const maxAPIRequestBody = 1 << 20
+
+// apiTokenEnv 调用方令牌的环境变量，请求需带 Authorization: Bearer <令牌>
+const apiTokenEnv = "EDU_API_TOKEN"
+
+// defaultAPITransactions 默认开放的交易：查询、上传和修改记录以及授权管理
+// 删除记录、初始化账本和擦除学生数据默认不开放，需要时用 api -allow 显式列出
+var defaultAPITransactions = []string{
+	"GetAllEvaluations", "GetEvaluationByID", "GetEvaluationByUser",
+	"GetTestResultsByID", "GetTestResultsByTestID", "GetTestResultsByUser",
+	"GetJudgementByID", "GetJudgementByJudgementID", "GetJudgementByUser",
+	"ExportRecords", "GetAccessGrants", "GetAccessLog",
+	"UploadEvaluation", "ModifyEvaluation", "UploadTestResult", "UploadJudgement",
+	"GrantAccess", "RevokeAccess",
+}
+
+// APIOptions REST接口的访问控制
+type APIOptions struct {
+	Token        string   // 调用方令牌，不能为空
+	Transactions []string // 开放的交易，未列出的交易不注册路由，也不出现在接口文档中
+}
+
+// apiOperation OpenAPI 文档中描述的交易，扩展字段由生成器写入
+type apiOperation struct {
+	Transaction string         `json:"x-fabric-transaction"` // evaluate / submit
+	Parameters  []apiParameter `json:"x-fabric-parameters"`  // 链码参数顺序
+}
+
+type apiParameter struct {
+	Name string `json:"name"`
+	JSON bool   `json:"json"` // 链码接收JSON字符串，请求体中为对象
+}
+
+// apiErrorResponse 错误响应，对应文档中的 ErrorResponse；链码返回的错误另带错误码、错误信息键和参数
+type apiErrorResponse struct {
+	Code    model.ErrorKind    `json:"code"`
+	Message string             `json:"message"`
+	Reason  model.ErrorCode    `json:"reason,omitempty"`
+	Key     string             `json:"key,omitempty"`
+	Details map[string]string  `json:"details,omitempty"`
+	Fields  []model.FieldError `json:"fields,omitempty"`
+}
+
+// loadAPIOperations 从嵌入的 OpenAPI 文档读取 allowed 中的交易，返回交易列表和只包含这些交易的文档，
+// 路由与文档始终一致；allowed 中有文档里不存在的交易时返回错误
+func loadAPIOperations(allowed []string) (map[string]*apiOperation, []byte, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(openAPIDocument, &doc); err != nil {
+		return nil, nil, fmt.Errorf("解析OpenAPI文档失败: %v", err)
+	}
+	var paths map[string]json.RawMessage
+	if err := json.Unmarshal(doc["paths"], &paths); err != nil {
+		return nil, nil, fmt.Errorf("解析OpenAPI文档失败: %v", err)
+	}
+
+	operations := make(map[string]*apiOperation)
+	allowedPaths := make(map[string]json.RawMessage)
+	for _, name := range allowed {
+		path := "/api/" + name
+		raw, ok := paths[path]
+		if !ok {
+			return nil, nil, fmt.Errorf("未知的交易 %s", name)
+		}
+		var item struct {
+			Post *apiOperation `json:"post"`
+		}
+		if err := json.Unmarshal(raw, &item); err != nil || item.Post == nil {
+			return nil, nil, fmt.Errorf("OpenAPI文档中交易 %s 的格式错误", name)
+		}
+		operations[path] = item.Post
+		allowedPaths[path] = raw
+	}
+
+	var err error
+	if doc["paths"], err = json.Marshal(allowedPaths); err != nil {
+		return nil, nil, err
+	}
+	document, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+	return operations, document, nil
+}
+
+// APIHandler 返回REST接口处理器：
+// /api/<交易名> 以JSON对象传入参数调用交易，/openapi.json 为接口文档，/docs/ 为 Swagger UI；
+// 只有 /api/ 下的交易需要令牌，文档和 Swagger UI 不需要
+func (c *Client) APIHandler(options APIOptions) (http.Handler, error) {
+	if options.Token == "" {
+		return nil, fmt.Errorf("REST接口需要设置调用方令牌（%s）", apiTokenEnv)
+	}
+	operations, document, err := loadAPIOperations(options.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	ui, err := fs.Sub(swaggerUIFiles, "swaggerui")
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(document)
+	})
+	mux.Handle("/docs/", http.StripPrefix("/docs/", http.FileServer(http.FS(ui))))
+	for path, operation := range operations {
+		mux.Handle(path, requireAPIToken(options.Token, c.apiTransaction(strings.TrimPrefix(path, "/api/"), operation)))
+	}
+	return mux, nil
+}
+
+// requireAPIToken 校验 Authorization: Bearer <令牌>，比较时间与令牌内容无关
+func requireAPIToken(token string, next http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="edu-api"`)
+			writeAPIError(w, http.StatusUnauthorized, model.ErrUnauthenticated, "缺少或错误的调用方令牌")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiTransaction 调用单个交易，提交类交易返回回执，查询类交易原样返回链码结果
+func (c *Client) apiTransaction(name string, operation *apiOperation) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeAPIError(w, http.StatusMethodNotAllowed, model.ErrInvalidArgument, "只支持 POST 请求")
+			return
+		}
+
+		body := make(map[string]json.RawMessage)
+		if len(operation.Parameters) > 0 {
+			decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIRequestBody))
+			if err := decoder.Decode(&body); err != nil {
+				writeAPIError(w, http.StatusBadRequest, model.ErrInvalidArgument, fmt.Sprintf("请求体格式错误: %v", err))
+				return
+			}
+		}
+		args, err := apiArguments(operation, body)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, model.ErrInvalidArgument, err.Error())
+			return
+		}
+
+		// 链码错误信息的语言取自 Accept-Language，不支持时使用客户端配置
+		var opts []CallOption
+		if lang := model.ParseLanguage(r.Header.Get("Accept-Language")); lang != "" {
+			opts = append(opts, Language(lang))
+		}
+		result, receipt, err := c.transact(r.Context(), name, operation.Transaction == "submit", args, opts...)
+		if err != nil {
+			var coded *ChaincodeError
+			if errors.As(err, &coded) {
+				writeAPIErrorResponse(w, coded.Code.Kind().HTTPStatus(), apiErrorResponse{
+					Code:    coded.Code.Kind(),
+					Message: coded.Message,
+					Reason:  coded.Code,
+					Key:     coded.Key,
+					Details: coded.Details,
+					Fields:  coded.Fields,
+				})
+				return
+			}
+			kind := apiErrorKind(err)
+			writeAPIError(w, kind.HTTPStatus(), kind, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if receipt != nil {
+			json.NewEncoder(w).Encode(receipt)
+			return
+		}
+		if len(result) == 0 {
+			result = []byte("null")
+		}
+		w.Write(result)
+	}
+}
+
+// apiArguments 按文档中的参数顺序组装链码参数
+// JSON参数序列化为字符串，其余参数接受字符串、数字或布尔值
+func apiArguments(operation *apiOperation, body map[string]json.RawMessage) ([]string, error) {
+	args := make([]string, len(operation.Parameters))
+	for i, param := range operation.Parameters {
+		raw, ok := body[param.Name]
+		if !ok {
+			return nil, fmt.Errorf("缺少参数 %s", param.Name)
+		}
+		raw = bytes.TrimSpace(raw)
+		switch {
+		case param.JSON:
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, raw); err != nil {
+				return nil, fmt.Errorf("参数 %s 格式错误: %v", param.Name, err)
+			}
+			args[i] = compact.String()
+		case bytes.Equal(raw, []byte("null")):
+			args[i] = ""
+		case len(raw) > 0 && raw[0] == '"':
+			if err := json.Unmarshal(raw, &args[i]); err != nil {
+				return nil, fmt.Errorf("参数 %s 格式错误: %v", param.Name, err)
+			}
+		case len(raw) > 0 && (raw[0] == '{' || raw[0] == '['):
+			return nil, fmt.Errorf("参数 %s 不能是对象或数组", param.Name)
+		default:
+			args[i] = string(raw)
+		}
+	}
+	return args, nil
+}
+
+// apiErrorKind 不带错误码的错误的类别：验证失败的交易视为冲突，网关不可用和超时单独归类，其余按错误信息归类
+func apiErrorKind(err error) model.ErrorKind {
+	var commitFailed *CommitFailedError
+	switch {
+	case errors.As(err, &commitFailed):
+		return model.ErrConflict
+	case errors.Is(err, ErrClientClosed), errors.Is(err, context.DeadlineExceeded), isUnavailable(err):
+		return model.ErrUnavailable
+	}
+	return model.ClassifyError(err.Error())
+}
+
+func writeAPIError(w http.ResponseWriter, status int, kind model.ErrorKind, message string) {
+	writeAPIErrorResponse(w, status, apiErrorResponse{Code: kind, Message: message})
+}
+
+func writeAPIErrorResponse(w http.ResponseWriter, status int, response apiErrorResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// transact 按名称调用交易，submit 为 false 时只查询，供 REST 接口使用
+func (c *Client) transact(ctx context.Context, name string, submit bool, args []string, opts ...CallOption) (result []byte, receipt *TxReceipt, err error) {
+	ctx, end, err := c.startCall(ctx, name, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer end(&err)
+
+	if submit {
+		// 擦除交易之后还要销毁学生密钥
+		if name == "EraseUserData" && len(args) == 1 {
+			receipt, err = c.eraseUserData(ctx, args[0])
+			return nil, receipt, err
+		}
+		receipt, err = c.submit(ctx, name, args...)
+		return nil, receipt, err
+	}
+	result, err = c.read(ctx, name, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("查询失败: %w", err)
+	}
+	result, err = c.openResult(ctx, name, result)
+	return result, nil, err
+}
+
+// runAPI 启动REST接口服务，直到收到中断信号；后端按 EDU_LEDGER_BACKEND 选择，不支持内存后端
+// 所有请求都以客户端的身份调用链码，默认只监听本机，调用方令牌从 EDU_API_TOKEN 读取
+func runAPI(args []string) error {
+	flags := flag.NewFlagSet("api", flag.ExitOnError)
+	addr := flags.String("addr", "127.0.0.1:8080", "REST接口监听地址，对外开放时应放在反向代理（TLS）之后")
+	allow := flags.String("allow", strings.Join(defaultAPITransactions, ","), "开放的交易，逗号分隔")
+	flags.Parse(args)
+
+	options := APIOptions{Token: os.Getenv(apiTokenEnv)}
+	for _, name := range strings.Split(*allow, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			options.Transactions = append(options.Transactions, name)
+		}
+	}
+	if options.Token == "" {
+		return fmt.Errorf("REST接口需要设置调用方令牌（%s）", apiTokenEnv)
+	}
+
+	backend, err := NewLedgerBackend()
+	if err != nil {
+		return fmt.Errorf("创建客户端失败: %v", err)
+	}
+	client, ok := backend.(*Client)
+	if !ok {
+		backend.Close()
+		return fmt.Errorf("REST接口需要 fabric 或 embedded 后端")
+	}
+	handler, err := client.APIHandler(options)
+	if err != nil {
+		client.Close()
+		return err
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		client.Close()
+		return fmt.Errorf("监听REST接口端口失败: %v", err)
+	}
+	server := &http.Server{Handler: handler}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("REST接口服务异常退出: %v", err)
+		}
+	}()
+	log.Printf("REST接口服务已启动: http://%s/docs/", listener.Addr())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+
+	ctx, cancel := context.WithTimeout(context.Background(), opsShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("关闭REST接口服务失败: %v", err)
+	}
+	return client.Shutdown(ctx)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"edu/model"
+)
+
+const testAPIToken = "test-token"
+
+func newTestAPIServer(t *testing.T, transactions []string) *httptest.Server {
+	t.Helper()
+	return newTestAPIServerFor(t, newTestEmbeddedClient(t), transactions)
+}
+
+func newTestAPIServerFor(t *testing.T, c *Client, transactions []string) *httptest.Server {
+	t.Helper()
+	handler, err := c.APIHandler(APIOptions{Token: testAPIToken, Transactions: transactions})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// apiCall 以 token 调用交易，token 为空时不带 Authorization 头
+func apiCall(t *testing.T, server *httptest.Server, name, token, body string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/api/"+name, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var raw json.RawMessage
+	json.NewDecoder(resp.Body).Decode(&raw)
+	return resp, raw
+}
+
+func TestAPIHandlerOptions(t *testing.T) {
+	c := newTestEmbeddedClient(t)
+	tests := []struct {
+		name    string
+		options APIOptions
+		wantErr string
+	}{
+		{"缺少令牌", APIOptions{Transactions: defaultAPITransactions}, apiTokenEnv},
+		{"未知交易", APIOptions{Token: testAPIToken, Transactions: []string{"GetEvaluationByID", "DropLedger"}}, "未知的交易 DropLedger"},
+		{"默认交易", APIOptions{Token: testAPIToken, Transactions: defaultAPITransactions}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := c.APIHandler(tt.options)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("APIHandler = %v，期望成功", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("APIHandler = %v，期望包含 %q 的错误", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAPIToken(t *testing.T) {
+	server := newTestAPIServer(t, []string{"UploadEvaluation", "GetEvaluationByID"})
+	evaluation, _ := json.Marshal(map[string]interface{}{"evaluationJSON": testEvaluation("eval_001", "user_001")})
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"缺少令牌", "", http.StatusUnauthorized},
+		{"错误令牌", "wrong-token", http.StatusUnauthorized},
+		{"令牌前缀", testAPIToken[:4], http.StatusUnauthorized},
+		{"正确令牌", testAPIToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := apiCall(t, server, "UploadEvaluation", tt.token, string(evaluation))
+			if resp.StatusCode != tt.status {
+				t.Fatalf("状态码 = %d，期望 %d: %s", resp.StatusCode, tt.status, body)
+			}
+			if tt.status != http.StatusUnauthorized {
+				return
+			}
+			var got apiErrorResponse
+			if err := json.Unmarshal(body, &got); err != nil || got.Code != model.ErrUnauthenticated {
+				t.Errorf("错误响应 = %s，期望 %s", body, model.ErrUnauthenticated)
+			}
+			if resp.Header.Get("WWW-Authenticate") == "" {
+				t.Error("401 响应缺少 WWW-Authenticate 头")
+			}
+		})
+	}
+
+	resp, body := apiCall(t, server, "GetEvaluationByID", testAPIToken, `{"evaluationID":"eval_001","userID":"user_001"}`)
+	var got Evaluation
+	if resp.StatusCode != http.StatusOK || json.Unmarshal(body, &got) != nil || got.EvaluationID != "eval_001" {
+		t.Errorf("查询已上传的测评 = %d %s", resp.StatusCode, body)
+	}
+}
+
+// 未开放的交易既不注册路由，也不出现在接口文档中；文档不需要令牌
+func TestAPIAllowlist(t *testing.T) {
+	server := newTestAPIServer(t, defaultAPITransactions)
+
+	for _, name := range []string{"DeleteRecord", "InitLedger", "EraseUserData"} {
+		if resp, body := apiCall(t, server, name, testAPIToken, `{}`); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("调用未开放的交易 %s: 状态码 = %d，期望 404: %s", name, resp.StatusCode, body)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var doc struct {
+		Paths map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Paths) != len(defaultAPITransactions) {
+		t.Errorf("文档中有 %d 个交易，期望 %d", len(doc.Paths), len(defaultAPITransactions))
+	}
+	for _, name := range defaultAPITransactions {
+		if _, ok := doc.Paths["/api/"+name]; !ok {
+			t.Errorf("文档缺少开放的交易 %s", name)
+		}
+	}
+	if _, ok := doc.Paths["/api/DeleteRecord"]; ok {
+		t.Error("文档包含未开放的交易 DeleteRecord")
+	}
+}
+
+// 返回链码结果但写入账本的交易（GrantAccess）按提交类交易调用，返回回执且授权在调用后可查
+func TestAPIGrantAccessSubmits(t *testing.T) {
+	c := newTestEmbeddedClient(t)
+	student := withTestIdentity(t, c, model.InstitutionMSPID, "user_001", model.RoleStudent)
+	server := newTestAPIServerFor(t, student, []string{"GrantAccess"})
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"grantee":   "Org2MSP/employer",
+		"scopeJSON": AccessScope{DocTypes: []string{"Evaluation"}},
+		"expiresAt": time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	})
+	resp, raw := apiCall(t, server, "GrantAccess", testAPIToken, string(body))
+	var receipt TxReceipt
+	if resp.StatusCode != http.StatusOK || json.Unmarshal(raw, &receipt) != nil || receipt.TransactionID == "" {
+		t.Fatalf("授权 = %d %s，期望交易回执", resp.StatusCode, raw)
+	}
+
+	grants, err := student.GetAccessGrants("user_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grants) != 1 || grants[0].GrantID != receipt.TransactionID {
+		t.Errorf("授权列表 = %+v，期望一条ID为 %s 的授权", grants, receipt.TransactionID)
+	}
+}
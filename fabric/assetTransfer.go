@@ -1,16 +1,17 @@
 package main
 
 import (
+	"context"
 	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
 	"path"
-	"time"
 	"strings"
 
-	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"edu/model"
 	"github.com/hyperledger/fabric-gateway/pkg/identity"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -27,44 +28,33 @@ const (
 	chaincodeID  = "atcc"
 )
 
-// 数据结构定义（必须与链码中的结构匹配）
-type Evaluation struct {
-	DocType      string `json:"docType"`
-	EvaluationID string `json:"Evaluation_ID"`
-	UserID       string `json:"User_ID"`
-	PointsDegree string `json:"Points_Degree"`
-	Feedback     string `json:"Feedback"`
-}
-
-type TestResult struct {
-	DocType     string `json:"docType"`
-	TestID      string `json:"Test_ID"`
-	UserID      string `json:"User_ID"`
-	ScoreSum    string `json:"Score_Sum"`
-	PaperNumber string `json:"Paper_Number"`
-	Answer      string `json:"Answer"`
-}
-
-type Judgement struct {
-	DocType            string `json:"docType"`
-	JudgementID        string `json:"Judgement_ID"`
-	UserID             string `json:"User_ID"`
-	JudgementObjection string `json:"Judgement_Objection"`
-	JudgementObjectID  string `json:"Judgement_ObjectID"`
-	JudgementRating    string `json:"Judgement_Rating"`
-	JudgementContent   string `json:"Judgement_Content"`
-	JudgementTime      string `json:"Judgement_Time"`
-}
+// 数据结构定义，与链码共用 edu/model 中的结构和校验规则
+type (
+	Evaluation = model.Evaluation
+	TestResult = model.TestResult
+	Judgement  = model.Judgement
+)
 
 type Client struct {
-	contract *client.Contract
+	pool        *peerPool
+	identity    *gatewayIdentity // 当前调用使用的签名身份
+	wallet      Wallet
+	cache       *readCache // 未启用缓存时为 nil
+	bypassCache bool
+	keys        KeyService       // 学生密钥，用于附带写入密钥和解密个人内容
+	sealed      bool             // 不解密，返回账本中保存的值
+	envelope    *fieldEncryption // 客户端字段加密，未配置时为 nil
+	language    string           // 链码错误信息的语言，为空时为中文
+	metrics     *clientMetrics
+	life        *lifecycle       // 派生视图共享，关闭任一视图即关闭客户端
+	embedded    *embeddedChannel // 进程内链码模式，为 nil 时通过网关调用
 }
 
-func NewClient() (*Client, error) {
-	// 创建gRPC客户端连接
-	connection, err := newGrpcConnection()
-	if err != nil {
-		return nil, fmt.Errorf("创建gRPC连接失败: %v", err)
+// NewClient 创建客户端，未指定节点时连接默认的 peer0.org1
+func NewClient(opts ...ClientOption) (*Client, error) {
+	options := defaultClientOptions()
+	for _, opt := range opts {
+		opt(options)
 	}
 
 	// 创建Gateway客户端
@@ -73,169 +63,417 @@ func NewClient() (*Client, error) {
 		return nil, err
 	}
 
-	sign, err := newSign()
+	// 离线模式下不配置签名函数，签名由外部完成
+	var sign identity.Sign
+	switch {
+	case options.offline:
+	case options.signer != nil:
+		sign = options.signer.Sign
+	default:
+		if sign, err = newSign(); err != nil {
+			return nil, err
+		}
+	}
+
+	// 为每个网关节点创建gRPC连接和Gateway
+	defaultIdentity := &gatewayIdentity{id: id, sign: sign}
+	pool, err := newPeerPool(options, defaultIdentity)
 	if err != nil {
 		return nil, err
 	}
 
-	gw, err := client.Connect(
-		id,
-		client.WithSign(sign),
-		client.WithClientConnection(connection),
-		client.WithEvaluateTimeout(5*time.Second),
-		client.WithEndorseTimeout(15*time.Second),
-		client.WithSubmitTimeout(5*time.Second),
-		client.WithCommitStatusTimeout(1*time.Minute),
-	)
+	c := &Client{pool: pool, identity: defaultIdentity, wallet: options.wallet, keys: options.keys, envelope: options.envelope, language: options.language, life: newLifecycle()}
+	c.metrics = newClientMetrics(c)
+	if options.cacheTTL > 0 {
+		c.cache = newReadCache(options.cacheTTL)
+		c.cache.start(c)
+	}
+	if options.credentialReloadInterval > 0 {
+		c.startWatching(options)
+	}
+	return c, nil
+}
+
+// ===================== 测评记录操作 =====================
+func (c *Client) UploadEvaluation(evaluation Evaluation) (*TxReceipt, error) {
+	return c.UploadEvaluationWithContext(context.Background(), evaluation)
+}
+
+// UploadEvaluationWithContext 上传测评记录，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) UploadEvaluationWithContext(ctx context.Context, evaluation Evaluation, opts ...CallOption) (receipt *TxReceipt, err error) {
+	ctx, end, err := c.startCall(ctx, "UploadEvaluation", opts...)
 	if err != nil {
-		return nil, fmt.Errorf("连接网关失败: %v", err)
+		return nil, err
 	}
+	defer end(&err)
 
-	network := gw.GetNetwork(channelName)
-	contract := network.GetContract(chaincodeID)
+	if err := evaluation.Validate(); err != nil {
+		return nil, fmt.Errorf("测评记录校验失败: %w", err)
+	}
+	evalJSON, err := marshalArg(ctx, evaluation)
+	if err != nil {
+		return nil, fmt.Errorf("序列化测评记录失败: %v", err)
+	}
+	return c.submit(ctx, "UploadEvaluation", evalJSON)
+}
 
-	return &Client{contract: contract}, nil
+// UploadEvaluationAsync 异步上传测评记录，交易提交给排序服务后立即返回
+func (c *Client) UploadEvaluationAsync(evaluation Evaluation) (*PendingTx, error) {
+	return c.UploadEvaluationAsyncWithContext(context.Background(), evaluation)
 }
 
-// ===================== 测评记录操作 =====================
-func (c *Client) UploadEvaluation(evaluation Evaluation) (string, error) {
-	evalJSON, err := json.Marshal(evaluation)
+// UploadEvaluationAsyncWithContext 异步上传测评记录，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) UploadEvaluationAsyncWithContext(ctx context.Context, evaluation Evaluation, opts ...CallOption) (pending *PendingTx, err error) {
+	ctx, end, err := c.startCall(ctx, "UploadEvaluationAsync", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	if err := evaluation.Validate(); err != nil {
+		return nil, fmt.Errorf("测评记录校验失败: %w", err)
+	}
+	evalJSON, err := marshalArg(ctx, evaluation)
 	if err != nil {
-		return "", fmt.Errorf("序列化测评记录失败: %v", err)
+		return nil, fmt.Errorf("序列化测评记录失败: %v", err)
 	}
+	return c.submitAsync(ctx, "UploadEvaluationAsync", "UploadEvaluation", evalJSON)
+}
 
-	result, err := c.contract.SubmitTransaction("UploadEvaluation", string(evalJSON))
+func (c *Client) ModifyEvaluation(evaluationID string, newEvaluation Evaluation) (*TxReceipt, error) {
+	return c.ModifyEvaluationWithContext(context.Background(), evaluationID, newEvaluation)
+}
+
+// ModifyEvaluationWithContext 修改测评记录，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) ModifyEvaluationWithContext(ctx context.Context, evaluationID string, newEvaluation Evaluation, opts ...CallOption) (receipt *TxReceipt, err error) {
+	ctx, end, err := c.startCall(ctx, "ModifyEvaluation", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	if err := newEvaluation.Validate(); err != nil {
+		return nil, fmt.Errorf("新测评记录校验失败: %w", err)
+	}
+	newEvalJSON, err := marshalArg(ctx, newEvaluation)
 	if err != nil {
-		return "", fmt.Errorf("提交交易失败: %v", err)
+		return nil, fmt.Errorf("序列化新测评记录失败: %v", err)
 	}
-	return string(result), nil
+	return c.submit(ctx, "ModifyEvaluation", evaluationID, newEvalJSON)
 }
 
-func (c *Client) ModifyEvaluation(evaluationID string, newEvaluation Evaluation) error {
-	newEvalJSON, err := json.Marshal(newEvaluation)
+// ModifyEvaluationAsync 异步修改测评记录
+func (c *Client) ModifyEvaluationAsync(evaluationID string, newEvaluation Evaluation) (*PendingTx, error) {
+	return c.ModifyEvaluationAsyncWithContext(context.Background(), evaluationID, newEvaluation)
+}
+
+// ModifyEvaluationAsyncWithContext 异步修改测评记录，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) ModifyEvaluationAsyncWithContext(ctx context.Context, evaluationID string, newEvaluation Evaluation, opts ...CallOption) (pending *PendingTx, err error) {
+	ctx, end, err := c.startCall(ctx, "ModifyEvaluationAsync", opts...)
 	if err != nil {
-		return fmt.Errorf("序列化新测评记录失败: %v", err)
+		return nil, err
 	}
+	defer end(&err)
 
-	_, err = c.contract.SubmitTransaction("ModifyEvaluation", evaluationID, string(newEvalJSON))
-	return err
+	if err := newEvaluation.Validate(); err != nil {
+		return nil, fmt.Errorf("新测评记录校验失败: %w", err)
+	}
+	newEvalJSON, err := marshalArg(ctx, newEvaluation)
+	if err != nil {
+		return nil, fmt.Errorf("序列化新测评记录失败: %v", err)
+	}
+	return c.submitAsync(ctx, "ModifyEvaluationAsync", "ModifyEvaluation", evaluationID, newEvalJSON)
 }
 
 func (c *Client) GetEvaluationByID(evaluationID, userID string) (*Evaluation, error) {
-	result, err := c.contract.EvaluateTransaction("GetEvaluationByID", evaluationID, userID)
+	return c.GetEvaluationByIDWithContext(context.Background(), evaluationID, userID)
+}
+
+// GetEvaluationByIDWithContext 按ID查询测评记录，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) GetEvaluationByIDWithContext(ctx context.Context, evaluationID, userID string, opts ...CallOption) (_ *Evaluation, err error) {
+	ctx, end, err := c.startCall(ctx, "GetEvaluationByID", opts...)
 	if err != nil {
-		return nil, fmt.Errorf("查询失败: %v", err)
+		return nil, err
+	}
+	defer end(&err)
+
+	result, err := c.cachedRecordQuery(ctx, "Evaluation", evaluationID, userID, "GetEvaluationByID", evaluationID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
 	}
 
 	var evaluation Evaluation
 	if err := json.Unmarshal(result, &evaluation); err != nil {
 		return nil, fmt.Errorf("解析结果失败: %v", err)
 	}
+	if err := c.newRecordOpener().evaluation(ctx, &evaluation); err != nil {
+		return nil, err
+	}
 	return &evaluation, nil
 }
 
 func (c *Client) GetEvaluationByUser(userID string) ([]Evaluation, error) {
-	result, err := c.contract.EvaluateTransaction("GetEvaluationByUser", userID)
+	return c.GetEvaluationByUserWithContext(context.Background(), userID)
+}
+
+// GetEvaluationByUserWithContext 查询用户的测评记录，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) GetEvaluationByUserWithContext(ctx context.Context, userID string, opts ...CallOption) (_ []Evaluation, err error) {
+	ctx, end, err := c.startCall(ctx, "GetEvaluationByUser", opts...)
 	if err != nil {
-		return nil, fmt.Errorf("查询失败: %v", err)
+		return nil, err
+	}
+	defer end(&err)
+
+	result, err := c.cachedListQuery(ctx, "Evaluation", "GetEvaluationByUser", userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
 	}
 
 	var evaluations []Evaluation
 	if err := json.Unmarshal(result, &evaluations); err != nil {
 		return nil, fmt.Errorf("解析结果失败: %v", err)
 	}
+	if err := c.newRecordOpener().evaluations(ctx, evaluations); err != nil {
+		return nil, err
+	}
 	return evaluations, nil
 }
 
 // ===================== 测试结果操作 =====================
-func (c *Client) UploadTestResult(test TestResult) (string, error) {
-	testJSON, err := json.Marshal(test)
+func (c *Client) UploadTestResult(test TestResult) (*TxReceipt, error) {
+	return c.UploadTestResultWithContext(context.Background(), test)
+}
+
+// UploadTestResultWithContext 上传测试结果，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) UploadTestResultWithContext(ctx context.Context, test TestResult, opts ...CallOption) (receipt *TxReceipt, err error) {
+	ctx, end, err := c.startCall(ctx, "UploadTestResult", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	if err := test.Validate(); err != nil {
+		return nil, fmt.Errorf("测试结果校验失败: %w", err)
+	}
+	testJSON, err := marshalArg(ctx, test)
 	if err != nil {
-		return "", fmt.Errorf("序列化测试结果失败: %v", err)
+		return nil, fmt.Errorf("序列化测试结果失败: %v", err)
 	}
+	return c.submit(ctx, "UploadTestResult", testJSON)
+}
 
-	result, err := c.contract.SubmitTransaction("UploadTestResult", string(testJSON))
+// UploadTestResultAsync 异步上传测试结果
+func (c *Client) UploadTestResultAsync(test TestResult) (*PendingTx, error) {
+	return c.UploadTestResultAsyncWithContext(context.Background(), test)
+}
+
+// UploadTestResultAsyncWithContext 异步上传测试结果，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) UploadTestResultAsyncWithContext(ctx context.Context, test TestResult, opts ...CallOption) (pending *PendingTx, err error) {
+	ctx, end, err := c.startCall(ctx, "UploadTestResultAsync", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	if err := test.Validate(); err != nil {
+		return nil, fmt.Errorf("测试结果校验失败: %w", err)
+	}
+	testJSON, err := marshalArg(ctx, test)
 	if err != nil {
-		return "", fmt.Errorf("提交交易失败: %v", err)
+		return nil, fmt.Errorf("序列化测试结果失败: %v", err)
 	}
-	return string(result), nil
+	return c.submitAsync(ctx, "UploadTestResultAsync", "UploadTestResult", testJSON)
 }
 
 func (c *Client) GetTestResultsByUser(userID string) ([]TestResult, error) {
-	result, err := c.contract.EvaluateTransaction("GetTestResultsByUser", userID)
+	return c.GetTestResultsByUserWithContext(context.Background(), userID)
+}
+
+// GetTestResultsByUserWithContext 查询用户的测试结果，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) GetTestResultsByUserWithContext(ctx context.Context, userID string, opts ...CallOption) (_ []TestResult, err error) {
+	ctx, end, err := c.startCall(ctx, "GetTestResultsByUser", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	result, err := c.cachedListQuery(ctx, "TestResult", "GetTestResultsByUser", userID)
 	if err != nil {
-		return nil, fmt.Errorf("查询失败: %v", err)
+		return nil, fmt.Errorf("查询失败: %w", err)
 	}
 
 	var tests []TestResult
 	if err := json.Unmarshal(result, &tests); err != nil {
 		return nil, fmt.Errorf("解析结果失败: %v", err)
 	}
+	if err := c.newRecordOpener().testResults(ctx, tests); err != nil {
+		return nil, err
+	}
 	return tests, nil
 }
 
 func (c *Client) GetTestResultsByID(userID, testID string) (*TestResult, error) {
-	result, err := c.contract.EvaluateTransaction("GetTestResultsByID", userID, testID)
+	return c.GetTestResultsByIDWithContext(context.Background(), userID, testID)
+}
+
+// GetTestResultsByIDWithContext 按ID查询测试结果，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) GetTestResultsByIDWithContext(ctx context.Context, userID, testID string, opts ...CallOption) (_ *TestResult, err error) {
+	ctx, end, err := c.startCall(ctx, "GetTestResultsByID", opts...)
 	if err != nil {
-		return nil, fmt.Errorf("查询失败: %v", err)
+		return nil, err
+	}
+	defer end(&err)
+
+	result, err := c.cachedRecordQuery(ctx, "TestResult", testID, userID, "GetTestResultsByID", userID, testID)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
 	}
 
 	var test TestResult
 	if err := json.Unmarshal(result, &test); err != nil {
 		return nil, fmt.Errorf("解析结果失败: %v", err)
 	}
+	if err := c.newRecordOpener().testResult(ctx, &test); err != nil {
+		return nil, err
+	}
 	return &test, nil
 }
 
 // ===================== 评价记录操作 =====================
-func (c *Client) UploadJudgement(judgement Judgement) (string, error) {
-	judgeJSON, err := json.Marshal(judgement)
+func (c *Client) UploadJudgement(judgement Judgement) (*TxReceipt, error) {
+	return c.UploadJudgementWithContext(context.Background(), judgement)
+}
+
+// UploadJudgementWithContext 上传评价记录，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) UploadJudgementWithContext(ctx context.Context, judgement Judgement, opts ...CallOption) (receipt *TxReceipt, err error) {
+	ctx, end, err := c.startCall(ctx, "UploadJudgement", opts...)
 	if err != nil {
-		return "", fmt.Errorf("序列化评价记录失败: %v", err)
+		return nil, err
 	}
+	defer end(&err)
 
-	result, err := c.contract.SubmitTransaction("UploadJudgement", string(judgeJSON))
+	if err := judgement.Validate(); err != nil {
+		return nil, fmt.Errorf("评价记录校验失败: %w", err)
+	}
+	judgeJSON, err := marshalArg(ctx, judgement)
+	if err != nil {
+		return nil, fmt.Errorf("序列化评价记录失败: %v", err)
+	}
+	return c.submit(ctx, "UploadJudgement", judgeJSON)
+}
+
+// UploadJudgementAsync 异步上传评价记录
+func (c *Client) UploadJudgementAsync(judgement Judgement) (*PendingTx, error) {
+	return c.UploadJudgementAsyncWithContext(context.Background(), judgement)
+}
+
+// UploadJudgementAsyncWithContext 异步上传评价记录，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) UploadJudgementAsyncWithContext(ctx context.Context, judgement Judgement, opts ...CallOption) (pending *PendingTx, err error) {
+	ctx, end, err := c.startCall(ctx, "UploadJudgementAsync", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	if err := judgement.Validate(); err != nil {
+		return nil, fmt.Errorf("评价记录校验失败: %w", err)
+	}
+	judgeJSON, err := marshalArg(ctx, judgement)
 	if err != nil {
-		return "", fmt.Errorf("提交交易失败: %v", err)
+		return nil, fmt.Errorf("序列化评价记录失败: %v", err)
 	}
-	return string(result), nil
+	return c.submitAsync(ctx, "UploadJudgementAsync", "UploadJudgement", judgeJSON)
 }
 
 func (c *Client) GetJudgementByUser(userID string) ([]Judgement, error) {
-	result, err := c.contract.EvaluateTransaction("GetJudgementByUser", userID)
+	return c.GetJudgementByUserWithContext(context.Background(), userID)
+}
+
+// GetJudgementByUserWithContext 查询用户的评价记录，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) GetJudgementByUserWithContext(ctx context.Context, userID string, opts ...CallOption) (_ []Judgement, err error) {
+	ctx, end, err := c.startCall(ctx, "GetJudgementByUser", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	result, err := c.cachedListQuery(ctx, "Judgement", "GetJudgementByUser", userID)
 	if err != nil {
-		return nil, fmt.Errorf("查询失败: %v", err)
+		return nil, fmt.Errorf("查询失败: %w", err)
 	}
 
 	var judgements []Judgement
 	if err := json.Unmarshal(result, &judgements); err != nil {
 		return nil, fmt.Errorf("解析结果失败: %v", err)
 	}
+	if err := c.newRecordOpener().judgements(ctx, judgements); err != nil {
+		return nil, err
+	}
 	return judgements, nil
 }
 
 func (c *Client) GetJudgementByID(userID, judgementID string) (*Judgement, error) {
-	result, err := c.contract.EvaluateTransaction("GetJudgementByID", userID, judgementID)
+	return c.GetJudgementByIDWithContext(context.Background(), userID, judgementID)
+}
+
+// GetJudgementByIDWithContext 按ID查询评价记录，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) GetJudgementByIDWithContext(ctx context.Context, userID, judgementID string, opts ...CallOption) (_ *Judgement, err error) {
+	ctx, end, err := c.startCall(ctx, "GetJudgementByID", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	result, err := c.cachedRecordQuery(ctx, "Judgement", judgementID, userID, "GetJudgementByID", userID, judgementID)
 	if err != nil {
-		return nil, fmt.Errorf("查询失败: %v", err)
+		return nil, fmt.Errorf("查询失败: %w", err)
 	}
 
 	var judgement Judgement
 	if err := json.Unmarshal(result, &judgement); err != nil {
 		return nil, fmt.Errorf("解析结果失败: %v", err)
 	}
+	if err := c.newRecordOpener().judgement(ctx, &judgement); err != nil {
+		return nil, err
+	}
 	return &judgement, nil
 }
 
 // ===================== 通用操作 =====================
-func (c *Client) DeleteRecord(recordType, recordID string) error {
-	_, err := c.contract.SubmitTransaction("DeleteRecord", recordType, recordID)
-	return err
+func (c *Client) DeleteRecord(recordType, recordID string) (*TxReceipt, error) {
+	return c.DeleteRecordWithContext(context.Background(), recordType, recordID)
+}
+
+// DeleteRecordWithContext 删除记录，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) DeleteRecordWithContext(ctx context.Context, recordType, recordID string, opts ...CallOption) (receipt *TxReceipt, err error) {
+	ctx, end, err := c.startCall(ctx, "DeleteRecord", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	return c.submit(ctx, "DeleteRecord", recordType, recordID)
+}
+
+// DeleteRecordAsync 异步删除记录
+func (c *Client) DeleteRecordAsync(recordType, recordID string) (*PendingTx, error) {
+	return c.DeleteRecordAsyncWithContext(context.Background(), recordType, recordID)
+}
+
+// DeleteRecordAsyncWithContext 异步删除记录，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) DeleteRecordAsyncWithContext(ctx context.Context, recordType, recordID string, opts ...CallOption) (pending *PendingTx, err error) {
+	ctx, end, err := c.startCall(ctx, "DeleteRecordAsync", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	return c.submitAsync(ctx, "DeleteRecordAsync", "DeleteRecord", recordType, recordID)
 }
 
 // ===================== 连接工具函数 =====================
-func newGrpcConnection() (*grpc.ClientConn, error) {
-	certBytes, err := ioutil.ReadFile(tlsCertPath)
+func newGrpcConnection(peer PeerConfig) (*grpc.ClientConn, error) {
+	certBytes, err := ioutil.ReadFile(peer.TLSCertPath)
 	if err != nil {
 		return nil, fmt.Errorf("读取TLS证书失败: %v", err)
 	}
@@ -245,8 +483,8 @@ func newGrpcConnection() (*grpc.ClientConn, error) {
 		return nil, fmt.Errorf("解析TLS证书失败")
 	}
 
-	transportCredentials := credentials.NewClientTLSFromCert(certPool, "peer0.org1.example.com")
-	connection, err := grpc.Dial(peerEndpoint, grpc.WithTransportCredentials(transportCredentials))
+	transportCredentials := credentials.NewClientTLSFromCert(certPool, peer.HostOverride)
+	connection, err := grpc.Dial(peer.Endpoint, grpc.WithTransportCredentials(transportCredentials))
 	if err != nil {
 		return nil, fmt.Errorf("创建gRPC连接失败: %v", err)
 	}
@@ -304,10 +542,29 @@ func newSign() (identity.Sign, error) {
 
 // ===================== 示例使用 =====================
 func main() {
-	client, err := NewClient()
+	// 链路追踪由环境变量 OTEL_TRACES_EXPORTER 控制，默认不导出
+	shutdownTracing, err := SetupTracing(tracingConfigFromEnv())
+	if err != nil {
+		log.Fatalf("初始化链路追踪失败: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// 带参数时执行子命令
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			shutdownTracing(context.Background())
+			log.Fatalf("%s 执行失败: %v", os.Args[1], err)
+		}
+		return
+	}
+
+	// EDU_LEDGER_BACKEND=memory 时无需Fabric网络即可运行示例
+	backend, err := NewLedgerBackend()
 	if err != nil {
 		log.Fatalf("创建客户端失败: %v", err)
 	}
+	defer backend.Close()
+	ctx := context.Background()
 
 	// 示例：上传测评记录
 	eval := Evaluation{
@@ -316,20 +573,36 @@ func main() {
 		PointsDegree: "B+",
 		Feedback:     "Good performance with room for improvement",
 	}
-	if _, err := client.UploadEvaluation(eval); err != nil {
+	receipt, err := backend.UploadEvaluationWithContext(ctx, eval)
+	if err != nil {
 		log.Printf("上传测评记录失败: %v", err)
+	} else {
+		fmt.Printf("交易回执: %+v\n", receipt)
 	}
 
 	// 示例：查询测评记录
-	result, err := client.GetEvaluationByID("eval_002", "user_002")
+	result, err := backend.GetEvaluationByIDWithContext(ctx, "eval_002", "user_002")
 	if err != nil {
 		log.Printf("查询测评记录失败: %v", err)
 	} else {
 		fmt.Printf("查询结果: %+v\n", result)
 	}
 
-	// 示例：删除记录
-	if err := client.DeleteRecord("Evaluation", "eval_002"); err != nil {
+	// 示例：异步删除记录，稍后再等待提交结果（异步提交只有Fabric后端支持）
+	client, ok := backend.(*Client)
+	if !ok {
+		if _, err := backend.DeleteRecordWithContext(ctx, "Evaluation", "eval_002"); err != nil {
+			log.Printf("删除记录失败: %v", err)
+		}
+		return
+	}
+	pending, err := client.DeleteRecordAsync("Evaluation", "eval_002")
+	if err != nil {
+		log.Printf("删除记录失败: %v", err)
+		return
+	}
+	fmt.Printf("删除交易已提交: %s\n", pending.TransactionID())
+	if _, err := pending.Wait(); err != nil {
 		log.Printf("删除记录失败: %v", err)
 	}
 }
\ No newline at end of file
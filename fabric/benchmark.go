@@ -0,0 +1,502 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	mathrand "math/rand"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ===================== 压力测试 =====================
+
+// 负载类型，名称与客户端方法一致
+const (
+	BenchUploadEvaluation = "UploadEvaluation"
+	BenchModifyEvaluation = "ModifyEvaluation"
+	BenchUploadTestResult = "UploadTestResult"
+	BenchUploadJudgement  = "UploadJudgement"
+)
+
+// defaultBenchmarkMix 默认负载比例，接近学期末集中上传成绩的情况
+var defaultBenchmarkMix = map[string]int{
+	BenchUploadTestResult: 6,
+	BenchUploadEvaluation: 2,
+	BenchModifyEvaluation: 1,
+	BenchUploadJudgement:  1,
+}
+
+// BenchmarkOptions 压力测试配置，Total 和 Duration 至少指定一个，都指定时先到者结束
+type BenchmarkOptions struct {
+	Concurrency int            // 并发调用数
+	Total       int            // 交易总数，0 表示只按 Duration 结束
+	Duration    time.Duration  // 持续时间，0 表示只按 Total 结束
+	Rate        float64        // 每秒发起的交易数上限，0 表示不限速
+	Mix         map[string]int // 各负载类型的权重，为空时使用默认比例
+	Users       int            // 合成用户数，评价记录ID在用户间复用，用户越少冲突越多
+	Timeout     time.Duration  // 单次调用超时，0 表示使用默认的分阶段超时
+}
+
+// BenchmarkReport 压力测试结果
+type BenchmarkReport struct {
+	Started   time.Time                      `json:"started"`
+	Elapsed   time.Duration                  `json:"elapsed"`
+	Total     int                            `json:"total"`
+	Succeeded int                            `json:"succeeded"`
+	Failed    int                            `json:"failed"`
+	TPS       float64                        `json:"tps"` // 成功交易数 / 耗时
+	Latency   LatencyStats                   `json:"latency"`
+	Kinds     map[string]*BenchmarkKindStats `json:"kinds"`
+	Errors    map[string]int                 `json:"errors"` // 按错误类别计数，如 MVCC_READ_CONFLICT / timeout
+	Samples   map[string]string              `json:"samples,omitempty"`
+}
+
+// BenchmarkKindStats 单个负载类型的统计
+type BenchmarkKindStats struct {
+	Total     int          `json:"total"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Latency   LatencyStats `json:"latency"`
+}
+
+// LatencyStats 调用耗时分布，同步提交包含等待区块提交的时间
+type LatencyStats struct {
+	Min  time.Duration `json:"min"`
+	Mean time.Duration `json:"mean"`
+	P50  time.Duration `json:"p50"`
+	P90  time.Duration `json:"p90"`
+	P95  time.Duration `json:"p95"`
+	P99  time.Duration `json:"p99"`
+	Max  time.Duration `json:"max"`
+}
+
+// benchSample 单次调用的结果
+type benchSample struct {
+	kind    string
+	latency time.Duration
+	err     error
+}
+
+// ParseBenchmarkMix 解析负载比例，格式为 类型=权重,...，如 UploadTestResult=6,UploadJudgement=1
+func ParseBenchmarkMix(spec string) (map[string]int, error) {
+	mix := make(map[string]int)
+	if strings.TrimSpace(spec) == "" {
+		return mix, nil
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("负载比例格式错误: %q", pair)
+		}
+		kind := strings.TrimSpace(parts[0])
+		if _, ok := defaultBenchmarkMix[kind]; !ok {
+			return nil, fmt.Errorf("未知的负载类型 %s", kind)
+		}
+		weight, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("负载权重必须为非负整数: %q", pair)
+		}
+		mix[kind] = weight
+	}
+	return mix, nil
+}
+
+// benchWorkload 按比例生成合成记录并调用后端
+type benchWorkload struct {
+	backend LedgerBackend
+	runID   string
+	users   int
+	kinds   []string // 按权重展开，随机抽取即为加权选择
+	opts    []CallOption
+
+	mu          sync.Mutex
+	seq         int
+	evaluations []Evaluation // 本次上传成功的测评记录，供修改负载使用
+}
+
+func newBenchWorkload(backend LedgerBackend, options BenchmarkOptions) (*benchWorkload, error) {
+	mix := options.Mix
+	if len(mix) == 0 {
+		mix = defaultBenchmarkMix
+	}
+	w := &benchWorkload{backend: backend, users: options.Users}
+	if w.users <= 0 {
+		w.users = 100
+	}
+	// 按名称排序展开，保证同一配置的抽样分布稳定
+	names := make([]string, 0, len(mix))
+	for kind := range mix {
+		names = append(names, kind)
+	}
+	sort.Strings(names)
+	for _, kind := range names {
+		for i := 0; i < mix[kind]; i++ {
+			w.kinds = append(w.kinds, kind)
+		}
+	}
+	if len(w.kinds) == 0 {
+		return nil, fmt.Errorf("负载比例的权重之和必须大于0")
+	}
+	if options.Timeout > 0 {
+		w.opts = append(w.opts, CallTimeout(options.Timeout))
+	}
+
+	// 每次运行使用不同的ID前缀，重复运行不会与上次的记录冲突
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	w.runID = "bench-" + hex.EncodeToString(buf)
+	return w, nil
+}
+
+func (w *benchWorkload) next() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.seq++
+	return w.seq
+}
+
+// run 执行一次随机抽取的负载
+func (w *benchWorkload) run(ctx context.Context, rng *mathrand.Rand) benchSample {
+	kind := w.kinds[rng.Intn(len(w.kinds))]
+	user := fmt.Sprintf("%s-user-%d", w.runID, rng.Intn(w.users))
+	start := time.Now()
+
+	var err error
+	switch kind {
+	case BenchUploadEvaluation:
+		err = w.uploadEvaluation(ctx, rng, user)
+	case BenchModifyEvaluation:
+		w.mu.Lock()
+		var target *Evaluation
+		if len(w.evaluations) > 0 {
+			evaluation := w.evaluations[rng.Intn(len(w.evaluations))]
+			target = &evaluation
+		}
+		w.mu.Unlock()
+		if target == nil {
+			// 还没有可修改的记录时改为上传
+			kind = BenchUploadEvaluation
+			err = w.uploadEvaluation(ctx, rng, user)
+			break
+		}
+		target.PointsDegree = randomDegree(rng)
+		target.Feedback = fmt.Sprintf("benchmark modify %d", w.next())
+		_, err = w.backend.ModifyEvaluationWithContext(ctx, target.EvaluationID, *target, w.opts...)
+	case BenchUploadTestResult:
+		_, err = w.backend.UploadTestResultWithContext(ctx, TestResult{
+			TestID:      fmt.Sprintf("%s-test-%d", w.runID, w.next()),
+			UserID:      user,
+			ScoreSum:    strconv.Itoa(rng.Intn(101)),
+			PaperNumber: fmt.Sprintf("BENCH-PAPER-%02d", rng.Intn(20)),
+			Answer:      "benchmark answer",
+		}, w.opts...)
+	case BenchUploadJudgement:
+		// 评价记录允许覆盖，ID 按用户复用，并发覆盖同一记录时会产生 MVCC 冲突
+		_, err = w.backend.UploadJudgementWithContext(ctx, Judgement{
+			JudgementID:        fmt.Sprintf("%s-judge-%s", w.runID, user),
+			UserID:             user,
+			JudgementObjection: "None",
+			JudgementObjectID:  fmt.Sprintf("%s-teacher-%d", w.runID, rng.Intn(10)),
+			JudgementRating:    strconv.Itoa(1 + rng.Intn(5)),
+			JudgementContent:   "benchmark judgement",
+			JudgementTime:      time.Now().Format(time.RFC3339),
+		}, w.opts...)
+	}
+	return benchSample{kind: kind, latency: time.Since(start), err: err}
+}
+
+func (w *benchWorkload) uploadEvaluation(ctx context.Context, rng *mathrand.Rand, user string) error {
+	evaluation := Evaluation{
+		EvaluationID: fmt.Sprintf("%s-eval-%d", w.runID, w.next()),
+		UserID:       user,
+		PointsDegree: randomDegree(rng),
+		Feedback:     "benchmark feedback",
+	}
+	if _, err := w.backend.UploadEvaluationWithContext(ctx, evaluation, w.opts...); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.evaluations = append(w.evaluations, evaluation)
+	w.mu.Unlock()
+	return nil
+}
+
+func randomDegree(rng *mathrand.Rand) string {
+	return string(rune('A' + rng.Intn(4)))
+}
+
+// Benchmark 以 options.Concurrency 个并发调用向后端提交合成记录并统计吞吐量和延迟
+// 限速时按固定间隔发起交易，调用耗时超过间隔时实际速率会低于 Rate
+// ctx 取消时停止发起新交易，已发起的交易结束后返回已完成部分的结果
+func Benchmark(ctx context.Context, backend LedgerBackend, options BenchmarkOptions) (*BenchmarkReport, error) {
+	if options.Total <= 0 && options.Duration <= 0 {
+		return nil, fmt.Errorf("必须指定交易总数或持续时间")
+	}
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	workload, err := newBenchWorkload(backend, options)
+	if err != nil {
+		return nil, err
+	}
+
+	runCtx := ctx
+	if options.Duration > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, options.Duration)
+		defer cancel()
+	}
+
+	jobs := make(chan struct{})
+	samples := make(chan benchSample, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := mathrand.New(mathrand.NewSource(seed))
+			for range jobs {
+				// 已发起的交易使用外层 ctx，避免持续时间到期时把进行中的调用计为超时
+				samples <- workload.run(ctx, rng)
+			}
+		}(time.Now().UnixNano() + int64(i))
+	}
+
+	var collected []benchSample
+	collectDone := make(chan struct{})
+	go func() {
+		defer close(collectDone)
+		for sample := range samples {
+			collected = append(collected, sample)
+		}
+	}()
+
+	report := &BenchmarkReport{Started: time.Now()}
+	dispatch(runCtx, jobs, options.Total, options.Rate)
+	close(jobs)
+	wg.Wait()
+	close(samples)
+	<-collectDone
+
+	report.Elapsed = time.Since(report.Started)
+	summarizeBenchmark(report, collected)
+	return report, nil
+}
+
+// dispatch 按速率向 jobs 发送任务，total 为 0 时直到 ctx 结束
+func dispatch(ctx context.Context, jobs chan<- struct{}, total int, rate float64) {
+	var tick <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for sent := 0; total <= 0 || sent < total; sent++ {
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case jobs <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// summarizeBenchmark 汇总调用结果，每个错误类别保留一条示例错误信息
+func summarizeBenchmark(report *BenchmarkReport, samples []benchSample) {
+	report.Kinds = make(map[string]*BenchmarkKindStats)
+	report.Errors = make(map[string]int)
+	report.Samples = make(map[string]string)
+
+	all := make([]time.Duration, 0, len(samples))
+	byKind := make(map[string][]time.Duration)
+	for _, sample := range samples {
+		stats := report.Kinds[sample.kind]
+		if stats == nil {
+			stats = &BenchmarkKindStats{}
+			report.Kinds[sample.kind] = stats
+		}
+		stats.Total++
+		report.Total++
+		if sample.err != nil {
+			stats.Failed++
+			report.Failed++
+			class := benchErrorClass(sample.err)
+			report.Errors[class]++
+			if _, ok := report.Samples[class]; !ok {
+				report.Samples[class] = sample.err.Error()
+			}
+			continue
+		}
+		stats.Succeeded++
+		report.Succeeded++
+		all = append(all, sample.latency)
+		byKind[sample.kind] = append(byKind[sample.kind], sample.latency)
+	}
+
+	report.Latency = latencyStats(all)
+	for kind, latencies := range byKind {
+		report.Kinds[kind].Latency = latencyStats(latencies)
+	}
+	if report.Elapsed > 0 {
+		report.TPS = float64(report.Succeeded) / report.Elapsed.Seconds()
+	}
+}
+
+// benchErrorClass 在指标错误类别的基础上细分验证失败的原因，如 MVCC_READ_CONFLICT
+func benchErrorClass(err error) string {
+	var commitFailed *CommitFailedError
+	if errors.As(err, &commitFailed) && commitFailed.Receipt.Status != "" {
+		return commitFailed.Receipt.Status
+	}
+	return errorClass(err)
+}
+
+// latencyStats 计算成功调用的耗时分布，百分位取最近秩
+func latencyStats(latencies []time.Duration) LatencyStats {
+	if len(latencies) == 0 {
+		return LatencyStats{}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	var sum time.Duration
+	for _, latency := range latencies {
+		sum += latency
+	}
+	percentile := func(p float64) time.Duration {
+		rank := int(p*float64(len(latencies))+0.5) - 1
+		if rank < 0 {
+			rank = 0
+		}
+		if rank >= len(latencies) {
+			rank = len(latencies) - 1
+		}
+		return latencies[rank]
+	}
+	return LatencyStats{
+		Min:  latencies[0],
+		Mean: sum / time.Duration(len(latencies)),
+		P50:  percentile(0.50),
+		P90:  percentile(0.90),
+		P95:  percentile(0.95),
+		P99:  percentile(0.99),
+		Max:  latencies[len(latencies)-1],
+	}
+}
+
+// WriteBenchmarkReport 输出可读的测试结果
+func WriteBenchmarkReport(w io.Writer, report *BenchmarkReport) {
+	fmt.Fprintf(w, "耗时 %s，共 %d 笔交易，成功 %d，失败 %d，TPS %.1f\n",
+		report.Elapsed.Round(time.Millisecond), report.Total, report.Succeeded, report.Failed, report.TPS)
+	fmt.Fprintf(w, "\n%-18s %7s %7s %9s %9s %9s %9s %9s\n", "类型", "成功", "失败", "p50", "p90", "p95", "p99", "max")
+	writeLatencyRow(w, "全部", report.Succeeded, report.Failed, report.Latency)
+
+	kinds := make([]string, 0, len(report.Kinds))
+	for kind := range report.Kinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		stats := report.Kinds[kind]
+		writeLatencyRow(w, kind, stats.Succeeded, stats.Failed, stats.Latency)
+	}
+
+	if len(report.Errors) == 0 {
+		return
+	}
+	classes := make([]string, 0, len(report.Errors))
+	for class := range report.Errors {
+		classes = append(classes, class)
+	}
+	sort.Slice(classes, func(i, j int) bool { return report.Errors[classes[i]] > report.Errors[classes[j]] })
+	fmt.Fprintln(w, "\n错误分类:")
+	for _, class := range classes {
+		fmt.Fprintf(w, "  %-20s %7d  例: %s\n", class, report.Errors[class], report.Samples[class])
+	}
+}
+
+func writeLatencyRow(w io.Writer, name string, succeeded, failed int, latency LatencyStats) {
+	ms := func(d time.Duration) string {
+		return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 1, 64) + "ms"
+	}
+	fmt.Fprintf(w, "%-18s %7d %7d %9s %9s %9s %9s %9s\n",
+		name, succeeded, failed, ms(latency.P50), ms(latency.P90), ms(latency.P95), ms(latency.P99), ms(latency.Max))
+}
+
+// runBenchmark 压力测试命令，后端按 EDU_LEDGER_BACKEND 选择，embedded 可得到不含网络开销的基准
+func runBenchmark(args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	concurrency := flags.Int("c", 8, "并发调用数")
+	total := flags.Int("n", 0, "交易总数")
+	duration := flags.Duration("d", 0, "持续时间，如 30s")
+	rate := flags.Float64("rate", 0, "每秒发起的交易数上限，0 表示不限速")
+	mixSpec := flags.String("mix", "", "负载比例，如 UploadTestResult=6,UploadEvaluation=2,ModifyEvaluation=1,UploadJudgement=1")
+	users := flags.Int("users", 100, "合成用户数")
+	timeout := flags.Duration("timeout", 0, "单次调用超时")
+	output := flags.String("out", "", "将完整结果以JSON写入文件")
+	flags.Parse(args)
+	if *total <= 0 && *duration <= 0 {
+		*total = 1000
+	}
+
+	mix, err := ParseBenchmarkMix(*mixSpec)
+	if err != nil {
+		return err
+	}
+	backend, err := NewLedgerBackend()
+	if err != nil {
+		return err
+	}
+	defer backend.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := Benchmark(ctx, backend, BenchmarkOptions{
+		Concurrency: *concurrency,
+		Total:       *total,
+		Duration:    *duration,
+		Rate:        *rate,
+		Mix:         mix,
+		Users:       *users,
+		Timeout:     *timeout,
+	})
+	if err != nil {
+		return err
+	}
+	WriteBenchmarkReport(os.Stdout, report)
+
+	if *output != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(*output, data, 0644); err != nil {
+			return fmt.Errorf("写入测试结果失败: %v", err)
+		}
+		log.Printf("测试结果已写入 %s", *output)
+	}
+	return nil
+}
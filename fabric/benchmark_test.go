@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// 进程内基准：EDU_LEDGER_BACKEND=embedded 时 bench 命令在本进程中运行链码
+func TestBenchmarkEmbeddedBaseline(t *testing.T) {
+	t.Setenv(ledgerBackendEnv, "embedded")
+	t.Setenv(keyRingEnv, "")
+	backend, err := NewLedgerBackend(WithKeyService(NewFileKeyService(filepath.Join(t.TempDir(), "keys.json"))))
+	if err != nil {
+		t.Fatalf("创建进程内后端失败: %v", err)
+	}
+	defer backend.Close()
+	if c, ok := backend.(*Client); !ok || c.embedded == nil {
+		t.Fatalf("后端 = %T，期望进程内链码客户端", backend)
+	}
+
+	report, err := Benchmark(context.Background(), backend, BenchmarkOptions{Concurrency: 4, Total: 60, Users: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 60 || report.Succeeded+report.Failed != 60 {
+		t.Fatalf("完成 %d 笔（成功 %d，失败 %d），期望 60 笔", report.Total, report.Succeeded, report.Failed)
+	}
+	if report.Succeeded == 0 || report.Latency.P50 <= 0 {
+		t.Fatalf("没有成功的交易: %+v", report)
+	}
+	// 用户很少时评价记录的并发覆盖会产生 MVCC 冲突，除此之外不应有其他错误
+	for class, count := range report.Errors {
+		if class != "MVCC_READ_CONFLICT" {
+			t.Errorf("出现 %d 次 %s 错误: %s", count, class, report.Samples[class])
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go-apiv2/msp"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+// ===================== 区块解析 =====================
+
+// blockWrite 区块中一笔有效交易对链码状态的写入
+type blockWrite struct {
+	BlockNumber uint64
+	TxID        string
+	Timestamp   time.Time
+	Creator     string // 提交交易的用户ID（证书CN）
+	Key         string
+	Value       []byte
+	IsDelete    bool
+}
+
+// parseBlockWrites 提取区块内所有有效背书交易对指定链码命名空间的写集
+func parseBlockWrites(block *common.Block, chaincodeName string) ([]blockWrite, error) {
+	blockNumber := block.GetHeader().GetNumber()
+
+	// 交易验证码位于区块元数据的 TRANSACTIONS_FILTER 中，与交易按下标对应
+	var filter []byte
+	if metadata := block.GetMetadata().GetMetadata(); len(metadata) > int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		filter = metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	}
+
+	var writes []blockWrite
+	for i, envelopeBytes := range block.GetData().GetData() {
+		if i < len(filter) && peer.TxValidationCode(filter[i]) != peer.TxValidationCode_VALID {
+			continue
+		}
+
+		channelHeader, signatureHeader, transaction, err := unmarshalEndorserTransaction(envelopeBytes)
+		if err != nil {
+			return nil, fmt.Errorf("解析区块 %d 第 %d 笔交易失败: %v", blockNumber, i, err)
+		}
+		if transaction == nil {
+			continue
+		}
+		creator := creatorID(signatureHeader.GetCreator())
+
+		for _, action := range transaction.GetActions() {
+			kvWrites, err := chaincodeWrites(action, chaincodeName)
+			if err != nil {
+				return nil, fmt.Errorf("解析交易 %s 写集失败: %v", channelHeader.GetTxId(), err)
+			}
+			for _, w := range kvWrites {
+				writes = append(writes, blockWrite{
+					BlockNumber: blockNumber,
+					TxID:        channelHeader.GetTxId(),
+					Timestamp:   channelHeader.GetTimestamp().AsTime(),
+					Creator:     creator,
+					Key:         w.GetKey(),
+					Value:       w.GetValue(),
+					IsDelete:    w.GetIsDelete(),
+				})
+			}
+		}
+	}
+	return writes, nil
+}
+
+// unmarshalEndorserTransaction 解析交易信封，非背书交易（如配置交易）返回 nil
+func unmarshalEndorserTransaction(envelopeBytes []byte) (*common.ChannelHeader, *common.SignatureHeader, *peer.Transaction, error) {
+	envelope := &common.Envelope{}
+	if err := proto.Unmarshal(envelopeBytes, envelope); err != nil {
+		return nil, nil, nil, err
+	}
+	payload := &common.Payload{}
+	if err := proto.Unmarshal(envelope.GetPayload(), payload); err != nil {
+		return nil, nil, nil, err
+	}
+	channelHeader := &common.ChannelHeader{}
+	if err := proto.Unmarshal(payload.GetHeader().GetChannelHeader(), channelHeader); err != nil {
+		return nil, nil, nil, err
+	}
+	signatureHeader := &common.SignatureHeader{}
+	if err := proto.Unmarshal(payload.GetHeader().GetSignatureHeader(), signatureHeader); err != nil {
+		return nil, nil, nil, err
+	}
+	if channelHeader.GetType() != int32(common.HeaderType_ENDORSER_TRANSACTION) {
+		return channelHeader, signatureHeader, nil, nil
+	}
+
+	transaction := &peer.Transaction{}
+	if err := proto.Unmarshal(payload.GetData(), transaction); err != nil {
+		return nil, nil, nil, err
+	}
+	return channelHeader, signatureHeader, transaction, nil
+}
+
+// creatorID 从序列化身份中取出用户ID：证书CN与钱包标签、CA登记ID一致，证书无法解析时退回 MSP ID
+func creatorID(serializedIdentity []byte) string {
+	identity := &msp.SerializedIdentity{}
+	if err := proto.Unmarshal(serializedIdentity, identity); err != nil {
+		return ""
+	}
+	if block, _ := pem.Decode(identity.GetIdBytes()); block != nil {
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil && cert.Subject.CommonName != "" {
+			return cert.Subject.CommonName
+		}
+	}
+	return identity.GetMspid()
+}
+
+// chaincodeAction 解析交易动作中的链码执行结果
+func chaincodeAction(action *peer.TransactionAction) (*peer.ChaincodeActionPayload, *peer.ChaincodeAction, error) {
+	actionPayload := &peer.ChaincodeActionPayload{}
+	if err := proto.Unmarshal(action.GetPayload(), actionPayload); err != nil {
+		return nil, nil, err
+	}
+	responsePayload := &peer.ProposalResponsePayload{}
+	if err := proto.Unmarshal(actionPayload.GetAction().GetProposalResponsePayload(), responsePayload); err != nil {
+		return nil, nil, err
+	}
+	ccAction := &peer.ChaincodeAction{}
+	if err := proto.Unmarshal(responsePayload.GetExtension(), ccAction); err != nil {
+		return nil, nil, err
+	}
+	return actionPayload, ccAction, nil
+}
+
+// chaincodeWrites 返回交易动作在指定链码命名空间下的公共写集
+func chaincodeWrites(action *peer.TransactionAction, chaincodeName string) ([]*kvrwset.KVWrite, error) {
+	_, ccAction, err := chaincodeAction(action)
+	if err != nil {
+		return nil, err
+	}
+	txRWSet := &rwset.TxReadWriteSet{}
+	if err := proto.Unmarshal(ccAction.GetResults(), txRWSet); err != nil {
+		return nil, err
+	}
+
+	var writes []*kvrwset.KVWrite
+	for _, nsRWSet := range txRWSet.GetNsRwset() {
+		if nsRWSet.GetNamespace() != chaincodeName {
+			continue
+		}
+		kvRWSet := &kvrwset.KVRWSet{}
+		if err := proto.Unmarshal(nsRWSet.GetRwset(), kvRWSet); err != nil {
+			return nil, err
+		}
+		writes = append(writes, kvRWSet.GetWrites()...)
+	}
+	return writes, nil
+}
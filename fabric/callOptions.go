@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// ===================== 单次调用配置 =====================
+
+// 各阶段默认超时，调用未覆盖时使用
+const (
+	defaultEvaluateTimeout     = 5 * time.Second
+	defaultEndorseTimeout      = 15 * time.Second
+	defaultSubmitTimeout       = 5 * time.Second
+	defaultCommitStatusTimeout = 1 * time.Minute
+)
+
+// callPhase 一次调用中与网关交互的阶段
+type callPhase int
+
+const (
+	phaseEvaluate callPhase = iota
+	phaseEndorse
+	phaseSubmit
+	phaseCommitStatus
+	phaseCount
+)
+
+// CallOption 单次调用的可选配置，用于 ...WithContext 方法
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	timeout  time.Duration // 整个调用的超时，0 表示只受 ctx 约束
+	phases   [phaseCount]time.Duration
+	language string // 链码错误信息的语言，为空时使用客户端配置
+}
+
+func defaultCallOptions() *callOptions {
+	return &callOptions{
+		phases: [phaseCount]time.Duration{
+			phaseEvaluate:     defaultEvaluateTimeout,
+			phaseEndorse:      defaultEndorseTimeout,
+			phaseSubmit:       defaultSubmitTimeout,
+			phaseCommitStatus: defaultCommitStatusTimeout,
+		},
+	}
+}
+
+// CallTimeout 整个调用（含序列化、背书、提交和等待提交状态）的超时
+// 异步提交方法只约束到交易提交给排序服务为止
+func CallTimeout(timeout time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = timeout
+	}
+}
+
+// EvaluateTimeout 覆盖查询的超时
+func EvaluateTimeout(timeout time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.phases[phaseEvaluate] = timeout
+	}
+}
+
+// EndorseTimeout 覆盖背书阶段的超时
+func EndorseTimeout(timeout time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.phases[phaseEndorse] = timeout
+	}
+}
+
+// SubmitTimeout 覆盖提交给排序服务的超时
+func SubmitTimeout(timeout time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.phases[phaseSubmit] = timeout
+	}
+}
+
+// CommitStatusTimeout 覆盖等待提交状态的超时，超时不代表交易未被提交
+func CommitStatusTimeout(timeout time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.phases[phaseCommitStatus] = timeout
+	}
+}
+
+// Language 指定本次调用链码错误信息的语言（zh/en），覆盖 WithLanguage 的配置
+func Language(lang string) CallOption {
+	return func(o *callOptions) {
+		o.language = lang
+	}
+}
+
+// callOptionsKey 调用配置在 context 中的键，各阶段据此取超时
+type callOptionsKey struct{}
+
+func withCallOptions(ctx context.Context, opts []CallOption) context.Context {
+	options := defaultCallOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+	return context.WithValue(ctx, callOptionsKey{}, options)
+}
+
+func callOptionsFrom(ctx context.Context) *callOptions {
+	if options, ok := ctx.Value(callOptionsKey{}).(*callOptions); ok {
+		return options
+	}
+	return defaultCallOptions()
+}
+
+// phaseContext 返回阶段使用的 context，超时取调用覆盖值或默认值，同时受调用方截止时间约束
+func phaseContext(ctx context.Context, phase callPhase) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, callOptionsFrom(ctx).phases[phase])
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCallOptions(t *testing.T) {
+	defaults := callOptionsFrom(context.Background())
+	if defaults.timeout != 0 || defaults.phases[phaseEndorse] != defaultEndorseTimeout || defaults.language != "" {
+		t.Errorf("默认配置 = %+v", defaults)
+	}
+
+	ctx := withCallOptions(context.Background(), []CallOption{
+		CallTimeout(time.Second), EvaluateTimeout(2 * time.Second), EndorseTimeout(3 * time.Second),
+		SubmitTimeout(4 * time.Second), CommitStatusTimeout(5 * time.Second), Language("en"),
+	})
+	got := callOptionsFrom(ctx)
+	want := [phaseCount]time.Duration{2 * time.Second, 3 * time.Second, 4 * time.Second, 5 * time.Second}
+	if got.timeout != time.Second || got.phases != want || got.language != "en" {
+		t.Errorf("覆盖后的配置 = %+v", got)
+	}
+
+	tests := []struct {
+		name     string
+		parent   time.Duration // 调用方截止时间，0 表示没有
+		phase    time.Duration
+		deadline time.Duration
+	}{
+		{"阶段超时", 0, time.Second, time.Second},
+		{"调用方截止时间更早", 100 * time.Millisecond, time.Second, 100 * time.Millisecond},
+		{"阶段超时更早", time.Minute, time.Second, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := context.Background()
+			if tt.parent > 0 {
+				var cancel context.CancelFunc
+				parent, cancel = context.WithTimeout(parent, tt.parent)
+				defer cancel()
+			}
+			ctx, cancel := phaseContext(withCallOptions(parent, []CallOption{EndorseTimeout(tt.phase)}), phaseEndorse)
+			defer cancel()
+			deadline, ok := ctx.Deadline()
+			if remaining := time.Until(deadline); !ok || remaining > tt.deadline || remaining < tt.deadline-50*time.Millisecond {
+				t.Errorf("剩余时间 = %v，期望约 %v", remaining, tt.deadline)
+			}
+		})
+	}
+}
+
+// 调用方取消或调用超时后不再提交交易
+func TestCallContextCancelled(t *testing.T) {
+	c := newTestEmbeddedClient(t)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := c.UploadEvaluationWithContext(cancelled, testEvaluation("eval_001", "user_001")); !errors.Is(err, context.Canceled) {
+		t.Errorf("已取消的调用 错误 = %v，期望 context.Canceled", err)
+	}
+	if _, err := c.UploadEvaluationWithContext(context.Background(), testEvaluation("eval_002", "user_001"), CallTimeout(time.Nanosecond)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("超时的调用 错误 = %v，期望 context.DeadlineExceeded", err)
+	}
+	// 取消的调用没有写入记录，再次上传不会重复
+	for _, id := range []string{"eval_001", "eval_002"} {
+		if _, err := c.UploadEvaluation(testEvaluation(id, "user_001")); err != nil {
+			t.Errorf("上传 %s 失败: %v", id, err)
+		}
+	}
+	if _, err := c.GetEvaluationByUserWithContext(cancelled, "user_001"); !errors.Is(err, context.Canceled) {
+		t.Errorf("已取消的查询 错误 = %v，期望 context.Canceled", err)
+	}
+}
@@ -0,0 +1,1450 @@
+// Package atcc 测评记录链码：测评、测试结果和评价记录的上链、查询、授权访问与个人内容擦除
+package atcc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"edu/model"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// ===================== 数据结构定义 =====================
+// 记录结构与校验规则定义在 edu/model 中，与客户端共用
+// 注意：所有结构体包含 docType 字段用于CouchDB查询分类
+
+type (
+	Evaluation = model.Evaluation
+	TestResult = model.TestResult
+	Judgement  = model.Judgement
+)
+
+// ===================== 智能合约结构 =====================
+type SmartContract struct {
+	contractapi.Contract
+}
+
+// ===================== 错误 =====================
+// 所有错误都带有错误码和错误信息键（见 edu/model 的 messages.go），以 JSON 作为交易的错误信息返回
+
+// ccError 返回带错误码的链码错误，details 为成对的参数名和值
+func ccError(ctx contractapi.TransactionContextInterface, key string, details ...string) error {
+	return model.NewError(key, details...).Localize(callerLanguage(ctx))
+}
+
+// ccInvalid 记录校验失败，附带未通过校验的字段
+func ccInvalid(ctx contractapi.TransactionContextInterface, key string, err error) error {
+	return model.NewError(key, "cause", err.Error()).WithFields(err).Localize(callerLanguage(ctx))
+}
+
+func callerLanguage(ctx contractapi.TransactionContextInterface) string {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return model.LangZH
+	}
+	if lang := model.ParseLanguage(string(transient[model.LanguageTransient])); lang != "" {
+		return lang
+	}
+	return model.LangZH
+}
+
+// ===================== 测评记录管理 =====================
+
+// UploadEvaluation 上传测评记录
+// 参数：测评记录JSON字符串
+// 返回值：错误信息
+func (s *SmartContract) UploadEvaluation(ctx contractapi.TransactionContextInterface, evaluationJSON string) error {
+	var evaluation Evaluation
+	// 解析输入数据
+	if err := json.Unmarshal([]byte(evaluationJSON), &evaluation); err != nil {
+		return ccError(ctx, "evaluation.invalid_json", "cause", err.Error())
+	}
+	
+	// 个人内容从瞬态数据中取出，不随交易参数进入区块
+	var err error
+	if evaluation.Feedback, err = personalField(ctx, "Feedback", evaluation.Feedback); err != nil {
+		return err
+	}
+	
+	// 数据校验
+	if err := evaluation.Validate(); err != nil {
+		return ccInvalid(ctx, "evaluation.invalid", err)
+	}
+	
+	// 设置文档类型
+	evaluation.DocType = "Evaluation"
+	compositeKey := fmt.Sprintf("Evaluation-%s", evaluation.EvaluationID)
+	
+	// 检查重复记录
+	existing, err := ctx.GetStub().GetState(compositeKey)
+	if err != nil {
+		return ccError(ctx, "state.read_failed", "cause", err.Error())
+	}
+	if existing != nil {
+		return ccError(ctx, "evaluation.exists", "id", evaluation.EvaluationID)
+	}
+	
+	// 加密个人内容，擦除时间由链码设置
+	evaluation.ErasedAt = ""
+	if evaluation.Feedback, err = sealField(ctx, evaluation.UserID, compositeKey, "Feedback", evaluation.Feedback); err != nil {
+		return err
+	}
+	
+	// 存储数据
+	data, err := json.Marshal(evaluation)
+	if err != nil {
+		return ccError(ctx, "data.marshal_failed", "cause", err.Error())
+	}
+	if err := ctx.GetStub().PutState(compositeKey, data); err != nil {
+		return ccError(ctx, "state.write_failed", "cause", err.Error())
+	}
+	return emitRecordEvent(ctx, RecordEvent{DocType: "Evaluation", Action: "Create", RecordID: evaluation.EvaluationID, UserID: evaluation.UserID})
+}
+
+// ModifyEvaluation 修改测评记录
+// 参数：测评ID，新测评记录JSON
+// 返回值：错误信息
+func (s *SmartContract) ModifyEvaluation(ctx contractapi.TransactionContextInterface, evaluationID string, newEvaluationJSON string) error {
+	// 获取原记录
+	compositeKey := fmt.Sprintf("Evaluation-%s", evaluationID)
+	existingData, err := ctx.GetStub().GetState(compositeKey)
+	if err != nil {
+		return ccError(ctx, "state.read_failed", "cause", err.Error())
+	}
+	if existingData == nil {
+		return ccError(ctx, "evaluation.not_found")
+	}
+	
+	// 解析新数据
+	var newEval Evaluation
+	if err := json.Unmarshal([]byte(newEvaluationJSON), &newEval); err != nil {
+		return ccError(ctx, "evaluation.invalid_json", "cause", err.Error())
+	}
+	
+	// ID一致性检查
+	if newEval.EvaluationID != evaluationID {
+		return ccError(ctx, "evaluation.id_immutable")
+	}
+	if newEval.Feedback, err = personalField(ctx, "Feedback", newEval.Feedback); err != nil {
+		return err
+	}
+	if err := newEval.Validate(); err != nil {
+		return ccInvalid(ctx, "evaluation.invalid", err)
+	}
+	
+	// 保留原始文档类型
+	newEval.DocType = "Evaluation"
+	
+	// 擦除标记随所属用户保留，个人内容以新所属用户的密钥加密
+	var previous Evaluation
+	if err := json.Unmarshal(existingData, &previous); err != nil {
+		return ccError(ctx, "data.unmarshal_failed", "cause", err.Error())
+	}
+	newEval.ErasedAt = ""
+	if previous.UserID == newEval.UserID {
+		newEval.ErasedAt = previous.ErasedAt
+	}
+	if newEval.Feedback, err = sealField(ctx, newEval.UserID, compositeKey, "Feedback", newEval.Feedback); err != nil {
+		return err
+	}
+	
+	// 存储更新
+	data, err := json.Marshal(newEval)
+	if err != nil {
+		return ccError(ctx, "data.marshal_failed", "cause", err.Error())
+	}
+	if err := ctx.GetStub().PutState(compositeKey, data); err != nil {
+		return ccError(ctx, "state.write_failed", "cause", err.Error())
+	}
+	return emitRecordEvent(ctx, RecordEvent{
+		DocType:        "Evaluation",
+		Action:         "Modify",
+		RecordID:       evaluationID,
+		UserID:         newEval.UserID,
+		PreviousUserID: recordOwner(existingData),
+	})
+}
+
+// GetEvaluationByID 根据ID获取测评记录
+// 参数：测评ID，用户ID（用于权限验证）
+// 返回值：测评记录指针，错误信息
+func (s *SmartContract) GetEvaluationByID(ctx contractapi.TransactionContextInterface, evaluationID string, userID string) (*Evaluation, error) {
+	if evaluationID == "" || userID == "" {
+		return nil, ccError(ctx, "argument.empty")
+	}
+	
+	compositeKey := fmt.Sprintf("Evaluation-%s", evaluationID)
+	data, err := ctx.GetStub().GetState(compositeKey)
+	if err != nil {
+		return nil, ccError(ctx, "state.read_failed", "cause", err.Error())
+	}
+	if data == nil {
+		return nil, ccError(ctx, "evaluation.not_found")
+	}
+	
+	var evaluation Evaluation
+	if err := json.Unmarshal(data, &evaluation); err != nil {
+		return nil, ccError(ctx, "data.unmarshal_failed", "cause", err.Error())
+	}
+	
+	// 权限验证
+	if evaluation.UserID != userID {
+		return nil, ccError(ctx, "record.forbidden")
+	}
+	if err := checkRead(ctx, "Evaluation", evaluationID, evaluation.UserID); err != nil {
+		return nil, err
+	}
+	return &evaluation, nil
+}
+
+// GetEvaluationByUser 根据用户ID获取所有测评记录
+// 参数：用户ID
+// 返回值：测评记录切片，错误信息
+func (s *SmartContract) GetEvaluationByUser(ctx contractapi.TransactionContextInterface, userID string) ([]*Evaluation, error) {
+	if userID == "" {
+		return nil, ccError(ctx, "user_id.empty")
+	}
+	caller, err := callerAccess(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 构建安全查询（只返回属于该用户的记录）
+	query := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"docType": "Evaluation",
+			"User_ID": userID,
+		},
+	}
+	queryBytes, _ := json.Marshal(query)
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(string(queryBytes))
+	if err != nil {
+		return nil, ccError(ctx, "query.failed", "cause", err.Error())
+	}
+	defer resultsIterator.Close()
+
+	var evaluations []*Evaluation
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, ccError(ctx, "query.iterate_failed", "cause", err.Error())
+		}
+
+		var eval Evaluation
+		if err := json.Unmarshal(queryResponse.Value, &eval); err != nil {
+			return nil, ccError(ctx, "data.unmarshal_failed", "cause", err.Error())
+		}
+		if ok, err := caller.canRead(ctx, "Evaluation", eval.EvaluationID, eval.UserID); err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+		evaluations = append(evaluations, &eval)
+	}
+	return evaluations, nil
+}
+
+// GetAllEvaluations 获取所有测评记录（谨慎使用，大数据量时需要分页）
+// 参数：无
+// 返回值：调用者可读取的全部测评记录切片，错误信息
+func (s *SmartContract) GetAllEvaluations(ctx contractapi.TransactionContextInterface) ([]*Evaluation, error) {
+	caller, err := newAccessor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 构建类型查询
+	query := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"docType": "Evaluation",
+		},
+	}
+	queryBytes, _ := json.Marshal(query)
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(string(queryBytes))
+	if err != nil {
+		return nil, ccError(ctx, "query.failed", "cause", err.Error())
+	}
+	defer resultsIterator.Close()
+
+	var evaluations []*Evaluation
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, ccError(ctx, "query.iterate_failed", "cause", err.Error())
+		}
+
+		var eval Evaluation
+		if err := json.Unmarshal(queryResponse.Value, &eval); err != nil {
+			return nil, ccError(ctx, "data.unmarshal_failed", "cause", err.Error())
+		}
+		if ok, err := caller.canRead(ctx, "Evaluation", eval.EvaluationID, eval.UserID); err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+		evaluations = append(evaluations, &eval)
+	}
+	return evaluations, nil
+}
+
+// ===================== 测试结果管理 =====================
+
+// UploadTestResult 上传测试结果
+// 参数：测试结果JSON字符串
+// 返回值：错误信息
+func (s *SmartContract) UploadTestResult(ctx contractapi.TransactionContextInterface, testJSON string) error {
+	var testResult TestResult
+	if err := json.Unmarshal([]byte(testJSON), &testResult); err != nil {
+		return ccError(ctx, "test_result.invalid_json", "cause", err.Error())
+	}
+	
+	// 个人内容从瞬态数据中取出，不随交易参数进入区块
+	var err error
+	if testResult.Answer, err = personalField(ctx, "Answer", testResult.Answer); err != nil {
+		return err
+	}
+	
+	// 数据校验
+	if err := testResult.Validate(); err != nil {
+		return ccInvalid(ctx, "test_result.invalid", err)
+	}
+	
+	// 设置文档类型
+	testResult.DocType = "TestResult"
+	compositeKey := fmt.Sprintf("TestResult-%s", testResult.TestID)
+	
+	// 检查重复记录
+	existing, err := ctx.GetStub().GetState(compositeKey)
+	if err != nil {
+		return ccError(ctx, "state.read_failed", "cause", err.Error())
+	}
+	if existing != nil {
+		return ccError(ctx, "test_result.exists", "id", testResult.TestID)
+	}
+	
+	// 加密个人内容，擦除时间由链码设置
+	testResult.ErasedAt = ""
+	if testResult.Answer, err = sealField(ctx, testResult.UserID, compositeKey, "Answer", testResult.Answer); err != nil {
+		return err
+	}
+	
+	// 存储数据
+	data, err := json.Marshal(testResult)
+	if err != nil {
+		return ccError(ctx, "data.marshal_failed", "cause", err.Error())
+	}
+	if err := ctx.GetStub().PutState(compositeKey, data); err != nil {
+		return ccError(ctx, "state.write_failed", "cause", err.Error())
+	}
+	return emitRecordEvent(ctx, RecordEvent{DocType: "TestResult", Action: "Create", RecordID: testResult.TestID, UserID: testResult.UserID})
+}
+
+// GetTestResultsByUser 获取用户所有测试结果
+// 参数：用户ID
+// 返回值：测试结果切片，错误信息
+func (s *SmartContract) GetTestResultsByUser(ctx contractapi.TransactionContextInterface, userID string) ([]*TestResult, error) {
+	if userID == "" {
+		return nil, ccError(ctx, "user_id.empty")
+	}
+	caller, err := callerAccess(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	
+	// 构建安全查询
+	query := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"docType": "TestResult",
+			"User_ID": userID,
+		},
+	}
+	queryBytes, _ := json.Marshal(query)
+	
+	resultsIterator, err := ctx.GetStub().GetQueryResult(string(queryBytes))
+	if err != nil {
+		return nil, ccError(ctx, "query.failed", "cause", err.Error())
+	}
+	defer resultsIterator.Close()
+	
+	var results []*TestResult
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, ccError(ctx, "query.iterate_failed", "cause", err.Error())
+		}
+		
+		var test TestResult
+		if err := json.Unmarshal(queryResponse.Value, &test); err != nil {
+			return nil, ccError(ctx, "data.unmarshal_failed", "cause", err.Error())
+		}
+		if ok, err := caller.canRead(ctx, "TestResult", test.TestID, test.UserID); err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+		results = append(results, &test)
+	}
+	return results, nil
+}
+
+
+// GetTestResultsByTestID 根据测试ID获取测试结果
+// 参数：测试ID
+// 返回值：测试结果指针，错误信息
+// 注意：调用者须为记录所属学生、学校组织的管理员或教师，或持有学生的有效授权
+func (s *SmartContract) GetTestResultsByTestID(ctx contractapi.TransactionContextInterface, testID string) (*TestResult, error) {
+	testResult, err := readTestResult(ctx, testID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRead(ctx, "TestResult", testID, testResult.UserID); err != nil {
+		return nil, err
+	}
+	return testResult, nil
+}
+
+// readTestResult 读取测试结果，不做权限检查
+func readTestResult(ctx contractapi.TransactionContextInterface, testID string) (*TestResult, error) {
+	if testID == "" {
+		return nil, ccError(ctx, "test_id.empty")
+	}
+
+	compositeKey := fmt.Sprintf("TestResult-%s", testID)
+	data, err := ctx.GetStub().GetState(compositeKey)
+	if err != nil {
+		return nil, ccError(ctx, "state.read_failed", "cause", err.Error())
+	}
+	if data == nil {
+		return nil, ccError(ctx, "test_result.not_found")
+	}
+
+	var testResult TestResult
+	if err := json.Unmarshal(data, &testResult); err != nil {
+		return nil, ccError(ctx, "data.unmarshal_failed", "cause", err.Error())
+	}
+	return &testResult, nil
+}
+
+// GetTestResultsByID 根据用户ID和测试ID联合查询
+// 参数：用户ID（用于权限验证），测试ID
+// 返回值：测试结果指针，错误信息
+func (s *SmartContract) GetTestResultsByID(ctx contractapi.TransactionContextInterface, userID string, testID string) (*TestResult, error) {
+	if userID == "" || testID == "" {
+		return nil, ccError(ctx, "argument.empty")
+	}
+
+	// 先通过测试ID获取记录
+	testResult, err := readTestResult(ctx, testID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 权限验证
+	if testResult.UserID != userID {
+		return nil, ccError(ctx, "test_result.forbidden")
+	}
+	if err := checkRead(ctx, "TestResult", testID, testResult.UserID); err != nil {
+		return nil, err
+	}
+	return testResult, nil
+}
+
+// ===================== 评价记录管理 =====================
+
+// UploadJudgement 上传评价记录
+// 参数：评价记录JSON字符串
+// 返回值：错误信息
+func (s *SmartContract) UploadJudgement(ctx contractapi.TransactionContextInterface, judgementJSON string) error {
+	var judgement Judgement
+	if err := json.Unmarshal([]byte(judgementJSON), &judgement); err != nil {
+		return ccError(ctx, "judgement.invalid_json", "cause", err.Error())
+	}
+	
+	// 数据校验
+	if err := judgement.Validate(); err != nil {
+		return ccInvalid(ctx, "judgement.invalid", err)
+	}
+	
+	// 设置文档类型
+	judgement.DocType = "Judgement"
+	compositeKey := fmt.Sprintf("Judgement-%s", judgement.JudgementID)
+	
+	// 评价记录允许覆盖，读取原记录用于区分新增和修改
+	existing, err := ctx.GetStub().GetState(compositeKey)
+	if err != nil {
+		return ccError(ctx, "state.read_failed", "cause", err.Error())
+	}
+	event := RecordEvent{
+		DocType:   "Judgement",
+		Action:    "Create",
+		RecordID:  judgement.JudgementID,
+		UserID:    judgement.UserID,
+		ObjectID:  judgement.JudgementObjectID,
+		Objection: judgement.JudgementObjection != "",
+	}
+	if existing != nil {
+		event.Action = "Modify"
+		event.PreviousUserID = recordOwner(existing)
+	}
+	
+	// 存储数据
+	data, err := json.Marshal(judgement)
+	if err != nil {
+		return ccError(ctx, "data.marshal_failed", "cause", err.Error())
+	}
+	if err := ctx.GetStub().PutState(compositeKey, data); err != nil {
+		return ccError(ctx, "state.write_failed", "cause", err.Error())
+	}
+	return emitRecordEvent(ctx, event)
+}
+
+// GetJudgementByUser 获取用户所有评价记录
+// 参数：用户ID
+// 返回值：评价记录切片，错误信息
+func (s *SmartContract) GetJudgementByUser(ctx contractapi.TransactionContextInterface, userID string) ([]*Judgement, error) {
+	if userID == "" {
+		return nil, ccError(ctx, "user_id.empty")
+	}
+	caller, err := callerAccess(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 构建安全查询
+	query := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"docType": "Judgement",
+			"User_ID": userID,
+		},
+	}
+	queryBytes, _ := json.Marshal(query)
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(string(queryBytes))
+	if err != nil {
+		return nil, ccError(ctx, "query.failed", "cause", err.Error())
+	}
+	defer resultsIterator.Close()
+
+	var judgements []*Judgement
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, ccError(ctx, "query.iterate_failed", "cause", err.Error())
+		}
+
+		var judgement Judgement
+		if err := json.Unmarshal(queryResponse.Value, &judgement); err != nil {
+			return nil, ccError(ctx, "data.unmarshal_failed", "cause", err.Error())
+		}
+		if ok, err := caller.canRead(ctx, "Judgement", judgement.JudgementID, judgement.UserID); err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+		judgements = append(judgements, &judgement)
+	}
+	return judgements, nil
+}
+
+// GetJudgementByID 根据用户ID和评价ID联合查询
+// 参数：用户ID（用于权限验证），评价ID
+// 返回值：评价记录指针，错误信息
+func (s *SmartContract) GetJudgementByID(ctx contractapi.TransactionContextInterface, userID string, judgementID string) (*Judgement, error) {
+	if userID == "" || judgementID == "" {
+		return nil, ccError(ctx, "argument.empty")
+	}
+
+	// 先获取评价记录
+	judgement, err := readJudgement(ctx, judgementID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 权限验证
+	if judgement.UserID != userID {
+		return nil, ccError(ctx, "judgement.forbidden")
+	}
+	if err := checkRead(ctx, "Judgement", judgementID, judgement.UserID); err != nil {
+		return nil, err
+	}
+	return judgement, nil
+}
+
+
+// GetJudgementByJudgementID 根据评价ID查询
+// 参数：评价ID
+// 返回值：评价记录指针，错误信息
+// 注意：调用者须为记录所属学生、学校组织的管理员或教师，或持有学生的有效授权
+func (s *SmartContract) GetJudgementByJudgementID(ctx contractapi.TransactionContextInterface, judgementID string) (*Judgement, error) {
+	judgement, err := readJudgement(ctx, judgementID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRead(ctx, "Judgement", judgementID, judgement.UserID); err != nil {
+		return nil, err
+	}
+	return judgement, nil
+}
+
+// readJudgement 读取评价记录，不做权限检查
+func readJudgement(ctx contractapi.TransactionContextInterface, judgementID string) (*Judgement, error) {
+	if judgementID == "" {
+		return nil, ccError(ctx, "judgement_id.empty")
+	}
+
+	compositeKey := fmt.Sprintf("Judgement-%s", judgementID)
+	data, err := ctx.GetStub().GetState(compositeKey)
+	if err != nil {
+		return nil, ccError(ctx, "state.read_failed", "cause", err.Error())
+	}
+	if data == nil {
+		return nil, ccError(ctx, "judgement.not_found")
+	}
+
+	var judgement Judgement
+	if err := json.Unmarshal(data, &judgement); err != nil {
+		return nil, ccError(ctx, "data.unmarshal_failed", "cause", err.Error())
+	}
+	return &judgement, nil
+}
+
+
+// ===================== 通用功能 =====================
+
+// DeleteRecord 通用删除方法
+// 参数：记录类型（Evaluation/TestResult/Judgement），记录ID
+// 返回值：错误信息
+func (s *SmartContract) DeleteRecord(ctx contractapi.TransactionContextInterface, recordType string, recordID string) error {
+	if recordID == "" {
+		return ccError(ctx, "record_id.empty")
+	}
+	
+	var compositeKey string
+	switch recordType {
+	case "Evaluation":
+		compositeKey = fmt.Sprintf("Evaluation-%s", recordID)
+	case "TestResult":
+		compositeKey = fmt.Sprintf("TestResult-%s", recordID)
+	case "Judgement":
+		compositeKey = fmt.Sprintf("Judgement-%s", recordID)
+	default:
+		return ccError(ctx, "record_type.unsupported", "type", recordType)
+	}
+	
+	// 读取原记录以便在事件中带上所属用户
+	existing, err := ctx.GetStub().GetState(compositeKey)
+	if err != nil {
+		return ccError(ctx, "state.read_failed", "cause", err.Error())
+	}
+	if err := ctx.GetStub().DelState(compositeKey); err != nil {
+		return ccError(ctx, "state.write_failed", "cause", err.Error())
+	}
+	if existing == nil {
+		return nil
+	}
+	return emitRecordEvent(ctx, RecordEvent{DocType: recordType, Action: "Delete", RecordID: recordID, UserID: recordOwner(existing)})
+}
+
+// ===================== 分页导出 =====================
+
+// 单页最大记录数
+const maxExportPageSize = 1000
+
+// 导出过滤条件和结果定义在 edu/model 中，与客户端共用
+type (
+	ExportFilter = model.ExportFilter
+	ExportPage   = model.ExportPage
+)
+
+// ExportRecords 按记录类型分页导出，仅限学校组织的管理员或教师
+// 参数：记录类型（Evaluation/TestResult/Judgement），过滤条件JSON，每页条数，上一页返回的书签（首页为空）
+// 返回值：一页记录，错误信息
+func (s *SmartContract) ExportRecords(ctx contractapi.TransactionContextInterface, docType string, filterJSON string, pageSize int32, bookmark string) (*ExportPage, error) {
+	caller, err := newAccessor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.staff {
+		return nil, ccError(ctx, "export.forbidden")
+	}
+	if pageSize <= 0 || pageSize > maxExportPageSize {
+		return nil, ccError(ctx, "export.page_size", "max", fmt.Sprint(maxExportPageSize))
+	}
+
+	var filter ExportFilter
+	if filterJSON != "" {
+		if err := json.Unmarshal([]byte(filterJSON), &filter); err != nil {
+			return nil, ccError(ctx, "export.invalid_filter", "cause", err.Error())
+		}
+	}
+
+	switch docType {
+	case "Evaluation", "TestResult", "Judgement":
+	default:
+		return nil, ccError(ctx, "record_type.unsupported", "type", docType)
+	}
+	selector := map[string]interface{}{"docType": docType}
+	if filter.UserID != "" {
+		selector["User_ID"] = filter.UserID
+	}
+	if filter.PaperNumber != "" || len(filter.PaperNumbers) > 0 {
+		if docType != "TestResult" {
+			return nil, ccError(ctx, "export.no_paper_field", "type", docType)
+		}
+		paper := map[string]interface{}{}
+		if filter.PaperNumber != "" {
+			paper["$eq"] = filter.PaperNumber
+		}
+		if len(filter.PaperNumbers) > 0 {
+			paper["$in"] = filter.PaperNumbers
+		}
+		selector["Paper_Number"] = paper
+	}
+	if filter.From != "" || filter.To != "" {
+		if docType != "Judgement" {
+			return nil, ccError(ctx, "export.no_time_field", "type", docType)
+		}
+		// RFC3339 字符串按字典序比较即按时间比较（时区需一致）
+		timeRange := map[string]interface{}{}
+		if filter.From != "" {
+			timeRange["$gte"] = filter.From
+		}
+		if filter.To != "" {
+			timeRange["$lt"] = filter.To
+		}
+		selector["Judgement_Time"] = timeRange
+	}
+	queryBytes, _ := json.Marshal(map[string]interface{}{"selector": selector})
+
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(string(queryBytes), pageSize, bookmark)
+	if err != nil {
+		return nil, ccError(ctx, "query.failed", "cause", err.Error())
+	}
+	defer resultsIterator.Close()
+
+	page := &ExportPage{Records: []string{}, Bookmark: metadata.GetBookmark()}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, ccError(ctx, "query.iterate_failed", "cause", err.Error())
+		}
+		page.Records = append(page.Records, string(queryResponse.Value))
+	}
+	return page, nil
+}
+
+// ===================== 访问授权 =====================
+
+// 访问日志的文档类型
+const accessLogObject = "AccessLog"
+
+// maxGrantDuration 单次授权的最长有效期
+const maxGrantDuration = 365 * 24 * time.Hour
+
+// 授权结构定义在 edu/model 中，与客户端共用
+type (
+	AccessScope    = model.AccessScope
+	AccessGrant    = model.AccessGrant
+	AccessLogEntry = model.AccessLogEntry
+)
+
+// accessor 调用者身份及其在交易时间有效的授权
+type accessor struct {
+	id     string    // <MSP ID>/<证书CN>
+	userID string    // 学生身份的用户ID（证书CN），非学生为空
+	staff  bool      // 学校组织的管理员或教师身份
+	at     time.Time // 交易时间
+
+	grants map[string][]*AccessGrant // 按授权学生分组，首次使用时加载
+}
+
+// newAccessor 读取调用者证书和交易时间
+func newAccessor(ctx contractapi.TransactionContextInterface) (*accessor, error) {
+	identity := ctx.GetClientIdentity()
+	mspID, err := identity.GetMSPID()
+	if err != nil {
+		return nil, ccError(ctx, "caller.id_failed", "cause", err.Error())
+	}
+	cert, err := identity.GetX509Certificate()
+	if err != nil || cert == nil {
+		return nil, ccError(ctx, "caller.cert_failed", "cause", fmt.Sprint(err))
+	}
+	role, _, err := identity.GetAttributeValue(model.RoleAttribute)
+	if err != nil {
+		return nil, ccError(ctx, "caller.role_failed", "cause", err.Error())
+	}
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, ccError(ctx, "tx.time_failed", "cause", err.Error())
+	}
+
+	a := &accessor{id: mspID + "/" + cert.Subject.CommonName, at: timestamp.AsTime()}
+	if mspID == model.InstitutionMSPID {
+		switch role {
+		case model.RoleStudent:
+			a.userID = cert.Subject.CommonName
+		case model.RoleAdmin, model.RoleTeacher:
+			a.staff = true
+		}
+	}
+	return a, nil
+}
+
+// owns 调用者是否为学校组织的管理员、教师或学生本人
+func (a *accessor) owns(userID string) bool {
+	return a.staff || (a.userID != "" && a.userID == userID)
+}
+
+// grantsFrom 返回 userID 授予调用者且在交易时间有效的授权
+func (a *accessor) grantsFrom(ctx contractapi.TransactionContextInterface, userID string) ([]*AccessGrant, error) {
+	if a.grants == nil {
+		grants, err := queryGrants(ctx, map[string]interface{}{"docType": model.DocTypeAccessGrant, "grantee": a.id})
+		if err != nil {
+			return nil, err
+		}
+		a.grants = make(map[string][]*AccessGrant)
+		for _, grant := range grants {
+			if grant.Active(a.at) {
+				a.grants[grant.UserID] = append(a.grants[grant.UserID], grant)
+			}
+		}
+	}
+	return a.grants[userID], nil
+}
+
+// canRead 调用者能否读取 owner 的记录，通过授权读取时写入访问日志
+func (a *accessor) canRead(ctx contractapi.TransactionContextInterface, docType, recordID, owner string) (bool, error) {
+	if a.owns(owner) {
+		return true, nil
+	}
+	grants, err := a.grantsFrom(ctx, owner)
+	if err != nil {
+		return false, err
+	}
+	recordKey := docType + "-" + recordID
+	for _, grant := range grants {
+		if grant.Covers(docType, recordKey) {
+			return true, logAccess(ctx, a, grant, recordKey)
+		}
+	}
+	return false, nil
+}
+
+// checkRead 单条记录的读取权限检查
+func checkRead(ctx contractapi.TransactionContextInterface, docType, recordID, owner string) error {
+	caller, err := newAccessor(ctx)
+	if err != nil {
+		return err
+	}
+	ok, err := caller.canRead(ctx, docType, recordID, owner)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ccError(ctx, "record.forbidden")
+	}
+	return nil
+}
+
+// callerAccess 按用户查询前的权限检查，第三方至少持有该学生的一项有效授权，结果再按授权范围过滤
+func callerAccess(ctx contractapi.TransactionContextInterface, userID string) (*accessor, error) {
+	caller, err := newAccessor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if caller.owns(userID) {
+		return caller, nil
+	}
+	grants, err := caller.grantsFrom(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(grants) == 0 {
+		return nil, ccError(ctx, "user_records.forbidden")
+	}
+	return caller, nil
+}
+
+// logAccess 记录一次授权读取
+// 访问日志随读取交易写入账本，客户端通过授权读取时以提交方式调用查询交易，日志随交易提交
+func logAccess(ctx contractapi.TransactionContextInterface, a *accessor, grant *AccessGrant, recordKey string) error {
+	txID := ctx.GetStub().GetTxID()
+	logf(ctx, "%s 通过授权 %s 读取 %s（用户 %s）", a.id, grant.GrantID, recordKey, grant.UserID)
+
+	// 键中带交易时间，按学生前缀查询时按时间排列
+	at := a.at.UTC().Format(time.RFC3339)
+	key, err := ctx.GetStub().CreateCompositeKey(accessLogObject, []string{grant.UserID, at, txID, recordKey})
+	if err != nil {
+		return ccError(ctx, "access_log.key_failed", "cause", err.Error())
+	}
+	data, err := json.Marshal(AccessLogEntry{
+		GrantID:  grant.GrantID,
+		UserID:   grant.UserID,
+		Accessor: a.id,
+		Record:   recordKey,
+		TxID:     txID,
+		Time:     at,
+	})
+	if err != nil {
+		return ccError(ctx, "data.marshal_failed", "cause", err.Error())
+	}
+	if err := ctx.GetStub().PutState(key, data); err != nil {
+		return ccError(ctx, "state.write_failed", "cause", err.Error())
+	}
+	return nil
+}
+
+// queryGrants 按 CouchDB 选择器查询授权
+func queryGrants(ctx contractapi.TransactionContextInterface, selector map[string]interface{}) ([]*AccessGrant, error) {
+	queryBytes, _ := json.Marshal(map[string]interface{}{"selector": selector})
+	resultsIterator, err := ctx.GetStub().GetQueryResult(string(queryBytes))
+	if err != nil {
+		return nil, ccError(ctx, "query.failed", "cause", err.Error())
+	}
+	defer resultsIterator.Close()
+
+	var grants []*AccessGrant
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, ccError(ctx, "query.iterate_failed", "cause", err.Error())
+		}
+		var grant AccessGrant
+		if err := json.Unmarshal(queryResponse.Value, &grant); err != nil {
+			return nil, ccError(ctx, "data.unmarshal_failed", "cause", err.Error())
+		}
+		grants = append(grants, &grant)
+	}
+	return grants, nil
+}
+
+// GrantAccess 学生授权指定身份读取自己的记录
+// 参数：被授权身份（<MSP ID>/<证书CN>），授权范围JSON，过期时间（RFC3339）
+// 返回值：授权记录（授权ID即本交易ID），错误信息
+func (s *SmartContract) GrantAccess(ctx contractapi.TransactionContextInterface, grantee string, scopeJSON string, expiresAt string) (*AccessGrant, error) {
+	caller, err := newAccessor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if caller.userID == "" {
+		return nil, ccError(ctx, "grant.student_only")
+	}
+	if parts := strings.SplitN(grantee, "/", 2); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, ccError(ctx, "grant.grantee_format")
+	}
+	if grantee == caller.id {
+		return nil, ccError(ctx, "grant.self")
+	}
+
+	var scope AccessScope
+	if err := json.Unmarshal([]byte(scopeJSON), &scope); err != nil {
+		return nil, ccError(ctx, "grant.invalid_scope", "cause", err.Error())
+	}
+	if len(scope.DocTypes) == 0 && len(scope.Records) == 0 {
+		return nil, ccError(ctx, "grant.empty_scope")
+	}
+	if scope.DocTypes == nil {
+		scope.DocTypes = []string{} // 链码元数据要求返回值中存在 docTypes 字段
+	}
+	for _, docType := range scope.DocTypes {
+		switch docType {
+		case "Evaluation", "TestResult", "Judgement":
+		default:
+			return nil, ccError(ctx, "record_type.unsupported", "type", docType)
+		}
+	}
+	for _, recordKey := range scope.Records {
+		parts := strings.SplitN(recordKey, "-", 2)
+		switch {
+		case len(parts) != 2:
+			return nil, ccError(ctx, "grant.record_format", "record", recordKey)
+		case parts[0] != "Evaluation" && parts[0] != "TestResult" && parts[0] != "Judgement":
+			return nil, ccError(ctx, "record_type.unsupported", "type", parts[0])
+		}
+		data, err := ctx.GetStub().GetState(recordKey)
+		if err != nil {
+			return nil, ccError(ctx, "state.read_failed", "cause", err.Error())
+		}
+		if data == nil {
+			return nil, ccError(ctx, "record.not_found", "record", recordKey)
+		}
+		if recordOwner(data) != caller.userID {
+			return nil, ccError(ctx, "grant.record_forbidden", "record", recordKey)
+		}
+	}
+
+	expires, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		return nil, ccError(ctx, "grant.invalid_expiry", "cause", err.Error())
+	}
+	if !expires.After(caller.at) {
+		return nil, ccError(ctx, "grant.expiry_past")
+	}
+	if expires.Sub(caller.at) > maxGrantDuration {
+		return nil, ccError(ctx, "grant.expiry_too_long", "days", fmt.Sprint(int(maxGrantDuration.Hours()/24)))
+	}
+
+	grant := &AccessGrant{
+		DocType:   model.DocTypeAccessGrant,
+		GrantID:   ctx.GetStub().GetTxID(),
+		UserID:    caller.userID,
+		Grantee:   grantee,
+		Scope:     scope,
+		CreatedAt: caller.at.UTC().Format(time.RFC3339),
+		ExpiresAt: expires.UTC().Format(time.RFC3339),
+	}
+	data, err := json.Marshal(grant)
+	if err != nil {
+		return nil, ccError(ctx, "data.marshal_failed", "cause", err.Error())
+	}
+	if err := ctx.GetStub().PutState(model.DocTypeAccessGrant+"-"+grant.GrantID, data); err != nil {
+		return nil, ccError(ctx, "state.write_failed", "cause", err.Error())
+	}
+	if err := emitRecordEvent(ctx, RecordEvent{DocType: model.DocTypeAccessGrant, Action: "Create", RecordID: grant.GrantID, UserID: grant.UserID}); err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
+
+// RevokeAccess 学生撤销自己的授权，撤销后的读取交易立即失去授权
+// 参数：授权ID
+// 返回值：错误信息
+func (s *SmartContract) RevokeAccess(ctx contractapi.TransactionContextInterface, grantID string) error {
+	if grantID == "" {
+		return ccError(ctx, "grant_id.empty")
+	}
+	caller, err := newAccessor(ctx)
+	if err != nil {
+		return err
+	}
+
+	key := model.DocTypeAccessGrant + "-" + grantID
+	data, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return ccError(ctx, "state.read_failed", "cause", err.Error())
+	}
+	if data == nil {
+		return ccError(ctx, "grant.not_found")
+	}
+	var grant AccessGrant
+	if err := json.Unmarshal(data, &grant); err != nil {
+		return ccError(ctx, "data.unmarshal_failed", "cause", err.Error())
+	}
+	if caller.userID == "" || grant.UserID != caller.userID {
+		return ccError(ctx, "grant.revoke_forbidden")
+	}
+	if grant.RevokedAt != "" {
+		return ccError(ctx, "grant.revoked", "grantId", grantID)
+	}
+
+	grant.RevokedAt = caller.at.UTC().Format(time.RFC3339)
+	if data, err = json.Marshal(grant); err != nil {
+		return ccError(ctx, "data.marshal_failed", "cause", err.Error())
+	}
+	if err := ctx.GetStub().PutState(key, data); err != nil {
+		return ccError(ctx, "state.write_failed", "cause", err.Error())
+	}
+	return emitRecordEvent(ctx, RecordEvent{DocType: model.DocTypeAccessGrant, Action: "Modify", RecordID: grantID, UserID: grant.UserID})
+}
+
+// GetAccessGrants 查询学生的全部授权（含已过期和已撤销的）
+// 参数：用户ID
+// 返回值：授权切片，错误信息
+func (s *SmartContract) GetAccessGrants(ctx contractapi.TransactionContextInterface, userID string) ([]*AccessGrant, error) {
+	if userID == "" {
+		return nil, ccError(ctx, "user_id.empty")
+	}
+	caller, err := newAccessor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.owns(userID) {
+		return nil, ccError(ctx, "grant.list_forbidden")
+	}
+	return queryGrants(ctx, map[string]interface{}{"docType": model.DocTypeAccessGrant, "userId": userID})
+}
+
+// GetAccessLog 查询学生记录的授权访问日志
+// 参数：用户ID
+// 返回值：访问日志切片（按读取时间排列），错误信息
+func (s *SmartContract) GetAccessLog(ctx contractapi.TransactionContextInterface, userID string) ([]*AccessLogEntry, error) {
+	if userID == "" {
+		return nil, ccError(ctx, "user_id.empty")
+	}
+	caller, err := newAccessor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.owns(userID) {
+		return nil, ccError(ctx, "access_log.forbidden")
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(accessLogObject, []string{userID})
+	if err != nil {
+		return nil, ccError(ctx, "query.failed", "cause", err.Error())
+	}
+	defer resultsIterator.Close()
+
+	entries := []*AccessLogEntry{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, ccError(ctx, "query.iterate_failed", "cause", err.Error())
+		}
+		var entry AccessLogEntry
+		if err := json.Unmarshal(queryResponse.Value, &entry); err != nil {
+			return nil, ccError(ctx, "data.unmarshal_failed", "cause", err.Error())
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, nil
+}
+
+// ===================== 记录加密 =====================
+// 个人自由文本（测评反馈、测试答案）以学生密钥加密后写入账本。明文和密钥保存在链下，
+// 只在写入交易中经瞬态数据交给链码，不进入区块；擦除时销毁密钥，区块历史中只剩无法解密的密文
+
+// docTypeStudentKey 学生密钥登记的文档类型
+const docTypeStudentKey = "StudentKey"
+
+// StudentKey 学生密钥登记，只保存密钥校验值，用于拒绝与首次登记不一致的密钥
+type StudentKey struct {
+	DocType   string `json:"docType"`
+	UserID    string `json:"userId"`
+	KeyCheck  string `json:"keyCheck,omitempty"` // HMAC-SHA256(密钥, "edu-record-key")，十六进制
+	CreatedAt string `json:"createdAt"`
+	ErasedAt  string `json:"erasedAt,omitempty"`
+}
+
+// readStudentKey 读取学生密钥登记，未登记时返回 nil
+func readStudentKey(ctx contractapi.TransactionContextInterface, userID string) (*StudentKey, error) {
+	data, err := ctx.GetStub().GetState(docTypeStudentKey + "-" + userID)
+	if err != nil {
+		return nil, ccError(ctx, "state.read_failed", "cause", err.Error())
+	}
+	if data == nil {
+		return nil, nil
+	}
+	var registered StudentKey
+	if err := json.Unmarshal(data, &registered); err != nil {
+		return nil, ccError(ctx, "data.unmarshal_failed", "cause", err.Error())
+	}
+	return &registered, nil
+}
+
+func putStudentKey(ctx contractapi.TransactionContextInterface, registered *StudentKey) error {
+	data, err := json.Marshal(registered)
+	if err != nil {
+		return ccError(ctx, "data.marshal_failed", "cause", err.Error())
+	}
+	if err := ctx.GetStub().PutState(docTypeStudentKey+"-"+registered.UserID, data); err != nil {
+		return ccError(ctx, "state.write_failed", "cause", err.Error())
+	}
+	return nil
+}
+
+// studentKey 读取瞬态数据中的学生密钥并与登记的校验值核对，首次使用时登记
+func studentKey(ctx contractapi.TransactionContextInterface, userID string) ([]byte, error) {
+	registered, err := readStudentKey(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if registered != nil && registered.ErasedAt != "" {
+		return nil, ccError(ctx, "user_data.erased_write", "userId", userID)
+	}
+
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, ccError(ctx, "transient.read_failed", "cause", err.Error())
+	}
+	key := transient[model.RecordKeyTransient]
+	if len(key) != 32 {
+		return nil, ccError(ctx, "record_key.missing", "transient", model.RecordKeyTransient)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("edu-record-key"))
+	check := hex.EncodeToString(mac.Sum(nil))
+
+	if registered == nil {
+		timestamp, err := ctx.GetStub().GetTxTimestamp()
+		if err != nil {
+			return nil, ccError(ctx, "tx.time_failed", "cause", err.Error())
+		}
+		registered = &StudentKey{
+			DocType:   docTypeStudentKey,
+			UserID:    userID,
+			KeyCheck:  check,
+			CreatedAt: timestamp.AsTime().UTC().Format(time.RFC3339),
+		}
+		if err := putStudentKey(ctx, registered); err != nil {
+			return nil, err
+		}
+	} else if !hmac.Equal([]byte(registered.KeyCheck), []byte(check)) {
+		return nil, ccError(ctx, "record_key.mismatch", "userId", userID)
+	}
+	return key, nil
+}
+
+// personalField 从瞬态数据中取出个人内容字段的明文
+// 交易参数会原样写入区块，参数中的个人内容即使随后加密也会以明文留在区块历史中，因此直接拒绝
+func personalField(ctx contractapi.TransactionContextInterface, field, argValue string) (string, error) {
+	if argValue != "" {
+		return "", ccError(ctx, "field.in_args", "field", field, "transient", model.PersonalTransient)
+	}
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", ccError(ctx, "transient.read_failed", "cause", err.Error())
+	}
+	data, ok := transient[model.PersonalTransient]
+	if !ok {
+		return "", nil
+	}
+	var fields map[string]string
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", ccError(ctx, "personal.invalid_json", "transient", model.PersonalTransient, "cause", err.Error())
+	}
+	return fields[field], nil
+}
+
+// sealField 用学生密钥加密自由文本字段，返回写入账本的值；空字段不需要密钥
+// recordKey 和字段名作为附加数据，密文不能被挪到其他记录或字段中
+func sealField(ctx contractapi.TransactionContextInterface, userID, recordKey, field, plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	if strings.HasPrefix(plaintext, model.EncryptedPrefix) {
+		return "", ccError(ctx, "field.ciphertext", "field", field)
+	}
+	key, err := studentKey(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", ccError(ctx, "cipher.init_failed", "cause", err.Error())
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", ccError(ctx, "cipher.init_failed", "cause", err.Error())
+	}
+
+	// 各背书节点必须写入相同的密文，随机数由密钥、交易ID和字段位置派生，同一密钥下不会重复
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(ctx.GetStub().GetTxID() + "|" + recordKey + "|" + field))
+	nonce := mac.Sum(nil)[:gcm.NonceSize()]
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), []byte(recordKey+"|"+field))
+	return model.EncryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// EraseUserData 擦除学生的个人内容：标记学生密钥已擦除，清空其测评反馈和测试答案并记录擦除时间
+// 客户端随后从密钥服务中销毁密钥，区块历史中的密文从此无法解密；擦除后不能再为该学生写入个人内容
+// 参数：用户ID
+// 返回值：错误信息
+func (s *SmartContract) EraseUserData(ctx contractapi.TransactionContextInterface, userID string) error {
+	if userID == "" {
+		return ccError(ctx, "user_id.empty")
+	}
+	caller, err := newAccessor(ctx)
+	if err != nil {
+		return err
+	}
+	if !caller.owns(userID) {
+		return ccError(ctx, "user_data.erase_forbidden")
+	}
+
+	registered, err := readStudentKey(ctx, userID)
+	if err != nil {
+		return err
+	}
+	erasedAt := caller.at.UTC().Format(time.RFC3339)
+	switch {
+	case registered == nil:
+		registered = &StudentKey{DocType: docTypeStudentKey, UserID: userID, CreatedAt: erasedAt}
+	case registered.ErasedAt != "":
+		return ccError(ctx, "user_data.erased", "userId", userID)
+	}
+	registered.ErasedAt = erasedAt
+	if err := putStudentKey(ctx, registered); err != nil {
+		return err
+	}
+
+	// 先收集再写入，不在查询迭代过程中修改状态
+	erased := make(map[string][]byte)
+	for _, docType := range []string{"Evaluation", "TestResult"} {
+		queryBytes, _ := json.Marshal(map[string]interface{}{
+			"selector": map[string]interface{}{"docType": docType, "User_ID": userID},
+		})
+		resultsIterator, err := ctx.GetStub().GetQueryResult(string(queryBytes))
+		if err != nil {
+			return ccError(ctx, "query.failed", "cause", err.Error())
+		}
+		for resultsIterator.HasNext() {
+			queryResponse, err := resultsIterator.Next()
+			if err != nil {
+				resultsIterator.Close()
+				return ccError(ctx, "query.iterate_failed", "cause", err.Error())
+			}
+			var record interface{}
+			switch docType {
+			case "Evaluation":
+				var e Evaluation
+				err = json.Unmarshal(queryResponse.Value, &e)
+				e.Feedback, e.ErasedAt = "", erasedAt
+				record = e
+			case "TestResult":
+				var t TestResult
+				err = json.Unmarshal(queryResponse.Value, &t)
+				t.Answer, t.ErasedAt = "", erasedAt
+				record = t
+			}
+			if err != nil {
+				resultsIterator.Close()
+				return ccError(ctx, "data.unmarshal_failed", "cause", err.Error())
+			}
+			if erased[queryResponse.Key], err = json.Marshal(record); err != nil {
+				resultsIterator.Close()
+				return ccError(ctx, "data.marshal_failed", "cause", err.Error())
+			}
+		}
+		resultsIterator.Close()
+	}
+	for key, data := range erased {
+		if err := ctx.GetStub().PutState(key, data); err != nil {
+			return ccError(ctx, "state.write_failed", "cause", err.Error())
+		}
+	}
+	logf(ctx, "擦除用户 %s 的个人内容，共 %d 条记录", userID, len(erased))
+	return emitRecordEvent(ctx, RecordEvent{DocType: model.DocTypeUserData, Action: "Erase", RecordID: userID, UserID: userID})
+}
+
+// ===================== 链码事件 =====================
+
+// RecordEvent 记录变更事件内容，定义在 edu/model 中，与客户端共用
+type RecordEvent = model.RecordEvent
+
+// emitRecordEvent 设置记录变更事件，客户端据此失效缓存或推送通知
+func emitRecordEvent(ctx contractapi.TransactionContextInterface, event RecordEvent) error {
+	if event.PreviousUserID == event.UserID {
+		event.PreviousUserID = ""
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return ccError(ctx, "event.marshal_failed", "cause", err.Error())
+	}
+	logf(ctx, "%s %s %s（用户 %s）", event.Action, event.DocType, event.RecordID, event.UserID)
+	if err := ctx.GetStub().SetEvent(model.RecordEventName, payload); err != nil {
+		return ccError(ctx, "event.emit_failed", "cause", err.Error())
+	}
+	return nil
+}
+
+// recordOwner 从记录JSON中取出所属用户ID，解析失败时返回空串
+func recordOwner(data []byte) string {
+	var owner struct {
+		UserID string `json:"User_ID"`
+	}
+	json.Unmarshal(data, &owner)
+	return owner.UserID
+}
+
+// ===================== 链路追踪 =====================
+
+// traceID 从 traceparent（00-<trace-id>-<span-id>-<flags>）中取出追踪ID，未传入时返回空串
+func traceID(ctx contractapi.TransactionContextInterface) string {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(string(transient[model.TraceparentTransient]), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 {
+		return ""
+	}
+	return parts[1]
+}
+
+// logf 输出带追踪ID和交易ID的日志，可与客户端的span关联
+func logf(ctx contractapi.TransactionContextInterface, format string, args ...interface{}) {
+	log.Printf("[trace=%s tx=%s] %s", traceID(ctx), ctx.GetStub().GetTxID(), fmt.Sprintf(format, args...))
+}
+
+// beforeTransaction 每笔交易执行前记录调用的方法
+func beforeTransaction(ctx contractapi.TransactionContextInterface) error {
+	function, _ := ctx.GetStub().GetFunctionAndParameters()
+	logf(ctx, "执行 %s", function)
+	return nil
+}
+
+// ===================== 初始化方法 =====================
+
+// InitLedger 初始化示例数据（仅限开发环境使用）
+func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
+	// 示例测评记录
+	evaluation := Evaluation{
+		DocType:      "Evaluation",
+		EvaluationID: "eval_001",
+		UserID:       "user_001",
+		PointsDegree: "A",
+		Feedback:     "Excellent performance in all aspects",
+	}
+	evalKey := fmt.Sprintf("Evaluation-%s", evaluation.EvaluationID)
+	if data, err := json.Marshal(evaluation); err == nil {
+		ctx.GetStub().PutState(evalKey, data)
+	}
+	
+	// 示例测试结果
+	testResult := TestResult{
+		DocType:     "TestResult",
+		TestID:      "test_001",
+		UserID:      "user_001",
+		ScoreSum:    "98",
+		PaperNumber: "2023-FINAL-01",
+		Answer:      "Correct answers for all questions",
+	}
+	testKey := fmt.Sprintf("TestResult-%s", testResult.TestID)
+	if data, err := json.Marshal(testResult); err == nil {
+		ctx.GetStub().PutState(testKey, data)
+	}
+	
+	// 示例评价记录
+	judgement := Judgement{
+		DocType:            "Judgement",
+		JudgementID:        "judge_001",
+		UserID:             "user_002",
+		JudgementObjection: "None",
+		JudgementObjectID:  "eval_001",
+		JudgementRating:    "5",
+		JudgementContent:   "Very fair evaluation",
+		JudgementTime:      time.Now().Format(time.RFC3339),
+	}
+	judgeKey := fmt.Sprintf("Judgement-%s", judgement.JudgementID)
+	if data, err := json.Marshal(judgement); err == nil {
+		ctx.GetStub().PutState(judgeKey, data)
+	}
+	
+	return nil
+}
+
+// ===================== 创建链码 =====================
+
+// NewChaincode 创建链码，由 fabric/chaincode 的主函数启动，客户端的进程内后端也用它在本地运行链码
+func NewChaincode() (*contractapi.ContractChaincode, error) {
+	contract := &SmartContract{}
+	contract.BeforeTransaction = beforeTransaction
+
+	chaincode, err := contractapi.NewChaincode(contract)
+	if err != nil {
+		return nil, fmt.Errorf("链码初始化失败: %v", err)
+	}
+	return chaincode, nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+
+	"edu/chaincode/atcc"
+)
+
+// ===================== 主函数 =====================
+func main() {
+	chaincode, err := atcc.NewChaincode()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := chaincode.Start(); err != nil {
+		fmt.Printf("链码服务启动失败: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+
+	"edu/model"
+	"google.golang.org/grpc/status"
+)
+
+// ===================== 链码错误 =====================
+
+// ChaincodeError 链码返回的带错误码的错误，错误信息为调用时选择的语言（见 WithLanguage / Language）
+// 用 errors.As 取出错误码、错误信息键和参数，或用 errors.Is(err, model.CodeNotFound) 判断错误码
+type ChaincodeError struct {
+	model.CodedError
+	cause error // 网关或进程内通道返回的原始错误
+}
+
+func (e *ChaincodeError) Error() string {
+	return e.Message
+}
+
+func (e *ChaincodeError) Unwrap() error {
+	return e.cause
+}
+
+// Is 与错误码比较
+func (e *ChaincodeError) Is(target error) bool {
+	code, ok := target.(model.ErrorCode)
+	return ok && code == e.Code
+}
+
+// chaincodeError 将链码返回的结构化错误解码为 *ChaincodeError，其他错误原样返回
+// 查询时链码错误信息在网关错误信息中；背书失败时网关只返回概要，链码错误信息在各背书节点的错误详情中
+func chaincodeError(err error) error {
+	var decoded *ChaincodeError
+	if err == nil || errors.As(err, &decoded) {
+		return err
+	}
+	messages := []string{err.Error()}
+	if st, ok := status.FromError(err); ok {
+		for _, detail := range st.Details() {
+			if d, ok := detail.(interface{ GetMessage() string }); ok {
+				messages = append(messages, d.GetMessage())
+			}
+		}
+	}
+	for _, message := range messages {
+		if coded, ok := model.ParseError(message); ok {
+			return &ChaincodeError{CodedError: *coded, cause: err}
+		}
+	}
+	return err
+}
+
+// errorKey 返回链码错误的错误信息键，不是链码错误时返回空串
+func errorKey(err error) string {
+	var coded *ChaincodeError
+	if errors.As(err, &coded) {
+		return coded.Key
+	}
+	return ""
+}
+
+// localError 创建与链码相同格式的错误，供不经过链码的后端（如内存账本）使用
+func localError(key string, details ...string) *ChaincodeError {
+	return &ChaincodeError{CodedError: *model.NewError(key, details...)}
+}
+
+// localInvalid 创建记录校验失败的错误，附带未通过校验的字段
+func localInvalid(key string, err error) *ChaincodeError {
+	return &ChaincodeError{CodedError: *model.NewError(key, "cause", err.Error()).WithFields(err), cause: err}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"edu/model"
+	"github.com/hyperledger/fabric-protos-go-apiv2/gateway"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestChaincodeErrorDecoding(t *testing.T) {
+	coded := model.NewError("record.not_found", "record", "Evaluation-eval_001")
+	endorseErr, err := status.New(codes.Aborted, "failed to endorse transaction, see attached details for more info").
+		WithDetails(&gateway.ErrorDetail{Message: "chaincode response 500, " + coded.Error()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		err  error
+		code codes.Code
+	}{
+		// 查询时链码错误信息在网关错误信息中
+		{"查询错误", status.Error(codes.Unknown, "evaluate call to endorser returned error: chaincode response 500, "+coded.Error()), codes.Unknown},
+		// 背书失败时链码错误信息只在背书节点的错误详情中
+		{"背书错误详情", endorseErr.Err(), codes.Aborted},
+		{"进程内错误", fmt.Errorf("chaincode response 500, %s", coded.Error()), codes.Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded := chaincodeError(tt.err)
+			var ccErr *ChaincodeError
+			if !errors.As(decoded, &ccErr) {
+				t.Fatalf("错误 %v 未解码为 *ChaincodeError", decoded)
+			}
+			if ccErr.Code != model.CodeNotFound || ccErr.Key != "record.not_found" || ccErr.Details["record"] != "Evaluation-eval_001" {
+				t.Errorf("解码结果 = %+v", ccErr.CodedError)
+			}
+			if decoded.Error() != coded.Message {
+				t.Errorf("错误信息 = %q，期望 %q", decoded.Error(), coded.Message)
+			}
+			if !errors.Is(decoded, model.CodeNotFound) || errors.Is(decoded, model.CodeForbidden) {
+				t.Error("errors.Is 应只匹配链码返回的错误码")
+			}
+			if !errors.Is(fmt.Errorf("读取失败: %w", decoded), model.CodeNotFound) {
+				t.Error("再次包装后 errors.Is 仍应匹配错误码")
+			}
+			// 原始错误保留在错误链中
+			if !errors.Is(decoded, tt.err) || status.Code(decoded) != tt.code {
+				t.Errorf("原始错误 = %v (%s)，期望 %v (%s)", errors.Unwrap(decoded), status.Code(decoded), tt.err, tt.code)
+			}
+			if again := chaincodeError(decoded); again != decoded {
+				t.Error("已解码的错误应原样返回")
+			}
+		})
+	}
+
+	for _, err := range []error{nil, status.Error(codes.Unavailable, "connection refused"), errors.New("chaincode response 500, 找不到指定测评记录")} {
+		if got := chaincodeError(err); got != err {
+			t.Errorf("非结构化错误 %v 应原样返回，得到 %v", err, got)
+		}
+	}
+}
+
+func TestChaincodeErrorLanguage(t *testing.T) {
+	tests := []struct {
+		name   string
+		client []ClientOption
+		call   []CallOption
+		want   string
+	}{
+		{"默认中文", nil, nil, "找不到指定测评记录"},
+		{"客户端配置英文", []ClientOption{WithLanguage(model.LangEN)}, nil, "evaluation not found"},
+		{"单次调用覆盖", []ClientOption{WithLanguage(model.LangEN)}, []CallOption{Language(model.LangZH)}, "找不到指定测评记录"},
+		{"单次调用英文", nil, []CallOption{Language("en-US")}, "evaluation not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestEmbeddedClient(t, tt.client...)
+			_, err := c.GetEvaluationByIDWithContext(context.Background(), "eval_404", "user_001", tt.call...)
+			var ccErr *ChaincodeError
+			if !errors.As(err, &ccErr) {
+				t.Fatalf("错误 %v 不是 *ChaincodeError", err)
+			}
+			if ccErr.Message != tt.want || ccErr.Key != "evaluation.not_found" || !errors.Is(err, model.CodeNotFound) {
+				t.Errorf("错误 = %q (%s)，期望 %q", ccErr.Message, ccErr.Key, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import "time"
+
+// ===================== 客户端配置 =====================
+
+// PeerConfig 网关节点配置
+type PeerConfig struct {
+	Endpoint     string // 节点地址，如 localhost:7051
+	TLSCertPath  string // 节点TLS根证书路径
+	HostOverride string // TLS校验使用的主机名
+}
+
+// SelectionStrategy 网关节点选择策略
+type SelectionStrategy int
+
+const (
+	RoundRobin   SelectionStrategy = iota // 在健康节点间轮询
+	LeastLatency                          // 选择健康检查延迟最低的节点
+)
+
+// ClientOption 客户端可选配置
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	peers               []PeerConfig
+	strategy            SelectionStrategy
+	healthCheckInterval time.Duration
+	healthCheckTimeout  time.Duration
+	wallet              Wallet
+	signer              Signer
+	offline             bool
+	cacheTTL            time.Duration
+	keys                KeyService
+	envelope            *fieldEncryption
+	language            string
+
+	credentialReloadInterval time.Duration
+}
+
+func defaultClientOptions() *clientOptions {
+	return &clientOptions{
+		peers: []PeerConfig{
+			{Endpoint: peerEndpoint, TLSCertPath: tlsCertPath, HostOverride: "peer0.org1.example.com"},
+		},
+		strategy:            RoundRobin,
+		healthCheckInterval: 10 * time.Second,
+		healthCheckTimeout:  3 * time.Second,
+		keys:                newKeyServiceFromEnv(),
+		envelope:            newKeyRingFromEnv(),
+
+		credentialReloadInterval: 30 * time.Second,
+	}
+}
+
+// WithPeers 指定网关节点列表，替换默认节点
+func WithPeers(peers ...PeerConfig) ClientOption {
+	return func(o *clientOptions) {
+		o.peers = peers
+	}
+}
+
+// WithSelectionStrategy 指定网关节点选择策略
+func WithSelectionStrategy(strategy SelectionStrategy) ClientOption {
+	return func(o *clientOptions) {
+		o.strategy = strategy
+	}
+}
+
+// WithWallet 指定身份钱包，用于 Client.As 按用户切换签名身份
+func WithWallet(wallet Wallet) ClientOption {
+	return func(o *clientOptions) {
+		o.wallet = wallet
+	}
+}
+
+// WithSigner 指定默认身份的签名器，替代从 keyPath 读取本地私钥
+func WithSigner(signer Signer) ClientOption {
+	return func(o *clientOptions) {
+		o.signer = signer
+	}
+}
+
+// WithOfflineSigning 启用离线签名模式：客户端不持有私钥，
+// 只能通过 PrepareProposal 等离线流程提交交易
+func WithOfflineSigning() ClientOption {
+	return func(o *clientOptions) {
+		o.offline = true
+	}
+}
+
+// WithReadCache 启用按用户的查询缓存，条目在 ttl 到期或收到对应链码事件时失效
+func WithReadCache(ttl time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.cacheTTL = ttl
+	}
+}
+
+// WithKeyService 指定保存学生密钥的密钥服务，替代 EDU_KEY_SERVICE 的配置
+func WithKeyService(keys KeyService) ClientOption {
+	return func(o *clientOptions) {
+		o.keys = keys
+	}
+}
+
+// WithFieldEncryption 在客户端加密测评反馈和评价内容，替代 EDU_KEY_RING 的配置
+// scope 决定记录使用的密钥范围，为 nil 时按学生划分（StudentScope）
+func WithFieldEncryption(ring KeyRing, scope KeyScope) ClientOption {
+	return func(o *clientOptions) {
+		if scope == nil {
+			scope = StudentScope
+		}
+		o.envelope = &fieldEncryption{ring: ring, scope: scope}
+	}
+}
+
+// WithLanguage 指定链码错误信息的语言（zh/en），单次调用可用 Language 覆盖
+func WithLanguage(lang string) ClientOption {
+	return func(o *clientOptions) {
+		o.language = lang
+	}
+}
+
+// WithHealthCheck 指定健康检查间隔和单次检查超时
+func WithHealthCheck(interval, timeout time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.healthCheckInterval = interval
+		o.healthCheckTimeout = timeout
+	}
+}
+
+// WithCredentialReload 指定检查身份证书、私钥和TLS根证书是否更新的间隔，0 表示不检查
+func WithCredentialReload(interval time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.credentialReloadInterval = interval
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// ===================== 命令行子命令 =====================
+
+// command 子命令定义
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"sign-offline": {usage: "sign-offline -key <私钥PEM文件> <离线请求文件>...", run: runSignOffline},
+	"indexer":      {usage: "indexer [-db <数据库文件>] [-min-count N] run|rebuild|query <SQL>|judgements <对象ID>|teachers [<教师ID>]", run: runIndexer},
+	"ops":          {usage: "ops [-addr <监听地址>]", run: runOps},
+	"import":       {usage: "import [-kind TestResult|Evaluation] [-map 字段=表头,...] [-sheet <工作表>] [-dry-run [-no-check]] [-workers N] [-out <结果文件>] <CSV/XLSX文件>", run: runImport},
+	"export":       {usage: "export [-dir <目录>] [-format csv|jsonl|parquet] [-types <类型,...>] [-user <用户ID>] [-paper <试卷编号>] [-course <课程ID> -courses <文件>] [-from <时间>] [-to <时间>] [-page-size N] [-retries N]", run: runExport},
+	"bench":        {usage: "bench [-c N] [-n N | -d <时长>] [-rate <每秒交易数>] [-mix 类型=权重,...] [-users N] [-timeout <时长>] [-out <结果JSON>]", run: runBenchmark},
+	"api":          {usage: "api [-addr <监听地址>] [-allow <交易,...>]", run: runAPI},
+	"report":       {usage: "report -user <用户ID> [-term <学期>] [-from <时间>] [-to <时间>] [-db <索引数据库>] [-format html|pdf|both] [-template <HTML模板>] [-font <TTF字体>] [-out <文件名>] [-verify-url <URL>]", run: runReport},
+	"notify":       {usage: "notify [-store <订阅文件>] run [-smtp <地址>] [-workers N] [-attempts N] [-dead-letter <文件>] | subscribe -user <用户ID>|-role <角色> [-types ...] [-actions ...] [-objection-only] -webhook <URL> [-secret <密钥>]|-email <邮箱> | unsubscribe <订阅ID> | list", run: runNotify},
+	"transcript":   {usage: "transcript -user <用户ID> [-db <索引文件>] [-cert <证书>] [-key <私钥>|-sign-url <URL> -key-id <ID>] [-msp <MSP ID>] [-out <文件>]（接收方用 model/cmd/transcriptverify 验证）", run: runTranscript},
+	"grant":        {usage: "grant [-wallet <钱包目录>] [-as <学生用户ID>] issue -to <MSP ID>/<证书CN> [-types <类型,...>] [-records <类型-ID,...>] [-expires <时间|时长>] | revoke <授权ID> | list <用户ID> | log <用户ID>", run: runGrant},
+	"erase":        {usage: "erase [-wallet <钱包目录>] [-as <用户ID>] <学生用户ID>（学生密钥服务由 EDU_KEY_SERVICE 指定）", run: runErase},
+	"keys":         {usage: "keys [-batch <每批条数>] rotate|reencrypt <密钥范围>（主密钥服务由 EDU_KEY_RING 指定）", run: runKeys},
+}
+
+// runCommand 执行子命令，未知命令时打印用法
+func runCommand(name string, args []string) error {
+	cmd, ok := commands[name]
+	if !ok {
+		printUsage()
+		return fmt.Errorf("未知命令 %s", name)
+	}
+	return cmd.run(args)
+}
+
+func printUsage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "用法:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+}
+
+// runSignOffline 在持有私钥的机器上为导出的离线请求签名，结果写回原文件
+// 签名前打印解析出的请求内容供核对，摘要与内容不一致的请求拒绝签名
+func runSignOffline(args []string) error {
+	flags := flag.NewFlagSet("sign-offline", flag.ExitOnError)
+	keyFile := flags.String("key", "", "PEM格式私钥文件")
+	flags.Parse(args)
+	if *keyFile == "" || flags.NArg() == 0 {
+		return fmt.Errorf("缺少 -key 参数或离线请求文件")
+	}
+
+	keyPEM, err := ioutil.ReadFile(*keyFile)
+	if err != nil {
+		return fmt.Errorf("读取私钥文件失败: %v", err)
+	}
+	signer, err := NewLocalKeySigner(keyPEM)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range flags.Args() {
+		request, err := ReadOfflineRequest(path)
+		if err != nil {
+			return err
+		}
+		summary, err := VerifyOfflineRequest(request)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		fmt.Printf("%s: %s\n", path, summary)
+		if err := SignOfflineRequest(request, signer); err != nil {
+			return err
+		}
+		if err := WriteOfflineRequest(path, request); err != nil {
+			return err
+		}
+		fmt.Printf("已签名 %s（%s 阶段，交易 %s）\n", path, request.Stage, request.TransactionID)
+	}
+	return nil
+}
@@ -0,0 +1,674 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"edu/model"
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go-apiv2/msp"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ===================== 进程内链码 =====================
+
+// NewEmbeddedClient 创建不连接网络的客户端，调用直接分发给进程内的链码，用于集成测试
+// cc 通常为 edu/chaincode/atcc.NewChaincode()，链码需使用 fabric-contract-api-go/v2，
+// 与网关客户端共用 fabric-protos-go-apiv2，否则两套 protobuf 定义在同一进程中注册冲突
+// 节点、证书热更新和离线签名相关配置在此模式下不生效
+func NewEmbeddedClient(cc shim.Chaincode, opts ...ClientOption) (*Client, error) {
+	options := defaultClientOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	id, err := newEmbeddedIdentity()
+	if err != nil {
+		return nil, err
+	}
+	defaultIdentity := &gatewayIdentity{id: id}
+	pool := &peerPool{options: options, identity: defaultIdentity, stop: make(chan struct{})}
+
+	c := &Client{
+		pool:     pool,
+		identity: defaultIdentity,
+		wallet:   options.wallet,
+		keys:     options.keys,
+		envelope: options.envelope,
+		language: options.language,
+		life:     newLifecycle(),
+		embedded: newEmbeddedChannel(cc),
+	}
+	c.metrics = newClientMetrics(c)
+	if options.cacheTTL > 0 {
+		c.cache = newReadCache(options.cacheTTL)
+		c.cache.start(c)
+	}
+	return c, nil
+}
+
+// newEmbeddedIdentity 生成自签名证书作为默认身份，contractapi 会解析调用者证书
+// 证书带 edu.role=admin 属性，与网络中导入导出等服务使用的学校管理员身份权限相同
+func newEmbeddedIdentity() (*identity.X509Identity, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("生成进程内身份失败: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "embedded", OrganizationalUnit: []string{"client"}},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtraExtensions: []pkix.Extension{
+			{Id: model.AttrsExtensionOID, Value: []byte(`{"attrs":{"` + model.RoleAttribute + `":"` + model.RoleAdmin + `"}}`)},
+		},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("生成进程内身份失败: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return identity.NewX509Identity(mspID, cert)
+}
+
+// keyVersion 键的版本，即最后写入它的交易所在位置
+type keyVersion struct {
+	block uint64
+	tx    uint64
+}
+
+type versionedValue struct {
+	value   []byte
+	version keyVersion
+}
+
+// embeddedChannel 进程内模拟的通道，每笔交易单独出块
+// 背书时在已提交状态上模拟执行并记录读写集，提交时做 MVCC 检查，有效交易的写集和链码事件才会生效
+type embeddedChannel struct {
+	chaincode shim.Chaincode
+
+	mu     sync.RWMutex
+	state  map[string]versionedValue
+	height uint64
+	events []*client.ChaincodeEvent
+	notify chan struct{} // 出块时关闭并替换，唤醒事件订阅
+}
+
+func newEmbeddedChannel(cc shim.Chaincode) *embeddedChannel {
+	return &embeddedChannel{
+		chaincode: cc,
+		state:     make(map[string]versionedValue),
+		notify:    make(chan struct{}),
+	}
+}
+
+// embeddedTx 模拟执行的结果
+type embeddedTx struct {
+	id        string
+	timestamp time.Time
+	reads     map[string]*keyVersion // nil 表示读取时键不存在
+	writes    map[string][]byte      // nil 表示删除
+	event     *client.ChaincodeEvent
+}
+
+// simulate 以 gid 的身份模拟执行一次链码调用，持有读锁，期间不会出块
+func (ch *embeddedChannel) simulate(ctx context.Context, gid *gatewayIdentity, txName string, args []string) (*embeddedTx, *peer.Response, error) {
+	// 与网关调用相同，调用方取消或超时后不再执行
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	id, _ := gid.credentials()
+	creator, err := proto.Marshal(&msp.SerializedIdentity{Mspid: id.MspID(), IdBytes: id.Credentials()})
+	if err != nil {
+		return nil, nil, fmt.Errorf("序列化调用者身份失败: %v", err)
+	}
+	// 与 Fabric 相同，交易ID为随机数与调用者身份的哈希
+	nonce := make([]byte, 24)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	txID := sha256.Sum256(append(nonce, creator...))
+
+	stubArgs := [][]byte{[]byte(txName)}
+	for _, arg := range args {
+		stubArgs = append(stubArgs, []byte(arg))
+	}
+	stub := &embeddedStub{
+		channel:   ch,
+		txID:      hex.EncodeToString(txID[:]),
+		args:      stubArgs,
+		transient: proposalTransient(ctx),
+		creator:   creator,
+		timestamp: timestamppb.Now(),
+		tx: &embeddedTx{
+			reads:  make(map[string]*keyVersion),
+			writes: make(map[string][]byte),
+		},
+	}
+	stub.tx.id, stub.tx.timestamp = stub.txID, stub.timestamp.AsTime()
+
+	ch.mu.RLock()
+	response := ch.chaincode.Invoke(stub)
+	ch.mu.RUnlock()
+	return stub.tx, response, nil
+}
+
+// evaluate 模拟执行并返回结果，不提交
+func (ch *embeddedChannel) evaluate(ctx context.Context, gid *gatewayIdentity, txName string, args []string) ([]byte, error) {
+	ctx, span := startPhase(ctx, "evaluate", attribute.String("fabric.peer", "embedded"))
+	_, response, err := ch.simulate(ctx, gid, txName, args)
+	if err == nil && response.GetStatus() >= shim.ERRORTHRESHOLD {
+		err = status.Errorf(codes.Unknown, "evaluate call to endorser returned error: chaincode response %d, %s", response.GetStatus(), response.GetMessage())
+	}
+	endSpan(span, err)
+	if err != nil {
+		return nil, chaincodeError(err)
+	}
+	return response.GetPayload(), nil
+}
+
+// endorseAndSubmit 背书并提交，提交在进程内同步完成，返回时提交状态已确定
+func (ch *embeddedChannel) endorseAndSubmit(ctx context.Context, gid *gatewayIdentity, txName string, args []string) (submittedTx, []byte, error) {
+	endorseCtx, span := startPhase(ctx, "endorse", attribute.String("fabric.peer", "embedded"))
+	tx, response, err := ch.simulate(endorseCtx, gid, txName, args)
+	if err == nil && response.GetStatus() >= shim.ERRORTHRESHOLD {
+		err = status.Errorf(codes.Aborted, "failed to endorse transaction: chaincode response %d, %s", response.GetStatus(), response.GetMessage())
+	}
+	endSpan(span, err)
+	if err != nil {
+		return nil, nil, fmt.Errorf("提交交易失败: %w", chaincodeError(err))
+	}
+
+	_, span = startPhase(ctx, "submit", attribute.String("fabric.tx_id", tx.id))
+	if err := ctx.Err(); err != nil {
+		endSpan(span, err)
+		return nil, nil, fmt.Errorf("提交交易失败: %w", err)
+	}
+	result := ch.commit(tx)
+	endSpan(span, nil)
+	return &embeddedCommit{status: result, timestamp: tx.timestamp}, response.GetPayload(), nil
+}
+
+// commit 校验读集版本后写入新区块
+func (ch *embeddedChannel) commit(tx *embeddedTx) *client.Status {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	result := &client.Status{
+		Code:          peer.TxValidationCode_VALID,
+		Successful:    true,
+		TransactionID: tx.id,
+		BlockNumber:   ch.height,
+	}
+	for key, read := range tx.reads {
+		current, exists := ch.state[key]
+		if (read == nil) == exists || (read != nil && *read != current.version) {
+			result.Code, result.Successful = peer.TxValidationCode_MVCC_READ_CONFLICT, false
+			break
+		}
+	}
+
+	if result.Successful {
+		version := keyVersion{block: ch.height}
+		for key, value := range tx.writes {
+			if value == nil {
+				delete(ch.state, key)
+			} else {
+				ch.state[key] = versionedValue{value: value, version: version}
+			}
+		}
+		if tx.event != nil {
+			tx.event.BlockNumber = ch.height
+			ch.events = append(ch.events, tx.event)
+		}
+	}
+	ch.height++
+	close(ch.notify)
+	ch.notify = make(chan struct{})
+	return result
+}
+
+// chaincodeEvents 从 startBlock（未指定时为当前高度）开始推送有效交易的链码事件，ctx 取消时关闭通道
+func (ch *embeddedChannel) chaincodeEvents(ctx context.Context, startBlock ...uint64) <-chan *client.ChaincodeEvent {
+	ch.mu.RLock()
+	start := ch.height
+	ch.mu.RUnlock()
+	if len(startBlock) > 0 {
+		start = startBlock[0]
+	}
+
+	events := make(chan *client.ChaincodeEvent)
+	go func() {
+		defer close(events)
+		next := 0
+		for {
+			ch.mu.RLock()
+			pending := ch.events[next:]
+			notify := ch.notify
+			ch.mu.RUnlock()
+			next += len(pending)
+
+			for _, event := range pending {
+				if event.BlockNumber < start {
+					continue
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-notify:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+func (ch *embeddedChannel) chainHeight() uint64 {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+	return ch.height
+}
+
+// embeddedCommit 进程内提交的交易，提交状态在创建时已确定
+type embeddedCommit struct {
+	status    *client.Status
+	timestamp time.Time
+}
+
+func (e *embeddedCommit) TransactionID() string {
+	return e.status.TransactionID
+}
+
+func (e *embeddedCommit) StatusWithContext(ctx context.Context, opts ...grpc.CallOption) (*client.Status, error) {
+	return e.status, nil
+}
+
+func (e *embeddedCommit) TxTimestamp() time.Time {
+	return e.timestamp
+}
+
+// ===================== 模拟链码桩 =====================
+
+// 组合键分隔符，与 shim 的实现一致
+const (
+	compositeKeyNamespace = "\x00"
+	maxUnicodeRune        = string(utf8.MaxRune)
+)
+
+// embeddedStub 模拟 shim.ChaincodeStubInterface，读取已提交状态并记录读写集
+// 私有数据、跨链码调用等未模拟的方法调用时会 panic（由嵌入的 nil 接口引发）
+type embeddedStub struct {
+	shim.ChaincodeStubInterface
+
+	channel   *embeddedChannel
+	txID      string
+	args      [][]byte
+	transient map[string][]byte
+	creator   []byte
+	timestamp *timestamppb.Timestamp
+	tx        *embeddedTx
+}
+
+func (s *embeddedStub) GetArgs() [][]byte {
+	return s.args
+}
+
+func (s *embeddedStub) GetStringArgs() []string {
+	args := make([]string, len(s.args))
+	for i, arg := range s.args {
+		args[i] = string(arg)
+	}
+	return args
+}
+
+func (s *embeddedStub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	if len(args) == 0 {
+		return "", nil
+	}
+	return args[0], args[1:]
+}
+
+func (s *embeddedStub) GetTxID() string {
+	return s.txID
+}
+
+func (s *embeddedStub) GetChannelID() string {
+	return channelName
+}
+
+func (s *embeddedStub) GetCreator() ([]byte, error) {
+	return s.creator, nil
+}
+
+func (s *embeddedStub) GetTransient() (map[string][]byte, error) {
+	return s.transient, nil
+}
+
+func (s *embeddedStub) GetTxTimestamp() (*timestamppb.Timestamp, error) {
+	return s.timestamp, nil
+}
+
+// GetState 与 Fabric 相同，读取的是已提交状态，看不到本交易自己的写入
+func (s *embeddedStub) GetState(key string) ([]byte, error) {
+	current, exists := s.channel.state[key]
+	s.recordRead(key, current, exists)
+	if !exists {
+		return nil, nil
+	}
+	return current.value, nil
+}
+
+func (s *embeddedStub) recordRead(key string, current versionedValue, exists bool) {
+	if _, read := s.tx.reads[key]; read {
+		return
+	}
+	if !exists {
+		s.tx.reads[key] = nil
+		return
+	}
+	version := current.version
+	s.tx.reads[key] = &version
+}
+
+func (s *embeddedStub) PutState(key string, value []byte) error {
+	if key == "" {
+		return fmt.Errorf("key must not be an empty string")
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	s.tx.writes[key] = value
+	return nil
+}
+
+func (s *embeddedStub) DelState(key string) error {
+	if key == "" {
+		return fmt.Errorf("key must not be an empty string")
+	}
+	s.tx.writes[key] = nil
+	return nil
+}
+
+func (s *embeddedStub) SetEvent(name string, payload []byte) error {
+	if name == "" {
+		return fmt.Errorf("event name can not be empty string")
+	}
+	s.tx.event = &client.ChaincodeEvent{
+		TransactionID: s.txID,
+		ChaincodeName: chaincodeID,
+		EventName:     name,
+		Payload:       payload,
+	}
+	return nil
+}
+
+func (s *embeddedStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	for _, part := range append([]string{objectType}, attributes...) {
+		if !utf8.ValidString(part) || strings.Contains(part, compositeKeyNamespace) || strings.Contains(part, maxUnicodeRune) {
+			return "", fmt.Errorf("组合键包含非法字符: %q", part)
+		}
+	}
+	return compositeKeyNamespace + objectType + compositeKeyNamespace + strings.Join(append(attributes, ""), compositeKeyNamespace), nil
+}
+
+func (s *embeddedStub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	parts := strings.Split(strings.TrimPrefix(compositeKey, compositeKeyNamespace), compositeKeyNamespace)
+	if len(parts) < 2 {
+		return "", nil, fmt.Errorf("不是组合键: %q", compositeKey)
+	}
+	return parts[0], parts[1 : len(parts)-1], nil
+}
+
+// GetStateByRange 返回 [startKey, endKey) 内的普通键，空串表示不限
+// 返回的键计入读集，但不做 Fabric 的幻读检查
+func (s *embeddedStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	iterator, _, err := s.GetStateByRangeWithPagination(startKey, endKey, 0, "")
+	return iterator, err
+}
+
+func (s *embeddedStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	return s.rangeQuery(startKey, endKey, false, pageSize, bookmark)
+}
+
+func (s *embeddedStub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	startKey, err := s.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+	iterator, _, err := s.rangeQuery(startKey, startKey+maxUnicodeRune, true, 0, "")
+	return iterator, err
+}
+
+func (s *embeddedStub) rangeQuery(startKey, endKey string, composite bool, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	if bookmark != "" {
+		startKey = bookmark
+	}
+	var kvs []*queryresult.KV
+	for _, key := range s.channel.sortedKeys() {
+		if strings.HasPrefix(key, compositeKeyNamespace) != composite {
+			continue
+		}
+		if key < startKey || (bookmark != "" && key == bookmark) || (endKey != "" && key >= endKey) {
+			continue
+		}
+		current := s.channel.state[key]
+		s.recordRead(key, current, true)
+		kvs = append(kvs, &queryresult.KV{Namespace: chaincodeID, Key: key, Value: current.value})
+		if pageSize > 0 && int32(len(kvs)) == pageSize {
+			break
+		}
+	}
+	return &embeddedIterator{kvs: kvs}, pageMetadata(kvs, bookmark), nil
+}
+
+// GetQueryResult 在内存中执行 CouchDB 查询，支持 selector 的相等匹配和
+// $eq/$ne/$gt/$gte/$lt/$lte/$in/$exists 操作符，结果按键排序
+// 与 Fabric 相同，富查询的结果不计入读集
+func (s *embeddedStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	iterator, _, err := s.GetQueryResultWithPagination(query, 0, "")
+	return iterator, err
+}
+
+func (s *embeddedStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	var parsed map[string]interface{}
+	if err := json.Unmarshal([]byte(query), &parsed); err != nil {
+		return nil, nil, fmt.Errorf("解析查询失败: %v", err)
+	}
+	selector, _ := parsed["selector"].(map[string]interface{})
+	if selector == nil {
+		return nil, nil, fmt.Errorf("查询缺少 selector")
+	}
+	for field := range parsed {
+		if field != "selector" && field != "use_index" {
+			return nil, nil, fmt.Errorf("进程内模式不支持查询字段 %s", field)
+		}
+	}
+
+	var kvs []*queryresult.KV
+	for _, key := range s.channel.sortedKeys() {
+		if bookmark != "" && key <= bookmark {
+			continue
+		}
+		value := s.channel.state[key].value
+		var doc map[string]interface{}
+		if json.Unmarshal(value, &doc) != nil {
+			continue
+		}
+		matched, err := matchSelector(doc, selector)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !matched {
+			continue
+		}
+		kvs = append(kvs, &queryresult.KV{Namespace: chaincodeID, Key: key, Value: value})
+		if pageSize > 0 && int32(len(kvs)) == pageSize {
+			break
+		}
+	}
+	return &embeddedIterator{kvs: kvs}, pageMetadata(kvs, bookmark), nil
+}
+
+func (s *embeddedStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	return nil, fmt.Errorf("进程内模式不支持历史查询")
+}
+
+// sortedKeys 调用方需持有读锁（模拟执行期间始终持有）
+func (ch *embeddedChannel) sortedKeys() []string {
+	keys := make([]string, 0, len(ch.state))
+	for key := range ch.state {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// pageMetadata 书签为本页最后一个键，没有结果时沿用传入的书签
+func pageMetadata(kvs []*queryresult.KV, bookmark string) *peer.QueryResponseMetadata {
+	if len(kvs) > 0 {
+		bookmark = kvs[len(kvs)-1].Key
+	}
+	return &peer.QueryResponseMetadata{FetchedRecordsCount: int32(len(kvs)), Bookmark: bookmark}
+}
+
+// matchSelector 判断文档是否满足 selector 中的所有字段条件
+func matchSelector(doc map[string]interface{}, selector map[string]interface{}) (bool, error) {
+	for field, condition := range selector {
+		value, present := doc[field]
+		operators, ok := condition.(map[string]interface{})
+		if !ok {
+			if !present || !reflect.DeepEqual(value, condition) {
+				return false, nil
+			}
+			continue
+		}
+		for op, operand := range operators {
+			matched, err := matchOperator(op, value, present, operand)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+func matchOperator(op string, value interface{}, present bool, operand interface{}) (bool, error) {
+	switch op {
+	case "$exists":
+		want, _ := operand.(bool)
+		return present == want, nil
+	case "$eq":
+		return present && reflect.DeepEqual(value, operand), nil
+	case "$ne":
+		return present && !reflect.DeepEqual(value, operand), nil
+	case "$in":
+		candidates, _ := operand.([]interface{})
+		for _, candidate := range candidates {
+			if present && reflect.DeepEqual(value, candidate) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$gt", "$gte", "$lt", "$lte":
+		if !present {
+			return false, nil
+		}
+		cmp, ok := compareJSON(value, operand)
+		if !ok {
+			return false, nil
+		}
+		switch op {
+		case "$gt":
+			return cmp > 0, nil
+		case "$gte":
+			return cmp >= 0, nil
+		case "$lt":
+			return cmp < 0, nil
+		default:
+			return cmp <= 0, nil
+		}
+	}
+	return false, fmt.Errorf("进程内模式不支持查询操作符 %s", op)
+}
+
+// compareJSON 比较同类型的字符串或数字，类型不同时不可比较
+func compareJSON(a, b interface{}) (int, bool) {
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	case float64:
+		y, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// embeddedIterator 已物化的查询结果
+type embeddedIterator struct {
+	kvs  []*queryresult.KV
+	next int
+}
+
+func (it *embeddedIterator) HasNext() bool {
+	return it.next < len(it.kvs)
+}
+
+func (it *embeddedIterator) Next() (*queryresult.KV, error) {
+	if !it.HasNext() {
+		return nil, fmt.Errorf("没有更多结果")
+	}
+	kv := it.kvs[it.next]
+	it.next++
+	return kv, nil
+}
+
+func (it *embeddedIterator) Close() error {
+	return nil
+}
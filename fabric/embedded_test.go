@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"edu/chaincode/atcc"
+	"edu/model"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+)
+
+// newTestEmbeddedClient 创建运行 edu/chaincode 链码的进程内客户端，学生密钥保存在临时目录中
+func newTestEmbeddedClient(t *testing.T, opts ...ClientOption) *Client {
+	t.Helper()
+	t.Setenv(keyRingEnv, "")
+	cc, err := atcc.NewChaincode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts = append([]ClientOption{WithKeyService(NewFileKeyService(filepath.Join(t.TempDir(), "keys.json")))}, opts...)
+	c, err := NewEmbeddedClient(cc, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func testEvaluation(id, userID string) Evaluation {
+	return Evaluation{EvaluationID: id, UserID: userID, PointsDegree: "A", Feedback: "课堂表现积极，作业完成认真"}
+}
+
+func TestEmbeddedUploadAndRead(t *testing.T) {
+	c := newTestEmbeddedClient(t)
+	evaluation := testEvaluation("eval_001", "user_001")
+
+	receipt, err := c.UploadEvaluation(evaluation)
+	if err != nil {
+		t.Fatalf("上传测评记录失败: %v", err)
+	}
+	if !receipt.Successful || receipt.Status != peer.TxValidationCode_VALID.String() || receipt.TransactionID == "" {
+		t.Fatalf("回执 = %+v，期望验证通过", receipt)
+	}
+
+	got, err := c.GetEvaluationByID("eval_001", "user_001")
+	if err != nil {
+		t.Fatalf("查询测评记录失败: %v", err)
+	}
+	if got.Feedback != evaluation.Feedback || got.PointsDegree != evaluation.PointsDegree || got.DocType != model.DocTypeEvaluation {
+		t.Errorf("查询结果 = %+v，期望 %+v", got, evaluation)
+	}
+
+	list, err := c.GetEvaluationByUser("user_001")
+	if err != nil {
+		t.Fatalf("按用户查询失败: %v", err)
+	}
+	if len(list) != 1 || list[0].EvaluationID != "eval_001" {
+		t.Errorf("按用户查询结果 = %+v，期望一条 eval_001", list)
+	}
+
+	if _, err := c.UploadEvaluation(evaluation); !errors.Is(err, model.CodeDuplicate) {
+		t.Errorf("重复上传的错误 = %v，期望 DUPLICATE", err)
+	}
+	if _, err := c.GetEvaluationByID("eval_404", "user_001"); !errors.Is(err, model.CodeNotFound) {
+		t.Errorf("查询不存在记录的错误 = %v，期望 NOT_FOUND", err)
+	}
+}
+
+func TestEmbeddedMVCCConflict(t *testing.T) {
+	c := newTestEmbeddedClient(t)
+	base := Evaluation{EvaluationID: "eval_001", UserID: "user_001", PointsDegree: "B"}
+	if _, err := c.UploadEvaluation(base); err != nil {
+		t.Fatal(err)
+	}
+
+	// 两笔修改都基于同一版本模拟执行，先提交的有效，后提交的读集已过期
+	ctx := context.Background()
+	modify := func(degree string) *embeddedTx {
+		e := base
+		e.PointsDegree = degree
+		data, _ := json.Marshal(e)
+		tx, response, err := c.embedded.simulate(ctx, c.identity, "ModifyEvaluation", []string{"eval_001", string(data)})
+		if err != nil || response.GetStatus() != 200 {
+			t.Fatalf("模拟执行失败: %v %s", err, response.GetMessage())
+		}
+		return tx
+	}
+	first, second := modify("A"), modify("C")
+
+	if status := c.embedded.commit(first); !status.Successful {
+		t.Fatalf("第一笔交易 = %v，期望有效", status.Code)
+	}
+	status := c.embedded.commit(second)
+	if status.Successful || status.Code != peer.TxValidationCode_MVCC_READ_CONFLICT {
+		t.Fatalf("第二笔交易 = %v，期望 MVCC_READ_CONFLICT", status.Code)
+	}
+	receipt, err := receiptFromCommit(ctx, &embeddedCommit{status: status})
+	var failed *CommitFailedError
+	if !errors.As(err, &failed) || receipt == nil || receipt.Successful {
+		t.Errorf("冲突交易的回执 = %+v, 错误 = %v，期望 CommitFailedError", receipt, err)
+	}
+
+	got, err := c.GetEvaluationByID("eval_001", "user_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PointsDegree != "A" {
+		t.Errorf("评分等级 = %s，期望只有第一笔修改生效", got.PointsDegree)
+	}
+}
+
+func TestEmbeddedChaincodeEvents(t *testing.T) {
+	c := newTestEmbeddedClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, err := c.ChaincodeEvents(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UploadEvaluation(testEvaluation("eval_001", "user_001")); err != nil {
+		t.Fatal(err)
+	}
+	// 背书失败的交易不出块，不产生事件
+	if _, err := c.UploadEvaluation(testEvaluation("eval_001", "user_001")); err == nil {
+		t.Fatal("重复上传应当失败")
+	}
+	if _, err := c.DeleteRecord("Evaluation", "eval_001"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []RecordEvent{
+		{DocType: model.DocTypeEvaluation, Action: "Create", RecordID: "eval_001", UserID: "user_001"},
+		{DocType: model.DocTypeEvaluation, Action: "Delete", RecordID: "eval_001", UserID: "user_001"},
+	} {
+		select {
+		case event := <-events:
+			var got RecordEvent
+			if err := json.Unmarshal(event.Payload, &got); err != nil {
+				t.Fatal(err)
+			}
+			if event.EventName != model.RecordEventName || event.ChaincodeName != chaincodeID || got != want {
+				t.Errorf("事件 = %s %+v，期望 %s %+v", event.EventName, got, model.RecordEventName, want)
+			}
+		case <-ctx.Done():
+			t.Fatalf("等待事件 %+v 超时", want)
+		}
+	}
+
+	// 从第一个区块重新订阅，可以收到历史事件
+	replay, err := c.ChaincodeEvents(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-replay:
+		if event.BlockNumber != 0 {
+			t.Errorf("重放的第一个事件在区块 %d，期望 0", event.BlockNumber)
+		}
+	case <-ctx.Done():
+		t.Fatal("等待重放事件超时")
+	}
+}
@@ -0,0 +1,809 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"edu/model"
+)
+
+// ===================== 字段信封加密 =====================
+// 测评反馈和评价内容在客户端加密后再提交，通道中的其他组织只能看到密文。
+// 每个字段值使用随机数据密钥加密，数据密钥由密钥范围（按学生或按课程）的主密钥封装，
+// 密文格式为 env:v1:<范围>#<版本>:<base64 封装的数据密钥>:<base64 nonce||密文>。
+// 测评反馈随后还会被链码以学生密钥再加密一层（见 recordKeys.go）。擦除学生数据时同时销毁 student:<用户ID>
+// 范围的全部主密钥，该学生的评价内容从此也无法解开；按课程划分的范围由多名学生共用，擦除时不会销毁，
+// 需要擦除学生数据的部署只能按学生划分范围
+
+// 主密钥的环境变量：http(s) 地址使用 HTTPKeyRing，其他值为 FileKeyRing 的文件路径；未设置时不加密
+const keyRingEnv = "EDU_KEY_RING"
+
+// 密钥范围名称的最大长度，保证密文不超过 model.EncryptedMaxLen
+const maxKeyScopeLen = 128
+
+// 每批重新加密的记录数，即每页导出的条数，上限与链码分页导出一致
+const (
+	defaultRotationBatchSize = 100
+	maxRotationBatchSize     = 1000
+)
+
+// ErrKeyForbidden 调用者无权读取该范围的主密钥，字段保持密文
+var ErrKeyForbidden = errors.New("无权读取该范围的密钥")
+
+// KeyRing 按范围保存信封加密的主密钥，每个范围有递增的版本，新写入使用当前版本
+// 读取旧版本用于解密轮换前写入的记录；是否有权读取某个范围由密钥服务按调用者判断
+type KeyRing interface {
+	// CurrentKey 返回范围的当前版本和主密钥，范围不存在时创建版本 1
+	CurrentKey(ctx context.Context, scope string) (int, []byte, error)
+	// KeyVersion 返回范围指定版本的主密钥，无权读取时返回 ErrKeyForbidden
+	KeyVersion(ctx context.Context, scope string, version int) ([]byte, error)
+	// Rotate 为范围生成新版本的主密钥并设为当前版本，返回新版本号
+	Rotate(ctx context.Context, scope string) (int, error)
+	// Destroy 销毁范围的全部版本，之后读取和轮换都返回 ErrKeyDestroyed；范围不存在时同样留下销毁标记
+	Destroy(ctx context.Context, scope string) error
+}
+
+// KeyScope 返回记录个人内容使用的密钥范围，record 为 *Evaluation 或 *Judgement
+type KeyScope func(docType string, record interface{}) string
+
+// studentScopePrefix 按学生划分的范围前缀，擦除学生数据时销毁 student:<用户ID>
+const studentScopePrefix = "student:"
+
+// StudentScope 按记录所属学生划分密钥范围：student:<用户ID>
+func StudentScope(docType string, record interface{}) string {
+	switch r := record.(type) {
+	case *Evaluation:
+		return studentScopePrefix + r.UserID
+	case *Judgement:
+		return studentScopePrefix + r.UserID
+	}
+	return ""
+}
+
+// CourseScope 按课程划分密钥范围：course:<课程ID>
+// 账本记录中没有课程字段，由 courseOf 给出记录所属课程，返回空时按学生划分
+// 课程范围的主密钥由多名学生共用，擦除学生数据时不会销毁，该学生的记录仍可用课程密钥解开
+func CourseScope(courseOf func(docType string, record interface{}) string) KeyScope {
+	return func(docType string, record interface{}) string {
+		if course := courseOf(docType, record); course != "" {
+			return "course:" + course
+		}
+		return StudentScope(docType, record)
+	}
+}
+
+// fieldEncryption 客户端字段加密配置
+type fieldEncryption struct {
+	ring  KeyRing
+	scope KeyScope
+}
+
+// newKeyRingFromEnv 按 EDU_KEY_RING 创建字段加密配置，未设置时返回 nil，默认按学生划分范围
+func newKeyRingFromEnv() *fieldEncryption {
+	location := os.Getenv(keyRingEnv)
+	switch {
+	case location == "":
+		return nil
+	case strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://"):
+		return &fieldEncryption{ring: NewHTTPKeyRing(location), scope: StudentScope}
+	}
+	return &fieldEncryption{ring: NewFileKeyRing(location), scope: StudentScope}
+}
+
+// ===================== 密文格式 =====================
+
+// envelope 解析后的字段密文
+type envelope struct {
+	scope   string
+	version int
+	wrapped []byte // 主密钥封装的数据密钥
+	sealed  []byte // 数据密钥加密的字段值
+}
+
+// sealEnvelope 用新的数据密钥加密字段值，recordKey 和字段名作为附加数据，密文不能被挪到其他记录或字段中
+func sealEnvelope(kek []byte, scope string, version int, recordKey, field, plaintext string) (string, error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", fmt.Errorf("生成数据密钥失败: %v", err)
+	}
+	header := scope + "#" + strconv.Itoa(version)
+	wrapped, err := gcmSeal(kek, dek, []byte(header))
+	if err != nil {
+		return "", err
+	}
+	sealed, err := gcmSeal(dek, []byte(plaintext), []byte(recordKey+"|"+field))
+	if err != nil {
+		return "", err
+	}
+	return model.EnvelopePrefix + header + ":" +
+		base64.StdEncoding.EncodeToString(wrapped) + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// parseEnvelope 解析字段密文；范围名称中可以有冒号，从右侧切分
+func parseEnvelope(value string) (*envelope, error) {
+	body := strings.TrimPrefix(value, model.EnvelopePrefix)
+	parts := strings.Split(body, ":")
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("字段密文格式错误")
+	}
+	header := strings.Join(parts[:len(parts)-2], ":")
+	hash := strings.LastIndex(header, "#")
+	if hash <= 0 {
+		return nil, fmt.Errorf("字段密文格式错误")
+	}
+	env := &envelope{scope: header[:hash]}
+	var err error
+	if env.version, err = strconv.Atoi(header[hash+1:]); err != nil || env.version <= 0 {
+		return nil, fmt.Errorf("字段密文的密钥版本错误: %q", header[hash+1:])
+	}
+	if env.wrapped, err = base64.StdEncoding.DecodeString(parts[len(parts)-2]); err != nil {
+		return nil, fmt.Errorf("字段密文格式错误: %v", err)
+	}
+	if env.sealed, err = base64.StdEncoding.DecodeString(parts[len(parts)-1]); err != nil {
+		return nil, fmt.Errorf("字段密文格式错误: %v", err)
+	}
+	return env, nil
+}
+
+// open 用范围主密钥解开数据密钥，再解密字段值
+func (env *envelope) open(kek []byte, recordKey, field string) (string, error) {
+	dek, err := gcmOpen(kek, env.wrapped, []byte(env.scope+"#"+strconv.Itoa(env.version)))
+	if err != nil {
+		return "", fmt.Errorf("解开 %s 的 %s 的数据密钥失败，主密钥与 %s 版本 %d 不一致", recordKey, field, env.scope, env.version)
+	}
+	plaintext, err := gcmOpen(dek, env.sealed, []byte(recordKey+"|"+field))
+	if err != nil {
+		return "", fmt.Errorf("解密 %s 的 %s 失败，密文不属于该记录或已损坏", recordKey, field)
+	}
+	return string(plaintext), nil
+}
+
+// gcmSeal AES-GCM 加密，随机 nonce 放在密文前
+func gcmSeal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("生成随机数失败: %v", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func gcmOpen(key, sealed, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("密文长度错误")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("创建加密器失败: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// ===================== 写入时加密 =====================
+
+// sealArgs 加密写入交易中记录的个人内容，未配置字段加密或字段已是密文时原样提交
+// 明文在加密前校验，链码只能看到密文，无法再检查明文长度
+func (c *Client) sealArgs(ctx context.Context, txName string, args []string) ([]string, error) {
+	if c.envelope == nil {
+		return args, nil
+	}
+	index := 0
+	switch {
+	case (txName == "UploadEvaluation" || txName == "UploadJudgement") && len(args) == 1:
+	case txName == "ModifyEvaluation" && len(args) == 2:
+		index = 1
+	default:
+		return args, nil
+	}
+
+	var record interface{}
+	var field *string
+	var docType, recordKey, fieldName string
+	switch txName {
+	case "UploadEvaluation", "ModifyEvaluation":
+		var e Evaluation
+		if err := json.Unmarshal([]byte(args[index]), &e); err != nil {
+			return args, nil // 由链码返回解析错误
+		}
+		record, field = &e, &e.Feedback
+		docType, recordKey, fieldName = model.DocTypeEvaluation, "Evaluation-"+e.EvaluationID, "Feedback"
+	default:
+		var j Judgement
+		if err := json.Unmarshal([]byte(args[index]), &j); err != nil {
+			return args, nil
+		}
+		record, field = &j, &j.JudgementContent
+		docType, recordKey, fieldName = model.DocTypeJudgement, "Judgement-"+j.JudgementID, "Judgement_Content"
+	}
+	if *field == "" || strings.HasPrefix(*field, model.EnvelopePrefix) {
+		return args, nil
+	}
+	if err := model.Validate(record); err != nil {
+		return nil, fmt.Errorf("记录校验失败: %w", err)
+	}
+
+	scope := c.envelope.scope(docType, record)
+	if scope == "" || len(scope) > maxKeyScopeLen {
+		return nil, fmt.Errorf("%s 的密钥范围 %q 无效（不能为空，最多 %d 个字符）", recordKey, scope, maxKeyScopeLen)
+	}
+	version, kek, err := c.envelope.ring.CurrentKey(ctx, scope)
+	if err != nil {
+		return nil, fmt.Errorf("读取密钥范围 %s 的主密钥失败: %w", scope, err)
+	}
+	if *field, err = sealEnvelope(kek, scope, version, recordKey, fieldName, *field); err != nil {
+		return nil, err
+	}
+
+	data, err := marshalArg(ctx, record)
+	if err != nil {
+		return nil, fmt.Errorf("序列化记录失败: %v", err)
+	}
+	sealed := append([]string(nil), args...)
+	sealed[index] = data
+	return sealed, nil
+}
+
+// ===================== 读取时解密 =====================
+
+// openEnvelope 解密客户端加密的字段；未配置字段加密、无权读取该范围、范围密钥不存在或已销毁时保持密文
+func (o *recordOpener) openEnvelope(ctx context.Context, recordKey, field, value string) (string, error) {
+	if o.client.envelope == nil || !strings.HasPrefix(value, model.EnvelopePrefix) {
+		return value, nil
+	}
+	env, err := parseEnvelope(value)
+	if err != nil {
+		return "", fmt.Errorf("%s 的 %s: %v", recordKey, field, err)
+	}
+
+	id := env.scope + "#" + strconv.Itoa(env.version)
+	kek, ok := o.scopeKeys[id]
+	if !ok {
+		kek, err = o.client.envelope.ring.KeyVersion(ctx, env.scope, env.version)
+		switch {
+		case err == ErrKeyForbidden, err == ErrKeyNotFound, err == ErrKeyDestroyed:
+			kek = nil
+		case err != nil:
+			return "", fmt.Errorf("读取密钥范围 %s 版本 %d 的主密钥失败: %w", env.scope, env.version, err)
+		}
+		o.scopeKeys[id] = kek
+	}
+	if kek == nil {
+		return value, nil
+	}
+	return env.open(kek, recordKey, field)
+}
+
+func (o *recordOpener) judgement(ctx context.Context, j *Judgement) (err error) {
+	if o.client.sealed {
+		return nil
+	}
+	j.JudgementContent, err = o.openEnvelope(ctx, "Judgement-"+j.JudgementID, "Judgement_Content", j.JudgementContent)
+	return err
+}
+
+func (o *recordOpener) judgements(ctx context.Context, judgements []Judgement) error {
+	for i := range judgements {
+		if err := o.judgement(ctx, &judgements[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ===================== 密钥轮换 =====================
+
+// RotationReport 密钥轮换结果
+type RotationReport struct {
+	Scope       string   `json:"scope"`
+	Version     int      `json:"version"`          // 当前版本，重新加密后的记录都使用该版本
+	Scanned     int      `json:"scanned"`          // 检查的记录数
+	Reencrypted int      `json:"reencrypted"`      // 重新加密的记录数
+	Batches     int      `json:"batches"`          // 提交的批次数
+	Failed      []string `json:"failed,omitempty"` // 重新加密失败的记录及原因，可再次调用 ReencryptFieldKey 重试
+}
+
+// RotateFieldKey 生成范围的新版本主密钥，并把该范围内的记录重新加密到新版本
+// 调用者须为学校组织的管理员或教师（需要分页导出全部记录）；旧版本主密钥在全部记录重新加密前仍需保留
+func (c *Client) RotateFieldKey(ctx context.Context, scope string, batchSize int) (report *RotationReport, err error) {
+	ctx, end, err := c.startCall(ctx, "RotateFieldKey")
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	if c.envelope == nil {
+		return nil, fmt.Errorf("未配置字段加密（%s）", keyRingEnv)
+	}
+	version, err := c.envelope.ring.Rotate(ctx, scope)
+	if err != nil {
+		return nil, fmt.Errorf("轮换密钥范围 %s 失败: %w", scope, err)
+	}
+	log.Printf("密钥范围 %s 已轮换到版本 %d，开始重新加密", scope, version)
+	return c.reencrypt(ctx, scope, version, batchSize)
+}
+
+// ReencryptFieldKey 把范围内低于当前版本的记录重新加密到当前版本，用于轮换中断或部分失败后继续
+func (c *Client) ReencryptFieldKey(ctx context.Context, scope string, batchSize int) (report *RotationReport, err error) {
+	ctx, end, err := c.startCall(ctx, "ReencryptFieldKey")
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	if c.envelope == nil {
+		return nil, fmt.Errorf("未配置字段加密（%s）", keyRingEnv)
+	}
+	version, _, err := c.envelope.ring.CurrentKey(ctx, scope)
+	if err != nil {
+		return nil, fmt.Errorf("读取密钥范围 %s 的主密钥失败: %w", scope, err)
+	}
+	return c.reencrypt(ctx, scope, version, batchSize)
+}
+
+// reencryptItem 一条待重新加密的记录
+type reencryptItem struct {
+	recordKey string
+	txName    string
+	args      []string
+}
+
+// reencrypt 分页读取测评记录和评价记录，每页中需要重新加密的记录为一批：
+// 批内并发提交（测评记录通过 ModifyEvaluation，评价记录通过覆盖写入 UploadJudgement），全部提交完成后再读取下一页
+func (c *Client) reencrypt(ctx context.Context, scope string, version, batchSize int) (*RotationReport, error) {
+	if batchSize <= 0 || batchSize > maxRotationBatchSize {
+		batchSize = defaultRotationBatchSize
+	}
+	_, kek, err := c.envelope.ring.CurrentKey(ctx, scope)
+	if err != nil {
+		return nil, fmt.Errorf("读取密钥范围 %s 的主密钥失败: %w", scope, err)
+	}
+	report := &RotationReport{Scope: scope, Version: version}
+	opener := c.newRecordOpener()
+
+	// 按学生划分的范围只需导出该学生的记录
+	var filter ExportFilter
+	if userID := strings.TrimPrefix(scope, studentScopePrefix); userID != scope {
+		filter.UserID = userID
+	}
+	filterJSON, err := json.Marshal(filter)
+	if err != nil {
+		return nil, fmt.Errorf("序列化过滤条件失败: %v", err)
+	}
+
+	// rewrap 需要重新加密时返回新密文，不属于该范围或已是当前版本时返回空串
+	rewrap := func(recordKey, field, value string) (string, error) {
+		if !strings.HasPrefix(value, model.EnvelopePrefix) {
+			return "", nil
+		}
+		env, err := parseEnvelope(value)
+		if err != nil || env.scope != scope || env.version >= version {
+			return "", nil
+		}
+		plaintext, err := opener.openEnvelope(ctx, recordKey, field, value)
+		if err != nil {
+			return "", err
+		}
+		if plaintext == value {
+			return "", fmt.Errorf("无权读取密钥范围 %s 版本 %d", scope, env.version)
+		}
+		return sealEnvelope(kek, scope, version, recordKey, field, plaintext)
+	}
+
+	for _, docType := range []string{model.DocTypeEvaluation, model.DocTypeJudgement} {
+		bookmark := ""
+		for {
+			page, err := c.exportPage(ctx, docType, string(filterJSON), int32(batchSize), bookmark)
+			if err != nil {
+				return report, err
+			}
+			var batch []reencryptItem
+			for _, raw := range page.Records {
+				report.Scanned++
+				item, err := c.reencryptRecord(ctx, opener, docType, raw, rewrap)
+				if err != nil {
+					report.Failed = append(report.Failed, err.Error())
+					continue
+				}
+				if item != nil {
+					batch = append(batch, *item)
+				}
+			}
+			if len(batch) > 0 {
+				report.Batches++
+				c.submitReencryptBatch(ctx, batch, report)
+			}
+			if int32(len(page.Records)) < int32(batchSize) || page.Bookmark == "" {
+				break
+			}
+			bookmark = page.Bookmark
+		}
+	}
+	return report, nil
+}
+
+// reencryptRecord 为一条导出记录生成重新加密的写入交易，不需要重新加密时返回 nil
+func (c *Client) reencryptRecord(ctx context.Context, opener *recordOpener, docType, raw string, rewrap func(recordKey, field, value string) (string, error)) (*reencryptItem, error) {
+	switch docType {
+	case model.DocTypeEvaluation:
+		var e Evaluation
+		if err := json.Unmarshal([]byte(raw), &e); err != nil {
+			return nil, fmt.Errorf("解析测评记录失败: %v", err)
+		}
+		recordKey := "Evaluation-" + e.EvaluationID
+		// 外层为链码的学生密钥加密，先解开再检查信封密文
+		feedback, err := opener.openStudent(ctx, e.UserID, recordKey, "Feedback", e.Feedback)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", recordKey, err)
+		}
+		if e.Feedback, err = rewrap(recordKey, "Feedback", feedback); err != nil || e.Feedback == "" {
+			if err != nil {
+				err = fmt.Errorf("%s: %v", recordKey, err)
+			}
+			return nil, err
+		}
+		e.DocType, e.ErasedAt = "", ""
+		data, err := json.Marshal(e)
+		if err != nil {
+			return nil, fmt.Errorf("%s: 序列化失败: %v", recordKey, err)
+		}
+		return &reencryptItem{recordKey: recordKey, txName: "ModifyEvaluation", args: []string{e.EvaluationID, string(data)}}, nil
+
+	default:
+		var j Judgement
+		if err := json.Unmarshal([]byte(raw), &j); err != nil {
+			return nil, fmt.Errorf("解析评价记录失败: %v", err)
+		}
+		recordKey := "Judgement-" + j.JudgementID
+		content, err := rewrap(recordKey, "Judgement_Content", j.JudgementContent)
+		if err != nil || content == "" {
+			if err != nil {
+				err = fmt.Errorf("%s: %v", recordKey, err)
+			}
+			return nil, err
+		}
+		j.DocType, j.JudgementContent = "", content
+		data, err := json.Marshal(j)
+		if err != nil {
+			return nil, fmt.Errorf("%s: 序列化失败: %v", recordKey, err)
+		}
+		return &reencryptItem{recordKey: recordKey, txName: "UploadJudgement", args: []string{string(data)}}, nil
+	}
+}
+
+// submitReencryptBatch 并发提交一批重新加密的记录并等待全部完成
+func (c *Client) submitReencryptBatch(ctx context.Context, batch []reencryptItem, report *RotationReport) {
+	pending := make([]*PendingTx, len(batch))
+	for i, item := range batch {
+		tx, err := c.submitAsync(ctx, "ReencryptFieldKey", item.txName, item.args...)
+		if err != nil {
+			report.Failed = append(report.Failed, fmt.Sprintf("%s: %v", item.recordKey, err))
+			continue
+		}
+		pending[i] = tx
+	}
+	for i, tx := range pending {
+		if tx == nil {
+			continue
+		}
+		if _, err := tx.Await(ctx); err != nil {
+			report.Failed = append(report.Failed, fmt.Sprintf("%s: %v", batch[i].recordKey, err))
+			continue
+		}
+		report.Reencrypted++
+	}
+}
+
+// ===================== 文件主密钥 =====================
+
+// FileKeyRing 将各范围的主密钥保存在本地 JSON 文件中，能读取文件即可解密全部范围，只适合单机部署和测试
+type FileKeyRing struct {
+	path string
+	mu   sync.Mutex
+}
+
+type fileScopeKeys struct {
+	Current     int            `json:"current"`
+	Keys        map[int][]byte `json:"keys,omitempty"`
+	DestroyedAt string         `json:"destroyedAt,omitempty"`
+}
+
+func NewFileKeyRing(path string) *FileKeyRing {
+	return &FileKeyRing{path: path}
+}
+
+func (r *FileKeyRing) CurrentKey(ctx context.Context, scope string) (int, []byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	scopes, err := r.load()
+	if err != nil {
+		return 0, nil, err
+	}
+	if s, ok := scopes[scope]; ok {
+		if s.DestroyedAt != "" {
+			return 0, nil, ErrKeyDestroyed
+		}
+		return s.Current, s.Keys[s.Current], nil
+	}
+	s, err := r.addVersion(scopes, scope)
+	if err != nil {
+		return 0, nil, err
+	}
+	return s.Current, s.Keys[s.Current], nil
+}
+
+func (r *FileKeyRing) KeyVersion(ctx context.Context, scope string, version int) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	scopes, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	s, ok := scopes[scope]
+	if ok && s.DestroyedAt != "" {
+		return nil, ErrKeyDestroyed
+	}
+	if !ok || s.Keys[version] == nil {
+		return nil, ErrKeyNotFound
+	}
+	return s.Keys[version], nil
+}
+
+func (r *FileKeyRing) Rotate(ctx context.Context, scope string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	scopes, err := r.load()
+	if err != nil {
+		return 0, err
+	}
+	if s, ok := scopes[scope]; ok && s.DestroyedAt != "" {
+		return 0, ErrKeyDestroyed
+	}
+	s, err := r.addVersion(scopes, scope)
+	if err != nil {
+		return 0, err
+	}
+	return s.Current, nil
+}
+
+// Destroy 删除范围的全部版本，只保留当前版本号和销毁时间
+func (r *FileKeyRing) Destroy(ctx context.Context, scope string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	scopes, err := r.load()
+	if err != nil {
+		return err
+	}
+	s, ok := scopes[scope]
+	if ok && s.DestroyedAt != "" {
+		return nil
+	}
+	if !ok {
+		s = &fileScopeKeys{}
+		scopes[scope] = s
+	}
+	s.Keys = nil
+	s.DestroyedAt = time.Now().UTC().Format(time.RFC3339)
+	return r.save(scopes)
+}
+
+// addVersion 生成范围的下一个版本并保存
+func (r *FileKeyRing) addVersion(scopes map[string]*fileScopeKeys, scope string) (*fileScopeKeys, error) {
+	s, ok := scopes[scope]
+	if !ok {
+		s = &fileScopeKeys{Keys: make(map[int][]byte)}
+		scopes[scope] = s
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("生成主密钥失败: %v", err)
+	}
+	s.Current++
+	s.Keys[s.Current] = key
+	return s, r.save(scopes)
+}
+
+func (r *FileKeyRing) load() (map[string]*fileScopeKeys, error) {
+	scopes := make(map[string]*fileScopeKeys)
+	data, err := ioutil.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return scopes, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取主密钥文件失败: %v", err)
+	}
+	if err := json.Unmarshal(data, &scopes); err != nil {
+		return nil, fmt.Errorf("解析主密钥文件失败: %v", err)
+	}
+	return scopes, nil
+}
+
+// save 先写临时文件再改名，避免写入中断留下损坏的主密钥文件
+func (r *FileKeyRing) save(scopes map[string]*fileScopeKeys) error {
+	data, err := json.MarshalIndent(scopes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化主密钥文件失败: %v", err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(r.path), ".keyring-*")
+	if err != nil {
+		return fmt.Errorf("写入主密钥文件失败: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入主密钥文件失败: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入主密钥文件失败: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("写入主密钥文件失败: %v", err)
+	}
+	return nil
+}
+
+// ===================== HTTP主密钥服务 =====================
+
+// HTTPKeyRing 通过HTTP调用主密钥服务，服务按调用者判断能读取哪些范围：
+// GET <url>/scopes/<范围> 读取当前版本（不存在时创建），GET <url>/scopes/<范围>/versions/<版本> 读取指定版本，
+// POST <url>/scopes/<范围>/rotate 轮换，DELETE <url>/scopes/<范围> 销毁全部版本；
+// 响应为 {"version": N, "key": "<base64>"}，403 表示无权读取，404 表示不存在，410 表示已销毁
+type HTTPKeyRing struct {
+	url        string
+	httpClient *http.Client
+}
+
+func NewHTTPKeyRing(url string) *HTTPKeyRing {
+	return &HTTPKeyRing{
+		url:        strings.TrimSuffix(url, "/"),
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+type scopeKeyResponse struct {
+	Version int    `json:"version"`
+	Key     []byte `json:"key"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (r *HTTPKeyRing) CurrentKey(ctx context.Context, scope string) (int, []byte, error) {
+	resp, err := r.do(ctx, http.MethodGet, "/scopes/"+url.PathEscape(scope))
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.Version, resp.Key, nil
+}
+
+func (r *HTTPKeyRing) KeyVersion(ctx context.Context, scope string, version int) ([]byte, error) {
+	resp, err := r.do(ctx, http.MethodGet, fmt.Sprintf("/scopes/%s/versions/%d", url.PathEscape(scope), version))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Key, nil
+}
+
+func (r *HTTPKeyRing) Rotate(ctx context.Context, scope string) (int, error) {
+	resp, err := r.do(ctx, http.MethodPost, "/scopes/"+url.PathEscape(scope)+"/rotate")
+	if err != nil {
+		return 0, err
+	}
+	return resp.Version, nil
+}
+
+func (r *HTTPKeyRing) Destroy(ctx context.Context, scope string) error {
+	_, err := r.do(ctx, http.MethodDelete, "/scopes/"+url.PathEscape(scope))
+	if err == ErrKeyDestroyed {
+		return nil
+	}
+	return err
+}
+
+func (r *HTTPKeyRing) do(ctx context.Context, method, path string) (*scopeKeyResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, method, r.url+path, bytes.NewReader(nil))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求主密钥服务失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusForbidden:
+		return nil, ErrKeyForbidden
+	case http.StatusNotFound:
+		return nil, ErrKeyNotFound
+	case http.StatusGone:
+		return nil, ErrKeyDestroyed
+	}
+	if method == http.MethodDelete && resp.StatusCode < 300 {
+		return nil, nil
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取主密钥服务响应失败: %v", err)
+	}
+	var keyResp scopeKeyResponse
+	if err := json.Unmarshal(data, &keyResp); err != nil {
+		return nil, fmt.Errorf("解析主密钥服务响应失败（HTTP %d）: %v", resp.StatusCode, err)
+	}
+	if keyResp.Error != "" {
+		return nil, fmt.Errorf("主密钥服务返回错误: %s", keyResp.Error)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("主密钥服务返回 HTTP %d", resp.StatusCode)
+	}
+	if keyResp.Version <= 0 || len(keyResp.Key) != 32 {
+		return nil, fmt.Errorf("主密钥服务返回的密钥无效（版本 %d，长度 %d）", keyResp.Version, len(keyResp.Key))
+	}
+	return &keyResp, nil
+}
+
+// ===================== 命令行 =====================
+
+// runKeys 轮换字段加密的主密钥，主密钥服务由 EDU_KEY_RING 指定
+func runKeys(args []string) error {
+	flags := flag.NewFlagSet("keys", flag.ExitOnError)
+	batchSize := flags.Int("batch", defaultRotationBatchSize, "每批重新加密的记录数")
+	flags.Parse(args)
+	if flags.NArg() != 2 || (flags.Arg(0) != "rotate" && flags.Arg(0) != "reencrypt") {
+		return fmt.Errorf("用法: keys [-batch N] rotate|reencrypt <密钥范围，如 student:<用户ID> 或 course:<课程ID>>")
+	}
+
+	client, err := NewClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var report *RotationReport
+	if flags.Arg(0) == "rotate" {
+		report, err = client.RotateFieldKey(context.Background(), flags.Arg(1), *batchSize)
+	} else {
+		report, err = client.ReencryptFieldKey(context.Background(), flags.Arg(1), *batchSize)
+	}
+	if report != nil {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	}
+	if err != nil {
+		return err
+	}
+	if len(report.Failed) > 0 {
+		return fmt.Errorf("%d 条记录重新加密失败，可用 keys reencrypt %s 重试", len(report.Failed), report.Scope)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"edu/model"
+)
+
+// newTestEnvelopeClient 创建按学生划分范围做字段加密的进程内客户端
+func newTestEnvelopeClient(t *testing.T) (*Client, *FileKeyRing) {
+	t.Helper()
+	ring := NewFileKeyRing(filepath.Join(t.TempDir(), "keyring.json"))
+	return newTestEmbeddedClient(t, WithFieldEncryption(ring, StudentScope)), ring
+}
+
+func testJudgement(id, userID, content string) Judgement {
+	return Judgement{
+		JudgementID:      id,
+		UserID:           userID,
+		JudgementRating:  "4",
+		JudgementContent: content,
+		JudgementTime:    time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC).Format(time.RFC3339),
+	}
+}
+
+// scopeVersion 返回记录中信封密文的范围版本
+func scopeVersion(t *testing.T, value string) int {
+	t.Helper()
+	env, err := parseEnvelope(value)
+	if err != nil {
+		t.Fatalf("解析信封密文 %q 失败: %v", value, err)
+	}
+	return env.version
+}
+
+func TestEraseDestroysStudentScopeKey(t *testing.T) {
+	c, ring := newTestEnvelopeClient(t)
+	judgement := testJudgement("judge_001", "user_001", "课堂讨论积极，报告结构清晰")
+	if _, err := c.UploadJudgement(judgement); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.GetJudgementByID("user_001", "judge_001")
+	if err != nil || got.JudgementContent != judgement.JudgementContent {
+		t.Fatalf("擦除前读取 = %+v, %v", got, err)
+	}
+
+	if _, err := c.EraseUserData("user_001"); err != nil {
+		t.Fatalf("擦除失败: %v", err)
+	}
+	if _, err := ring.KeyVersion(context.Background(), "student:user_001", 1); err != ErrKeyDestroyed {
+		t.Errorf("擦除后读取主密钥的错误 = %v，期望 ErrKeyDestroyed", err)
+	}
+	got, err = c.GetJudgementByID("user_001", "judge_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got.JudgementContent, model.EnvelopePrefix) {
+		t.Errorf("擦除后评价内容 = %q，期望无法解开的密文", got.JudgementContent)
+	}
+	if _, err := c.UploadJudgement(testJudgement("judge_002", "user_001", "新的评价")); err == nil {
+		t.Error("擦除后不应能再写入该学生的评价内容")
+	}
+	// 其他学生的范围不受影响
+	if _, err := c.UploadJudgement(testJudgement("judge_003", "user_002", "小组合作良好")); err != nil {
+		t.Errorf("写入其他学生的评价失败: %v", err)
+	}
+}
+
+func TestRotateFieldKeyBatches(t *testing.T) {
+	c, _ := newTestEnvelopeClient(t)
+	for i := 1; i <= 5; i++ {
+		e := testEvaluation(fmt.Sprintf("eval_%03d", i), "user_001")
+		if _, err := c.UploadEvaluation(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 1; i <= 2; i++ {
+		if _, err := c.UploadJudgement(testJudgement(fmt.Sprintf("judge_%03d", i), "user_001", "报告结构清晰")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 其他学生的记录不在该范围内
+	if _, err := c.UploadEvaluation(testEvaluation("eval_other", "user_002")); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := c.RotateFieldKey(context.Background(), "student:user_001", 2)
+	if err != nil {
+		t.Fatalf("轮换失败: %v", err)
+	}
+	// 测评记录 5 条分 3 页，评价记录 2 条 1 页，每页一批
+	if report.Version != 2 || report.Scanned != 7 || report.Reencrypted != 7 || report.Batches != 4 || len(report.Failed) != 0 {
+		t.Fatalf("轮换结果 = %+v，期望版本 2、检查 7 条、重新加密 7 条、4 批", report)
+	}
+
+	sealed := c.Sealed()
+	judgements, err := sealed.GetJudgementByUser("user_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, j := range judgements {
+		if v := scopeVersion(t, j.JudgementContent); v != 2 {
+			t.Errorf("%s 的主密钥版本 = %d，期望 2", j.JudgementID, v)
+		}
+	}
+	list, err := c.GetEvaluationByUser("user_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range list {
+		if e.Feedback != testEvaluation("", "").Feedback {
+			t.Errorf("%s 重新加密后的反馈 = %q", e.EvaluationID, e.Feedback)
+		}
+	}
+
+	// 全部记录已是当前版本，再次执行不提交任何交易
+	again, err := c.ReencryptFieldKey(context.Background(), "student:user_001", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Reencrypted != 0 || again.Batches != 0 || again.Scanned != 7 {
+		t.Errorf("重复执行结果 = %+v，期望没有需要重新加密的记录", again)
+	}
+}
+
+func TestReencryptPartialFailure(t *testing.T) {
+	c, _ := newTestEnvelopeClient(t)
+	if _, err := c.UploadJudgement(testJudgement("judge_001", "user_001", "报告结构清晰")); err != nil {
+		t.Fatal(err)
+	}
+	// 已是密文的内容原样提交，这条记录的数据密钥无法用主密钥解开
+	garbage := base64.StdEncoding.EncodeToString([]byte("not a wrapped key, not sealed data"))
+	broken := testJudgement("judge_002", "user_001", model.EnvelopePrefix+"student:user_001#1:"+garbage+":"+garbage)
+	if _, err := c.UploadJudgement(broken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UploadJudgement(testJudgement("judge_003", "user_001", "小组合作良好")); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := c.RotateFieldKey(context.Background(), "student:user_001", 10)
+	if err != nil {
+		t.Fatalf("轮换失败: %v", err)
+	}
+	if report.Reencrypted != 2 || len(report.Failed) != 1 || !strings.HasPrefix(report.Failed[0], "Judgement-judge_002:") {
+		t.Fatalf("轮换结果 = %+v，期望 2 条成功、judge_002 失败", report)
+	}
+
+	// 重试只处理仍停留在旧版本的记录
+	retry, err := c.ReencryptFieldKey(context.Background(), "student:user_001", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retry.Reencrypted != 0 || len(retry.Failed) != 1 {
+		t.Errorf("重试结果 = %+v，期望只有 judge_002 再次失败", retry)
+	}
+
+	got, err := c.GetJudgementByID("user_001", "judge_003")
+	if err != nil || got.JudgementContent != "小组合作良好" {
+		t.Errorf("重新加密后读取 = %+v, %v", got, err)
+	}
+}
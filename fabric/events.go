@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"edu/model"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+)
+
+// ===================== 事件订阅 =====================
+
+// BlockEvents 从 startBlock 开始订阅完整区块，ctx 取消时结束订阅
+// 连接中断时通道被关闭，调用方应从已处理的区块之后重新订阅
+func (c *Client) BlockEvents(ctx context.Context, startBlock uint64) (blocks <-chan *common.Block, err error) {
+	ctx, end, err := c.startCall(ctx, "BlockEvents")
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	if c.embedded != nil {
+		return nil, fmt.Errorf("进程内链码模式不支持区块事件")
+	}
+
+	err = c.pool.do(ctx, func(peer *peerConn) error {
+		gw, err := c.pool.gatewayFor(peer, c.identity)
+		if err != nil {
+			return err
+		}
+		blocks, err = gw.network.BlockEvents(ctx, client.WithStartBlock(startBlock))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("订阅区块事件失败: %w", err)
+	}
+	return blocks, nil
+}
+
+// RecordEvent 记录变更事件内容，定义在 edu/model 中，与链码共用
+type RecordEvent = model.RecordEvent
+
+// ChaincodeEvents 订阅链码事件，不指定起始区块时从当前区块开始
+func (c *Client) ChaincodeEvents(ctx context.Context, startBlock ...uint64) (events <-chan *client.ChaincodeEvent, err error) {
+	ctx, end, err := c.startCall(ctx, "ChaincodeEvents")
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	if c.embedded != nil {
+		return c.embedded.chaincodeEvents(ctx, startBlock...), nil
+	}
+
+	var options []client.ChaincodeEventsOption
+	if len(startBlock) > 0 {
+		options = append(options, client.WithStartBlock(startBlock[0]))
+	}
+
+	err = c.pool.do(ctx, func(peer *peerConn) error {
+		gw, err := c.pool.gatewayFor(peer, c.identity)
+		if err != nil {
+			return err
+		}
+		events, err = gw.network.ChaincodeEvents(ctx, chaincodeID, options...)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("订阅链码事件失败: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,578 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"edu/model"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/xitongsys/parquet-go/writer"
+	"google.golang.org/protobuf/proto"
+)
+
+// ===================== 账本导出 =====================
+
+// 导出格式
+const (
+	ExportCSV     = "csv"
+	ExportJSONL   = "jsonl"
+	ExportParquet = "parquet"
+)
+
+const (
+	defaultExportPageSize = 200
+	exportManifestName    = "manifest.json"
+)
+
+// 可导出的记录类型及其客户端结构
+var exportTypes = []struct {
+	docType    string
+	recordType reflect.Type
+}{
+	{"Evaluation", reflect.TypeOf(Evaluation{})},
+	{"TestResult", reflect.TypeOf(TestResult{})},
+	{"Judgement", reflect.TypeOf(Judgement{})},
+}
+
+// 导出过滤条件和分页结果定义在 edu/model 中，与链码共用
+// 试卷编号只有 TestResult 有，时间范围只适用于 Judgement；按课程过滤解析为该课程的试卷编号，同样只适用于 TestResult
+type (
+	ExportFilter = model.ExportFilter
+	ExportPage   = model.ExportPage
+)
+
+// ExportOptions 导出配置
+type ExportOptions struct {
+	Dir          string       // 输出目录，每种记录类型一个文件，另有 manifest.json
+	Format       string       // csv / jsonl / parquet
+	DocTypes     []string     // 为空时导出全部类型
+	Filter       ExportFilter // 过滤条件
+	Course       string       // 按课程过滤，由 PaperCourses 解析为 Filter.PaperNumbers
+	PaperCourses PaperCourses // 试卷与课程的对应关系，按课程过滤时必须提供
+	PageSize     int32        // 每页条数，默认200
+	Retries      int          // 导出期间出块时重新导出的次数
+}
+
+// PaperCourses 试卷编号到课程ID的对应关系
+// 账本记录中没有课程字段，测试结果通过试卷编号归属课程，测评和评价无法按课程区分
+type PaperCourses map[string]string
+
+// LoadPaperCourses 读取试卷与课程的对应关系，CSV 每行为 <试卷编号>,<课程ID>，可带表头 paper_number,course_id
+func LoadPaperCourses(path string) (PaperCourses, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开试卷课程对应文件失败: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("解析试卷课程对应文件失败: %v", err)
+	}
+	courses := make(PaperCourses, len(rows))
+	for i, row := range rows {
+		paper, course := strings.TrimSpace(row[0]), strings.TrimSpace(row[1])
+		if i == 0 && paper == "paper_number" {
+			continue
+		}
+		if paper == "" || course == "" {
+			return nil, fmt.Errorf("试卷课程对应文件第 %d 行: 试卷编号和课程ID不能为空", i+1)
+		}
+		if existing, ok := courses[paper]; ok && existing != course {
+			return nil, fmt.Errorf("试卷课程对应文件第 %d 行: 试卷 %s 同时对应课程 %s 和 %s", i+1, paper, existing, course)
+		}
+		courses[paper] = course
+	}
+	return courses, nil
+}
+
+// Papers 课程的全部试卷编号，按编号排序
+func (m PaperCourses) Papers(course string) []string {
+	var papers []string
+	for paper, c := range m {
+		if c == course {
+			papers = append(papers, paper)
+		}
+	}
+	sort.Strings(papers)
+	return papers
+}
+
+// resolveCourse 把按课程过滤解析为试卷编号，同时指定试卷编号时该试卷必须属于课程
+func resolveCourse(options *ExportOptions) error {
+	if options.Course == "" {
+		return nil
+	}
+	if options.PaperCourses == nil {
+		return fmt.Errorf("按课程 %s 导出需要试卷与课程的对应关系", options.Course)
+	}
+	papers := options.PaperCourses.Papers(options.Course)
+	if len(papers) == 0 {
+		return fmt.Errorf("课程 %s 没有对应的试卷", options.Course)
+	}
+	if paper := options.Filter.PaperNumber; paper != "" && options.PaperCourses[paper] != options.Course {
+		return fmt.Errorf("试卷 %s 不属于课程 %s", paper, options.Course)
+	}
+	options.Filter.PaperNumbers = papers
+	return nil
+}
+
+// ExportManifest 导出清单，按课程导出时 Filter.PaperNumbers 为课程的试卷
+type ExportManifest struct {
+	Channel     string       `json:"channel"`
+	Chaincode   string       `json:"chaincode"`
+	Format      string       `json:"format"`
+	Filter      ExportFilter `json:"filter"`
+	Course      string       `json:"course,omitempty"`
+	BlockHeight uint64       `json:"blockHeight"` // 导出内容对应的账本高度（包含区块 0 到 blockHeight-1）
+	Consistent  bool         `json:"consistent"`  // 导出前后账本高度一致，内容是该高度的快照
+	StartedAt   time.Time    `json:"startedAt"`
+	FinishedAt  time.Time    `json:"finishedAt"`
+	Attempts    int          `json:"attempts"`
+	Files       []ExportFile `json:"files"`
+	Skipped     []ExportSkip `json:"skipped,omitempty"`
+}
+
+// ExportFile 导出文件信息
+type ExportFile struct {
+	DocType string `json:"docType"`
+	Path    string `json:"path"` // 相对于输出目录
+	Records int    `json:"records"`
+	SHA256  string `json:"sha256"`
+}
+
+// ExportSkip 因过滤条件不适用而未导出的记录类型
+type ExportSkip struct {
+	DocType string `json:"docType"`
+	Reason  string `json:"reason"`
+}
+
+// ChainHeight 通过 qscc 查询通道当前区块高度
+func (c *Client) ChainHeight() (uint64, error) {
+	return c.ChainHeightWithContext(context.Background())
+}
+
+// ChainHeightWithContext 查询通道当前区块高度，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+func (c *Client) ChainHeightWithContext(ctx context.Context, opts ...CallOption) (height uint64, err error) {
+	ctx, end, err := c.startCall(ctx, "ChainHeight", opts...)
+	if err != nil {
+		return 0, err
+	}
+	defer end(&err)
+
+	return c.chainHeight(ctx)
+}
+
+// chainHeight 查询区块高度，供已登记的调用内部使用
+func (c *Client) chainHeight(ctx context.Context) (uint64, error) {
+	if c.embedded != nil {
+		return c.embedded.chainHeight(), nil
+	}
+
+	var result []byte
+	err := c.pool.do(ctx, func(peer *peerConn) error {
+		gw, err := c.pool.gatewayFor(peer, c.identity)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := phaseContext(ctx, phaseEvaluate)
+		defer cancel()
+
+		proposal, err := gw.network.GetContract("qscc").NewProposal("GetChainInfo", client.WithArguments(channelName))
+		if err == nil {
+			result, err = proposal.EvaluateWithContext(ctx)
+		}
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("查询区块高度失败: %w", err)
+	}
+
+	info := &common.BlockchainInfo{}
+	if err := proto.Unmarshal(result, info); err != nil {
+		return 0, fmt.Errorf("解析链信息失败: %v", err)
+	}
+	return info.GetHeight(), nil
+}
+
+// Export 分页导出账本记录到 options.Dir，并写出清单
+// 分页查询不是快照，导出前后账本高度不一致时重新导出，超过 Retries 次后清单中 Consistent 为 false
+// 多个网关节点的区块高度可能短暂不同，严格一致时应只配置一个节点
+func (c *Client) Export(ctx context.Context, options ExportOptions) (manifest *ExportManifest, err error) {
+	ctx, end, err := c.startCall(ctx, "Export")
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	if options.PageSize <= 0 {
+		options.PageSize = defaultExportPageSize
+	}
+	switch options.Format {
+	case ExportCSV, ExportJSONL, ExportParquet:
+	default:
+		return nil, fmt.Errorf("不支持的导出格式 %s", options.Format)
+	}
+	if err := resolveCourse(&options); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(options.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建导出目录失败: %v", err)
+	}
+
+	manifest = &ExportManifest{
+		Channel:   channelName,
+		Chaincode: chaincodeID,
+		Format:    options.Format,
+		Filter:    options.Filter,
+		Course:    options.Course,
+		StartedAt: time.Now().UTC(),
+	}
+	for {
+		manifest.Attempts++
+		before, err := c.chainHeight(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.exportAll(ctx, options, manifest); err != nil {
+			return nil, err
+		}
+		after, err := c.chainHeight(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		manifest.BlockHeight, manifest.Consistent = after, before == after
+		if manifest.Consistent || manifest.Attempts > options.Retries {
+			break
+		}
+		log.Printf("导出期间区块高度从 %d 变为 %d，重新导出", before, after)
+	}
+	manifest.FinishedAt = time.Now().UTC()
+
+	if err := writeExportManifest(filepath.Join(options.Dir, exportManifestName), manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// exportAll 导出一轮所有记录类型，覆盖上一轮的文件
+func (c *Client) exportAll(ctx context.Context, options ExportOptions, manifest *ExportManifest) error {
+	manifest.Files, manifest.Skipped = nil, nil
+	filterJSON, err := json.Marshal(options.Filter)
+	if err != nil {
+		return fmt.Errorf("序列化过滤条件失败: %v", err)
+	}
+
+	for _, t := range exportTypes {
+		if !exportSelected(options.DocTypes, t.docType) {
+			continue
+		}
+		if reason := filterNotApplicable(t.docType, options.Filter); reason != "" {
+			manifest.Skipped = append(manifest.Skipped, ExportSkip{DocType: t.docType, Reason: reason})
+			continue
+		}
+
+		name := t.docType + "." + options.Format
+		records, sum, err := c.exportType(ctx, t.docType, t.recordType, string(filterJSON), options, filepath.Join(options.Dir, name))
+		if err != nil {
+			return fmt.Errorf("导出 %s 失败: %w", t.docType, err)
+		}
+		manifest.Files = append(manifest.Files, ExportFile{DocType: t.docType, Path: name, Records: records, SHA256: sum})
+	}
+	return nil
+}
+
+// exportType 逐页读取一种记录类型并流式写入文件，返回记录数和文件摘要
+func (c *Client) exportType(ctx context.Context, docType string, recordType reflect.Type, filterJSON string, options ExportOptions, path string) (int, string, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("创建导出文件失败: %v", err)
+	}
+	defer file.Close()
+
+	digest := sha256.New()
+	w, err := newRecordWriter(options.Format, io.MultiWriter(file, digest), recordType)
+	if err != nil {
+		return 0, "", err
+	}
+
+	count := 0
+	bookmark := ""
+	for {
+		page, err := c.exportPage(ctx, docType, filterJSON, options.PageSize, bookmark)
+		if err != nil {
+			return 0, "", err
+		}
+		for _, record := range page.Records {
+			if err := w.write([]byte(record)); err != nil {
+				return 0, "", fmt.Errorf("写入导出文件失败: %v", err)
+			}
+		}
+		count += len(page.Records)
+		// CouchDB 在最后一页之后仍会返回书签，不足一页即表示结束
+		if int32(len(page.Records)) < options.PageSize || page.Bookmark == "" {
+			break
+		}
+		bookmark = page.Bookmark
+	}
+
+	if err := w.close(); err != nil {
+		return 0, "", fmt.Errorf("写入导出文件失败: %v", err)
+	}
+	return count, hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+func (c *Client) exportPage(ctx context.Context, docType, filterJSON string, pageSize int32, bookmark string) (*ExportPage, error) {
+	result, err := c.evaluate(ctx, "ExportRecords", docType, filterJSON, fmt.Sprint(pageSize), bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("分页查询失败: %w", err)
+	}
+	var page ExportPage
+	if err := json.Unmarshal(result, &page); err != nil {
+		return nil, fmt.Errorf("解析结果失败: %v", err)
+	}
+	return &page, nil
+}
+
+func exportSelected(docTypes []string, docType string) bool {
+	if len(docTypes) == 0 {
+		return true
+	}
+	for _, t := range docTypes {
+		if t == docType {
+			return true
+		}
+	}
+	return false
+}
+
+// filterNotApplicable 过滤条件引用了该记录类型没有的字段时返回原因
+func filterNotApplicable(docType string, filter ExportFilter) string {
+	if (filter.PaperNumber != "" || len(filter.PaperNumbers) > 0) && docType != "TestResult" {
+		return "没有试卷编号字段"
+	}
+	if (filter.From != "" || filter.To != "") && docType != "Judgement" {
+		return "没有时间字段"
+	}
+	return ""
+}
+
+func writeExportManifest(path string, manifest *ExportManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化导出清单失败: %v", err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("写入导出清单失败: %v", err)
+	}
+	return nil
+}
+
+// ===================== 导出格式 =====================
+
+// recordWriter 把链码返回的记录JSON写成目标格式
+type recordWriter interface {
+	write(record []byte) error
+	close() error
+}
+
+func newRecordWriter(format string, w io.Writer, recordType reflect.Type) (recordWriter, error) {
+	switch format {
+	case ExportCSV:
+		return newCSVRecordWriter(w, recordType), nil
+	case ExportJSONL:
+		return &jsonlRecordWriter{w: w}, nil
+	case ExportParquet:
+		return newParquetRecordWriter(w, recordType)
+	}
+	return nil, fmt.Errorf("不支持的导出格式 %s", format)
+}
+
+// exportColumns 记录结构的JSON字段名，作为CSV表头和Parquet列名
+func exportColumns(recordType reflect.Type) []string {
+	columns := make([]string, recordType.NumField())
+	for i := range columns {
+		columns[i] = strings.Split(recordType.Field(i).Tag.Get("json"), ",")[0]
+	}
+	return columns
+}
+
+// jsonlRecordWriter 每行一条记录，保留链码存储的原始JSON
+type jsonlRecordWriter struct {
+	w io.Writer
+}
+
+func (j *jsonlRecordWriter) write(record []byte) error {
+	_, err := j.w.Write(append(record, '\n'))
+	return err
+}
+
+func (j *jsonlRecordWriter) close() error {
+	return nil
+}
+
+type csvRecordWriter struct {
+	w          *csv.Writer
+	recordType reflect.Type
+	header     bool
+}
+
+func newCSVRecordWriter(w io.Writer, recordType reflect.Type) *csvRecordWriter {
+	return &csvRecordWriter{w: csv.NewWriter(w), recordType: recordType}
+}
+
+func (c *csvRecordWriter) write(record []byte) error {
+	if !c.header {
+		if err := c.w.Write(exportColumns(c.recordType)); err != nil {
+			return err
+		}
+		c.header = true
+	}
+
+	value := reflect.New(c.recordType)
+	if err := json.Unmarshal(record, value.Interface()); err != nil {
+		return fmt.Errorf("解析记录失败: %v", err)
+	}
+	row := make([]string, c.recordType.NumField())
+	for i := range row {
+		row[i] = value.Elem().Field(i).String()
+	}
+	return c.w.Write(row)
+}
+
+func (c *csvRecordWriter) close() error {
+	// 没有记录时也写出表头
+	if !c.header {
+		c.w.Write(exportColumns(c.recordType))
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// parquetRecordWriter 所有列均为可选的 UTF8 字符串
+type parquetRecordWriter struct {
+	w *writer.JSONWriter
+}
+
+func newParquetRecordWriter(w io.Writer, recordType reflect.Type) (*parquetRecordWriter, error) {
+	type field struct {
+		Tag string `json:"Tag"`
+	}
+	schema := struct {
+		Tag    string  `json:"Tag"`
+		Fields []field `json:"Fields"`
+	}{Tag: "name=parquet_go_root, repetitiontype=REQUIRED"}
+	for _, column := range exportColumns(recordType) {
+		schema.Fields = append(schema.Fields, field{
+			Tag: fmt.Sprintf("name=%s, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL", column),
+		})
+	}
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	pw, err := writer.NewJSONWriterFromWriter(string(schemaJSON), w, 1)
+	if err != nil {
+		return nil, fmt.Errorf("创建Parquet写入器失败: %v", err)
+	}
+	return &parquetRecordWriter{w: pw}, nil
+}
+
+func (p *parquetRecordWriter) write(record []byte) error {
+	return p.w.Write(string(record))
+}
+
+func (p *parquetRecordWriter) close() error {
+	return p.w.WriteStop()
+}
+
+// runExport 导出账本记录
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	dir := flags.String("dir", "export", "输出目录")
+	format := flags.String("format", ExportCSV, "导出格式（csv/jsonl/parquet）")
+	types := flags.String("types", "", "记录类型，逗号分隔，默认全部（Evaluation,TestResult,Judgement）")
+	userID := flags.String("user", "", "按用户ID过滤")
+	paper := flags.String("paper", "", "按试卷编号过滤（仅 TestResult）")
+	course := flags.String("course", "", "按课程过滤（仅 TestResult，需要 -courses）")
+	coursesFile := flags.String("courses", "", "试卷与课程的对应关系，CSV 每行为 <试卷编号>,<课程ID>")
+	from := flags.String("from", "", "起始时间，RFC3339（仅 Judgement）")
+	to := flags.String("to", "", "截止时间，RFC3339（仅 Judgement）")
+	pageSize := flags.Int("page-size", defaultExportPageSize, "每页条数")
+	retries := flags.Int("retries", 3, "导出期间出块时重新导出的次数")
+	flags.Parse(args)
+
+	for _, t := range []string{*from, *to} {
+		if t == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, t); err != nil {
+			return fmt.Errorf("时间格式错误 %q，应为RFC3339: %v", t, err)
+		}
+	}
+	options := ExportOptions{
+		Dir:      *dir,
+		Format:   *format,
+		Filter:   ExportFilter{UserID: *userID, PaperNumber: *paper, From: *from, To: *to},
+		Course:   *course,
+		PageSize: int32(*pageSize),
+		Retries:  *retries,
+	}
+	if *types != "" {
+		options.DocTypes = strings.Split(*types, ",")
+	}
+	if *coursesFile != "" {
+		courses, err := LoadPaperCourses(*coursesFile)
+		if err != nil {
+			return err
+		}
+		options.PaperCourses = courses
+	}
+
+	client, err := NewClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	manifest, err := client.Export(ctx, options)
+	if err != nil {
+		return err
+	}
+	for _, f := range manifest.Files {
+		log.Printf("%s: %d 条 -> %s", f.DocType, f.Records, filepath.Join(*dir, f.Path))
+	}
+	for _, s := range manifest.Skipped {
+		log.Printf("%s: 跳过（%s）", s.DocType, s.Reason)
+	}
+	if !manifest.Consistent {
+		log.Printf("警告: 导出期间账本持续出块，导出内容可能跨越多个区块高度（最终高度 %d）", manifest.BlockHeight)
+	} else {
+		log.Printf("导出内容与区块高度 %d 一致", manifest.BlockHeight)
+	}
+	return nil
+}
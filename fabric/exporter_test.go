@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"edu/model"
+)
+
+// newTestExportClient 创建进程内客户端并写入导出用的记录：
+// 两名学生各 3 条测评；5 条测试结果分属三张试卷；3 条评价分布在三天
+func newTestExportClient(t *testing.T) *Client {
+	t.Helper()
+	c := newTestEmbeddedClient(t)
+	for _, user := range []string{"user_001", "user_002"} {
+		for i := 1; i <= 3; i++ {
+			if _, err := c.UploadEvaluation(testEvaluation(fmt.Sprintf("eval_%s_%d", user, i), user)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	tests := []TestResult{
+		{TestID: "test_001", UserID: "user_001", ScoreSum: "90", PaperNumber: "MATH-01"},
+		{TestID: "test_002", UserID: "user_002", ScoreSum: "75", PaperNumber: "MATH-01"},
+		{TestID: "test_003", UserID: "user_001", ScoreSum: "88", PaperNumber: "MATH-02"},
+		{TestID: "test_004", UserID: "user_002", ScoreSum: "60", PaperNumber: "PHYS-01"},
+		{TestID: "test_005", UserID: "user_001", ScoreSum: "95", PaperNumber: "PHYS-01"},
+	}
+	for _, test := range tests {
+		if _, err := c.UploadTestResult(test); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, day := range []int{1, 2, 3} {
+		judgement := testJudgement(fmt.Sprintf("judge_%03d", i+1), "user_001", "")
+		judgement.JudgementTime = time.Date(2024, 6, day, 8, 0, 0, 0, time.UTC).Format(time.RFC3339)
+		if _, err := c.UploadJudgement(judgement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+var testPaperCourses = PaperCourses{"MATH-01": "math", "MATH-02": "math", "PHYS-01": "physics"}
+
+// exportedIDs 读取 JSON Lines 导出文件中的记录ID，按ID排序
+func exportedIDs(t *testing.T, path, idField string) []string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	ids := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("导出行不是 JSON: %v", err)
+		}
+		ids = append(ids, fmt.Sprint(record[idField]))
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestExportFilters(t *testing.T) {
+	c := newTestExportClient(t)
+	idFields := map[string]string{"Evaluation": "Evaluation_ID", "TestResult": "Test_ID", "Judgement": "Judgement_ID"}
+
+	tests := []struct {
+		name    string
+		options ExportOptions
+		want    map[string][]string // 记录类型 -> 导出的记录ID，不在其中的类型应被跳过
+	}{
+		{"全部记录", ExportOptions{}, map[string][]string{
+			"Evaluation": {"eval_user_001_1", "eval_user_001_2", "eval_user_001_3", "eval_user_002_1", "eval_user_002_2", "eval_user_002_3"},
+			"TestResult": {"test_001", "test_002", "test_003", "test_004", "test_005"},
+			"Judgement":  {"judge_001", "judge_002", "judge_003"},
+		}},
+		{"按用户", ExportOptions{Filter: ExportFilter{UserID: "user_002"}}, map[string][]string{
+			"Evaluation": {"eval_user_002_1", "eval_user_002_2", "eval_user_002_3"},
+			"TestResult": {"test_002", "test_004"},
+			"Judgement":  {},
+		}},
+		{"按试卷", ExportOptions{Filter: ExportFilter{PaperNumber: "MATH-01"}}, map[string][]string{
+			"TestResult": {"test_001", "test_002"},
+		}},
+		{"按课程", ExportOptions{Course: "math", PaperCourses: testPaperCourses}, map[string][]string{
+			"TestResult": {"test_001", "test_002", "test_003"},
+		}},
+		{"按课程和用户", ExportOptions{Course: "physics", PaperCourses: testPaperCourses, Filter: ExportFilter{UserID: "user_001"}}, map[string][]string{
+			"TestResult": {"test_005"},
+		}},
+		{"按课程和试卷", ExportOptions{Course: "math", PaperCourses: testPaperCourses, Filter: ExportFilter{PaperNumber: "MATH-02"}}, map[string][]string{
+			"TestResult": {"test_003"},
+		}},
+		{"按时间范围", ExportOptions{Filter: ExportFilter{From: "2024-06-02T00:00:00Z", To: "2024-06-03T08:00:00Z"}}, map[string][]string{
+			"Judgement": {"judge_002"},
+		}},
+		{"只导出指定类型", ExportOptions{DocTypes: []string{"Judgement"}, Filter: ExportFilter{From: "2024-06-02T00:00:00Z"}}, map[string][]string{
+			"Judgement": {"judge_002", "judge_003"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := tt.options
+			options.Dir, options.Format, options.PageSize = t.TempDir(), ExportJSONL, 2
+			manifest, err := c.Export(context.Background(), options)
+			if err != nil {
+				t.Fatalf("导出失败: %v", err)
+			}
+
+			got := map[string][]string{}
+			for _, f := range manifest.Files {
+				ids := exportedIDs(t, filepath.Join(options.Dir, f.Path), idFields[f.DocType])
+				if f.Records != len(ids) {
+					t.Errorf("%s 清单记录数 = %d，文件中 %d 条", f.DocType, f.Records, len(ids))
+				}
+				got[f.DocType] = ids
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("导出记录 = %v，期望 %v", got, tt.want)
+			}
+			if len(manifest.Files)+len(manifest.Skipped) != len(exportTypes) && len(options.DocTypes) == 0 {
+				t.Errorf("导出 %d 种、跳过 %d 种，期望覆盖全部记录类型", len(manifest.Files), len(manifest.Skipped))
+			}
+			if manifest.Course != options.Course {
+				t.Errorf("清单课程 = %q，期望 %q", manifest.Course, options.Course)
+			}
+		})
+	}
+}
+
+// 记录数恰好是页大小的整数倍、不足一页和多页时都完整导出且不重复
+func TestExportPagination(t *testing.T) {
+	c := newTestExportClient(t)
+	for _, pageSize := range []int32{1, 2, 3, 6, 7, 1000} {
+		t.Run(fmt.Sprint(pageSize), func(t *testing.T) {
+			dir := t.TempDir()
+			manifest, err := c.Export(context.Background(), ExportOptions{Dir: dir, Format: ExportJSONL, DocTypes: []string{"Evaluation"}, PageSize: pageSize})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := exportedIDs(t, filepath.Join(dir, "Evaluation.jsonl"), "Evaluation_ID")
+			if len(ids) != 6 || manifest.Files[0].Records != 6 {
+				t.Fatalf("导出 %d 条（清单 %d 条），期望 6 条", len(ids), manifest.Files[0].Records)
+			}
+			for i := 1; i < len(ids); i++ {
+				if ids[i] == ids[i-1] {
+					t.Errorf("记录 %s 导出了两次", ids[i])
+				}
+			}
+			if !manifest.Consistent || manifest.BlockHeight == 0 {
+				t.Errorf("清单 = %+v，期望与区块高度一致", manifest)
+			}
+		})
+	}
+
+	if _, err := c.exportPage(context.Background(), "Evaluation", "", 1001, ""); !errors.Is(err, model.CodeInvalidInput) {
+		t.Errorf("超过单页上限的错误 = %v，期望 INVALID_INPUT", err)
+	}
+}
+
+func TestExportManifestAndCSV(t *testing.T) {
+	c := newTestExportClient(t)
+	dir := t.TempDir()
+	manifest, err := c.Export(context.Background(), ExportOptions{Dir: dir, Format: ExportCSV, Course: "physics", PaperCourses: testPaperCourses})
+	if err != nil {
+		t.Fatal(err)
+	}
+	skipped := map[string]string{}
+	for _, s := range manifest.Skipped {
+		skipped[s.DocType] = s.Reason
+	}
+	if len(manifest.Files) != 1 || skipped["Evaluation"] == "" || skipped["Judgement"] == "" {
+		t.Errorf("导出文件 %+v，跳过 %+v，期望只导出 TestResult", manifest.Files, manifest.Skipped)
+	}
+
+	var written ExportManifest
+	data, err := os.ReadFile(filepath.Join(dir, exportManifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatal(err)
+	}
+	if written.Course != "physics" || !reflect.DeepEqual(written.Filter.PaperNumbers, []string{"PHYS-01"}) {
+		t.Errorf("清单中的过滤条件 = %+v（课程 %q），期望课程 physics 解析为试卷 PHYS-01", written.Filter, written.Course)
+	}
+
+	file, err := os.Open(filepath.Join(dir, "TestResult.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || !reflect.DeepEqual(rows[0], exportColumns(reflect.TypeOf(TestResult{}))) {
+		t.Fatalf("CSV = %v，期望表头加两行", rows)
+	}
+	for _, row := range rows[1:] {
+		if row[4] != "PHYS-01" {
+			t.Errorf("CSV 行 %v 的试卷编号不是 PHYS-01", row)
+		}
+	}
+}
+
+func TestExportFilterErrors(t *testing.T) {
+	c := newTestExportClient(t)
+	tests := []struct {
+		name    string
+		options ExportOptions
+		want    string
+	}{
+		{"缺少对应关系", ExportOptions{Course: "math"}, "对应关系"},
+		{"课程没有试卷", ExportOptions{Course: "chemistry", PaperCourses: testPaperCourses}, "没有对应的试卷"},
+		{"试卷不属于课程", ExportOptions{Course: "math", PaperCourses: testPaperCourses, Filter: ExportFilter{PaperNumber: "PHYS-01"}}, "不属于课程"},
+		{"格式不支持", ExportOptions{Format: "xlsx"}, "不支持的导出格式"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := tt.options
+			options.Dir = filepath.Join(t.TempDir(), "out")
+			if options.Format == "" {
+				options.Format = ExportJSONL
+			}
+			_, err := c.Export(context.Background(), options)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("错误 = %v，期望包含 %q", err, tt.want)
+			}
+			if _, statErr := os.Stat(options.Dir); !os.IsNotExist(statErr) {
+				t.Errorf("过滤条件无效时不应创建输出目录")
+			}
+		})
+	}
+
+	// 链码拒绝对没有对应字段的记录类型使用过滤条件
+	chaincodeTests := []struct {
+		docType string
+		filter  ExportFilter
+	}{
+		{"Evaluation", ExportFilter{PaperNumbers: []string{"MATH-01"}}},
+		{"Judgement", ExportFilter{PaperNumber: "MATH-01"}},
+		{"TestResult", ExportFilter{From: "2024-06-01T00:00:00Z"}},
+	}
+	for _, tt := range chaincodeTests {
+		filterJSON, _ := json.Marshal(tt.filter)
+		if _, err := c.exportPage(context.Background(), tt.docType, string(filterJSON), 10, ""); !errors.Is(err, model.CodeInvalidInput) {
+			t.Errorf("%s 使用过滤条件 %+v 的错误 = %v，期望 INVALID_INPUT", tt.docType, tt.filter, err)
+		}
+	}
+}
+
+func TestLoadPaperCourses(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    PaperCourses
+		err     string
+	}{
+		{"带表头", "paper_number,course_id\nMATH-01,math\nPHYS-01, physics\n", PaperCourses{"MATH-01": "math", "PHYS-01": "physics"}, ""},
+		{"不带表头", "MATH-01,math\nMATH-02,math\n", PaperCourses{"MATH-01": "math", "MATH-02": "math"}, ""},
+		{"重复的相同对应", "MATH-01,math\nMATH-01,math\n", PaperCourses{"MATH-01": "math"}, ""},
+		{"试卷对应多个课程", "MATH-01,math\nMATH-01,physics\n", nil, "同时对应"},
+		{"课程为空", "MATH-01,\n", nil, "不能为空"},
+		{"列数错误", "MATH-01,math,extra\n", nil, "解析"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "courses.csv")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadPaperCourses(path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("错误 = %v，期望包含 %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("对应关系 = %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+	if got := testPaperCourses.Papers("math"); !reflect.DeepEqual(got, []string{"MATH-01", "MATH-02"}) {
+		t.Errorf("Papers(math) = %v", got)
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ===================== Fabric CA 登记 =====================
+
+// CAClient Fabric CA REST API 客户端，负责登记、重新登记和吊销身份
+type CAClient struct {
+	url        string // CA地址，如 https://localhost:7054
+	caName     string
+	mspID      string
+	httpClient *http.Client
+}
+
+// NewCAClient 创建CA客户端，tlsCertPath 为空时使用系统根证书
+func NewCAClient(url, caName, mspID, tlsCertPath string) (*CAClient, error) {
+	tlsConfig := &tls.Config{}
+	if tlsCertPath != "" {
+		certBytes, err := ioutil.ReadFile(tlsCertPath)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA TLS证书失败: %v", err)
+		}
+		certPool := x509.NewCertPool()
+		if ok := certPool.AppendCertsFromPEM(certBytes); !ok {
+			return nil, fmt.Errorf("解析CA TLS证书失败")
+		}
+		tlsConfig.RootCAs = certPool
+	}
+
+	return &CAClient{
+		url:    strings.TrimSuffix(url, "/"),
+		caName: caName,
+		mspID:  mspID,
+		httpClient: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+// caEnrollRequest 登记请求体
+type caEnrollRequest struct {
+	CertificateRequest string `json:"certificate_request"`
+	CAName             string `json:"caname,omitempty"`
+}
+
+// caRevokeRequest 吊销请求体，ID 与 Serial/AKI 二选一
+type caRevokeRequest struct {
+	ID     string `json:"id,omitempty"`
+	Serial string `json:"serial,omitempty"` // 十六进制证书序列号
+	AKI    string `json:"aki,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	CAName string `json:"caname,omitempty"`
+}
+
+// caRevokedCert 吊销响应中的一张证书
+type caRevokedCert struct {
+	Serial string `json:"Serial"`
+	AKI    string `json:"AKI"`
+}
+
+// caResponse CA通用响应格式
+type caResponse struct {
+	Success bool `json:"success"`
+	Result  struct {
+		Cert       string `json:"Cert"` // base64编码的PEM证书
+		ServerInfo struct {
+			CAName  string `json:"CAName"`
+			CAChain string `json:"CAChain"`
+		} `json:"ServerInfo"`
+		RevokedCerts []caRevokedCert `json:"RevokedCerts,omitempty"`
+	} `json:"result"`
+	Errors []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// Enroll 使用登记ID和密码向CA登记，生成新密钥对并返回钱包身份
+func (ca *CAClient) Enroll(enrollmentID, secret string) (*WalletIdentity, error) {
+	privateKey, body, err := ca.newEnrollRequest(enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, ca.url+"/api/v1/enroll", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(enrollmentID, secret)
+
+	return ca.doEnroll(req, privateKey)
+}
+
+// Reenroll 使用现有身份向CA重新登记（证书续期），生成新密钥对
+func (ca *CAClient) Reenroll(current *WalletIdentity) (*WalletIdentity, error) {
+	cert, err := parseCertificatePEM(current.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	currentKey, err := parseECPrivateKeyPEM(current.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, body, err := ca.newEnrollRequest(cert.Subject.CommonName)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := caAuthToken(current.Certificate, currentKey, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, ca.url+"/api/v1/reenroll", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", token)
+
+	return ca.doEnroll(req, privateKey)
+}
+
+// Revoke 以 requester 身份吊销 enrollmentID 名下的全部证书，返回被吊销证书的序列号
+func (ca *CAClient) Revoke(requester *WalletIdentity, enrollmentID, reason string) ([]string, error) {
+	return ca.revoke(requester, caRevokeRequest{ID: enrollmentID, Reason: reason, CAName: ca.caName})
+}
+
+// RevokeCertificate 以 requester 身份吊销单张证书（如私钥泄露的旧证书）
+func (ca *CAClient) RevokeCertificate(requester *WalletIdentity, certPEM, reason string) ([]string, error) {
+	cert, err := parseCertificatePEM(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	return ca.revoke(requester, caRevokeRequest{
+		Serial: cert.SerialNumber.Text(16),
+		AKI:    hex.EncodeToString(cert.AuthorityKeyId),
+		Reason: reason,
+		CAName: ca.caName,
+	})
+}
+
+func (ca *CAClient) revoke(requester *WalletIdentity, revokeReq caRevokeRequest) ([]string, error) {
+	key, err := parseECPrivateKeyPEM(requester.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(revokeReq)
+	if err != nil {
+		return nil, err
+	}
+	token, err := caAuthToken(requester.Certificate, key, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, ca.url+"/api/v1/revoke", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", token)
+
+	caResp, err := ca.do(req, "CA吊销失败")
+	if err != nil {
+		return nil, err
+	}
+	serials := make([]string, 0, len(caResp.Result.RevokedCerts))
+	for _, revoked := range caResp.Result.RevokedCerts {
+		serials = append(serials, revoked.Serial)
+	}
+	return serials, nil
+}
+
+// newEnrollRequest 生成P-256密钥和证书签名请求
+func (ca *CAClient) newEnrollRequest(commonName string) (*ecdsa.PrivateKey, []byte, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("生成密钥失败: %v", err)
+	}
+
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: commonName},
+	}, privateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("生成证书签名请求失败: %v", err)
+	}
+	csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER})
+
+	body, err := json.Marshal(caEnrollRequest{CertificateRequest: string(csrPEM), CAName: ca.caName})
+	if err != nil {
+		return nil, nil, err
+	}
+	return privateKey, body, nil
+}
+
+func (ca *CAClient) doEnroll(req *http.Request, privateKey *ecdsa.PrivateKey) (*WalletIdentity, error) {
+	caResp, err := ca.do(req, "CA登记失败")
+	if err != nil {
+		return nil, err
+	}
+
+	certPEM, err := base64.StdEncoding.DecodeString(caResp.Result.Cert)
+	if err != nil {
+		return nil, fmt.Errorf("解码证书失败: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("序列化私钥失败: %v", err)
+	}
+
+	return &WalletIdentity{
+		MSPID:       ca.mspID,
+		Certificate: string(certPEM),
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})),
+	}, nil
+}
+
+// do 发送请求并解析CA响应，失败时错误以 failure 开头
+func (ca *CAClient) do(req *http.Request, failure string) (*caResponse, error) {
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := ca.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求CA失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取CA响应失败: %v", err)
+	}
+
+	var caResp caResponse
+	if err := json.Unmarshal(data, &caResp); err != nil {
+		return nil, fmt.Errorf("解析CA响应失败（HTTP %d）: %v", resp.StatusCode, err)
+	}
+	if !caResp.Success {
+		if len(caResp.Errors) > 0 {
+			return nil, fmt.Errorf("%s: [%d] %s", failure, caResp.Errors[0].Code, caResp.Errors[0].Message)
+		}
+		return nil, fmt.Errorf("%s（HTTP %d）", failure, resp.StatusCode)
+	}
+	return &caResp, nil
+}
+
+// ===================== 钱包登记工具 =====================
+
+// EnrollToWallet 登记身份并以 userID 为标签存入钱包
+func (ca *CAClient) EnrollToWallet(wallet Wallet, userID, secret string) error {
+	id, err := ca.Enroll(userID, secret)
+	if err != nil {
+		return err
+	}
+	return wallet.Put(userID, id)
+}
+
+// ReenrollIfExpiring 证书在 within 时间内到期时重新登记并更新钱包，返回是否已续期
+func (ca *CAClient) ReenrollIfExpiring(wallet Wallet, userID string, within time.Duration) (bool, error) {
+	current, err := wallet.Get(userID)
+	if err != nil {
+		return false, err
+	}
+	cert, err := parseCertificatePEM(current.Certificate)
+	if err != nil {
+		return false, err
+	}
+	if time.Until(cert.NotAfter) > within {
+		return false, nil
+	}
+
+	renewed, err := ca.Reenroll(current)
+	if err != nil {
+		return false, err
+	}
+	return true, wallet.Put(userID, renewed)
+}
+
+// ===================== 令牌与密钥工具 =====================
+
+// caAuthToken 生成Fabric CA身份令牌：base64(证书).base64(签名)
+// 签名内容为 base64(请求体).base64(证书) 的SHA-256摘要
+func caAuthToken(certPEM string, key *ecdsa.PrivateKey, body []byte) (string, error) {
+	b64Cert := base64.StdEncoding.EncodeToString([]byte(certPEM))
+	b64Body := base64.StdEncoding.EncodeToString(body)
+	digest := sha256.Sum256([]byte(b64Body + "." + b64Cert))
+
+	sig, err := signLowS(key, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("生成CA令牌签名失败: %v", err)
+	}
+	return b64Cert + "." + base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// signLowS ECDSA签名并规范化为low-S形式（Fabric要求）
+func signLowS(key *ecdsa.PrivateKey, digest []byte) ([]byte, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+	if err != nil {
+		return nil, err
+	}
+	halfOrder := new(big.Int).Rsh(key.Curve.Params().N, 1)
+	if s.Cmp(halfOrder) > 0 {
+		s.Sub(key.Curve.Params().N, s)
+	}
+	return asn1.Marshal(struct{ R, S *big.Int }{r, s})
+}
+
+func parseCertificatePEM(certPEM string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return nil, fmt.Errorf("证书不是有效的PEM格式")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("解析证书失败: %v", err)
+	}
+	return cert, nil
+}
+
+func parseECPrivateKeyPEM(keyPEM string) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("私钥不是有效的PEM格式")
+	}
+
+	var key crypto.PrivateKey
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("解析私钥失败: %v", err)
+		}
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("仅支持ECDSA私钥")
+	}
+	return ecKey, nil
+}
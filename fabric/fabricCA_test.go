@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnrollToWalletAndReenrollIfExpiring(t *testing.T) {
+	_, client := newTestCA(t)
+	wallet := NewInMemoryWallet()
+	if err := client.EnrollToWallet(wallet, "user_001", "secret"); err != nil {
+		t.Fatalf("登记到钱包失败: %v", err)
+	}
+	current, err := wallet.Get("user_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 证书有效期 1 小时，距到期超过 within 时不续期
+	renewed, err := client.ReenrollIfExpiring(wallet, "user_001", time.Minute)
+	if err != nil || renewed {
+		t.Fatalf("续期 = %v, %v，期望不续期", renewed, err)
+	}
+	renewed, err = client.ReenrollIfExpiring(wallet, "user_001", 2*time.Hour)
+	if err != nil || !renewed {
+		t.Fatalf("续期 = %v, %v，期望已续期", renewed, err)
+	}
+	updated, _ := wallet.Get("user_001")
+	if updated.Certificate == current.Certificate || updated.PrivateKey == current.PrivateKey {
+		t.Error("续期后钱包中的身份未更新")
+	}
+	if _, err := client.ReenrollIfExpiring(wallet, "user_002", time.Hour); err == nil {
+		t.Error("钱包中不存在的身份应返回错误")
+	}
+}
+
+func TestCAClientErrorResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{"CA错误", func(w http.ResponseWriter, r *http.Request) {
+			writeCAError(w, http.StatusUnauthorized, 20, "Authentication failure")
+		}, "CA登记失败: [20] Authentication failure"},
+		{"无错误详情", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(caResponse{})
+		}, "CA登记失败（HTTP 500）"},
+		{"非JSON响应", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+		}, "解析CA响应失败（HTTP 502）"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+			client, err := NewCAClient(server.URL+"/", "", mspID, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := client.Enroll("user_001", "secret"); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("错误 = %v，期望包含 %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewCAClient("https://localhost:7054", "", mspID, "/nonexistent/tls.pem"); err == nil {
+		t.Error("TLS证书不存在时应返回错误")
+	}
+}
+
+func TestCAAuthTokenLowS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	halfOrder := new(big.Int).Rsh(key.Curve.Params().N, 1)
+	digest := sha256.Sum256([]byte("body"))
+	for i := 0; i < 32; i++ {
+		sig, err := signLowS(key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rs struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(sig, &rs); err != nil {
+			t.Fatal(err)
+		}
+		if rs.S.Cmp(halfOrder) > 0 {
+			t.Fatalf("签名 S 值大于半阶")
+		}
+		if !ecdsa.VerifyASN1(&key.PublicKey, digest[:], sig) {
+			t.Fatal("low-S 签名无法验证")
+		}
+	}
+}
+
+func TestParseECPrivateKeyPEM(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+	sec1, _ := x509.MarshalECPrivateKey(key)
+	tests := []struct {
+		name    string
+		pem     string
+		wantErr bool
+	}{
+		{"PKCS8", string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})), false},
+		{"SEC1", string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1})), false},
+		{"非PEM", "not a key", true},
+		{"内容损坏", string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("broken")})), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseECPrivateKeyPEM(tt.pem)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("期望解析失败")
+				}
+				return
+			}
+			if err != nil || !got.Equal(key) {
+				t.Errorf("解析结果不一致: %v", err)
+			}
+		})
+	}
+}
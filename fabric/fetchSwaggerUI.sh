@@ -0,0 +1,16 @@
+#!/bin/sh
+# 下载 Swagger UI 静态资源到 swaggerui/，由 REST 接口服务嵌入（go generate 时执行）
+# 资源不提交到仓库（见 .gitignore），需要 Swagger UI 时先运行 go generate 再构建；
+# 未下载时 /docs/ 只显示提示和 openapi.json 的链接
+set -e
+
+VERSION=5.17.14
+cd "$(dirname "$0")"
+
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+curl -fsSL "https://registry.npmjs.org/swagger-ui-dist/-/swagger-ui-dist-$VERSION.tgz" | tar -xz -C "$tmp"
+for file in swagger-ui-bundle.js swagger-ui.css favicon-32x32.png LICENSE; do
+	cp "$tmp/package/$file" swaggerui/
+done
+echo "已下载 Swagger UI $VERSION"
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"edu/model"
+	"github.com/xuri/excelize/v2"
+)
+
+// ===================== 批量导入 =====================
+
+// 每行的导入结果
+const (
+	ImportWouldCreate = "would-create" // 试运行：校验通过且账本中不存在
+	ImportCreated     = "created"      // 已上链
+	ImportConflict    = "conflict"     // 账本中已存在同ID记录
+	ImportInvalid     = "invalid"      // 校验失败或文件内ID重复
+	ImportFailed      = "failed"       // 查询或提交失败
+	ImportSkipped     = "skipped"      // 导入被取消，未处理
+)
+
+// ImportOptions 导入配置
+type ImportOptions struct {
+	Kind    string            // TestResult / Evaluation
+	Mapping map[string]string // 字段名 -> 表头，未指定的字段按字段名或JSON标签匹配表头
+	Sheet   string            // XLSX工作表，默认第一个
+	DryRun  bool              // 只校验并检查冲突，不提交
+	Workers int               // 并发提交数
+}
+
+// ImportRow 文件中的一行记录
+type ImportRow struct {
+	Line   int // 文件中的行号（表头为第1行）
+	ID     string
+	UserID string
+	Record interface{} // TestResult 或 Evaluation
+	Err    error       // 校验错误
+}
+
+// ImportResult 单行导入结果
+type ImportResult struct {
+	Line          int
+	ID            string
+	UserID        string
+	Status        string
+	TransactionID string
+	BlockNumber   uint64
+	Error         string
+}
+
+// importKind 可导入的记录类型
+type importKind struct {
+	recordType reflect.Type
+	idField    string
+	exists     func(ctx context.Context, backend LedgerBackend, id, userID string) error
+	upload     func(ctx context.Context, backend LedgerBackend, record interface{}) (*TxReceipt, error)
+}
+
+var importKinds = map[string]*importKind{
+	"TestResult": {
+		recordType: reflect.TypeOf(TestResult{}),
+		idField:    "TestID",
+		exists: func(ctx context.Context, backend LedgerBackend, id, userID string) error {
+			_, err := backend.GetTestResultsByIDWithContext(ctx, userID, id)
+			return err
+		},
+		upload: func(ctx context.Context, backend LedgerBackend, record interface{}) (*TxReceipt, error) {
+			return backend.UploadTestResultWithContext(ctx, *record.(*TestResult))
+		},
+	},
+	"Evaluation": {
+		recordType: reflect.TypeOf(Evaluation{}),
+		idField:    "EvaluationID",
+		exists: func(ctx context.Context, backend LedgerBackend, id, userID string) error {
+			_, err := backend.GetEvaluationByIDWithContext(ctx, id, userID)
+			return err
+		},
+		upload: func(ctx context.Context, backend LedgerBackend, record interface{}) (*TxReceipt, error) {
+			return backend.UploadEvaluationWithContext(ctx, *record.(*Evaluation))
+		},
+	},
+}
+
+// ParseColumnMapping 解析 "字段=表头,字段=表头" 格式的列映射
+func ParseColumnMapping(spec string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	if strings.TrimSpace(spec) == "" {
+		return mapping, nil
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("列映射格式错误: %q", pair)
+		}
+		mapping[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return mapping, nil
+}
+
+// ReadImportFile 读取CSV或XLSX文件，按列映射转换为记录并逐行校验
+// 校验失败的行也会返回，错误记录在 ImportRow.Err 中
+func ReadImportFile(path string, options ImportOptions) ([]ImportRow, error) {
+	kind, ok := importKinds[options.Kind]
+	if !ok {
+		return nil, fmt.Errorf("不支持导入的记录类型 %s", options.Kind)
+	}
+
+	var table [][]string
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		table, err = readCSV(path)
+	case ".xlsx":
+		table, err = readXLSX(path, options.Sheet)
+	default:
+		return nil, fmt.Errorf("不支持的文件格式 %s（仅支持 .csv/.xlsx）", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(table) == 0 {
+		return nil, fmt.Errorf("文件为空")
+	}
+
+	columns, err := resolveColumns(kind.recordType, table[0], options.Mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []ImportRow
+	seen := make(map[string]int)
+	for i, cells := range table[1:] {
+		if isBlankRow(cells) {
+			continue
+		}
+		row := buildImportRow(kind, columns, cells)
+		row.Line = i + 2
+		if row.Err == nil {
+			if first, dup := seen[row.ID]; dup {
+				row.Err = fmt.Errorf("ID %s 与第 %d 行重复", row.ID, first)
+			} else {
+				seen[row.ID] = row.Line
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// resolveColumns 返回字段名到列下标的映射
+func resolveColumns(recordType reflect.Type, header []string, mapping map[string]string) (map[string]int, error) {
+	headerIndex := make(map[string]int)
+	for i, name := range header {
+		headerIndex[normalizeHeader(name)] = i
+	}
+
+	columns := make(map[string]int)
+	for field, column := range mapping {
+		if _, ok := recordType.FieldByName(field); !ok || field == "DocType" {
+			return nil, fmt.Errorf("%s 没有字段 %s", recordType.Name(), field)
+		}
+		index, ok := headerIndex[normalizeHeader(column)]
+		if !ok {
+			return nil, fmt.Errorf("表头中找不到列 %s（映射到字段 %s）", column, field)
+		}
+		columns[field] = index
+	}
+
+	// 未显式映射的字段按字段名或JSON标签匹配
+	for i := 0; i < recordType.NumField(); i++ {
+		field := recordType.Field(i)
+		if field.Name == "DocType" {
+			continue
+		}
+		if _, mapped := columns[field.Name]; mapped {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		for _, name := range []string{field.Name, tag} {
+			if index, ok := headerIndex[normalizeHeader(name)]; ok {
+				columns[field.Name] = index
+				break
+			}
+		}
+	}
+	return columns, nil
+}
+
+// normalizeHeader 表头比较时忽略大小写、空格和下划线
+func normalizeHeader(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer("_", "", " ", "").Replace(name)
+}
+
+func buildImportRow(kind *importKind, columns map[string]int, cells []string) ImportRow {
+	value := reflect.New(kind.recordType)
+	for field, index := range columns {
+		if index < len(cells) {
+			value.Elem().FieldByName(field).SetString(strings.TrimSpace(cells[index]))
+		}
+	}
+
+	row := ImportRow{
+		ID:     value.Elem().FieldByName(kind.idField).String(),
+		UserID: value.Elem().FieldByName("UserID").String(),
+		Record: value.Interface(),
+	}
+	row.Err = model.Validate(row.Record)
+	return row
+}
+
+func isBlankRow(cells []string) bool {
+	for _, cell := range cells {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func readCSV(path string) ([][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开CSV文件失败: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	var table [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析CSV文件失败: %v", err)
+		}
+		// csv 会跳过空行，补齐后下标与文件行号保持一致
+		line, _ := reader.FieldPos(0)
+		for len(table) < line-1 {
+			table = append(table, nil)
+		}
+		table = append(table, record)
+	}
+	// Excel 导出的UTF-8 CSV带BOM
+	if len(table) > 0 && len(table[0]) > 0 {
+		table[0][0] = strings.TrimPrefix(table[0][0], "\ufeff")
+	}
+	return table, nil
+}
+
+func readXLSX(path, sheet string) ([][]string, error) {
+	file, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开XLSX文件失败: %v", err)
+	}
+	defer file.Close()
+
+	if sheet == "" {
+		sheet = file.GetSheetName(0)
+	}
+	table, err := file.GetRows(sheet)
+	if err != nil {
+		return nil, fmt.Errorf("读取工作表 %s 失败: %v", sheet, err)
+	}
+	return table, nil
+}
+
+// ===================== 导入执行 =====================
+
+// Import 校验、检查冲突并（非试运行时）并发提交，结果与 rows 一一对应
+// ctx 取消后尚未开始的行标记为 skipped
+func Import(ctx context.Context, backend LedgerBackend, rows []ImportRow, options ImportOptions) []ImportResult {
+	kind := importKinds[options.Kind]
+	workers := options.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	// 冲突检查必须读取最新账本状态
+	if c, ok := backend.(*Client); ok {
+		backend = c.Consistent()
+	}
+
+	results := make([]ImportResult, len(rows))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = importRow(ctx, backend, kind, rows[i], options)
+			}
+		}()
+	}
+
+	for i, row := range rows {
+		results[i] = ImportResult{Line: row.Line, ID: row.ID, UserID: row.UserID, Status: ImportSkipped}
+		if ctx.Err() != nil {
+			continue
+		}
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func importRow(ctx context.Context, backend LedgerBackend, kind *importKind, row ImportRow, options ImportOptions) ImportResult {
+	result := ImportResult{Line: row.Line, ID: row.ID, UserID: row.UserID}
+	if row.Err != nil {
+		result.Status, result.Error = ImportInvalid, row.Err.Error()
+		return result
+	}
+
+	// 查询成功或无权访问（属于其他用户）都说明记录已存在
+	err := kind.exists(ctx, backend, row.ID, row.UserID)
+	switch {
+	case err == nil || errors.Is(err, model.CodeForbidden):
+		result.Status, result.Error = ImportConflict, "账本中已存在该ID的记录"
+		return result
+	case !errors.Is(err, model.CodeNotFound):
+		result.Status, result.Error = ImportFailed, err.Error()
+		return result
+	}
+
+	if options.DryRun {
+		result.Status = ImportWouldCreate
+		return result
+	}
+	receipt, err := kind.upload(ctx, backend, row.Record)
+	if receipt != nil {
+		result.TransactionID, result.BlockNumber = receipt.TransactionID, receipt.BlockNumber
+	}
+	if err != nil {
+		result.Status, result.Error = ImportFailed, err.Error()
+		return result
+	}
+	result.Status = ImportCreated
+	return result
+}
+
+// validateImport 只做文件内校验的试运行结果，不访问账本
+func validateImport(rows []ImportRow) []ImportResult {
+	results := make([]ImportResult, len(rows))
+	for i, row := range rows {
+		results[i] = ImportResult{Line: row.Line, ID: row.ID, UserID: row.UserID, Status: ImportWouldCreate}
+		if row.Err != nil {
+			results[i].Status, results[i].Error = ImportInvalid, row.Err.Error()
+		}
+	}
+	return results
+}
+
+// WriteImportResults 写出逐行结果CSV，用于与原文件核对
+func WriteImportResults(path string, results []ImportResult) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建结果文件失败: %v", err)
+	}
+	defer file.Close()
+	return writeImportResults(file, results)
+}
+
+func writeImportResults(w io.Writer, results []ImportResult) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"line", "id", "user_id", "status", "tx_id", "block_number", "error"})
+	for _, r := range results {
+		block := ""
+		if r.TransactionID != "" {
+			block = strconv.FormatUint(r.BlockNumber, 10)
+		}
+		writer.Write([]string{strconv.Itoa(r.Line), r.ID, r.UserID, r.Status, r.TransactionID, block, r.Error})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// summarizeImport 按状态统计行数
+func summarizeImport(results []ImportResult) string {
+	counts := make(map[string]int)
+	for _, r := range results {
+		counts[r.Status]++
+	}
+	statuses := make([]string, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	parts := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		parts = append(parts, fmt.Sprintf("%s=%d", status, counts[status]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// runImport 从CSV/XLSX批量导入测试结果或测评记录
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	kindName := flags.String("kind", "TestResult", "记录类型（TestResult/Evaluation）")
+	mappingSpec := flags.String("map", "", "列映射，如 TestID=考试编号,UserID=学号,ScoreSum=总分")
+	sheet := flags.String("sheet", "", "XLSX工作表名称，默认第一个")
+	dryRun := flags.Bool("dry-run", false, "只校验并检查冲突，不提交")
+	noCheck := flags.Bool("no-check", false, "试运行时不连接网络检查冲突")
+	workers := flags.Int("workers", 4, "并发提交数")
+	output := flags.String("out", "", "逐行结果CSV文件，默认为 <输入文件>.result.csv")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("需要指定一个导入文件")
+	}
+
+	mapping, err := ParseColumnMapping(*mappingSpec)
+	if err != nil {
+		return err
+	}
+	options := ImportOptions{
+		Kind:    *kindName,
+		Mapping: mapping,
+		Sheet:   *sheet,
+		DryRun:  *dryRun,
+		Workers: *workers,
+	}
+	if *noCheck && !*dryRun {
+		return fmt.Errorf("-no-check 只能与 -dry-run 一起使用")
+	}
+
+	rows, err := ReadImportFile(flags.Arg(0), options)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var results []ImportResult
+	if *noCheck {
+		results = validateImport(rows)
+	} else {
+		backend, err := NewLedgerBackend()
+		if err != nil {
+			return err
+		}
+		defer backend.Close()
+		results = Import(ctx, backend, rows, options)
+	}
+
+	if *output == "" {
+		*output = flags.Arg(0) + ".result.csv"
+	}
+	if err := WriteImportResults(*output, results); err != nil {
+		return err
+	}
+	log.Printf("导入完成（%d 行）: %s，结果已写入 %s", len(results), summarizeImport(results), *output)
+	return nil
+}
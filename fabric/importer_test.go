@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// writeImportCSV 写出测评记录CSV：第3行为空行，第5行等级不合法，第6行与第2行ID重复
+func writeImportCSV(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "evaluations.csv")
+	data := "\ufeff测评编号,User_ID,等级,Feedback\n" +
+		"eval_001,user_001,A,课堂表现积极\n" +
+		"\n" +
+		"eval_002,user_002,B+,作业完成认真\n" +
+		"eval_003,user_003,Z,\n" +
+		"eval_001,user_004,A-,\n" +
+		"eval_004,user_001,C,需要加强练习\n"
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func importOptions(dryRun bool) ImportOptions {
+	return ImportOptions{
+		Kind:    "Evaluation",
+		Mapping: map[string]string{"EvaluationID": "测评编号", "PointsDegree": "等级"},
+		DryRun:  dryRun,
+		Workers: 2,
+	}
+}
+
+func importStatuses(results []ImportResult) map[string]string {
+	statuses := make(map[string]string)
+	for _, r := range results {
+		statuses[r.ID+"@"+r.UserID] = r.Status
+	}
+	return statuses
+}
+
+func TestReadImportFile(t *testing.T) {
+	rows, err := ReadImportFile(writeImportCSV(t), importOptions(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantLines := []int{2, 4, 5, 6, 7}
+	if len(rows) != len(wantLines) {
+		t.Fatalf("读取 %d 行，期望 %d 行", len(rows), len(wantLines))
+	}
+	for i, row := range rows {
+		if row.Line != wantLines[i] {
+			t.Errorf("第 %d 条的行号 = %d，期望 %d", i, row.Line, wantLines[i])
+		}
+	}
+	first := rows[0].Record.(*Evaluation)
+	if first.EvaluationID != "eval_001" || first.PointsDegree != "A" || first.Feedback != "课堂表现积极" || rows[0].Err != nil {
+		t.Errorf("第 2 行 = %+v, %v", first, rows[0].Err)
+	}
+	if rows[2].Err == nil {
+		t.Error("等级不合法的行应校验失败")
+	}
+	if rows[3].Err == nil || !strings.Contains(rows[3].Err.Error(), "第 2 行重复") {
+		t.Errorf("重复ID的错误 = %v", rows[3].Err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		options ImportOptions
+	}{
+		{"不支持的类型", ImportOptions{Kind: "Judgement"}},
+		{"映射到不存在的字段", ImportOptions{Kind: "Evaluation", Mapping: map[string]string{"Score": "测评编号"}}},
+		{"映射到不存在的列", ImportOptions{Kind: "Evaluation", Mapping: map[string]string{"EvaluationID": "编号"}}},
+	} {
+		if _, err := ReadImportFile(writeImportCSV(t), tt.options); err == nil {
+			t.Errorf("%s应返回错误", tt.name)
+		}
+	}
+}
+
+func TestReadImportXLSX(t *testing.T) {
+	file := excelize.NewFile()
+	for i, row := range [][]interface{}{
+		{"Test_ID", "学号", "Score Sum"},
+		{"test_001", "user_001", "95"},
+		{"test_002", "user_002", "abc"},
+	} {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := file.SetSheetRow("Sheet1", cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "tests.xlsx")
+	if err := file.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := ReadImportFile(path, ImportOptions{Kind: "TestResult", Mapping: map[string]string{"UserID": "学号"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0].Err != nil || rows[1].Err == nil {
+		t.Fatalf("读取结果 = %+v", rows)
+	}
+	if test := rows[0].Record.(*TestResult); test.TestID != "test_001" || test.UserID != "user_001" || test.ScoreSum != "95" {
+		t.Errorf("第 2 行 = %+v", test)
+	}
+}
+
+func TestImportDryRunAndConflicts(t *testing.T) {
+	c := newTestEmbeddedClient(t)
+	if _, err := c.UploadEvaluation(testEvaluation("eval_004", "user_001")); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := ReadImportFile(writeImportCSV(t), importOptions(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 试运行检查冲突但不提交
+	keys := len(c.embedded.state)
+	results := Import(context.Background(), c, rows, importOptions(true))
+	want := map[string]string{
+		"eval_001@user_001": ImportWouldCreate,
+		"eval_002@user_002": ImportWouldCreate,
+		"eval_003@user_003": ImportInvalid,
+		"eval_001@user_004": ImportInvalid,
+		"eval_004@user_001": ImportConflict,
+	}
+	if got := importStatuses(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("试运行结果 = %v，期望 %v", got, want)
+	}
+	if len(c.embedded.state) != keys {
+		t.Errorf("试运行写入了 %d 个键", len(c.embedded.state)-keys)
+	}
+	if summary := summarizeImport(results); summary != "conflict=1 invalid=2 would-create=2" {
+		t.Errorf("统计 = %q", summary)
+	}
+
+	// 正式导入只提交通过校验且不冲突的行，重复导入时这些行变为冲突
+	results = Import(context.Background(), c, rows, importOptions(false))
+	want["eval_001@user_001"], want["eval_002@user_002"] = ImportCreated, ImportCreated
+	if got := importStatuses(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("导入结果 = %v，期望 %v", got, want)
+	}
+	for _, r := range results {
+		if r.Status == ImportCreated && r.TransactionID == "" {
+			t.Errorf("第 %d 行已上链但没有交易ID", r.Line)
+		}
+	}
+	if got, err := c.GetEvaluationByID("eval_002", "user_002"); err != nil || got.PointsDegree != "B+" {
+		t.Errorf("导入的记录 = %+v, %v", got, err)
+	}
+
+	again := Import(context.Background(), c, rows, importOptions(false))
+	want["eval_001@user_001"], want["eval_002@user_002"] = ImportConflict, ImportConflict
+	if got := importStatuses(again); !reflect.DeepEqual(got, want) {
+		t.Errorf("重复导入结果 = %v，期望 %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := writeImportResults(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(results)+1 || !strings.HasPrefix(lines[1], "2,eval_001,user_001,created,") {
+		t.Errorf("结果文件 = %s", buf.String())
+	}
+}
+
+// failingBackend 查询记录时返回指定错误，其余方法不应被调用
+type failingBackend struct {
+	LedgerBackend
+	err error
+}
+
+func (b *failingBackend) GetEvaluationByIDWithContext(ctx context.Context, evaluationID, userID string, opts ...CallOption) (*Evaluation, error) {
+	return nil, b.err
+}
+
+func TestImportCheckFailures(t *testing.T) {
+	rows, err := ReadImportFile(writeImportCSV(t), importOptions(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		// 记录属于其他用户时查询返回无权访问，同样视为冲突
+		{"无权访问", localError("record.forbidden"), ImportConflict},
+		{"查询失败", errors.New("所有网关节点均不可用"), ImportFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := Import(context.Background(), &failingBackend{err: tt.err}, rows, importOptions(false))
+			for _, r := range results {
+				if r.Status != ImportInvalid && r.Status != tt.want {
+					t.Errorf("第 %d 行 = %s，期望 %s", r.Line, r.Status, tt.want)
+				}
+			}
+		})
+	}
+
+	// 取消后未开始的行标记为 skipped
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, r := range Import(ctx, &failingBackend{}, rows, importOptions(false)) {
+		if r.Status != ImportSkipped {
+			t.Errorf("取消后第 %d 行 = %s，期望 skipped", r.Line, r.Status)
+		}
+	}
+}
@@ -0,0 +1,594 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// 区块事件中断后重新订阅的等待时间
+const indexerRetryDelay = 5 * time.Second
+
+// indexerSchema 链下索引表结构，表中只保存世界状态的最新值
+const indexerSchema = `
+CREATE TABLE IF NOT EXISTS evaluations (
+	evaluation_id TEXT PRIMARY KEY,
+	user_id       TEXT NOT NULL,
+	points_degree TEXT,
+	feedback      TEXT,
+	teacher_id    TEXT,
+	tx_id         TEXT NOT NULL,
+	block_number  INTEGER NOT NULL,
+	updated_at    TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_evaluations_user ON evaluations(user_id);
+
+CREATE TABLE IF NOT EXISTS test_results (
+	test_id      TEXT PRIMARY KEY,
+	user_id      TEXT NOT NULL,
+	score_sum    TEXT,
+	paper_number TEXT,
+	answer       TEXT,
+	tx_id        TEXT NOT NULL,
+	block_number INTEGER NOT NULL,
+	updated_at   TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_test_results_user ON test_results(user_id);
+CREATE INDEX IF NOT EXISTS idx_test_results_paper ON test_results(paper_number);
+
+CREATE TABLE IF NOT EXISTS judgements (
+	judgement_id   TEXT PRIMARY KEY,
+	user_id        TEXT NOT NULL,
+	objection      TEXT,
+	object_id      TEXT,
+	rating         TEXT,
+	content        TEXT,
+	judgement_time TEXT,
+	tx_id          TEXT NOT NULL,
+	block_number   INTEGER NOT NULL,
+	updated_at     TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_judgements_user ON judgements(user_id);
+CREATE INDEX IF NOT EXISTS idx_judgements_object ON judgements(object_id);
+
+CREATE TABLE IF NOT EXISTS checkpoint (
+	id         INTEGER PRIMARY KEY CHECK (id = 1),
+	next_block INTEGER NOT NULL,
+	updated_at TEXT NOT NULL
+);
+INSERT OR IGNORE INTO checkpoint (id, next_block, updated_at) VALUES (1, 0, '');
+`
+
+// indexerMigrations 旧版本索引库缺少的列，补列后已有记录为空，需 rebuild 回填
+var indexerMigrations = []struct {
+	table, column, definition, index string
+}{
+	{"evaluations", "teacher_id", "TEXT", `CREATE INDEX IF NOT EXISTS idx_evaluations_teacher ON evaluations(teacher_id)`},
+}
+
+// ===================== 链下索引 =====================
+
+// Indexer 订阅区块事件，把 Evaluation/TestResult/Judgement 世界状态同步到 SQLite
+type Indexer struct {
+	client *Client
+	db     *sql.DB
+	readDB *sql.DB // 只读连接，执行调用方传入的SQL
+}
+
+// OpenIndexer 打开（或创建）索引数据库
+func OpenIndexer(c *Client, dbPath string) (*Indexer, error) {
+	db, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("打开索引数据库失败: %v", err)
+	}
+	if _, err := db.Exec(indexerSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化索引表失败: %v", err)
+	}
+	if err := migrateIndexer(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	// 以只读方式打开同一文件，并禁止该连接上的任何写入
+	readDB, err := sql.Open("sqlite3", "file:"+dbPath+"?mode=ro&_query_only=1&_busy_timeout=5000")
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("打开索引数据库失败: %v", err)
+	}
+	return &Indexer{client: c, db: db, readDB: readDB}, nil
+}
+
+// migrateIndexer 为旧版本索引库补充新增的列和索引
+func migrateIndexer(db *sql.DB) error {
+	for _, m := range indexerMigrations {
+		var exists bool
+		err := db.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?`, m.table, m.column).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("读取索引表结构失败: %v", err)
+		}
+		if !exists {
+			if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, m.table, m.column, m.definition)); err != nil {
+				return fmt.Errorf("升级索引表失败: %v", err)
+			}
+			log.Printf("索引表 %s 已新增列 %s，已有记录需执行 rebuild 回填", m.table, m.column)
+		}
+		if _, err := db.Exec(m.index); err != nil {
+			return fmt.Errorf("创建索引失败: %v", err)
+		}
+	}
+	return nil
+}
+
+func (ix *Indexer) Close() error {
+	ix.readDB.Close()
+	return ix.db.Close()
+}
+
+// NextBlock 返回下一个待处理的区块号
+func (ix *Indexer) NextBlock() (uint64, error) {
+	var next uint64
+	if err := ix.db.QueryRow(`SELECT next_block FROM checkpoint WHERE id = 1`).Scan(&next); err != nil {
+		return 0, fmt.Errorf("读取检查点失败: %v", err)
+	}
+	return next, nil
+}
+
+// Reset 清空索引并把检查点置回创世区块
+func (ix *Indexer) Reset() error {
+	tx, err := ix.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		`DELETE FROM evaluations`,
+		`DELETE FROM test_results`,
+		`DELETE FROM judgements`,
+		`UPDATE checkpoint SET next_block = 0, updated_at = '' WHERE id = 1`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("清空索引失败: %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Run 从检查点开始持续同步，连接中断时自动重新订阅，ctx 取消时返回
+func (ix *Indexer) Run(ctx context.Context) error {
+	for {
+		next, err := ix.NextBlock()
+		if err != nil {
+			return err
+		}
+
+		err = ix.follow(ctx, next)
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Printf("索引同步中断，%v 后从区块 %d 重新订阅: %v", indexerRetryDelay, next, err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(indexerRetryDelay):
+		}
+	}
+}
+
+func (ix *Indexer) follow(ctx context.Context, startBlock uint64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	blocks, err := ix.client.BlockEvents(ctx, startBlock)
+	if err != nil {
+		return err
+	}
+	for block := range blocks {
+		if err := ix.applyBlock(block); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("区块事件通道已关闭")
+}
+
+// applyBlock 在同一个数据库事务中应用区块写集并推进检查点
+func (ix *Indexer) applyBlock(block *common.Block) error {
+	blockNumber := block.GetHeader().GetNumber()
+	next, err := ix.NextBlock()
+	if err != nil {
+		return err
+	}
+	if blockNumber < next {
+		return nil // 重新订阅时可能收到已处理的区块
+	}
+
+	writes, err := parseBlockWrites(block, chaincodeID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := ix.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, w := range writes {
+		if err := applyWrite(tx, w); err != nil {
+			return fmt.Errorf("应用交易 %s 键 %s 失败: %v", w.TxID, w.Key, err)
+		}
+	}
+	if _, err := tx.Exec(`UPDATE checkpoint SET next_block = ?, updated_at = ? WHERE id = 1`,
+		blockNumber+1, time.Now().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("更新检查点失败: %v", err)
+	}
+	return tx.Commit()
+}
+
+// applyWrite 按键前缀把一次写入映射到对应的表
+func applyWrite(tx *sql.Tx, w blockWrite) error {
+	parts := strings.SplitN(w.Key, "-", 2)
+	if len(parts) != 2 {
+		return nil // 非业务记录键
+	}
+	recordType, recordID := parts[0], parts[1]
+	updatedAt := w.Timestamp.Format(time.RFC3339)
+
+	switch recordType {
+	case "Evaluation":
+		if w.IsDelete {
+			_, err := tx.Exec(`DELETE FROM evaluations WHERE evaluation_id = ?`, recordID)
+			return err
+		}
+		var e Evaluation
+		if err := json.Unmarshal(w.Value, &e); err != nil {
+			return err
+		}
+		// 教师取记录中的 Teacher_ID，而不是交易提交者（导入服务等代录的测评提交者不是教师）
+		_, err := tx.Exec(`INSERT OR REPLACE INTO evaluations
+			(evaluation_id, user_id, points_degree, feedback, teacher_id, tx_id, block_number, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			recordID, e.UserID, e.PointsDegree, e.Feedback, e.TeacherID, w.TxID, w.BlockNumber, updatedAt)
+		return err
+
+	case "TestResult":
+		if w.IsDelete {
+			_, err := tx.Exec(`DELETE FROM test_results WHERE test_id = ?`, recordID)
+			return err
+		}
+		var t TestResult
+		if err := json.Unmarshal(w.Value, &t); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`INSERT OR REPLACE INTO test_results
+			(test_id, user_id, score_sum, paper_number, answer, tx_id, block_number, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			recordID, t.UserID, t.ScoreSum, t.PaperNumber, t.Answer, w.TxID, w.BlockNumber, updatedAt)
+		return err
+
+	case "Judgement":
+		if w.IsDelete {
+			_, err := tx.Exec(`DELETE FROM judgements WHERE judgement_id = ?`, recordID)
+			return err
+		}
+		var j Judgement
+		if err := json.Unmarshal(w.Value, &j); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`INSERT OR REPLACE INTO judgements
+			(judgement_id, user_id, objection, object_id, rating, content, judgement_time, tx_id, block_number, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			recordID, j.UserID, j.JudgementObjection, j.JudgementObjectID, j.JudgementRating,
+			j.JudgementContent, j.JudgementTime, w.TxID, w.BlockNumber, updatedAt)
+		return err
+	}
+	return nil
+}
+
+// ===================== 报表查询 =====================
+
+// Query 执行只读SQL查询，每行以列名为键返回
+// 查询在只读连接上执行，写入语句会返回错误
+func (ix *Indexer) Query(query string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := ix.readDB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询执行失败: %v", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("结果读取失败: %v", err)
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				row[column] = string(b)
+			} else {
+				row[column] = values[i]
+			}
+		}
+		results = append(results, row)
+	}
+	return results, rows.Err()
+}
+
+// PaperScoreSummary 单张试卷的成绩统计
+type PaperScoreSummary struct {
+	PaperNumber string  `json:"paperNumber"`
+	Count       int     `json:"count"`
+	Average     float64 `json:"average"`
+	Min         float64 `json:"min"`
+	Max         float64 `json:"max"`
+}
+
+// ScoreSummaryByPaper 按试卷编号统计测试成绩
+func (ix *Indexer) ScoreSummaryByPaper() ([]PaperScoreSummary, error) {
+	rows, err := ix.db.Query(`SELECT paper_number, COUNT(*),
+		AVG(CAST(score_sum AS REAL)), MIN(CAST(score_sum AS REAL)), MAX(CAST(score_sum AS REAL))
+		FROM test_results GROUP BY paper_number ORDER BY paper_number`)
+	if err != nil {
+		return nil, fmt.Errorf("查询执行失败: %v", err)
+	}
+	defer rows.Close()
+
+	var summaries []PaperScoreSummary
+	for rows.Next() {
+		var s PaperScoreSummary
+		if err := rows.Scan(&s.PaperNumber, &s.Count, &s.Average, &s.Min, &s.Max); err != nil {
+			return nil, fmt.Errorf("结果读取失败: %v", err)
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries, rows.Err()
+}
+
+// 评价汇总的默认最少评价人数
+const defaultJudgementMinCount = 5
+
+// JudgementSummary 评价评分汇总
+// 评价人数低于 MinCount 时只返回 Hidden，避免从均值或分布推断出个人评分
+type JudgementSummary struct {
+	ObjectID  string          `json:"objectId,omitempty"`
+	TeacherID string          `json:"teacherId,omitempty"`
+	MinCount  int             `json:"minCount"`
+	Hidden    bool            `json:"hidden"`
+	Stats     *JudgementStats `json:"stats,omitempty"`
+}
+
+// JudgementStats 评分统计，评分为 1-5 的整数
+// 同一用户对同一对象的多条评价只计最后写入的一条；教师汇总中学生对不同测评的评价分别计入，
+// 因此 Count 可能大于 Raters，隐藏阈值按 Raters 判断
+type JudgementStats struct {
+	Count         int            `json:"count"`         // 计入的评分条数
+	Raters        int            `json:"raters"`        // 评价人数（去重用户）
+	Mean          float64        `json:"mean"`          // 平均评分
+	Distribution  map[string]int `json:"distribution"`  // 评分 -> 条数
+	ObjectionRate float64        `json:"objectionRate"` // 提出异议的比例
+}
+
+// GetJudgementSummary 汇总评价对象（如某条测评）收到的评分，按 object_id 索引查询
+func (ix *Indexer) GetJudgementSummary(objectID string, minCount int) (*JudgementSummary, error) {
+	if objectID == "" {
+		return nil, fmt.Errorf("评价对象ID不能为空")
+	}
+	stats, err := ix.judgementStats(`judgements j WHERE j.object_id = ?`, objectID)
+	if err != nil {
+		return nil, err
+	}
+	return newJudgementSummary(&JudgementSummary{ObjectID: objectID}, stats, minCount), nil
+}
+
+// GetTeacherJudgementSummary 汇总教师所录入测评收到的评分
+// 教师为测评记录中的 Teacher_ID，评价对象不是测评的评价不计入
+func (ix *Indexer) GetTeacherJudgementSummary(teacherID string, minCount int) (*JudgementSummary, error) {
+	if teacherID == "" {
+		return nil, fmt.Errorf("教师ID不能为空")
+	}
+	stats, err := ix.judgementStats(`evaluations e JOIN judgements j ON j.object_id = e.evaluation_id
+		WHERE e.teacher_id = ?`, teacherID)
+	if err != nil {
+		return nil, err
+	}
+	return newJudgementSummary(&JudgementSummary{TeacherID: teacherID}, stats, minCount), nil
+}
+
+// JudgementSummaryByTeacher 按教师汇总评分，人数不足的教师同样列出但隐藏统计
+func (ix *Indexer) JudgementSummaryByTeacher(minCount int) ([]JudgementSummary, error) {
+	rows, err := ix.db.Query(`SELECT DISTINCT teacher_id FROM evaluations
+		WHERE teacher_id IS NOT NULL AND teacher_id <> '' ORDER BY teacher_id`)
+	if err != nil {
+		return nil, fmt.Errorf("查询执行失败: %v", err)
+	}
+	var teachers []string
+	for rows.Next() {
+		var teacherID string
+		if err := rows.Scan(&teacherID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("结果读取失败: %v", err)
+		}
+		teachers = append(teachers, teacherID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	summaries := make([]JudgementSummary, 0, len(teachers))
+	for _, teacherID := range teachers {
+		summary, err := ix.GetTeacherJudgementSummary(teacherID, minCount)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, *summary)
+	}
+	return summaries, nil
+}
+
+// judgementStats 统计 from 子句（评价表别名 j）选出的评价
+func (ix *Indexer) judgementStats(from string, args ...interface{}) (*JudgementStats, error) {
+	stats := &JudgementStats{Distribution: map[string]int{"1": 0, "2": 0, "3": 0, "4": 0, "5": 0}}
+	if err := ix.db.QueryRow(`SELECT COUNT(DISTINCT j.user_id) FROM `+from, args...).Scan(&stats.Raters); err != nil {
+		return nil, fmt.Errorf("查询执行失败: %v", err)
+	}
+
+	// 按区块号取每个用户对每个对象最后写入的评价，重复评价不会放大个人评分的权重
+	rows, err := ix.db.Query(`SELECT CAST(rating AS INTEGER) AS r, COUNT(*),
+		SUM(CASE WHEN objection <> '' THEN 1 ELSE 0 END)
+		FROM (SELECT j.rating, j.objection, ROW_NUMBER() OVER (
+			PARTITION BY j.user_id, j.object_id ORDER BY j.block_number DESC, j.judgement_id DESC) AS n
+			FROM `+from+`)
+		WHERE n = 1 GROUP BY r`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询执行失败: %v", err)
+	}
+	defer rows.Close()
+
+	var sum, objections int
+	for rows.Next() {
+		var rating, count, objected int
+		if err := rows.Scan(&rating, &count, &objected); err != nil {
+			return nil, fmt.Errorf("结果读取失败: %v", err)
+		}
+		stats.Distribution[strconv.Itoa(rating)] += count
+		stats.Count += count
+		sum += rating * count
+		objections += objected
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if stats.Count > 0 {
+		stats.Mean = float64(sum) / float64(stats.Count)
+		stats.ObjectionRate = float64(objections) / float64(stats.Count)
+	}
+	return stats, nil
+}
+
+// newJudgementSummary 评价人数达到阈值时才附上统计
+func newJudgementSummary(summary *JudgementSummary, stats *JudgementStats, minCount int) *JudgementSummary {
+	if minCount < 1 {
+		minCount = 1
+	}
+	summary.MinCount = minCount
+	if stats.Raters < minCount {
+		summary.Hidden = true
+		return summary
+	}
+	summary.Stats = stats
+	return summary
+}
+
+// ===================== 命令行 =====================
+
+// runIndexer 索引服务命令：run 持续同步，rebuild 从创世区块重建，query 执行报表查询，
+// judgements/teachers 输出评价对象或教师的评分汇总
+func runIndexer(args []string) error {
+	flags := flag.NewFlagSet("indexer", flag.ExitOnError)
+	dbPath := flags.String("db", "edu_index.db", "SQLite索引数据库文件")
+	minCount := flags.Int("min-count", defaultJudgementMinCount, "评价人数少于该值时隐藏汇总")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		return fmt.Errorf("缺少子命令（run/rebuild/query/judgements/teachers）")
+	}
+
+	// 评分汇总同样只读本地数据库
+	switch flags.Arg(0) {
+	case "judgements", "teachers":
+		ix, err := OpenIndexer(nil, *dbPath)
+		if err != nil {
+			return err
+		}
+		defer ix.Close()
+
+		var result interface{}
+		switch {
+		case flags.Arg(0) == "judgements" && flags.NArg() < 2:
+			return fmt.Errorf("缺少评价对象ID")
+		case flags.Arg(0) == "judgements":
+			result, err = ix.GetJudgementSummary(flags.Arg(1), *minCount)
+		case flags.NArg() >= 2:
+			result, err = ix.GetTeacherJudgementSummary(flags.Arg(1), *minCount)
+		default:
+			result, err = ix.JudgementSummaryByTeacher(*minCount)
+		}
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+
+	// query 只读本地数据库，不需要连接网络
+	if flags.Arg(0) == "query" {
+		if flags.NArg() < 2 {
+			return fmt.Errorf("缺少SQL语句")
+		}
+		ix, err := OpenIndexer(nil, *dbPath)
+		if err != nil {
+			return err
+		}
+		defer ix.Close()
+
+		rows, err := ix.Query(flags.Arg(1))
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		for _, row := range rows {
+			encoder.Encode(row)
+		}
+		return nil
+	}
+
+	client, err := NewClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	ix, err := OpenIndexer(client, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer ix.Close()
+
+	switch flags.Arg(0) {
+	case "run":
+	case "rebuild":
+		if err := ix.Reset(); err != nil {
+			return err
+		}
+		log.Printf("索引已清空，从创世区块重建")
+	default:
+		return fmt.Errorf("未知子命令 %s", flags.Arg(0))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return ix.Run(ctx)
+}
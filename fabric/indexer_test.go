@@ -0,0 +1,416 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go-apiv2/msp"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestIndexerQueryReadOnly(t *testing.T) {
+	ix, err := OpenIndexer(nil, filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+	if _, err := ix.db.Exec(`INSERT INTO evaluations (evaluation_id, user_id, points_degree, tx_id, block_number, updated_at)
+		VALUES ('eval_001', 'user_001', 'A', 'tx1', 1, '2024-06-01T08:00:00Z')`); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := ix.Query(`SELECT evaluation_id, points_degree FROM evaluations WHERE user_id = ?`, "user_001")
+	if err != nil {
+		t.Fatalf("只读查询失败: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["evaluation_id"] != "eval_001" || rows[0]["points_degree"] != "A" {
+		t.Fatalf("查询结果 = %v", rows)
+	}
+
+	for _, query := range []string{
+		`DELETE FROM evaluations`,
+		`UPDATE evaluations SET points_degree = 'C'`,
+		`UPDATE checkpoint SET next_block = 0`,
+		`DROP TABLE evaluations`,
+		`PRAGMA query_only = 0; DELETE FROM evaluations`,
+	} {
+		if _, err := ix.Query(query); err == nil {
+			t.Errorf("写入语句 %q 应当失败", query)
+		}
+	}
+
+	// 索引写入仍使用读写连接，只读连接能看到新写入的数据
+	if _, err := ix.db.Exec(`UPDATE evaluations SET points_degree = 'B'`); err != nil {
+		t.Fatal(err)
+	}
+	rows, err = ix.Query(`SELECT points_degree FROM evaluations`)
+	if err != nil || len(rows) != 1 || rows[0]["points_degree"] != "B" {
+		t.Errorf("写入后查询 = %v, %v，期望只有一行且评分为 B", rows, err)
+	}
+}
+
+// indexTestWrites 在一个事务中应用写入，区块号按顺序递增
+func indexTestWrites(t *testing.T, ix *Indexer, records ...interface{}) {
+	t.Helper()
+	tx, err := ix.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	for i, record := range records {
+		var key string
+		switch r := record.(type) {
+		case Evaluation:
+			key = "Evaluation-" + r.EvaluationID
+		case Judgement:
+			key = "Judgement-" + r.JudgementID
+		}
+		value, err := json.Marshal(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := blockWrite{BlockNumber: uint64(i + 1), TxID: fmt.Sprintf("tx%d", i+1), Timestamp: time.Now(), Creator: "importer", Key: key, Value: value}
+		if err := applyWrite(tx, w); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testRating(id, user, object, rating, objection string) Judgement {
+	return Judgement{JudgementID: id, UserID: user, JudgementObjectID: object, JudgementRating: rating,
+		JudgementObjection: objection, JudgementTime: "2024-06-01T08:00:00Z"}
+}
+
+func TestJudgementSummary(t *testing.T) {
+	ix, err := OpenIndexer(nil, filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+	indexTestWrites(t, ix,
+		Evaluation{EvaluationID: "eval_001", UserID: "user_001", PointsDegree: "A", TeacherID: "teacher_001"},
+		Evaluation{EvaluationID: "eval_002", UserID: "user_002", PointsDegree: "B", TeacherID: "teacher_001"},
+		Evaluation{EvaluationID: "eval_003", UserID: "user_003", PointsDegree: "C", TeacherID: "teacher_002"},
+		testRating("judge_001", "user_001", "eval_001", "5", ""),
+		testRating("judge_002", "user_002", "eval_001", "4", "评分偏低"),
+		testRating("judge_003", "user_003", "eval_001", "3", ""),
+		// 同一用户对同一对象的重复评价只计最后一条
+		testRating("judge_004", "user_001", "eval_001", "1", ""),
+		testRating("judge_005", "user_001", "eval_002", "5", ""),
+		testRating("judge_006", "user_004", "eval_003", "2", ""),
+		testRating("judge_007", "user_004", "other_object", "5", ""),
+	)
+
+	tests := []struct {
+		name     string
+		summary  func() (*JudgementSummary, error)
+		hidden   bool
+		count    int
+		raters   int
+		mean     float64
+		dist     map[string]int
+		objected float64
+	}{
+		{"评价对象", func() (*JudgementSummary, error) { return ix.GetJudgementSummary("eval_001", 3) },
+			false, 3, 3, 8.0 / 3, map[string]int{"1": 1, "2": 0, "3": 1, "4": 1, "5": 0}, 1.0 / 3},
+		{"人数不足时隐藏", func() (*JudgementSummary, error) { return ix.GetJudgementSummary("eval_001", 4) },
+			true, 0, 0, 0, nil, 0},
+		{"重复评价不计入人数", func() (*JudgementSummary, error) { return ix.GetJudgementSummary("eval_002", 2) },
+			true, 0, 0, 0, nil, 0},
+		{"没有评价", func() (*JudgementSummary, error) { return ix.GetJudgementSummary("eval_404", 1) },
+			true, 0, 0, 0, nil, 0},
+		// 教师汇总中学生对不同测评的评价分别计入
+		{"教师", func() (*JudgementSummary, error) { return ix.GetTeacherJudgementSummary("teacher_001", 3) },
+			false, 4, 3, 13.0 / 4, map[string]int{"1": 1, "2": 0, "3": 1, "4": 1, "5": 1}, 1.0 / 4},
+		{"教师只统计测评收到的评价", func() (*JudgementSummary, error) { return ix.GetTeacherJudgementSummary("teacher_002", 1) },
+			false, 1, 1, 2, map[string]int{"1": 0, "2": 1, "3": 0, "4": 0, "5": 0}, 0},
+		{"提交者不是教师", func() (*JudgementSummary, error) { return ix.GetTeacherJudgementSummary("importer", 1) },
+			true, 0, 0, 0, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, err := tt.summary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary.Hidden != tt.hidden || (summary.Stats == nil) != tt.hidden {
+				t.Fatalf("汇总 = %+v，期望隐藏 %v", summary, tt.hidden)
+			}
+			if tt.hidden {
+				return
+			}
+			s := summary.Stats
+			if s.Count != tt.count || s.Raters != tt.raters {
+				t.Errorf("条数/人数 = %d/%d，期望 %d/%d", s.Count, s.Raters, tt.count, tt.raters)
+			}
+			if math.Abs(s.Mean-tt.mean) > 1e-9 || math.Abs(s.ObjectionRate-tt.objected) > 1e-9 {
+				t.Errorf("均值/异议比例 = %v/%v，期望 %v/%v", s.Mean, s.ObjectionRate, tt.mean, tt.objected)
+			}
+			if !reflect.DeepEqual(s.Distribution, tt.dist) {
+				t.Errorf("分布 = %v，期望 %v", s.Distribution, tt.dist)
+			}
+		})
+	}
+
+	summaries, err := ix.JudgementSummaryByTeacher(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 || summaries[0].TeacherID != "teacher_001" || summaries[0].Hidden ||
+		summaries[1].TeacherID != "teacher_002" || !summaries[1].Hidden {
+		t.Errorf("按教师汇总 = %+v", summaries)
+	}
+	if _, err := ix.GetJudgementSummary("", 1); err == nil {
+		t.Error("空对象ID应当失败")
+	}
+	if _, err := ix.GetTeacherJudgementSummary("", 1); err == nil {
+		t.Error("空教师ID应当失败")
+	}
+}
+
+// testTx 测试区块中的一笔交易
+type testTx struct {
+	invalid bool   // 验证未通过
+	config  bool   // 配置交易
+	ns      string // 链码命名空间，为空时为 chaincodeID
+	writes  []*kvrwset.KVWrite
+}
+
+func putRecord(t *testing.T, key string, record interface{}) *kvrwset.KVWrite {
+	t.Helper()
+	value, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &kvrwset.KVWrite{Key: key, Value: value}
+}
+
+func deleteRecord(key string) *kvrwset.KVWrite {
+	return &kvrwset.KVWrite{Key: key, IsDelete: true}
+}
+
+// testBlock 构造区块，交易ID为 tx<区块号>-<序号>
+func testBlock(t *testing.T, number uint64, txs ...testTx) *common.Block {
+	t.Helper()
+	block := &common.Block{
+		Header:   &common.BlockHeader{Number: number},
+		Data:     &common.BlockData{},
+		Metadata: &common.BlockMetadata{Metadata: make([][]byte, common.BlockMetadataIndex_TRANSACTIONS_FILTER+1)},
+	}
+	filter := make([]byte, len(txs))
+	for i, tx := range txs {
+		filter[i] = byte(peer.TxValidationCode_VALID)
+		if tx.invalid {
+			filter[i] = byte(peer.TxValidationCode_MVCC_READ_CONFLICT)
+		}
+		ns := tx.ns
+		if ns == "" {
+			ns = chaincodeID
+		}
+		headerType := common.HeaderType_ENDORSER_TRANSACTION
+		if tx.config {
+			headerType = common.HeaderType_CONFIG
+		}
+		results := mustMarshal(t, &rwset.TxReadWriteSet{NsRwset: []*rwset.NsReadWriteSet{
+			{Namespace: ns, Rwset: mustMarshal(t, &kvrwset.KVRWSet{Writes: tx.writes})},
+		}})
+		responsePayload := mustMarshal(t, &peer.ProposalResponsePayload{Extension: mustMarshal(t, &peer.ChaincodeAction{Results: results})})
+		actionPayload := mustMarshal(t, &peer.ChaincodeActionPayload{Action: &peer.ChaincodeEndorsedAction{ProposalResponsePayload: responsePayload}})
+		payload := mustMarshal(t, &common.Payload{
+			Header: &common.Header{
+				ChannelHeader: mustMarshal(t, &common.ChannelHeader{
+					Type:      int32(headerType),
+					TxId:      fmt.Sprintf("tx%d-%d", number, i),
+					Timestamp: timestamppb.New(time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)),
+				}),
+				SignatureHeader: mustMarshal(t, &common.SignatureHeader{Creator: mustMarshal(t, &msp.SerializedIdentity{Mspid: "Org1MSP"})}),
+			},
+			Data: mustMarshal(t, &peer.Transaction{Actions: []*peer.TransactionAction{{Payload: actionPayload}}}),
+		})
+		block.Data.Data = append(block.Data.Data, mustMarshal(t, &common.Envelope{Payload: payload}))
+	}
+	block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = filter
+	return block
+}
+
+// dumpIndex 按表和主键列出索引中的记录：表 主键 主要字段 交易ID
+func dumpIndex(t *testing.T, ix *Indexer) []string {
+	t.Helper()
+	rows, err := ix.Query(`
+		SELECT 'evaluations' AS tbl, evaluation_id AS id, points_degree || '/' || COALESCE(teacher_id, '') AS value, tx_id FROM evaluations
+		UNION ALL SELECT 'judgements', judgement_id, rating, tx_id FROM judgements
+		UNION ALL SELECT 'test_results', test_id, score_sum, tx_id FROM test_results
+		ORDER BY tbl, id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, row := range rows {
+		lines = append(lines, fmt.Sprintf("%v %v %v %v", row["tbl"], row["id"], row["value"], row["tx_id"]))
+	}
+	return lines
+}
+
+func TestIndexerApplyBlock(t *testing.T) {
+	eval := func(id, degree, teacher string) Evaluation {
+		return Evaluation{EvaluationID: id, UserID: "user_001", PointsDegree: degree, TeacherID: teacher}
+	}
+	tests := []struct {
+		name    string
+		blocks  func(t *testing.T) []*common.Block
+		wantErr string // 最后一个区块的错误
+		next    uint64
+		rows    []string
+	}{
+		{"三类记录", func(t *testing.T) []*common.Block {
+			return []*common.Block{testBlock(t, 0, testTx{writes: []*kvrwset.KVWrite{
+				putRecord(t, "Evaluation-eval_001", eval("eval_001", "A", "teacher_001")),
+				putRecord(t, "TestResult-test_001", TestResult{TestID: "test_001", UserID: "user_001", ScoreSum: "98"}),
+				putRecord(t, "Judgement-judge_001", testRating("judge_001", "user_001", "eval_001", "5", "")),
+			}})}
+		}, "", 1, []string{"evaluations eval_001 A/teacher_001 tx0-0", "judgements judge_001 5 tx0-0", "test_results test_001 98 tx0-0"}},
+
+		{"修改覆盖旧值", func(t *testing.T) []*common.Block {
+			return []*common.Block{
+				testBlock(t, 0, testTx{writes: []*kvrwset.KVWrite{putRecord(t, "Evaluation-eval_001", eval("eval_001", "A", "teacher_001"))}}),
+				testBlock(t, 1, testTx{writes: []*kvrwset.KVWrite{putRecord(t, "Evaluation-eval_001", eval("eval_001", "B", "teacher_002"))}}),
+			}
+		}, "", 2, []string{"evaluations eval_001 B/teacher_002 tx1-0"}},
+
+		{"删除", func(t *testing.T) []*common.Block {
+			return []*common.Block{
+				testBlock(t, 0, testTx{writes: []*kvrwset.KVWrite{
+					putRecord(t, "Evaluation-eval_001", eval("eval_001", "A", "")),
+					putRecord(t, "Evaluation-eval_002", eval("eval_002", "B", "")),
+					putRecord(t, "Judgement-judge_001", testRating("judge_001", "user_001", "eval_001", "5", "")),
+				}}),
+				testBlock(t, 1, testTx{writes: []*kvrwset.KVWrite{deleteRecord("Evaluation-eval_001"), deleteRecord("Judgement-judge_001"), deleteRecord("TestResult-test_404")}}),
+			}
+		}, "", 2, []string{"evaluations eval_002 B/ tx0-0"}},
+
+		{"跳过无效交易和配置交易", func(t *testing.T) []*common.Block {
+			return []*common.Block{testBlock(t, 0,
+				testTx{invalid: true, writes: []*kvrwset.KVWrite{putRecord(t, "Evaluation-eval_001", eval("eval_001", "A", ""))}},
+				testTx{config: true},
+				testTx{writes: []*kvrwset.KVWrite{putRecord(t, "Evaluation-eval_002", eval("eval_002", "B", ""))}},
+			)}
+		}, "", 1, []string{"evaluations eval_002 B/ tx0-2"}},
+
+		{"忽略其他链码和非业务键", func(t *testing.T) []*common.Block {
+			return []*common.Block{testBlock(t, 0,
+				testTx{ns: "othercc", writes: []*kvrwset.KVWrite{putRecord(t, "Evaluation-eval_001", eval("eval_001", "A", ""))}},
+				testTx{writes: []*kvrwset.KVWrite{
+					putRecord(t, "AccessGrant-tx1", map[string]string{"docType": "AccessGrant"}),
+					{Key: "studentKeys", Value: []byte("key")},
+				}},
+			)}
+		}, "", 1, nil},
+
+		{"重新订阅时跳过已处理的区块", func(t *testing.T) []*common.Block {
+			return []*common.Block{
+				testBlock(t, 0, testTx{writes: []*kvrwset.KVWrite{putRecord(t, "Evaluation-eval_001", eval("eval_001", "A", ""))}}),
+				testBlock(t, 0, testTx{writes: []*kvrwset.KVWrite{putRecord(t, "Evaluation-eval_001", eval("eval_001", "F", ""))}}),
+			}
+		}, "", 1, []string{"evaluations eval_001 A/ tx0-0"}},
+
+		{"记录无法解析时整个区块不生效", func(t *testing.T) []*common.Block {
+			return []*common.Block{
+				testBlock(t, 0, testTx{writes: []*kvrwset.KVWrite{putRecord(t, "Evaluation-eval_001", eval("eval_001", "A", ""))}}),
+				testBlock(t, 1, testTx{writes: []*kvrwset.KVWrite{
+					putRecord(t, "Evaluation-eval_002", eval("eval_002", "B", "")),
+					{Key: "Judgement-judge_001", Value: []byte("{")},
+				}}),
+			}
+		}, "应用交易 tx1-0 键 Judgement-judge_001 失败", 1, []string{"evaluations eval_001 A/ tx0-0"}},
+
+		{"交易无法解析", func(t *testing.T) []*common.Block {
+			block := testBlock(t, 0)
+			block.Data.Data = [][]byte{[]byte("not an envelope")}
+			block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = []byte{byte(peer.TxValidationCode_VALID)}
+			return []*common.Block{block}
+		}, "解析区块 0 第 0 笔交易失败", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ix, err := OpenIndexer(nil, filepath.Join(t.TempDir(), "index.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ix.Close()
+
+			blocks := tt.blocks(t)
+			for i, block := range blocks {
+				err = ix.applyBlock(block)
+				if i < len(blocks)-1 && err != nil {
+					t.Fatalf("应用区块 %d 失败: %v", i, err)
+				}
+			}
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("错误 = %v，期望 %q", err, tt.wantErr)
+			}
+			if next, err := ix.NextBlock(); err != nil || next != tt.next {
+				t.Errorf("检查点 = %d, %v，期望 %d", next, err, tt.next)
+			}
+			if got := dumpIndex(t, ix); !reflect.DeepEqual(got, tt.rows) {
+				t.Errorf("索引内容 = %q，期望 %q", got, tt.rows)
+			}
+
+			// 重建前清空索引和检查点
+			if err := ix.Reset(); err != nil {
+				t.Fatal(err)
+			}
+			if next, _ := ix.NextBlock(); next != 0 || dumpIndex(t, ix) != nil {
+				t.Errorf("Reset 后检查点 = %d，索引 = %q", next, dumpIndex(t, ix))
+			}
+		})
+	}
+}
+
+// 旧版本索引库打开时补充 teacher_id 列，已有记录保留
+func TestIndexerMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := strings.Replace(indexerSchema, "\tteacher_id    TEXT,\n", "", 1)
+	if old == indexerSchema {
+		t.Fatal("表结构中没有 teacher_id 列")
+	}
+	for _, stmt := range []string{old, `INSERT INTO evaluations (evaluation_id, user_id, points_degree, tx_id, block_number, updated_at)
+		VALUES ('eval_001', 'user_001', 'A', 'tx1', 1, '2024-06-01T08:00:00Z')`} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	for i := 0; i < 2; i++ { // 第二次打开时列已存在，不再升级
+		ix, err := OpenIndexer(nil, path)
+		if err != nil {
+			t.Fatalf("第 %d 次打开失败: %v", i+1, err)
+		}
+		rows, err := ix.Query(`SELECT COUNT(*) AS n FROM pragma_index_list('evaluations') WHERE name = 'idx_evaluations_teacher'`)
+		if err != nil || rows[0]["n"] != int64(1) {
+			t.Errorf("teacher_id 索引 = %v, %v", rows, err)
+		}
+		if got := dumpIndex(t, ix); !reflect.DeepEqual(got, []string{"evaluations eval_001 A/ tx1"}) {
+			t.Errorf("升级后的记录 = %q", got)
+		}
+		ix.Close()
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"edu/chaincode/atcc"
+)
+
+// ===================== 账本后端 =====================
+
+// LedgerBackend 记录操作接口
+// *Client 通过Fabric网关实现，MemoryBackend 在本地内存中模拟链码，用于无网络环境下的开发和演示
+type LedgerBackend interface {
+	UploadEvaluationWithContext(ctx context.Context, evaluation Evaluation, opts ...CallOption) (*TxReceipt, error)
+	ModifyEvaluationWithContext(ctx context.Context, evaluationID string, newEvaluation Evaluation, opts ...CallOption) (*TxReceipt, error)
+	GetEvaluationByIDWithContext(ctx context.Context, evaluationID, userID string, opts ...CallOption) (*Evaluation, error)
+	GetEvaluationByUserWithContext(ctx context.Context, userID string, opts ...CallOption) ([]Evaluation, error)
+
+	UploadTestResultWithContext(ctx context.Context, test TestResult, opts ...CallOption) (*TxReceipt, error)
+	GetTestResultsByUserWithContext(ctx context.Context, userID string, opts ...CallOption) ([]TestResult, error)
+	GetTestResultsByIDWithContext(ctx context.Context, userID, testID string, opts ...CallOption) (*TestResult, error)
+
+	UploadJudgementWithContext(ctx context.Context, judgement Judgement, opts ...CallOption) (*TxReceipt, error)
+	GetJudgementByUserWithContext(ctx context.Context, userID string, opts ...CallOption) ([]Judgement, error)
+	GetJudgementByIDWithContext(ctx context.Context, userID, judgementID string, opts ...CallOption) (*Judgement, error)
+
+	DeleteRecordWithContext(ctx context.Context, recordType, recordID string, opts ...CallOption) (*TxReceipt, error)
+
+	Close() error
+}
+
+var (
+	_ LedgerBackend = (*Client)(nil)
+	_ LedgerBackend = (*MemoryBackend)(nil)
+)
+
+// 后端选择的环境变量
+const (
+	ledgerBackendEnv = "EDU_LEDGER_BACKEND" // fabric（默认）/ memory / embedded
+	ledgerFileEnv    = "EDU_LEDGER_FILE"    // 内存后端的持久化文件，为空时只保存在内存中
+)
+
+// NewLedgerBackend 按环境变量选择后端：
+// EDU_LEDGER_BACKEND=memory 时使用内存后端（不检查身份和授权），并从 EDU_LEDGER_FILE 加载和保存数据，
+// embedded 时在进程内运行 edu/chaincode 的链码，否则连接Fabric网关
+func NewLedgerBackend(opts ...ClientOption) (LedgerBackend, error) {
+	switch backend := os.Getenv(ledgerBackendEnv); backend {
+	case "", "fabric":
+		return NewClient(opts...)
+	case "memory":
+		return NewMemoryBackend(os.Getenv(ledgerFileEnv))
+	case "embedded":
+		cc, err := atcc.NewChaincode()
+		if err != nil {
+			return nil, fmt.Errorf("创建进程内链码失败: %v", err)
+		}
+		return NewEmbeddedClient(cc, opts...)
+	default:
+		return nil, fmt.Errorf("未知的账本后端 %s（可选 fabric/memory/embedded）", backend)
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+)
+
+// ErrClientClosed 客户端关闭（或正在关闭）后发起的调用返回该错误
+var ErrClientClosed = errors.New("客户端已关闭")
+
+// ===================== 客户端生命周期 =====================
+
+// lifecycle 跟踪进行中的调用，支持拒绝新调用后等待已有调用结束
+// 由 As 等派生视图共享，任一视图关闭即关闭整个客户端
+type lifecycle struct {
+	mu         sync.Mutex
+	closing    bool
+	active     int
+	idle       chan struct{} // 关闭中且没有进行中的调用时关闭
+	idleClosed bool
+
+	closeOnce sync.Once
+	stopWatch chan struct{}
+	watchDone chan struct{}
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{idle: make(chan struct{})}
+}
+
+// acquire 登记一次调用，客户端关闭中返回 false
+func (l *lifecycle) acquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closing {
+		return false
+	}
+	l.active++
+	return true
+}
+
+// retain 为已登记调用派生的后台工作（如异步交易的提交等待）追加登记，
+// 调用方必须持有一次 acquire，因此不会与排空竞争
+func (l *lifecycle) retain() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active++
+}
+
+func (l *lifecycle) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	l.checkIdle()
+}
+
+// beginClosing 拒绝新调用，返回在所有进行中的调用结束后关闭的通道
+func (l *lifecycle) beginClosing() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closing = true
+	l.checkIdle()
+	return l.idle
+}
+
+// checkIdle 调用方需持有锁
+func (l *lifecycle) checkIdle() {
+	if l.closing && l.active == 0 && !l.idleClosed {
+		close(l.idle)
+		l.idleClosed = true
+	}
+}
+
+// Close 立即关闭客户端：停止证书监视、缓存事件订阅和健康检查，关闭所有连接
+// 进行中的调用会因连接关闭而失败，需要等待它们完成时使用 Shutdown
+func (c *Client) Close() error {
+	c.life.closeOnce.Do(func() {
+		c.life.beginClosing()
+		if c.life.stopWatch != nil {
+			close(c.life.stopWatch)
+			<-c.life.watchDone
+		}
+		if c.cache != nil {
+			c.cache.stop()
+		}
+		c.pool.close()
+	})
+	return nil
+}
+
+// Shutdown 优雅关闭：拒绝新调用，等待进行中的调用和未确认的异步交易结束后关闭客户端
+// ctx 到期时不再等待，直接关闭并返回错误
+func (c *Client) Shutdown(ctx context.Context) error {
+	var err error
+	select {
+	case <-c.life.beginClosing():
+	case <-ctx.Done():
+		err = fmt.Errorf("等待进行中的调用结束超时: %v", ctx.Err())
+	}
+	c.Close()
+	return err
+}
+
+// ===================== 证书热更新 =====================
+
+// watchedFiles 一组需要监视的文件，任一文件修改时间变化后调用 reload
+type watchedFiles struct {
+	name   string
+	paths  []string
+	stamp  time.Time
+	reload func() error
+}
+
+// startWatching 轮询身份证书、私钥和节点TLS根证书的修改时间，变化时重新加载
+// 证书续期通常先后写入多个文件，重新加载失败时保留旧凭据并在下一轮重试
+func (c *Client) startWatching(options *clientOptions) {
+	var watches []*watchedFiles
+
+	// 签名器或离线模式下私钥不在本地文件中，只监视证书
+	identityPaths := []string{certPath}
+	reloadKey := !options.offline && options.signer == nil
+	if reloadKey {
+		identityPaths = append(identityPaths, keyPath)
+	}
+	watches = append(watches, &watchedFiles{
+		name:  "身份证书",
+		paths: identityPaths,
+		reload: func() error {
+			return c.reloadDefaultIdentity(reloadKey)
+		},
+	})
+
+	for _, peer := range c.pool.peers {
+		peer := peer
+		watches = append(watches, &watchedFiles{
+			name:  fmt.Sprintf("节点 %s 的TLS根证书", peer.config.Endpoint),
+			paths: []string{peer.config.TLSCertPath},
+			reload: func() error {
+				return c.pool.connect(peer)
+			},
+		})
+	}
+
+	for _, w := range watches {
+		w.stamp = latestModTime(w.paths)
+	}
+
+	c.life.stopWatch = make(chan struct{})
+	c.life.watchDone = make(chan struct{})
+	go func() {
+		defer close(c.life.watchDone)
+
+		ticker := time.NewTicker(options.credentialReloadInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.life.stopWatch:
+				return
+			case <-ticker.C:
+			}
+			for _, w := range watches {
+				stamp := latestModTime(w.paths)
+				if stamp.Equal(w.stamp) {
+					continue
+				}
+				if err := w.reload(); err != nil {
+					log.Printf("重新加载%s失败，继续使用旧凭据: %v", w.name, err)
+					continue
+				}
+				w.stamp = stamp
+				log.Printf("已重新加载%s", w.name)
+			}
+		}
+	}()
+}
+
+// reloadDefaultIdentity 从磁盘重新读取默认身份，丢弃使用旧凭据创建的Gateway
+func (c *Client) reloadDefaultIdentity(reloadKey bool) error {
+	id, err := newIdentity()
+	if err != nil {
+		return err
+	}
+	_, sign := c.pool.identity.credentials()
+	if reloadKey {
+		if sign, err = newSign(); err != nil {
+			return err
+		}
+		if err := checkKeyPair(id, keyPath); err != nil {
+			return err
+		}
+	}
+
+	c.pool.identity.update(id, sign)
+	c.pool.forgetIdentity(c.pool.identity.label)
+	return nil
+}
+
+// checkKeyPair 检查证书与 keyDir 中的私钥是否匹配，证书和私钥分别写入时可能暂时不匹配，此时不应切换
+func checkKeyPair(id *identity.X509Identity, keyDir string) error {
+	cert, err := identity.CertificateFromPEM(id.Credentials())
+	if err != nil {
+		return err
+	}
+	files, err := ioutil.ReadDir(keyDir)
+	if err != nil {
+		return err
+	}
+	// 与 newSign 相同，使用第一个以_sk结尾的私钥文件
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), "_sk") {
+			continue
+		}
+		keyPEM, err := ioutil.ReadFile(path.Join(keyDir, f.Name()))
+		if err != nil {
+			return err
+		}
+		key, err := parseECPrivateKeyPEM(string(keyPEM))
+		if err != nil {
+			return err
+		}
+		if !key.PublicKey.Equal(cert.PublicKey) {
+			return fmt.Errorf("证书与私钥不匹配，可能尚未写入完成")
+		}
+		return nil
+	}
+	return fmt.Errorf("未找到私钥文件")
+}
+
+// latestModTime 返回一组路径中最新的修改时间，目录取其中文件的最新修改时间
+func latestModTime(paths []string) time.Time {
+	var latest time.Time
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		if !info.IsDir() {
+			continue
+		}
+		files, err := ioutil.ReadDir(p)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			if f.ModTime().After(latest) {
+				latest = f.ModTime()
+			}
+		}
+	}
+	return latest
+}
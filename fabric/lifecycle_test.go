@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+)
+
+// Shutdown 拒绝新调用并等待进行中的调用结束
+func TestShutdownWaitsForCalls(t *testing.T) {
+	c := newTestEmbeddedClient(t)
+	_, end, err := c.startCall(context.Background(), "InFlight")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- c.Shutdown(context.Background()) }()
+
+	eventually(t, "拒绝新调用", func() bool {
+		_, err := c.UploadEvaluation(testEvaluation("eval_001", "user_001"))
+		return errors.Is(err, ErrClientClosed)
+	})
+	select {
+	case err := <-done:
+		t.Fatalf("进行中的调用未结束时 Shutdown 已返回: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	var callErr error
+	end(&callErr)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Shutdown = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("调用结束后 Shutdown 未返回")
+	}
+	if _, err := c.GetEvaluationByUser("user_001"); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("关闭后查询 错误 = %v，期望 ErrClientClosed", err)
+	}
+}
+
+// ctx 到期时 Shutdown 不再等待，关闭客户端并返回错误
+func TestShutdownDeadline(t *testing.T) {
+	c := newTestEmbeddedClient(t)
+	_, end, err := c.startCall(context.Background(), "InFlight")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		var callErr error
+		end(&callErr)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.Shutdown(ctx); err == nil || !strings.Contains(err.Error(), "超时") {
+		t.Errorf("Shutdown = %v，期望超时错误", err)
+	}
+	if _, _, err := c.startCall(context.Background(), "After"); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("超时关闭后调用 错误 = %v，期望 ErrClientClosed", err)
+	}
+}
+
+// testKeyPEM 生成PEM格式的ECDSA私钥
+func testKeyPEM(t *testing.T) (*ecdsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err := identity.PrivateKeyToPEM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, keyPEM
+}
+
+func TestCheckKeyPair(t *testing.T) {
+	key, keyPEM := testKeyPEM(t)
+	_, otherPEM := testKeyPEM(t)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "User1@org1.example.com"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := identity.NewX509Identity("Org1MSP", cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		files   map[string][]byte
+		errPart string // 为空表示匹配
+	}{
+		{"匹配", map[string][]byte{"abc_sk": keyPEM}, ""},
+		{"私钥尚未更新", map[string][]byte{"abc_sk": otherPEM}, "不匹配"},
+		{"忽略其他文件", map[string][]byte{"README": []byte("x"), "abc_sk": keyPEM}, ""},
+		{"没有私钥文件", map[string][]byte{"key.pem": keyPEM}, "未找到私钥文件"},
+		{"私钥写入一半", map[string][]byte{"abc_sk": keyPEM[:len(keyPEM)/2]}, "私钥"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, data := range tt.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := checkKeyPair(id, dir)
+			if tt.errPart == "" {
+				if err != nil {
+					t.Errorf("checkKeyPair = %v，期望匹配", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errPart) {
+				t.Errorf("checkKeyPair = %v，期望包含 %q 的错误", err, tt.errPart)
+			}
+		})
+	}
+	if err := checkKeyPair(id, filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("私钥目录不存在时应当失败")
+	}
+}
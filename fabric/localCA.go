@@ -0,0 +1,318 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ===================== 本地替身CA =====================
+
+// LocalCA 实现 Fabric CA enroll/reenroll/revoke 接口子集的本地替身服务
+// 仅用于开发和测试环境，不持久化任何数据，也不检查 hf.Revoker 等注册属性
+type LocalCA struct {
+	name     string
+	validity time.Duration
+	cert     *x509.Certificate
+	certPEM  []byte
+	key      *ecdsa.PrivateKey
+
+	mu      sync.Mutex
+	secrets map[string]string   // 登记ID -> 密码
+	issued  map[string][]string // 登记ID -> 已签发证书序列号（十六进制）
+	revoked map[string]bool     // 已吊销的证书序列号
+	serial  int64
+}
+
+// NewLocalCA 创建带自签名根证书的本地CA
+func NewLocalCA(name string, validity time.Duration) (*LocalCA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("生成CA密钥失败: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("生成CA证书失败: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalCA{
+		name:     name,
+		validity: validity,
+		cert:     cert,
+		certPEM:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		key:      key,
+		secrets:  make(map[string]string),
+		issued:   make(map[string][]string),
+		revoked:  make(map[string]bool),
+		serial:   1,
+	}, nil
+}
+
+// Register 注册登记ID和密码
+func (ca *LocalCA) Register(enrollmentID, secret string) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	ca.secrets[enrollmentID] = secret
+}
+
+// CertificatePEM 返回CA根证书
+func (ca *LocalCA) CertificatePEM() []byte {
+	return ca.certPEM
+}
+
+// Handler 返回处理 /api/v1/enroll、/api/v1/reenroll 和 /api/v1/revoke 的HTTP处理器
+func (ca *LocalCA) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/enroll", ca.handleEnroll)
+	mux.HandleFunc("/api/v1/reenroll", ca.handleReenroll)
+	mux.HandleFunc("/api/v1/revoke", ca.handleRevoke)
+	return mux
+}
+
+// Start 在 addr 上启动HTTP服务，返回访问地址和停止函数
+func (ca *LocalCA) Start(addr string) (string, func() error, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", nil, fmt.Errorf("监听地址失败: %v", err)
+	}
+	server := &http.Server{Handler: ca.Handler()}
+	go server.Serve(listener)
+	return "http://" + listener.Addr().String(), server.Close, nil
+}
+
+func (ca *LocalCA) handleEnroll(w http.ResponseWriter, r *http.Request) {
+	enrollmentID, secret, ok := r.BasicAuth()
+	ca.mu.Lock()
+	expected, registered := ca.secrets[enrollmentID]
+	ca.mu.Unlock()
+	if !ok || !registered || expected != secret {
+		writeCAError(w, http.StatusUnauthorized, 20, "Authentication failure")
+		return
+	}
+
+	_, csr, err := readCSR(r)
+	if err != nil {
+		writeCAError(w, http.StatusBadRequest, 0, err.Error())
+		return
+	}
+	ca.issue(w, enrollmentID, csr)
+}
+
+func (ca *LocalCA) handleReenroll(w http.ResponseWriter, r *http.Request) {
+	body, csr, err := readCSR(r)
+	if err != nil {
+		writeCAError(w, http.StatusBadRequest, 0, err.Error())
+		return
+	}
+
+	cert, err := ca.verifyToken(r.Header.Get("Authorization"), body)
+	if err != nil {
+		writeCAError(w, http.StatusUnauthorized, 20, err.Error())
+		return
+	}
+	ca.issue(w, cert.Subject.CommonName, csr)
+}
+
+// handleRevoke 吊销请求中的单张证书（serial）或登记ID名下的全部证书（id），吊销后证书不能再用于重新登记
+func (ca *LocalCA) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeCAError(w, http.StatusBadRequest, 0, "读取请求失败")
+		return
+	}
+	if _, err := ca.verifyToken(r.Header.Get("Authorization"), body); err != nil {
+		writeCAError(w, http.StatusUnauthorized, 20, err.Error())
+		return
+	}
+	var req caRevokeRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeCAError(w, http.StatusBadRequest, 0, "请求格式错误")
+		return
+	}
+
+	ca.mu.Lock()
+	var serials []string
+	switch {
+	case req.Serial != "":
+		serial := strings.ToLower(strings.TrimLeft(req.Serial, "0"))
+		for _, issued := range ca.issued {
+			for _, s := range issued {
+				if s == serial && !ca.revoked[s] {
+					serials = append(serials, s)
+				}
+			}
+		}
+	case req.ID != "":
+		if _, registered := ca.secrets[req.ID]; !registered {
+			ca.mu.Unlock()
+			writeCAError(w, http.StatusNotFound, 63, fmt.Sprintf("登记ID %s 不存在", req.ID))
+			return
+		}
+		for _, s := range ca.issued[req.ID] {
+			if !ca.revoked[s] {
+				serials = append(serials, s)
+			}
+		}
+		// 按登记ID吊销同时注销该身份，之后不能再用密码登记
+		delete(ca.secrets, req.ID)
+	}
+	for _, s := range serials {
+		ca.revoked[s] = true
+	}
+	ca.mu.Unlock()
+
+	if len(serials) == 0 {
+		writeCAError(w, http.StatusNotFound, 48, "没有可吊销的证书")
+		return
+	}
+	var resp caResponse
+	resp.Success = true
+	aki := strings.ToLower(hex.EncodeToString(ca.cert.SubjectKeyId))
+	for _, s := range serials {
+		resp.Result.RevokedCerts = append(resp.Result.RevokedCerts, caRevokedCert{Serial: s, AKI: aki})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// verifyToken 校验身份令牌：证书由本CA签发、在有效期内且未被吊销，签名覆盖请求体
+func (ca *LocalCA) verifyToken(token string, body []byte) (*x509.Certificate, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("令牌格式错误")
+	}
+	certPEM, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("令牌证书解码失败")
+	}
+	sig, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("令牌签名解码失败")
+	}
+
+	cert, err := parseCertificatePEM(string(certPEM))
+	if err != nil {
+		return nil, err
+	}
+	if err := cert.CheckSignatureFrom(ca.cert); err != nil {
+		return nil, fmt.Errorf("证书不是由本CA签发")
+	}
+	if now := time.Now(); now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return nil, fmt.Errorf("证书不在有效期内（%s 至 %s）", cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339))
+	}
+	ca.mu.Lock()
+	revoked := ca.revoked[cert.SerialNumber.Text(16)]
+	ca.mu.Unlock()
+	if revoked {
+		return nil, fmt.Errorf("证书已被吊销")
+	}
+
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("仅支持ECDSA证书")
+	}
+	digest := sha256.Sum256([]byte(base64.StdEncoding.EncodeToString(body) + "." + parts[0]))
+	if !ecdsa.VerifyASN1(pub, digest[:], sig) {
+		return nil, fmt.Errorf("令牌签名校验失败")
+	}
+	return cert, nil
+}
+
+// issue 按CSR中的公钥签发证书，主题使用登记ID
+func (ca *LocalCA) issue(w http.ResponseWriter, enrollmentID string, csr *x509.CertificateRequest) {
+	ca.mu.Lock()
+	ca.serial++
+	serial := ca.serial
+	ca.mu.Unlock()
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: enrollmentID, OrganizationalUnit: []string{"client"}},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(ca.validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, csr.PublicKey, ca.key)
+	if err != nil {
+		writeCAError(w, http.StatusInternalServerError, 0, err.Error())
+		return
+	}
+	ca.mu.Lock()
+	ca.issued[enrollmentID] = append(ca.issued[enrollmentID], big.NewInt(serial).Text(16))
+	ca.mu.Unlock()
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	var resp caResponse
+	resp.Success = true
+	resp.Result.Cert = base64.StdEncoding.EncodeToString(certPEM)
+	resp.Result.ServerInfo.CAName = ca.name
+	resp.Result.ServerInfo.CAChain = base64.StdEncoding.EncodeToString(ca.certPEM)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func readCSR(r *http.Request) ([]byte, *x509.CertificateRequest, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取请求失败")
+	}
+	var req caEnrollRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, nil, fmt.Errorf("请求格式错误")
+	}
+
+	block, _ := pem.Decode([]byte(req.CertificateRequest))
+	if block == nil {
+		return nil, nil, fmt.Errorf("证书签名请求不是有效的PEM格式")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析证书签名请求失败")
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, nil, fmt.Errorf("证书签名请求签名无效")
+	}
+	return body, csr, nil
+}
+
+func writeCAError(w http.ResponseWriter, status, code int, message string) {
+	var resp caResponse
+	resp.Errors = append(resp.Errors, struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{code, message})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestCA 启动本地替身CA并注册 user_001，返回指向它的CA客户端
+func newTestCA(t *testing.T) (*LocalCA, *CAClient) {
+	t.Helper()
+	ca, err := NewLocalCA("ca-org1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.Register("user_001", "secret")
+	server := httptest.NewServer(ca.Handler())
+	t.Cleanup(server.Close)
+	client, err := NewCAClient(server.URL, "ca-org1", mspID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca, client
+}
+
+// certificateWithValidity 用CA密钥为 id 的公钥重新签发指定有效期的证书
+func certificateWithValidity(t *testing.T, ca *LocalCA, id *WalletIdentity, notBefore, notAfter time.Time) *WalletIdentity {
+	t.Helper()
+	key, err := parseECPrivateKeyPEM(id.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "user_001"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied := *id
+	copied.Certificate = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	return &copied
+}
+
+func TestLocalCAEnroll(t *testing.T) {
+	ca, client := newTestCA(t)
+	id, err := client.Enroll("user_001", "secret")
+	if err != nil {
+		t.Fatalf("登记失败: %v", err)
+	}
+	cert, err := parseCertificatePEM(id.Certificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.MSPID != mspID || cert.Subject.CommonName != "user_001" {
+		t.Errorf("登记身份 = %s/%s", id.MSPID, cert.Subject.CommonName)
+	}
+	if err := cert.CheckSignatureFrom(ca.cert); err != nil {
+		t.Errorf("证书不是由本地CA签发: %v", err)
+	}
+	if _, err := id.gatewayIdentity("user_001"); err != nil {
+		t.Errorf("登记身份无法用于Gateway: %v", err)
+	}
+
+	for _, tt := range []struct{ name, id, secret string }{
+		{"密码错误", "user_001", "wrong"},
+		{"未注册", "user_002", "secret"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.Enroll(tt.id, tt.secret); err == nil || !strings.Contains(err.Error(), "[20]") {
+				t.Errorf("登记错误 = %v，期望认证失败", err)
+			}
+		})
+	}
+}
+
+func TestLocalCAReenroll(t *testing.T) {
+	ca, client := newTestCA(t)
+	id, err := client.Enroll("user_001", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewed, err := client.Reenroll(id)
+	if err != nil {
+		t.Fatalf("重新登记失败: %v", err)
+	}
+	if renewed.PrivateKey == id.PrivateKey || renewed.Certificate == id.Certificate {
+		t.Error("重新登记应生成新的密钥和证书")
+	}
+	cert, _ := parseCertificatePEM(renewed.Certificate)
+	if cert.Subject.CommonName != "user_001" {
+		t.Errorf("重新登记证书主题 = %s", cert.Subject.CommonName)
+	}
+
+	other, err := NewLocalCA("ca-org2", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	tests := []struct {
+		name    string
+		current *WalletIdentity
+		want    string
+	}{
+		{"证书已过期", certificateWithValidity(t, ca, id, now.Add(-2*time.Hour), now.Add(-time.Hour)), "有效期"},
+		{"证书尚未生效", certificateWithValidity(t, ca, id, now.Add(time.Hour), now.Add(2*time.Hour)), "有效期"},
+		{"其他CA签发", certificateWithValidity(t, other, id, now.Add(-time.Minute), now.Add(time.Hour)), "本CA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.Reenroll(tt.current); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("重新登记错误 = %v，期望包含 %q", err, tt.want)
+			}
+		})
+	}
+
+	// 令牌签名不覆盖请求体时拒绝
+	key, _ := parseECPrivateKeyPEM(id.PrivateKey)
+	token, err := caAuthToken(id.Certificate, key, []byte(`{"certificate_request":""}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ca.verifyToken(token, []byte(`{"certificate_request":"other"}`)); err == nil {
+		t.Error("签名与请求体不符的令牌应被拒绝")
+	}
+}
+
+func TestLocalCARevoke(t *testing.T) {
+	ca, client := newTestCA(t)
+	ca.Register("admin", "adminpw")
+	admin, err := client.Enroll("admin", "adminpw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := client.Enroll("user_001", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := client.Reenroll(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 吊销单张证书后，该证书不能再重新登记，同一身份的其他证书不受影响
+	serials, err := client.RevokeCertificate(admin, first.Certificate, "keyCompromise")
+	if err != nil {
+		t.Fatalf("吊销证书失败: %v", err)
+	}
+	firstCert, _ := parseCertificatePEM(first.Certificate)
+	if len(serials) != 1 || serials[0] != firstCert.SerialNumber.Text(16) {
+		t.Errorf("吊销的证书 = %v，期望 %s", serials, firstCert.SerialNumber.Text(16))
+	}
+	if _, err := client.Reenroll(first); err == nil || !strings.Contains(err.Error(), "吊销") {
+		t.Errorf("已吊销证书重新登记 = %v，期望被拒绝", err)
+	}
+	if _, err := client.RevokeCertificate(admin, first.Certificate, ""); err == nil {
+		t.Error("重复吊销同一证书应返回错误")
+	}
+	third, err := client.Reenroll(second)
+	if err != nil {
+		t.Fatalf("未吊销的证书重新登记失败: %v", err)
+	}
+
+	// 按登记ID吊销名下剩余证书，并且不能再用密码登记
+	serials, err = client.Revoke(admin, "user_001", "")
+	if err != nil {
+		t.Fatalf("吊销身份失败: %v", err)
+	}
+	if len(serials) != 2 {
+		t.Errorf("吊销了 %d 张证书，期望 2 张", len(serials))
+	}
+	if _, err := client.Reenroll(third); err == nil {
+		t.Error("身份吊销后证书仍可重新登记")
+	}
+	if _, err := client.Enroll("user_001", "secret"); err == nil {
+		t.Error("身份吊销后仍可用密码登记")
+	}
+	if _, err := client.Revoke(admin, "user_002", ""); err == nil {
+		t.Error("吊销不存在的身份应返回错误")
+	}
+	// 已吊销的身份不能发起吊销
+	if _, err := client.Revoke(third, "admin", ""); err == nil {
+		t.Error("已吊销证书发起的吊销请求应被拒绝")
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ===================== 内存账本后端 =====================
+
+// MemoryBackend 在内存中模拟链码的世界状态，用于开发和演示
+// 记录校验、重复检查和错误码与链码一致，按ID查询时只核对参数中的用户ID与记录所属用户；
+// 没有调用者身份，不检查角色和访问授权，也不写访问日志，个人内容不加密且不支持擦除。
+// 依赖身份和权限的行为（学生只能读自己的记录、授权读取、擦除）以链码为准，开发和测试时使用进程内后端（embedded）。
+// 每次写入视为一个区块，返回的交易回执与网关返回的格式相同
+type MemoryBackend struct {
+	mu     sync.RWMutex
+	path   string            // 持久化文件，为空时不保存
+	state  map[string][]byte // 键与链码相同，如 Evaluation-<ID>
+	height uint64            // 已写入的区块数
+}
+
+// memorySnapshot 持久化文件内容
+type memorySnapshot struct {
+	Height uint64                     `json:"height"`
+	State  map[string]json.RawMessage `json:"state"`
+}
+
+// NewMemoryBackend 创建内存后端，path 不为空时从该文件加载数据，每次写入后保存
+func NewMemoryBackend(path string) (*MemoryBackend, error) {
+	m := &MemoryBackend{path: path, state: make(map[string][]byte)}
+	if path == "" {
+		return m, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取账本文件失败: %v", err)
+	}
+	var snapshot memorySnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("解析账本文件失败: %v", err)
+	}
+	m.height = snapshot.Height
+	for key, value := range snapshot.State {
+		m.state[key] = value
+	}
+	return m, nil
+}
+
+// Close 内存后端没有需要释放的资源
+func (m *MemoryBackend) Close() error {
+	return nil
+}
+
+// commit 在持有写锁时调用，写入成功后生成回执并保存
+func (m *MemoryBackend) commit(writes map[string][]byte) (*TxReceipt, error) {
+	txID := make([]byte, 32)
+	if _, err := rand.Read(txID); err != nil {
+		return nil, fmt.Errorf("生成交易ID失败: %v", err)
+	}
+
+	for key, value := range writes {
+		if value == nil {
+			delete(m.state, key)
+		} else {
+			m.state[key] = value
+		}
+	}
+	m.height++
+	receipt := &TxReceipt{
+		TransactionID: hex.EncodeToString(txID),
+		BlockNumber:   m.height - 1,
+		Status:        "VALID",
+		Successful:    true,
+		Timestamp:     time.Now(),
+	}
+	return receipt, m.save()
+}
+
+func (m *MemoryBackend) save() error {
+	if m.path == "" {
+		return nil
+	}
+	snapshot := memorySnapshot{Height: m.height, State: make(map[string]json.RawMessage, len(m.state))}
+	for key, value := range m.state {
+		snapshot.State[key] = value
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化账本失败: %v", err)
+	}
+	if err := ioutil.WriteFile(m.path, data, 0644); err != nil {
+		return fmt.Errorf("保存账本文件失败: %v", err)
+	}
+	return nil
+}
+
+// get 读取并解析一条记录，不存在时返回 false
+func (m *MemoryBackend) get(key string, record interface{}) (bool, error) {
+	m.mu.RLock()
+	data, ok := m.state[key]
+	m.mu.RUnlock()
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, record); err != nil {
+		return false, fmt.Errorf("数据解析失败: %v", err)
+	}
+	return true, nil
+}
+
+// queryByUser 按键排序返回某类记录中属于该用户的记录，与 CouchDB 按 _id 排序的结果一致
+func (m *MemoryBackend) queryByUser(docType, userID string, each func(data []byte) error) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]string, 0, len(m.state))
+	for key := range m.state {
+		if strings.HasPrefix(key, docType+"-") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var selector struct {
+			DocType string `json:"docType"`
+			UserID  string `json:"User_ID"`
+		}
+		if err := json.Unmarshal(m.state[key], &selector); err != nil {
+			return fmt.Errorf("数据解析失败: %v", err)
+		}
+		if selector.DocType != docType || selector.UserID != userID {
+			continue
+		}
+		if err := each(m.state[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// create 校验不存在后写入新记录
+func (m *MemoryBackend) create(key string, record interface{}, duplicate error) (*TxReceipt, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("数据序列化失败: %v", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.state[key]; ok {
+		return nil, fmt.Errorf("提交交易失败: %w", duplicate)
+	}
+	return m.commit(map[string][]byte{key: data})
+}
+
+// ===================== 测评记录 =====================
+
+func (m *MemoryBackend) UploadEvaluationWithContext(ctx context.Context, evaluation Evaluation, opts ...CallOption) (*TxReceipt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := evaluation.Validate(); err != nil {
+		return nil, fmt.Errorf("提交交易失败: %w", localInvalid("evaluation.invalid", err))
+	}
+	evaluation.DocType = "Evaluation"
+	return m.create("Evaluation-"+evaluation.EvaluationID, evaluation,
+		localError("evaluation.exists", "id", evaluation.EvaluationID))
+}
+
+func (m *MemoryBackend) ModifyEvaluationWithContext(ctx context.Context, evaluationID string, newEvaluation Evaluation, opts ...CallOption) (*TxReceipt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := "Evaluation-" + evaluationID
+	if _, ok := m.state[key]; !ok {
+		return nil, fmt.Errorf("提交交易失败: %w", localError("evaluation.not_found"))
+	}
+	if newEvaluation.EvaluationID != evaluationID {
+		return nil, fmt.Errorf("提交交易失败: %w", localError("evaluation.id_immutable"))
+	}
+	if err := newEvaluation.Validate(); err != nil {
+		return nil, fmt.Errorf("提交交易失败: %w", localInvalid("evaluation.invalid", err))
+	}
+	newEvaluation.DocType = "Evaluation"
+	data, err := json.Marshal(newEvaluation)
+	if err != nil {
+		return nil, fmt.Errorf("数据序列化失败: %v", err)
+	}
+	return m.commit(map[string][]byte{key: data})
+}
+
+func (m *MemoryBackend) GetEvaluationByIDWithContext(ctx context.Context, evaluationID, userID string, opts ...CallOption) (*Evaluation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if evaluationID == "" || userID == "" {
+		return nil, fmt.Errorf("查询失败: %w", localError("argument.empty"))
+	}
+	var evaluation Evaluation
+	ok, err := m.get("Evaluation-"+evaluationID, &evaluation)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("查询失败: %w", err)
+	case !ok:
+		return nil, fmt.Errorf("查询失败: %w", localError("evaluation.not_found"))
+	case evaluation.UserID != userID:
+		return nil, fmt.Errorf("查询失败: %w", localError("record.forbidden"))
+	}
+	return &evaluation, nil
+}
+
+func (m *MemoryBackend) GetEvaluationByUserWithContext(ctx context.Context, userID string, opts ...CallOption) ([]Evaluation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if userID == "" {
+		return nil, fmt.Errorf("查询失败: %w", localError("user_id.empty"))
+	}
+	var evaluations []Evaluation
+	err := m.queryByUser("Evaluation", userID, func(data []byte) error {
+		var evaluation Evaluation
+		if err := json.Unmarshal(data, &evaluation); err != nil {
+			return fmt.Errorf("数据解析失败: %v", err)
+		}
+		evaluations = append(evaluations, evaluation)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
+	}
+	return evaluations, nil
+}
+
+// ===================== 测试结果 =====================
+
+func (m *MemoryBackend) UploadTestResultWithContext(ctx context.Context, test TestResult, opts ...CallOption) (*TxReceipt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := test.Validate(); err != nil {
+		return nil, fmt.Errorf("提交交易失败: %w", localInvalid("test_result.invalid", err))
+	}
+	test.DocType = "TestResult"
+	return m.create("TestResult-"+test.TestID, test, localError("test_result.exists", "id", test.TestID))
+}
+
+func (m *MemoryBackend) GetTestResultsByUserWithContext(ctx context.Context, userID string, opts ...CallOption) ([]TestResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if userID == "" {
+		return nil, fmt.Errorf("查询失败: %w", localError("user_id.empty"))
+	}
+	var tests []TestResult
+	err := m.queryByUser("TestResult", userID, func(data []byte) error {
+		var test TestResult
+		if err := json.Unmarshal(data, &test); err != nil {
+			return fmt.Errorf("数据解析失败: %v", err)
+		}
+		tests = append(tests, test)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
+	}
+	return tests, nil
+}
+
+func (m *MemoryBackend) GetTestResultsByIDWithContext(ctx context.Context, userID, testID string, opts ...CallOption) (*TestResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if userID == "" || testID == "" {
+		return nil, fmt.Errorf("查询失败: %w", localError("argument.empty"))
+	}
+	var test TestResult
+	ok, err := m.get("TestResult-"+testID, &test)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("查询失败: %w", err)
+	case !ok:
+		return nil, fmt.Errorf("查询失败: %w", localError("test_result.not_found"))
+	case test.UserID != userID:
+		return nil, fmt.Errorf("查询失败: %w", localError("test_result.forbidden"))
+	}
+	return &test, nil
+}
+
+// ===================== 评价记录 =====================
+
+// UploadJudgementWithContext 与链码一致，评价记录允许覆盖
+func (m *MemoryBackend) UploadJudgementWithContext(ctx context.Context, judgement Judgement, opts ...CallOption) (*TxReceipt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := judgement.Validate(); err != nil {
+		return nil, fmt.Errorf("提交交易失败: %w", localInvalid("judgement.invalid", err))
+	}
+	judgement.DocType = "Judgement"
+	data, err := json.Marshal(judgement)
+	if err != nil {
+		return nil, fmt.Errorf("数据序列化失败: %v", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.commit(map[string][]byte{"Judgement-" + judgement.JudgementID: data})
+}
+
+func (m *MemoryBackend) GetJudgementByUserWithContext(ctx context.Context, userID string, opts ...CallOption) ([]Judgement, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if userID == "" {
+		return nil, fmt.Errorf("查询失败: %w", localError("user_id.empty"))
+	}
+	var judgements []Judgement
+	err := m.queryByUser("Judgement", userID, func(data []byte) error {
+		var judgement Judgement
+		if err := json.Unmarshal(data, &judgement); err != nil {
+			return fmt.Errorf("数据解析失败: %v", err)
+		}
+		judgements = append(judgements, judgement)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
+	}
+	return judgements, nil
+}
+
+func (m *MemoryBackend) GetJudgementByIDWithContext(ctx context.Context, userID, judgementID string, opts ...CallOption) (*Judgement, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if userID == "" || judgementID == "" {
+		return nil, fmt.Errorf("查询失败: %w", localError("argument.empty"))
+	}
+	var judgement Judgement
+	ok, err := m.get("Judgement-"+judgementID, &judgement)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("查询失败: %w", err)
+	case !ok:
+		return nil, fmt.Errorf("查询失败: %w", localError("judgement.not_found"))
+	case judgement.UserID != userID:
+		return nil, fmt.Errorf("查询失败: %w", localError("judgement.forbidden"))
+	}
+	return &judgement, nil
+}
+
+// ===================== 通用操作 =====================
+
+// DeleteRecordWithContext 与链码一致，删除不存在的记录也会成功
+func (m *MemoryBackend) DeleteRecordWithContext(ctx context.Context, recordType, recordID string, opts ...CallOption) (*TxReceipt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if recordID == "" {
+		return nil, fmt.Errorf("提交交易失败: %w", localError("record_id.empty"))
+	}
+	switch recordType {
+	case "Evaluation", "TestResult", "Judgement":
+	default:
+		return nil, fmt.Errorf("提交交易失败: %w", localError("record_type.unsupported", "type", recordType))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.commit(map[string][]byte{recordType + "-" + recordID: nil})
+}
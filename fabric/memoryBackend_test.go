@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"edu/model"
+)
+
+// 内存后端与链码一致的部分：重复检查、按ID查询时的用户核对和删除语义（校验规则见 edu/model 的测试），
+// 同一组操作在内存后端和运行链码的进程内后端上返回相同的错误码。
+// 身份、授权和擦除只在链码中实现，相关测试使用进程内后端（见 accessGrant_test.go、recordKeys_test.go）
+func TestMemoryBackendMatchesChaincode(t *testing.T) {
+	ctx := context.Background()
+	steps := []struct {
+		name string
+		run  func(b LedgerBackend) error
+		want error // nil 表示成功，否则为期望的错误码
+	}{
+		{"上传测评", func(b LedgerBackend) error {
+			_, err := b.UploadEvaluationWithContext(ctx, testEvaluation("eval_001", "user_001"))
+			return err
+		}, nil},
+		{"重复上传", func(b LedgerBackend) error {
+			_, err := b.UploadEvaluationWithContext(ctx, testEvaluation("eval_001", "user_001"))
+			return err
+		}, model.CodeDuplicate},
+		{"修改测评ID", func(b LedgerBackend) error {
+			_, err := b.ModifyEvaluationWithContext(ctx, "eval_001", testEvaluation("eval_009", "user_001"))
+			return err
+		}, model.CodeInvalidInput},
+		{"修改不存在的测评", func(b LedgerBackend) error {
+			_, err := b.ModifyEvaluationWithContext(ctx, "eval_404", testEvaluation("eval_404", "user_001"))
+			return err
+		}, model.CodeNotFound},
+		{"按ID查询", func(b LedgerBackend) error {
+			_, err := b.GetEvaluationByIDWithContext(ctx, "eval_001", "user_001")
+			return err
+		}, nil},
+		{"参数中的用户不是记录所属用户", func(b LedgerBackend) error {
+			_, err := b.GetEvaluationByIDWithContext(ctx, "eval_001", "user_002")
+			return err
+		}, model.CodeForbidden},
+		{"查询不存在的记录", func(b LedgerBackend) error {
+			_, err := b.GetEvaluationByIDWithContext(ctx, "eval_404", "user_001")
+			return err
+		}, model.CodeNotFound},
+		{"评价允许覆盖", func(b LedgerBackend) error {
+			if _, err := b.UploadJudgementWithContext(ctx, testJudgement("judge_001", "user_001", "")); err != nil {
+				return err
+			}
+			_, err := b.UploadJudgementWithContext(ctx, testJudgement("judge_001", "user_001", ""))
+			return err
+		}, nil},
+		{"删除不存在的记录", func(b LedgerBackend) error {
+			_, err := b.DeleteRecordWithContext(ctx, "Evaluation", "eval_404")
+			return err
+		}, nil},
+		{"删除不支持的类型", func(b LedgerBackend) error {
+			_, err := b.DeleteRecordWithContext(ctx, "AccessGrant", "tx1")
+			return err
+		}, model.CodeInvalidInput},
+		{"删除后查询", func(b LedgerBackend) error {
+			if _, err := b.DeleteRecordWithContext(ctx, "Evaluation", "eval_001"); err != nil {
+				return err
+			}
+			_, err := b.GetEvaluationByIDWithContext(ctx, "eval_001", "user_001")
+			return err
+		}, model.CodeNotFound},
+	}
+
+	memory, err := NewMemoryBackend("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backends := []struct {
+		name    string
+		backend LedgerBackend
+	}{
+		{"memory", memory},
+		{"embedded", newTestEmbeddedClient(t)},
+	}
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			for _, step := range steps {
+				err := step.run(b.backend)
+				switch {
+				case step.want == nil && err != nil:
+					t.Errorf("%s: %v，期望成功", step.name, err)
+				case step.want != nil && !errors.Is(err, step.want):
+					t.Errorf("%s: 错误 = %v，期望 %v", step.name, err, step.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryBackendPersistence(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ledger.json")
+	m, err := NewMemoryBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := m.UploadEvaluationWithContext(ctx, testEvaluation("eval_001", "user_001"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewMemoryBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := reopened.GetEvaluationByIDWithContext(ctx, "eval_001", "user_001")
+	if err != nil || got.DocType != model.DocTypeEvaluation {
+		t.Fatalf("重新加载后查询 = %+v, %v", got, err)
+	}
+	second, err := reopened.UploadEvaluationWithContext(ctx, testEvaluation("eval_002", "user_001"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.BlockNumber != first.BlockNumber+1 {
+		t.Errorf("重新加载后的区块号 = %d，期望接着 %d", second.BlockNumber, first.BlockNumber)
+	}
+}
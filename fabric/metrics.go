@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+)
+
+// 指标名前缀
+const metricsNamespace = "edu_fabric"
+
+// ===================== 调用指标 =====================
+
+// clientMetrics 客户端调用指标，每个客户端使用独立的注册表，As 等派生视图共享同一实例
+type clientMetrics struct {
+	registry *prometheus.Registry
+
+	duration   *prometheus.HistogramVec
+	errors     *prometheus.CounterVec
+	inFlight   *prometheus.GaugeVec
+	commitWait *prometheus.HistogramVec
+	pending    prometheus.Gauge
+}
+
+func newClientMetrics(c *Client) *clientMetrics {
+	m := &clientMetrics{
+		registry: prometheus.NewRegistry(),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "client_request_duration_seconds",
+			Help:      "客户端方法调用耗时，同步提交包含等待区块提交的时间",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		}, []string{"method"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "client_errors_total",
+			Help:      "客户端方法调用失败次数，按错误类别区分",
+		}, []string{"method", "class"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "client_in_flight_requests",
+			Help:      "正在执行的客户端方法调用数",
+		}, []string{"method"}),
+		commitWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "client_commit_wait_seconds",
+			Help:      "异步交易从提交到确认提交状态的耗时",
+			Buckets:   []float64{.1, .25, .5, 1, 2, 5, 10, 30, 60},
+		}, []string{"method"}),
+		pending: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "client_pending_transactions",
+			Help:      "已提交但尚未确认提交状态的异步交易数",
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.duration, m.errors, m.inFlight, m.commitWait, m.pending,
+		&clientCollector{client: c},
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// observe 记录一次方法调用，用法：defer c.metrics.observe("方法名")(&err)
+func (m *clientMetrics) observe(method string) func(*error) {
+	start := time.Now()
+	m.inFlight.WithLabelValues(method).Inc()
+	return func(errp *error) {
+		m.inFlight.WithLabelValues(method).Dec()
+		m.duration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		if *errp != nil {
+			m.errors.WithLabelValues(method, errorClass(*errp)).Inc()
+		}
+	}
+}
+
+// observeCommit 记录异步交易的提交等待，在交易提交后调用，返回值在提交状态确定后调用
+func (m *clientMetrics) observeCommit(method string) func(error) {
+	start := time.Now()
+	m.pending.Inc()
+	return func(err error) {
+		m.pending.Dec()
+		m.commitWait.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		if err != nil {
+			m.errors.WithLabelValues(method, errorClass(err)).Inc()
+		}
+	}
+}
+
+// errorClass 将错误归类，作为错误计数的标签
+func errorClass(err error) string {
+	var commitFailed *CommitFailedError
+	var endorseErr *client.EndorseError
+	var submitErr *client.SubmitError
+	var commitStatusErr *client.CommitStatusError
+
+	switch {
+	case errors.Is(err, ErrClientClosed):
+		return "closed"
+	case errors.As(err, &commitFailed):
+		return "commit_invalid" // 交易已上链但验证失败，如 MVCC 冲突
+	case errors.Is(err, context.Canceled) || status.Code(err) == codes.Canceled:
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded:
+		return "timeout"
+	case status.Code(err) == codes.Unavailable:
+		return "unavailable"
+	case errors.As(err, &endorseErr) || status.Code(err) == codes.Aborted:
+		return "endorse" // 通常是链码返回的业务错误
+	case errors.As(err, &submitErr):
+		return "submit"
+	case errors.As(err, &commitStatusErr):
+		return "commit_status"
+	}
+	return "other"
+}
+
+// ===================== 连接与证书指标 =====================
+
+var (
+	peerUpDesc = prometheus.NewDesc(metricsNamespace+"_peer_up",
+		"网关节点最近一次健康检查是否通过", []string{"endpoint"}, nil)
+	peerStateDesc = prometheus.NewDesc(metricsNamespace+"_peer_connection_state",
+		"网关节点gRPC连接状态，当前状态为1", []string{"endpoint", "state"}, nil)
+	peerLatencyDesc = prometheus.NewDesc(metricsNamespace+"_peer_latency_seconds",
+		"网关节点健康检查延迟（指数平均）", []string{"endpoint"}, nil)
+	peerFailuresDesc = prometheus.NewDesc(metricsNamespace+"_peer_consecutive_failures",
+		"网关节点连续失败次数", []string{"endpoint"}, nil)
+	certExpiryDesc = prometheus.NewDesc(metricsNamespace+"_certificate_expiry_timestamp_seconds",
+		"证书过期时间（Unix时间戳），kind 为 identity（签名身份）或 tls（节点TLS根证书）", []string{"kind", "name"}, nil)
+	cacheDesc = prometheus.NewDesc(metricsNamespace+"_read_cache_events_total",
+		"查询缓存统计", []string{"event"}, nil)
+	cacheEntriesDesc = prometheus.NewDesc(metricsNamespace+"_read_cache_entries",
+		"查询缓存条目数", nil, nil)
+)
+
+// 连接状态枚举，每个节点对每种状态输出一条序列
+var connectionStates = []connectivity.State{
+	connectivity.Idle, connectivity.Connecting, connectivity.Ready,
+	connectivity.TransientFailure, connectivity.Shutdown,
+}
+
+// clientCollector 在抓取时读取节点池、证书和缓存的当前状态
+type clientCollector struct {
+	client *Client
+}
+
+func (cc *clientCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- peerUpDesc
+	ch <- peerStateDesc
+	ch <- peerLatencyDesc
+	ch <- peerFailuresDesc
+	ch <- certExpiryDesc
+	ch <- cacheDesc
+	ch <- cacheEntriesDesc
+}
+
+func (cc *clientCollector) Collect(ch chan<- prometheus.Metric) {
+	c := cc.client
+
+	for _, s := range c.pool.status() {
+		up := 0.0
+		if s.Healthy {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(peerUpDesc, prometheus.GaugeValue, up, s.Endpoint)
+		for _, state := range connectionStates {
+			value := 0.0
+			if state.String() == s.State {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(peerStateDesc, prometheus.GaugeValue, value, s.Endpoint, state.String())
+		}
+		ch <- prometheus.MustNewConstMetric(peerLatencyDesc, prometheus.GaugeValue, s.Latency.Seconds(), s.Endpoint)
+		ch <- prometheus.MustNewConstMetric(peerFailuresDesc, prometheus.GaugeValue, float64(s.ConsecutiveFailures), s.Endpoint)
+	}
+
+	// 默认身份、钱包中的身份以及各节点TLS根证书
+	id, _ := c.pool.identity.credentials()
+	if notAfter, ok := certificateExpiry(id.Credentials()); ok {
+		ch <- prometheus.MustNewConstMetric(certExpiryDesc, prometheus.GaugeValue, float64(notAfter.Unix()), "identity", "default")
+	}
+	if c.wallet != nil {
+		labels, _ := c.wallet.List()
+		for _, label := range labels {
+			walletID, err := c.wallet.Get(label)
+			if err != nil {
+				continue
+			}
+			if notAfter, ok := certificateExpiry([]byte(walletID.Certificate)); ok {
+				ch <- prometheus.MustNewConstMetric(certExpiryDesc, prometheus.GaugeValue, float64(notAfter.Unix()), "identity", label)
+			}
+		}
+	}
+	for _, peer := range c.pool.peers {
+		certPEM, err := ioutil.ReadFile(peer.config.TLSCertPath)
+		if err != nil {
+			continue
+		}
+		if notAfter, ok := certificateExpiry(certPEM); ok {
+			ch <- prometheus.MustNewConstMetric(certExpiryDesc, prometheus.GaugeValue, float64(notAfter.Unix()), "tls", peer.config.Endpoint)
+		}
+	}
+
+	if c.cache != nil {
+		stats := c.cache.stats()
+		for event, value := range map[string]uint64{
+			"hit":          stats.Hits,
+			"miss":         stats.Misses,
+			"expired":      stats.Expired,
+			"invalidation": stats.Invalidations,
+			"flush":        stats.Flushes,
+		} {
+			ch <- prometheus.MustNewConstMetric(cacheDesc, prometheus.CounterValue, float64(value), event)
+		}
+		ch <- prometheus.MustNewConstMetric(cacheEntriesDesc, prometheus.GaugeValue, float64(stats.Entries))
+	}
+}
+
+// certificateExpiry 解析PEM证书的过期时间
+func certificateExpiry(certPEM []byte) (time.Time, bool) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return time.Time{}, false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return cert.NotAfter, true
+}
@@ -0,0 +1,68 @@
+package model
+
+import "time"
+
+// ===================== 访问授权 =====================
+// 学生授予第三方身份的读取授权，链码写入账本，客户端读取和展示
+
+// DocTypeAccessGrant 授权记录的文档类型，授权和撤销以该类型发出记录变更事件
+const DocTypeAccessGrant = "AccessGrant"
+
+// AccessScope 授权范围，记录类型和单条记录至少指定一项
+type AccessScope struct {
+	DocTypes []string `json:"docTypes"`                               // 授权的记录类型，覆盖该类型的全部记录；未指定时存为空数组
+	Records  []string `json:"records,omitempty" metadata:",optional"` // 授权的单条记录，格式为 <记录类型>-<记录ID>
+}
+
+// AccessGrant 学生授予指定身份的读取授权
+type AccessGrant struct {
+	DocType   string      `json:"docType"`                                  // 固定为 AccessGrant
+	GrantID   string      `json:"grantId"`                                  // 授权ID，即授权交易的ID
+	UserID    string      `json:"userId"`                                   // 授权的学生
+	Grantee   string      `json:"grantee"`                                  // 被授权身份，<MSP ID>/<证书CN>
+	Scope     AccessScope `json:"scope"`                                    // 授权范围
+	CreatedAt string      `json:"createdAt"`                                // 授权交易时间（RFC3339）
+	ExpiresAt string      `json:"expiresAt"`                                // 过期时间（RFC3339），按读取交易的时间判断
+	RevokedAt string      `json:"revokedAt,omitempty" metadata:",optional"` // 撤销交易时间，撤销后立即失效
+}
+
+// Active 授权在 at 时刻是否有效：未撤销，且 at 在授权时间和过期时间之间
+func (g *AccessGrant) Active(at time.Time) bool {
+	if g.RevokedAt != "" {
+		return false
+	}
+	created, err := time.Parse(time.RFC3339, g.CreatedAt)
+	if err != nil {
+		return false
+	}
+	expires, err := time.Parse(time.RFC3339, g.ExpiresAt)
+	if err != nil {
+		return false
+	}
+	return !at.Before(created) && at.Before(expires)
+}
+
+// Covers 授权范围是否包含记录，recordKey 为 <记录类型>-<记录ID>
+func (g *AccessGrant) Covers(docType, recordKey string) bool {
+	for _, t := range g.Scope.DocTypes {
+		if t == docType {
+			return true
+		}
+	}
+	for _, key := range g.Scope.Records {
+		if key == recordKey {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessLogEntry 通过授权读取记录的访问日志
+type AccessLogEntry struct {
+	GrantID  string `json:"grantId"`  // 使用的授权
+	UserID   string `json:"userId"`   // 记录所属学生
+	Accessor string `json:"accessor"` // 读取者，<MSP ID>/<证书CN>
+	Record   string `json:"record"`   // 读取的记录，<记录类型>-<记录ID>
+	TxID     string `json:"txId"`     // 读取交易ID
+	Time     string `json:"time"`     // 读取交易时间（RFC3339）
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessGrantActive(t *testing.T) {
+	created := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	grant := AccessGrant{CreatedAt: "2024-03-01T08:00:00Z", ExpiresAt: "2024-04-01T08:00:00Z"}
+	tests := []struct {
+		name  string
+		edit  func(g *AccessGrant)
+		at    time.Time
+		valid bool
+	}{
+		{"有效期内", nil, created.Add(time.Hour), true},
+		{"授权交易时间", nil, created, true},
+		{"授权之前", nil, created.Add(-time.Second), false},
+		{"过期时间", nil, created.AddDate(0, 1, 0), false},
+		{"已撤销", func(g *AccessGrant) { g.RevokedAt = "2024-03-02T08:00:00Z" }, created.Add(time.Hour), false},
+		{"授权时间损坏", func(g *AccessGrant) { g.CreatedAt = "yesterday" }, created.Add(time.Hour), false},
+		{"过期时间损坏", func(g *AccessGrant) { g.ExpiresAt = "" }, created.Add(time.Hour), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := grant
+			if tt.edit != nil {
+				tt.edit(&g)
+			}
+			if got := g.Active(tt.at); got != tt.valid {
+				t.Errorf("Active(%s) = %v，期望 %v", tt.at.Format(time.RFC3339), got, tt.valid)
+			}
+		})
+	}
+}
+
+func TestAccessGrantCovers(t *testing.T) {
+	grant := AccessGrant{Scope: AccessScope{DocTypes: []string{"Evaluation"}, Records: []string{"TestResult-test_001"}}}
+	tests := []struct {
+		docType, recordKey string
+		covered            bool
+	}{
+		{"Evaluation", "Evaluation-eval_001", true},
+		{"TestResult", "TestResult-test_001", true},
+		{"TestResult", "TestResult-test_002", false},
+		{"Judgement", "Judgement-judge_001", false},
+	}
+	for _, tt := range tests {
+		if got := grant.Covers(tt.docType, tt.recordKey); got != tt.covered {
+			t.Errorf("Covers(%s, %s) = %v，期望 %v", tt.docType, tt.recordKey, got, tt.covered)
+		}
+	}
+}
+
+// 未撤销的授权省略 revokedAt；只授权单条记录的请求可以省略 docTypes
+func TestAccessGrantJSON(t *testing.T) {
+	data, err := json.Marshal(AccessGrant{DocType: DocTypeAccessGrant, GrantID: "tx1", Scope: AccessScope{DocTypes: []string{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "revokedAt") || strings.Contains(string(data), "records") {
+		t.Errorf("JSON = %s，期望省略 revokedAt 和 records", data)
+	}
+	if !strings.Contains(string(data), `"docTypes":[]`) {
+		t.Errorf("JSON = %s，期望 docTypes 为空数组", data)
+	}
+
+	var scope AccessScope
+	if err := json.Unmarshal([]byte(`{"records":["Evaluation-eval_001"]}`), &scope); err != nil {
+		t.Fatal(err)
+	}
+	if scope.DocTypes != nil || len(scope.Records) != 1 {
+		t.Errorf("授权范围 = %+v", scope)
+	}
+}
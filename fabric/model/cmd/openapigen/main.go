@@ -0,0 +1,666 @@
+// openapigen 根据 SmartContract 的交易方法和共享记录类型生成 OpenAPI 3 文档
+//
+// 交易方法即 contractapi 生成链码元数据的来源：接收者为 *SmartContract、
+// 首个参数为交易上下文的导出方法。参数说明取自方法注释中的“参数：”一行，
+// 以 xxxJSON 命名并反序列化为结构体的字符串参数在接口中直接使用该结构体，
+// 可能返回的错误取自方法体中的 ccError/ccInvalid 调用，按错误信息键取中文信息和错误码归类；
+// 方法体中的 fmt.Errorf 文本按 model.ClassifyError 归类。
+//
+// 用法（在 fabric 目录下）：go generate，或
+//
+//	go run -C model ./cmd/openapigen -src ../chaincode/atcc/atcc.go,../txReceipt.go -out ../openapi.json [-check]
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"edu/model"
+)
+
+// 交易上下文参数的类型名，用于识别交易方法
+const contextType = "TransactionContextInterface"
+
+func main() {
+	sources := flag.String("src", "../chaincode/atcc/atcc.go,../txReceipt.go", "链码源文件及提交回执定义所在文件，逗号分隔")
+	output := flag.String("out", "../openapi.json", "输出文件")
+	check := flag.Bool("check", false, "只检查输出文件是否最新，不一致时返回非零状态")
+	flag.Parse()
+
+	gen := &generator{
+		fset:    token.NewFileSet(),
+		structs: make(map[string]*ast.TypeSpec),
+		aliases: make(map[string]string),
+		schemas: make(map[string]interface{}),
+	}
+	// 链码以类型别名引用 edu/model 中的结构，记录类型的 Schema 由 model 生成，其他结构从 model 源文件读取（在 model 目录下运行）
+	modelSources, err := filepath.Glob("*.go")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, path := range modelSources {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		if err := gen.parse(path); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, path := range strings.Split(*sources, ",") {
+		if err := gen.parse(strings.TrimSpace(path)); err != nil {
+			log.Fatal(err)
+		}
+	}
+	doc, err := gen.document()
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	data = append(data, '\n')
+
+	if *check {
+		existing, err := os.ReadFile(*output)
+		if err != nil || !bytes.Equal(existing, data) {
+			log.Fatalf("%s 不是最新的，请在 fabric 目录下运行 go generate", *output)
+		}
+		return
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("已生成 %s（%d 个交易）\n", *output, len(gen.methods))
+}
+
+// generator 收集源文件中的类型和交易方法
+type generator struct {
+	fset    *token.FileSet
+	structs map[string]*ast.TypeSpec // 源文件中定义的结构体
+	aliases map[string]string        // 类型别名 -> model 中的类型名
+	methods []*method
+	schemas map[string]interface{} // components.schemas
+}
+
+// method 一个交易方法
+type method struct {
+	name    string
+	section string // 所在的注释分节，用作 tag
+	doc     []string
+	params  []*ast.Field
+	body    *ast.BlockStmt
+	result  ast.Expr // 除 error 外的返回值
+	submit  bool     // 提交类交易：没有返回值，或方法体直接写入账本（如 GrantAccess 返回新授权）
+}
+
+func (g *generator) parse(path string) error {
+	file, err := parser.ParseFile(g.fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("解析 %s 失败: %v", path, err)
+	}
+
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				ts, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				if ts.Doc == nil {
+					ts.Doc = decl.Doc
+				}
+				if sel, ok := ts.Type.(*ast.SelectorExpr); ok && ts.Assign.IsValid() && exprString(sel.X) == "model" {
+					g.aliases[ts.Name.Name] = sel.Sel.Name
+				} else if _, ok := ts.Type.(*ast.StructType); ok {
+					g.structs[ts.Name.Name] = ts
+				}
+			}
+		case *ast.FuncDecl:
+			if m := g.transaction(file, decl); m != nil {
+				g.methods = append(g.methods, m)
+			}
+		}
+	}
+	return nil
+}
+
+// transaction 识别 SmartContract 的交易方法
+func (g *generator) transaction(file *ast.File, fn *ast.FuncDecl) *method {
+	if fn.Recv == nil || !fn.Name.IsExported() || exprString(fn.Recv.List[0].Type) != "*SmartContract" {
+		return nil
+	}
+	params := fn.Type.Params.List
+	if len(params) == 0 || !strings.HasSuffix(exprString(params[0].Type), contextType) {
+		return nil
+	}
+
+	m := &method{name: fn.Name.Name, body: fn.Body, section: section(file, fn.Pos())}
+	if fn.Doc != nil {
+		m.doc = strings.Split(strings.TrimSpace(fn.Doc.Text()), "\n")
+	}
+	// 展开 a, b string 形式的参数
+	for _, field := range params[1:] {
+		for _, name := range field.Names {
+			m.params = append(m.params, &ast.Field{Names: []*ast.Ident{name}, Type: field.Type})
+		}
+	}
+	if results := fn.Type.Results; results != nil && len(results.List) == 2 {
+		m.result = results.List[0].Type
+	}
+	m.submit = m.result == nil || writesState(fn.Body)
+	return m
+}
+
+// writesState 方法体是否直接调用 PutState 或 DelState；只在辅助函数中写入的（如访问日志）不算
+func writesState(body *ast.BlockStmt) bool {
+	writes := false
+	ast.Inspect(body, func(node ast.Node) bool {
+		if sel, ok := node.(*ast.SelectorExpr); ok && (sel.Sel.Name == "PutState" || sel.Sel.Name == "DelState") {
+			writes = true
+		}
+		return !writes
+	})
+	return writes
+}
+
+// section 方法之前最近的 “===== 标题 =====” 注释
+func section(file *ast.File, pos token.Pos) string {
+	title := ""
+	for _, group := range file.Comments {
+		if group.Pos() > pos {
+			break
+		}
+		for _, comment := range group.List {
+			text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+			if strings.HasPrefix(text, "=====") {
+				title = strings.TrimSpace(strings.Trim(text, "="))
+			}
+		}
+	}
+	return title
+}
+
+// ===================== 文档生成 =====================
+
+func (g *generator) document() (map[string]interface{}, error) {
+	kinds := make([]string, len(model.ErrorKinds))
+	for i, kind := range model.ErrorKinds {
+		kinds[i] = string(kind)
+	}
+	codes := make([]string, len(model.ErrorCodes))
+	for i, code := range model.ErrorCodes {
+		codes[i] = string(code)
+	}
+	g.schemas["ErrorResponse"] = map[string]interface{}{
+		"type":     "object",
+		"required": []string{"code", "message"},
+		"properties": map[string]interface{}{
+			"code":    map[string]interface{}{"type": "string", "enum": kinds, "description": "错误类别"},
+			"message": map[string]interface{}{"type": "string", "description": "错误信息，语言取自 Accept-Language（zh/en）"},
+			"reason":  map[string]interface{}{"type": "string", "enum": codes, "description": "链码错误码，只有链码返回的错误才有"},
+			"key":     map[string]interface{}{"type": "string", "description": "链码错误信息键，见 x-fabric-error-keys"},
+			"details": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+				"description":          "错误信息中的参数",
+			},
+			"fields": map[string]interface{}{
+				"type":        "array",
+				"description": "校验未通过的字段",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"field":   map[string]interface{}{"type": "string"},
+						"rule":    map[string]interface{}{"type": "string"},
+						"message": map[string]interface{}{"type": "string"},
+					},
+				},
+			},
+		},
+	}
+
+	paths := make(map[string]interface{})
+	tags := []interface{}{}
+	seenTags := make(map[string]bool)
+	for _, m := range g.methods {
+		operation, err := g.operation(m)
+		if err != nil {
+			return nil, err
+		}
+		paths["/api/"+m.name] = map[string]interface{}{"post": operation}
+		if m.section != "" && !seenTags[m.section] {
+			seenTags[m.section] = true
+			tags = append(tags, map[string]interface{}{"name": m.section})
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":   "教育评价链码接口",
+			"version": "1.0.0",
+			"description": "由 SmartContract 生成，请勿手工修改。提交类交易返回交易回执，查询类交易返回链码结果。" +
+				"调用交易需在 Authorization 头中带调用方令牌，服务只开放启动时允许的交易。",
+		},
+		"tags":     tags,
+		"paths":    paths,
+		"security": []interface{}{map[string]interface{}{"bearerAuth": []string{}}},
+		"components": map[string]interface{}{
+			"schemas":         g.schemas,
+			"securitySchemes": map[string]interface{}{"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer"}},
+		},
+	}, nil
+}
+
+func (g *generator) operation(m *method) (map[string]interface{}, error) {
+	summary, paramDocs, resultDoc := parseDoc(m)
+	kind := "evaluate"
+	if m.submit {
+		kind = "submit"
+	}
+
+	operation := map[string]interface{}{
+		"operationId":             m.name,
+		"summary":                 summary,
+		"x-fabric-transaction":    kind,
+		"responses":               map[string]interface{}{},
+		"x-fabric-error-messages": []string{},
+	}
+	if m.section != "" {
+		operation["tags"] = []string{m.section}
+	}
+
+	// 请求体：参数按名称组成对象，服务端按 x-fabric-parameters 的顺序传给链码
+	decoded := g.decodedParams(m)
+	properties := make(map[string]interface{})
+	var required []string
+	order := []interface{}{}
+	for i, param := range m.params {
+		name := param.Names[0].Name
+		var schema map[string]interface{}
+		typeName, isJSON := decoded[name]
+		if isJSON {
+			schema = g.ref(typeName)
+		} else {
+			var err error
+			if schema, err = g.schemaFor(param.Type); err != nil {
+				return nil, fmt.Errorf("%s 参数 %s: %v", m.name, name, err)
+			}
+		}
+		if i < len(paramDocs) {
+			schema = withDescription(schema, paramDocs[i])
+		}
+		properties[name] = schema
+		required = append(required, name)
+		order = append(order, map[string]interface{}{"name": name, "json": isJSON})
+	}
+	operation["x-fabric-parameters"] = order
+	if len(m.params) > 0 {
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": map[string]interface{}{"type": "object", "properties": properties, "required": required},
+				},
+			},
+		}
+	}
+
+	responses := operation["responses"].(map[string]interface{})
+	var result map[string]interface{}
+	// 提交类交易由客户端返回交易回执，不返回链码结果
+	if !m.submit {
+		var err error
+		if result, err = g.schemaFor(m.result); err != nil {
+			return nil, fmt.Errorf("%s 返回值: %v", m.name, err)
+		}
+	} else {
+		result = g.ref("TxReceipt")
+		resultDoc = "交易回执"
+	}
+	responses["200"] = map[string]interface{}{
+		"description": orDefault(resultDoc, "成功"),
+		"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": result}},
+	}
+
+	// 错误响应：链码中的错误文本按类别分组，请求格式错误、内部错误和网关不可用总是可能出现，
+	// 提交类交易还可能在验证阶段失败
+	entries, err := errorEntries(m.body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", m.name, err)
+	}
+	messages, keys := []string{}, []string{}
+	for _, entry := range entries {
+		messages = append(messages, entry.message)
+		if entry.key != "" {
+			keys = append(keys, entry.key)
+		}
+	}
+	operation["x-fabric-error-messages"] = messages
+	operation["x-fabric-error-keys"] = keys
+	byKind := map[model.ErrorKind][]string{
+		model.ErrInvalidArgument: {"请求体格式错误"},
+		model.ErrUnauthenticated: {"缺少或错误的调用方令牌"},
+		model.ErrInternal:        nil,
+		model.ErrUnavailable:     {"网关节点不可用或调用超时"},
+	}
+	if m.submit {
+		byKind[model.ErrConflict] = []string{"交易验证失败（如 MVCC_READ_CONFLICT）"}
+	}
+	for _, entry := range entries {
+		byKind[entry.kind] = append(byKind[entry.kind], entry.message)
+	}
+	for kind, list := range byKind {
+		description := string(kind)
+		if len(list) > 0 {
+			description += "：" + strings.Join(list, "；")
+		}
+		responses[strconv.Itoa(kind.HTTPStatus())] = map[string]interface{}{
+			"description": description,
+			"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": g.ref("ErrorResponse")}},
+		}
+	}
+	return operation, nil
+}
+
+// parseDoc 从方法注释中取出摘要、各参数说明和返回值说明
+func parseDoc(m *method) (summary string, params []string, result string) {
+	for i, line := range m.doc {
+		line = strings.TrimSpace(line)
+		switch {
+		case i == 0:
+			summary = strings.TrimSpace(strings.TrimPrefix(line, m.name))
+		case strings.HasPrefix(line, "参数："):
+			text := strings.TrimPrefix(line, "参数：")
+			if text != "无" {
+				params = splitTopLevel(text)
+			}
+		case strings.HasPrefix(line, "返回值："):
+			result = strings.TrimSuffix(strings.TrimPrefix(line, "返回值："), "，错误信息")
+		}
+	}
+	return summary, params, result
+}
+
+// splitTopLevel 按全角逗号分割，括号内的逗号不分割
+func splitTopLevel(text string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range text {
+		switch r {
+		case '（', '(':
+			depth++
+		case '）', ')':
+			depth--
+		case '，':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(text[start:i]))
+				start = i + len("，")
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(text[start:]))
+}
+
+// decodedParams 找出方法体中 json.Unmarshal([]byte(param), &v) 的参数及 v 的类型
+func (g *generator) decodedParams(m *method) map[string]string {
+	varTypes := make(map[string]string)
+	decoded := make(map[string]string)
+	ast.Inspect(m.body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.ValueSpec:
+			for _, name := range node.Names {
+				varTypes[name.Name] = exprString(node.Type)
+			}
+		case *ast.CallExpr:
+			if exprString(node.Fun) != "json.Unmarshal" || len(node.Args) != 2 {
+				return true
+			}
+			conv, ok := node.Args[0].(*ast.CallExpr)
+			target, ok2 := node.Args[1].(*ast.UnaryExpr)
+			if !ok || !ok2 || len(conv.Args) != 1 || exprString(conv.Fun) != "[]byte" {
+				return true
+			}
+			param, ok := conv.Args[0].(*ast.Ident)
+			v, ok2 := target.X.(*ast.Ident)
+			if ok && ok2 && varTypes[v.Name] != "" {
+				decoded[param.Name] = varTypes[v.Name]
+			}
+		}
+		return true
+	})
+	return decoded
+}
+
+// errorEntry 方法可能返回的一种错误
+type errorEntry struct {
+	key     string // 链码错误信息键，fmt.Errorf 的错误为空
+	message string // 中文信息
+	kind    model.ErrorKind
+}
+
+// errorEntries 取出方法体中 ccError/ccInvalid 的错误信息键和 fmt.Errorf 的错误文本，按出现顺序去重
+func errorEntries(body *ast.BlockStmt) ([]errorEntry, error) {
+	var entries []errorEntry
+	var err error
+	seen := make(map[string]bool)
+	ast.Inspect(body, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok || err != nil {
+			return err == nil
+		}
+		var entry errorEntry
+		switch exprString(call.Fun) {
+		case "ccError", "ccInvalid":
+			if len(call.Args) < 2 {
+				return true
+			}
+			key, ok := stringLiteral(call.Args[1])
+			if !ok {
+				return true
+			}
+			code, message, known := model.MessageTemplate(key)
+			if !known {
+				err = fmt.Errorf("未登记的错误信息键 %q", key)
+				return false
+			}
+			entry = errorEntry{key: key, message: message, kind: code.Kind()}
+		case "fmt.Errorf":
+			if len(call.Args) == 0 {
+				return true
+			}
+			message, ok := stringLiteral(call.Args[0])
+			if !ok {
+				return true
+			}
+			entry = errorEntry{message: message, kind: model.ClassifyError(message)}
+		default:
+			return true
+		}
+		if !seen[entry.message] {
+			seen[entry.message] = true
+			entries = append(entries, entry)
+		}
+		return true
+	})
+	return entries, err
+}
+
+func stringLiteral(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	value, err := strconv.Unquote(lit.Value)
+	return value, err == nil
+}
+
+// ===================== 类型映射 =====================
+
+// schemaFor 将 Go 类型映射为 JSON Schema，结构体生成到 components.schemas 并返回引用
+func (g *generator) schemaFor(expr ast.Expr) (map[string]interface{}, error) {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return g.schemaFor(t.X)
+	case *ast.ArrayType:
+		items, err := g.schemaFor(t.Elt)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case *ast.SelectorExpr:
+		if exprString(t) == "time.Time" {
+			return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+		}
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return map[string]interface{}{"type": "string"}, nil
+		case "bool":
+			return map[string]interface{}{"type": "boolean"}, nil
+		case "int32":
+			return map[string]interface{}{"type": "integer", "format": "int32"}, nil
+		case "int", "int64":
+			return map[string]interface{}{"type": "integer", "format": "int64"}, nil
+		case "uint64":
+			return map[string]interface{}{"type": "integer", "format": "int64", "minimum": 0}, nil
+		}
+		if _, ok := g.aliases[t.Name]; ok {
+			return g.ref(t.Name), nil
+		}
+		if _, ok := g.structs[t.Name]; ok {
+			return g.ref(t.Name), nil
+		}
+	}
+	return nil, fmt.Errorf("不支持的类型 %s", exprString(expr))
+}
+
+// ref 返回组件引用，首次引用时生成组件
+func (g *generator) ref(name string) map[string]interface{} {
+	reference := map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	if _, done := g.schemas[name]; done {
+		return reference
+	}
+	g.schemas[name] = nil // 先占位，防止递归引用
+
+	structName := name
+	if modelName, ok := g.aliases[name]; ok {
+		if _, isRecord := model.Records[modelName]; isRecord {
+			g.schemas[name] = modelSchema(modelName)
+			return reference
+		}
+		structName = modelName
+	}
+	ts, ok := g.structs[structName]
+	if !ok {
+		log.Fatalf("找不到类型 %s 的定义", name)
+	}
+	schema, err := g.structSchema(ts)
+	if err != nil {
+		log.Fatalf("类型 %s: %v", name, err)
+	}
+	g.schemas[name] = schema
+	return reference
+}
+
+// modelSchema 共享记录类型使用 model 生成的 Schema，去掉独立文档才需要的 $schema 和 $id
+func modelSchema(name string) map[string]interface{} {
+	record, ok := model.Records[name]
+	if !ok {
+		log.Fatalf("model 中没有记录类型 %s", name)
+	}
+	data, err := model.MarshalSchema(record)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var schema map[string]interface{}
+	json.Unmarshal(data, &schema)
+	delete(schema, "$schema")
+	delete(schema, "$id")
+	return schema
+}
+
+func (g *generator) structSchema(ts *ast.TypeSpec) (map[string]interface{}, error) {
+	properties := make(map[string]interface{})
+	var required []string
+	for _, field := range ts.Type.(*ast.StructType).Fields.List {
+		if len(field.Names) == 0 || field.Tag == nil {
+			continue
+		}
+		tag, _ := strconv.Unquote(field.Tag.Value)
+		name, options, _ := strings.Cut(reflect.StructTag(tag).Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		schema, err := g.schemaFor(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("字段 %s: %v", name, err)
+		}
+		if field.Comment != nil {
+			schema = withDescription(schema, strings.TrimSpace(field.Comment.Text()))
+		}
+		properties[name] = schema
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	sort.Strings(required)
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	if ts.Doc != nil {
+		schema["description"] = strings.TrimPrefix(strings.TrimSpace(ts.Doc.Text()), ts.Name.Name+" ")
+	}
+	return schema, nil
+}
+
+// withDescription 返回带说明的副本，OpenAPI 3.1 允许 $ref 与 description 并列
+func withDescription(schema map[string]interface{}, description string) map[string]interface{} {
+	if description == "" {
+		return schema
+	}
+	copied := make(map[string]interface{}, len(schema)+1)
+	for k, v := range schema {
+		copied[k] = v
+	}
+	copied["description"] = description
+	return copied
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// exprString 类型表达式的源码形式，如 *SmartContract、contractapi.TransactionContextInterface
+func exprString(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return "*" + exprString(t.X)
+	case *ast.SelectorExpr:
+		return exprString(t.X) + "." + t.Sel.Name
+	case *ast.ArrayType:
+		return "[]" + exprString(t.Elt)
+	}
+	return fmt.Sprintf("%T", expr)
+}
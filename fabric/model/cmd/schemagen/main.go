@@ -0,0 +1,41 @@
+// schemagen 为共享记录类型生成 JSON Schema 文档，供前端和 Java 服务校验使用
+//
+// 用法：go generate ./...（在 model 目录下），或 go run ./cmd/schemagen -dir <输出目录>
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"edu/model"
+)
+
+func main() {
+	dir := flag.String("dir", "schema", "输出目录")
+	flag.Parse()
+
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		log.Fatalf("创建输出目录失败: %v", err)
+	}
+	names := make([]string, 0, len(model.Records))
+	for name := range model.Records {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		data, err := model.MarshalSchema(model.Records[name])
+		if err != nil {
+			log.Fatalf("生成 %s 的 Schema 失败: %v", name, err)
+		}
+		path := filepath.Join(*dir, name+".schema.json")
+		if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+			log.Fatalf("写入 %s 失败: %v", path, err)
+		}
+		fmt.Println(path)
+	}
+}
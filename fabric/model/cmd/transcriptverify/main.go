@@ -0,0 +1,91 @@
+// transcriptverify 离线验证成绩证明包，只依赖标准库，可单独分发给接收方
+//
+// 用法：
+//
+//	go run ./cmd/transcriptverify -ca <学校根CA证书PEM>[,...] [-intermediates <中间CA PEM>] [-msp <学校MSP ID>] [-min-endorsements N] <证明包文件>
+//
+// 全部记录验证通过时退出码为 0，否则为 1。
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"edu/model"
+	"edu/model/transcript"
+)
+
+func main() {
+	roots := flag.String("ca", "", "学校根CA证书PEM文件，逗号分隔")
+	intermediates := flag.String("intermediates", "", "中间CA证书PEM文件，逗号分隔")
+	issuerMSP := flag.String("msp", model.InstitutionMSPID, "签发证明的学校组织 MSP ID")
+	minEndorsements := flag.Int("min-endorsements", 1, "每笔交易至少需要的不同背书者数")
+	flag.Parse()
+	if *roots == "" || flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "用法: transcriptverify -ca <根CA证书>[,...] [-intermediates <中间CA证书>] [-msp <MSP ID>] [-min-endorsements N] <证明包文件>")
+		os.Exit(2)
+	}
+
+	options := transcript.VerifyOptions{IssuerMSPID: *issuerMSP, MinEndorsements: *minEndorsements}
+	var err error
+	if options.Roots, err = loadCertPool(*roots); err != nil {
+		log.Fatal(err)
+	}
+	if *intermediates != "" {
+		if options.Intermediates, err = loadCertPool(*intermediates); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("读取证明包失败: %v", err)
+	}
+	var bundle transcript.Bundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		log.Fatalf("解析证明包失败: %v", err)
+	}
+
+	t, results, err := transcript.Verify(&bundle, options)
+	if err != nil {
+		log.Fatalf("验证失败: %v", err)
+	}
+	fmt.Printf("学生 %s 的成绩证明，由 %s 于 %s 签发，通道 %s，链码 %s\n",
+		t.UserID, t.Issuer.MSPID, t.IssuedAt.Format("2006-01-02 15:04:05"), t.Channel, t.Chaincode)
+
+	failed := 0
+	for i, r := range results {
+		if r.Err != nil {
+			failed++
+			fmt.Printf("✗ %s %s（交易 %s）: %v\n", r.DocType, r.RecordID, r.TransactionID, r.Err)
+			continue
+		}
+		fmt.Printf("✓ %s %s %s\n  交易 %s，区块 %d，%s，背书 %s\n", r.DocType, r.RecordID, t.Entries[i].Record,
+			r.TransactionID, r.BlockNumber, r.Timestamp.Format("2006-01-02 15:04:05"), strings.Join(r.Endorsers, ", "))
+	}
+	if failed > 0 {
+		fmt.Printf("%d 条记录中 %d 条验证失败\n", len(results), failed)
+		os.Exit(1)
+	}
+	fmt.Printf("%d 条记录全部验证通过\n", len(results))
+}
+
+// loadCertPool 读取逗号分隔的PEM文件，每个文件可包含多张证书
+func loadCertPool(paths string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, path := range strings.Split(paths, ",") {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取证书文件失败: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("%s 中没有有效的PEM证书", path)
+		}
+	}
+	return pool, nil
+}
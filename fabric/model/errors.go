@@ -0,0 +1,185 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ===================== 错误类别 =====================
+
+// ErrorKind 链码错误类别，用于 REST 接口的错误码和 HTTP 状态码
+type ErrorKind string
+
+const (
+	ErrInvalidArgument ErrorKind = "INVALID_ARGUMENT"
+	ErrUnauthenticated ErrorKind = "UNAUTHENTICATED" // REST 接口的调用方令牌缺失或错误，链码错误不会归入
+	ErrForbidden       ErrorKind = "FORBIDDEN"
+	ErrNotFound        ErrorKind = "NOT_FOUND"
+	ErrConflict        ErrorKind = "CONFLICT"
+	ErrUnavailable     ErrorKind = "UNAVAILABLE"
+	ErrInternal        ErrorKind = "INTERNAL"
+)
+
+// ErrorKinds 全部错误类别，按 HTTP 状态码排列
+var ErrorKinds = []ErrorKind{ErrInvalidArgument, ErrUnauthenticated, ErrForbidden, ErrNotFound, ErrConflict, ErrInternal, ErrUnavailable}
+
+// HTTPStatus 错误类别对应的 HTTP 状态码
+func (k ErrorKind) HTTPStatus() int {
+	switch k {
+	case ErrInvalidArgument:
+		return http.StatusBadRequest
+	case ErrUnauthenticated:
+		return http.StatusUnauthorized
+	case ErrForbidden:
+		return http.StatusForbidden
+	case ErrNotFound:
+		return http.StatusNotFound
+	case ErrConflict:
+		return http.StatusConflict
+	case ErrUnavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// errorKeywords 按顺序匹配，先排除状态数据库读写等内部错误，再识别参数错误
+var errorKeywords = []struct {
+	keyword string
+	kind    ErrorKind
+}{
+	{"找不到", ErrNotFound},
+	{"无权", ErrForbidden},
+	{"已存在", ErrConflict},
+	{"已撤销", ErrConflict},
+	{"已擦除", ErrConflict},
+	{"状态数据库", ErrInternal},
+	{"状态查询失败", ErrInternal},
+	{"查询执行失败", ErrInternal},
+	{"结果迭代失败", ErrInternal},
+	{"数据解析失败", ErrInternal},
+	{"数据序列化失败", ErrInternal},
+	{"校验失败", ErrInvalidArgument},
+	{"解析", ErrInvalidArgument},
+	{"不能为空", ErrInvalidArgument},
+	{"不支持", ErrInvalidArgument},
+	{"禁止", ErrInvalidArgument},
+	{"必须", ErrInvalidArgument},
+	{"没有", ErrInvalidArgument},
+	{"缺少", ErrInvalidArgument},
+}
+
+// ClassifyError 按错误信息归类链码错误
+// 链码错误带有错误码时按错误码归类；contractapi 自身的参数错误等文本错误按其中的关键字识别，无法识别的归为 INTERNAL
+func ClassifyError(message string) ErrorKind {
+	if coded, ok := ParseError(message); ok {
+		return coded.Code.Kind()
+	}
+	for _, rule := range errorKeywords {
+		if strings.Contains(message, rule.keyword) {
+			return rule.kind
+		}
+	}
+	return ErrInternal
+}
+
+// ===================== 链码错误码 =====================
+
+// ErrorCode 链码错误码，随错误一起返回，调用者据此判断错误而不再匹配错误信息
+// ErrorCode 实现 error，客户端可以用 errors.Is(err, model.CodeNotFound) 判断
+type ErrorCode string
+
+const (
+	CodeNotFound     ErrorCode = "NOT_FOUND"
+	CodeForbidden    ErrorCode = "FORBIDDEN"
+	CodeDuplicate    ErrorCode = "DUPLICATE"
+	CodeInvalidInput ErrorCode = "INVALID_INPUT"
+	CodeConflict     ErrorCode = "CONFLICT"
+	CodeInternal     ErrorCode = "INTERNAL"
+)
+
+// ErrorCodes 全部链码错误码
+var ErrorCodes = []ErrorCode{CodeNotFound, CodeForbidden, CodeDuplicate, CodeInvalidInput, CodeConflict, CodeInternal}
+
+func (c ErrorCode) Error() string {
+	return string(c)
+}
+
+// Kind 错误码对应的错误类别，重复记录视为冲突
+func (c ErrorCode) Kind() ErrorKind {
+	switch c {
+	case CodeNotFound:
+		return ErrNotFound
+	case CodeForbidden:
+		return ErrForbidden
+	case CodeDuplicate, CodeConflict:
+		return ErrConflict
+	case CodeInvalidInput:
+		return ErrInvalidArgument
+	}
+	return ErrInternal
+}
+
+// CodedError 链码返回的结构化错误，以 JSON 作为交易的错误信息返回
+type CodedError struct {
+	Code    ErrorCode         `json:"code"`
+	Key     string            `json:"key"`               // 错误信息键，见 messages.go
+	Message string            `json:"message"`           // 按调用者选择的语言生成
+	Details map[string]string `json:"details,omitempty"` // 错误信息中的参数
+	Fields  []FieldError      `json:"fields,omitempty"`  // 校验未通过的字段
+}
+
+// NewError 按错误信息键创建错误，details 为成对的参数名和值，错误信息为中文
+// 错误码由键决定，未登记的键为 INTERNAL
+func NewError(key string, details ...string) *CodedError {
+	e := &CodedError{Code: CodeInternal, Key: key}
+	if entry, ok := messages[key]; ok {
+		e.Code = entry.code
+	}
+	if len(details) > 0 {
+		e.Details = make(map[string]string, len(details)/2)
+		for i := 0; i+1 < len(details); i += 2 {
+			e.Details[details[i]] = details[i+1]
+		}
+	}
+	return e.Localize(LangZH)
+}
+
+// WithFields 附加校验未通过的字段，err 不是 *ValidationError 时原样返回
+func (e *CodedError) WithFields(err error) *CodedError {
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		e.Fields = invalid.Fields
+	}
+	return e
+}
+
+// Localize 按语言重新生成错误信息
+func (e *CodedError) Localize(lang string) *CodedError {
+	e.Message = Message(lang, e.Key, e.Details)
+	return e
+}
+
+// Error 返回 JSON 编码，contractapi 以此作为交易的错误信息
+func (e *CodedError) Error() string {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return e.Message
+	}
+	return string(data)
+}
+
+// ParseError 从错误信息中取出链码返回的结构化错误
+// 网关和 SDK 会在链码错误信息前附加自己的说明，从第一个错误码对象处开始解析
+func ParseError(message string) (*CodedError, bool) {
+	start := strings.Index(message, `{"code":"`)
+	if start < 0 {
+		return nil, false
+	}
+	var coded CodedError
+	if err := json.NewDecoder(strings.NewReader(message[start:])).Decode(&coded); err != nil || coded.Code == "" || coded.Key == "" {
+		return nil, false
+	}
+	return &coded, true
+}
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestNewError(t *testing.T) {
+	e := NewError("evaluation.exists", "id", "eval_001")
+	if e.Code != CodeDuplicate || e.Message != "测评记录 eval_001 已存在" || e.Details["id"] != "eval_001" {
+		t.Errorf("错误 = %+v", e)
+	}
+	if unknown := NewError("no.such_key"); unknown.Code != CodeInternal || unknown.Message != "no.such_key" {
+		t.Errorf("未登记的键 = %+v，期望 INTERNAL 且信息为键本身", unknown)
+	}
+
+	invalid := &ValidationError{Fields: []FieldError{{Field: "User_ID", Rule: "required"}}}
+	withFields := NewError("evaluation.invalid_json", "cause", "bad").WithFields(fmt.Errorf("校验: %w", invalid))
+	if !reflect.DeepEqual(withFields.Fields, invalid.Fields) {
+		t.Errorf("校验字段 = %+v，期望 %+v", withFields.Fields, invalid.Fields)
+	}
+}
+
+// 网关和 SDK 在链码错误信息前后附加说明时仍能解析出结构化错误
+func TestParseError(t *testing.T) {
+	coded := NewError("record.not_found", "record", "Evaluation-eval_001")
+	tests := []struct {
+		name    string
+		message string
+		ok      bool
+	}{
+		{"原始错误", coded.Error(), true},
+		{"查询错误", "rpc error: code = Unknown desc = evaluate call to endorser returned error: chaincode response 500, " + coded.Error(), true},
+		{"背书错误详情", "chaincode response 500, " + coded.Error() + " (peer0.org1.example.com:7051)", true},
+		{"文本错误", "rpc error: code = Unavailable desc = connection refused", false},
+		{"缺少错误信息键", `{"code":"NOT_FOUND","message":"找不到"}`, false},
+		{"JSON 损坏", `{"code":"NOT_FOUND","key":`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseError(tt.message)
+			if ok != tt.ok {
+				t.Fatalf("ParseError = %+v, %v，期望 %v", got, ok, tt.ok)
+			}
+			if ok && !reflect.DeepEqual(got, coded) {
+				t.Errorf("解析结果 = %+v，期望 %+v", got, coded)
+			}
+		})
+	}
+}
+
+func TestErrorCodeIs(t *testing.T) {
+	err := fmt.Errorf("查询失败: %w", CodeNotFound)
+	if !errors.Is(err, CodeNotFound) {
+		t.Error("errors.Is 应识别包装后的错误码")
+	}
+	if errors.Is(err, CodeForbidden) {
+		t.Error("不同错误码不应匹配")
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	tests := []struct {
+		code   ErrorCode
+		kind   ErrorKind
+		status int
+	}{
+		{CodeNotFound, ErrNotFound, http.StatusNotFound},
+		{CodeForbidden, ErrForbidden, http.StatusForbidden},
+		{CodeDuplicate, ErrConflict, http.StatusConflict},
+		{CodeConflict, ErrConflict, http.StatusConflict},
+		{CodeInvalidInput, ErrInvalidArgument, http.StatusBadRequest},
+		{CodeInternal, ErrInternal, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if kind := tt.code.Kind(); kind != tt.kind || kind.HTTPStatus() != tt.status {
+			t.Errorf("%s 的类别 = %s (%d)，期望 %s (%d)", tt.code, kind, kind.HTTPStatus(), tt.kind, tt.status)
+		}
+	}
+
+	if status := ErrUnauthenticated.HTTPStatus(); status != http.StatusUnauthorized {
+		t.Errorf("UNAUTHENTICATED 的状态码 = %d，期望 401", status)
+	}
+	if kind := ClassifyError(NewError("grant.revoked", "grantId", "tx1").Error()); kind != ErrConflict {
+		t.Errorf("带错误码的错误类别 = %s，期望 CONFLICT", kind)
+	}
+	if kind := ClassifyError("Function Foo not found in contract SmartContract"); kind != ErrInternal {
+		t.Errorf("无法识别的错误类别 = %s，期望 INTERNAL", kind)
+	}
+}
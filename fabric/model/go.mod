@@ -0,0 +1,3 @@
+module edu/model
+
+go 1.20
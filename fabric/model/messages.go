@@ -0,0 +1,138 @@
+package model
+
+import (
+	"strings"
+)
+
+// ===================== 错误信息 =====================
+
+// 错误信息语言，未指定时为中文
+const (
+	LangZH = "zh"
+	LangEN = "en"
+)
+
+// ParseLanguage 从语言标签或 Accept-Language 列表中取出第一个支持的语言，都不支持时返回空串
+func ParseLanguage(value string) string {
+	for _, item := range strings.Split(value, ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.Split(item, ";")[0]))
+		switch {
+		case tag == LangZH || strings.HasPrefix(tag, LangZH+"-") || strings.HasPrefix(tag, LangZH+"_"):
+			return LangZH
+		case tag == LangEN || strings.HasPrefix(tag, LangEN+"-") || strings.HasPrefix(tag, LangEN+"_"):
+			return LangEN
+		}
+	}
+	return ""
+}
+
+// message 错误信息模板，{名称} 由错误参数替换
+type message struct {
+	code ErrorCode
+	zh   string
+	en   string
+}
+
+// messages 链码错误信息，键一经发布不再修改，调用者可以据此判断具体错误
+var messages = map[string]message{
+	// 记录不存在
+	"evaluation.not_found":  {CodeNotFound, "找不到指定测评记录", "evaluation not found"},
+	"test_result.not_found": {CodeNotFound, "找不到指定测试结果", "test result not found"},
+	"judgement.not_found":   {CodeNotFound, "找不到指定评价记录", "judgement not found"},
+	"record.not_found":      {CodeNotFound, "找不到记录 {record}", "record {record} not found"},
+	"grant.not_found":       {CodeNotFound, "找不到指定授权", "access grant not found"},
+
+	// 无权访问
+	"record.forbidden":          {CodeForbidden, "无权访问该记录", "access to this record is forbidden"},
+	"test_result.forbidden":     {CodeForbidden, "无权访问该测试记录", "access to this test result is forbidden"},
+	"judgement.forbidden":       {CodeForbidden, "无权访问该评价记录", "access to this judgement is forbidden"},
+	"user_records.forbidden":    {CodeForbidden, "无权访问该用户的记录", "access to this user's records is forbidden"},
+	"export.forbidden":          {CodeForbidden, "无权批量导出记录", "bulk export is forbidden"},
+	"grant.student_only":        {CodeForbidden, "无权授权：只有学生身份可以授权访问自己的记录", "only students can grant access to their own records"},
+	"grant.record_forbidden":    {CodeForbidden, "无权授权他人的记录 {record}", "cannot grant access to another user's record {record}"},
+	"grant.revoke_forbidden":    {CodeForbidden, "无权撤销该授权", "revoking this access grant is forbidden"},
+	"grant.list_forbidden":      {CodeForbidden, "无权查看该用户的授权", "viewing this user's access grants is forbidden"},
+	"access_log.forbidden":      {CodeForbidden, "无权查看该用户的访问日志", "viewing this user's access log is forbidden"},
+	"user_data.erase_forbidden": {CodeForbidden, "无权擦除该用户的数据", "erasing this user's data is forbidden"},
+	"record_key.mismatch":       {CodeForbidden, "无权使用与用户 {userId} 登记的密钥不一致的记录加密密钥", "the record encryption key does not match the key registered for user {userId}"},
+
+	// 记录重复
+	"evaluation.exists":  {CodeDuplicate, "测评记录 {id} 已存在", "evaluation {id} already exists"},
+	"test_result.exists": {CodeDuplicate, "测试结果 {id} 已存在", "test result {id} already exists"},
+
+	// 与当前状态冲突
+	"grant.revoked":          {CodeConflict, "授权 {grantId} 已撤销", "access grant {grantId} has been revoked"},
+	"user_data.erased":       {CodeConflict, "用户 {userId} 的数据已擦除", "the data of user {userId} has been erased"},
+	"user_data.erased_write": {CodeConflict, "用户 {userId} 的数据已擦除，不能再写入个人内容", "the data of user {userId} has been erased, personal content can no longer be written"},
+
+	// 参数错误
+	"argument.empty":           {CodeInvalidInput, "参数不能为空", "arguments must not be empty"},
+	"user_id.empty":            {CodeInvalidInput, "用户ID不能为空", "user ID must not be empty"},
+	"test_id.empty":            {CodeInvalidInput, "测试ID不能为空", "test ID must not be empty"},
+	"judgement_id.empty":       {CodeInvalidInput, "评价ID不能为空", "judgement ID must not be empty"},
+	"record_id.empty":          {CodeInvalidInput, "记录ID不能为空", "record ID must not be empty"},
+	"grant_id.empty":           {CodeInvalidInput, "授权ID不能为空", "access grant ID must not be empty"},
+	"evaluation.invalid_json":  {CodeInvalidInput, "解析测评记录失败: {cause}", "failed to parse evaluation: {cause}"},
+	"test_result.invalid_json": {CodeInvalidInput, "解析测试结果失败: {cause}", "failed to parse test result: {cause}"},
+	"judgement.invalid_json":   {CodeInvalidInput, "解析评价记录失败: {cause}", "failed to parse judgement: {cause}"},
+	"evaluation.invalid":       {CodeInvalidInput, "测评记录校验失败: {cause}", "evaluation is invalid: {cause}"},
+	"test_result.invalid":      {CodeInvalidInput, "测试结果校验失败: {cause}", "test result is invalid: {cause}"},
+	"judgement.invalid":        {CodeInvalidInput, "评价记录校验失败: {cause}", "judgement is invalid: {cause}"},
+	"evaluation.id_immutable":  {CodeInvalidInput, "禁止修改测评ID", "the evaluation ID cannot be changed"},
+	"record_type.unsupported":  {CodeInvalidInput, "不支持的记录类型 {type}", "unsupported record type {type}"},
+	"export.page_size":         {CodeInvalidInput, "每页条数必须在1到{max}之间", "page size must be between 1 and {max}"},
+	"export.invalid_filter":    {CodeInvalidInput, "解析过滤条件失败: {cause}", "failed to parse filter: {cause}"},
+	"export.no_paper_field":    {CodeInvalidInput, "{type} 没有试卷编号字段", "{type} has no paper number field"},
+	"export.no_time_field":     {CodeInvalidInput, "{type} 没有时间字段", "{type} has no time field"},
+	"grant.grantee_format":     {CodeInvalidInput, "被授权身份格式必须为 <MSP ID>/<证书CN>", "grantee must be <MSP ID>/<certificate CN>"},
+	"grant.self":               {CodeInvalidInput, "禁止授权给自己", "cannot grant access to yourself"},
+	"grant.invalid_scope":      {CodeInvalidInput, "解析授权范围失败: {cause}", "failed to parse access scope: {cause}"},
+	"grant.empty_scope":        {CodeInvalidInput, "授权范围必须指定记录类型或记录", "access scope must name record types or records"},
+	"grant.record_format":      {CodeInvalidInput, "授权记录格式必须为 <记录类型>-<记录ID>: {record}", "granted record must be <record type>-<record ID>: {record}"},
+	"grant.invalid_expiry":     {CodeInvalidInput, "解析过期时间失败: {cause}", "failed to parse expiry time: {cause}"},
+	"grant.expiry_past":        {CodeInvalidInput, "过期时间必须晚于交易时间", "expiry time must be after the transaction time"},
+	"grant.expiry_too_long":    {CodeInvalidInput, "授权有效期必须在 {days} 天以内", "access grants must expire within {days} days"},
+	"record_key.missing":       {CodeInvalidInput, "缺少记录加密密钥（瞬态数据 {transient}，32字节）", "missing record encryption key (transient field {transient}, 32 bytes)"},
+	"field.ciphertext":         {CodeInvalidInput, "禁止提交密文，{field} 必须为明文", "ciphertext is not accepted, {field} must be plaintext"},
+	"field.in_args":            {CodeInvalidInput, "{field} 属于个人内容，必须通过瞬态数据 {transient} 提交，不能放在交易参数中", "{field} is personal content and must be sent in transient field {transient}, not in the transaction arguments"},
+	"personal.invalid_json":    {CodeInvalidInput, "解析瞬态数据 {transient} 中的个人内容失败: {cause}", "failed to parse personal content in transient field {transient}: {cause}"},
+
+	// 内部错误
+	"state.read_failed":     {CodeInternal, "状态数据库查询失败: {cause}", "failed to read world state: {cause}"},
+	"state.write_failed":    {CodeInternal, "状态数据库写入失败: {cause}", "failed to write world state: {cause}"},
+	"query.failed":          {CodeInternal, "查询执行失败: {cause}", "query failed: {cause}"},
+	"query.iterate_failed":  {CodeInternal, "结果迭代失败: {cause}", "failed to iterate query results: {cause}"},
+	"data.unmarshal_failed": {CodeInternal, "数据解析失败: {cause}", "failed to parse stored data: {cause}"},
+	"data.marshal_failed":   {CodeInternal, "数据序列化失败: {cause}", "failed to serialize data: {cause}"},
+	"event.marshal_failed":  {CodeInternal, "事件序列化失败: {cause}", "failed to serialize event: {cause}"},
+	"event.emit_failed":     {CodeInternal, "设置链码事件失败: {cause}", "failed to set chaincode event: {cause}"},
+	"caller.id_failed":      {CodeInternal, "读取调用者身份失败: {cause}", "failed to read caller identity: {cause}"},
+	"caller.cert_failed":    {CodeInternal, "读取调用者证书失败: {cause}", "failed to read caller certificate: {cause}"},
+	"caller.role_failed":    {CodeInternal, "读取调用者角色失败: {cause}", "failed to read caller role: {cause}"},
+	"tx.time_failed":        {CodeInternal, "读取交易时间失败: {cause}", "failed to read transaction time: {cause}"},
+	"transient.read_failed": {CodeInternal, "读取瞬态数据失败: {cause}", "failed to read transient data: {cause}"},
+	"access_log.key_failed": {CodeInternal, "生成访问日志键失败: {cause}", "failed to build access log key: {cause}"},
+	"cipher.init_failed":    {CodeInternal, "创建加密器失败: {cause}", "failed to initialize cipher: {cause}"},
+}
+
+// Message 按语言生成错误信息，不支持的语言使用中文，未登记的键原样返回
+func Message(lang, key string, details map[string]string) string {
+	entry, ok := messages[key]
+	if !ok {
+		return key
+	}
+	text := entry.zh
+	if ParseLanguage(lang) == LangEN {
+		text = entry.en
+	}
+	for name, value := range details {
+		text = strings.ReplaceAll(text, "{"+name+"}", value)
+	}
+	return text
+}
+
+// MessageTemplate 返回键对应的错误码和中文信息模板，用于生成接口文档
+func MessageTemplate(key string) (ErrorCode, string, bool) {
+	entry, ok := messages[key]
+	return entry.code, entry.zh, ok
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+func TestParseLanguage(t *testing.T) {
+	tests := []struct{ value, want string }{
+		{"zh", LangZH},
+		{"EN", LangEN},
+		{"zh-CN", LangZH},
+		{"zh_TW", LangZH},
+		{"en-US,en;q=0.9,zh;q=0.8", LangEN},
+		{"fr-FR, zh-CN;q=0.8, en;q=0.5", LangZH},
+		{"fr", ""},
+		{"english", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ParseLanguage(tt.value); got != tt.want {
+			t.Errorf("ParseLanguage(%q) = %q，期望 %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMessageLocalization(t *testing.T) {
+	details := map[string]string{"userId": "user_001"}
+	tests := []struct{ lang, want string }{
+		{LangZH, "用户 user_001 的数据已擦除"},
+		{LangEN, "the data of user user_001 has been erased"},
+		{"en-GB", "the data of user user_001 has been erased"},
+		{"fr", "用户 user_001 的数据已擦除"},
+		{"", "用户 user_001 的数据已擦除"},
+	}
+	for _, tt := range tests {
+		if got := Message(tt.lang, "user_data.erased", details); got != tt.want {
+			t.Errorf("Message(%q) = %q，期望 %q", tt.lang, got, tt.want)
+		}
+	}
+
+	e := NewError("user_data.erased", "userId", "user_001").Localize(LangEN)
+	if e.Message != "the data of user user_001 has been erased" || e.Code != CodeConflict {
+		t.Errorf("英文错误 = %+v", e)
+	}
+	if parsed, ok := ParseError(e.Error()); !ok || parsed.Message != e.Message {
+		t.Errorf("解析英文错误 = %+v, %v", parsed, ok)
+	}
+}
+
+// 每条错误信息都有中英文，两种语言使用相同的参数
+func TestMessagesComplete(t *testing.T) {
+	placeholder := regexp.MustCompile(`\{[A-Za-z]+\}`)
+	params := func(text string) []string {
+		found := placeholder.FindAllString(text, -1)
+		sort.Strings(found)
+		return found
+	}
+	for key, entry := range messages {
+		if entry.code == "" || entry.zh == "" || entry.en == "" {
+			t.Errorf("%s 缺少错误码或信息: %+v", key, entry)
+			continue
+		}
+		if zh, en := params(entry.zh), params(entry.en); !reflect.DeepEqual(zh, en) {
+			t.Errorf("%s 的中英文参数不一致: %v / %v", key, zh, en)
+		}
+		if code, template, ok := MessageTemplate(key); !ok || code != entry.code || template != entry.zh {
+			t.Errorf("MessageTemplate(%s) = %s, %q, %v", key, code, template, ok)
+		}
+	}
+}
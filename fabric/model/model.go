@@ -0,0 +1,77 @@
+// Package model 定义链码与客户端共用的记录结构、校验规则和交互约定（授权、链码事件、瞬态数据等）
+//
+// 本目录是独立的 Go 模块，只依赖标准库；客户端（fabric/go.mod）和链码（fabric/chaincode/go.mod）
+// 分别通过 replace edu/model => ./model 和 replace edu/model => ../model 引用；
+// 前端和 Java 服务使用 schema/ 下生成的 JSON Schema。
+//
+// 字段规则写在 validate 标签中，Validate 和 JSON Schema 生成都从标签读取，保证两者一致：
+//
+//	required      不能为空
+//	max=N         最多 N 个字符（按 Unicode 字符计）
+//	enum=A|B|C    只能取列出的值
+//	range=1:5     min 到 max 之间的整数（字符串形式），用于取值较少的评分
+//	numeric       非负数字，如 98 或 87.5
+//	rfc3339       RFC3339 格式的时间，如 2024-01-02T15:04:05+08:00
+//	encrypted     可以是客户端加密的密文（EnvelopePrefix 开头），明文在加密前按 max 校验，
+//	              密文长度上限为 EncryptedMaxLen(max)
+//
+// 规则以外的字段可以为空；desc 标签为字段说明，写入生成的 JSON Schema。
+// 带 omitempty 的字段同时标注 metadata:",optional"，否则 contractapi 按元数据校验
+// 交易返回值时会要求该字段存在。
+package model
+
+// 文档类型，链码写入状态时设置，用于 CouchDB 查询分类
+const (
+	DocTypeEvaluation = "Evaluation"
+	DocTypeTestResult = "TestResult"
+	DocTypeJudgement  = "Judgement"
+)
+
+// Evaluation 测评记录
+type Evaluation struct {
+	DocType      string `json:"docType" desc:"文档类型标识，由链码设置"`
+	EvaluationID string `json:"Evaluation_ID" validate:"required,max=64" desc:"测评唯一ID"`
+	UserID       string `json:"User_ID" validate:"required,max=64" desc:"关联用户ID"`
+	PointsDegree string `json:"Points_Degree" validate:"required,enum=A+|A|A-|B+|B|B-|C+|C|C-|D|F" desc:"评分等级"`
+	Feedback     string `json:"Feedback" validate:"max=2000,encrypted" desc:"详细反馈，链上以学生密钥加密，可先由客户端加密"`
+	TeacherID    string `json:"Teacher_ID,omitempty" metadata:",optional" validate:"max=64" desc:"录入测评的教师ID，用于按教师汇总评价"`
+	ErasedAt     string `json:"Erased_At,omitempty" metadata:",optional" validate:"rfc3339" desc:"个人内容擦除时间，由链码设置"`
+}
+
+// TestResult 测试结果
+type TestResult struct {
+	DocType     string `json:"docType" desc:"文档类型标识，由链码设置"`
+	TestID      string `json:"Test_ID" validate:"required,max=64" desc:"测试唯一ID"`
+	UserID      string `json:"User_ID" validate:"required,max=64" desc:"关联用户ID"`
+	ScoreSum    string `json:"Score_Sum" validate:"numeric,max=16" desc:"总分"`
+	PaperNumber string `json:"Paper_Number" validate:"max=64" desc:"试卷编号"`
+	Answer      string `json:"Answer" validate:"max=20000" desc:"答案内容，链上以学生密钥加密"`
+	ErasedAt    string `json:"Erased_At,omitempty" metadata:",optional" validate:"rfc3339" desc:"个人内容擦除时间，由链码设置"`
+}
+
+// Judgement 评价记录
+type Judgement struct {
+	DocType            string `json:"docType" desc:"文档类型标识，由链码设置"`
+	JudgementID        string `json:"Judgement_ID" validate:"required,max=64" desc:"评价唯一ID"`
+	UserID             string `json:"User_ID" validate:"required,max=64" desc:"关联用户ID"`
+	JudgementObjection string `json:"Judgement_Objection" validate:"max=2000" desc:"异议内容"`
+	JudgementObjectID  string `json:"Judgement_ObjectID" validate:"max=64" desc:"关联对象ID"`
+	JudgementRating    string `json:"Judgement_Rating" validate:"required,range=1:5" desc:"评分（1-5）"`
+	JudgementContent   string `json:"Judgement_Content" validate:"max=2000,encrypted" desc:"评价内容，可由客户端加密"`
+	JudgementTime      string `json:"Judgement_Time" validate:"required,rfc3339" desc:"评价时间"`
+}
+
+// Validate 校验测评记录
+func (e Evaluation) Validate() error {
+	return Validate(e)
+}
+
+// Validate 校验测试结果
+func (t TestResult) Validate() error {
+	return Validate(t)
+}
+
+// Validate 校验评价记录
+func (j Judgement) Validate() error {
+	return Validate(j)
+}
@@ -0,0 +1,100 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+//go:generate go run ./cmd/schemagen -dir schema
+
+// ===================== JSON Schema =====================
+
+// schemaDialect 生成的文档遵循的 JSON Schema 版本
+const schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Records 共享的记录类型，按文档类型索引，用于生成 JSON Schema
+var Records = map[string]interface{}{
+	DocTypeEvaluation: Evaluation{},
+	DocTypeTestResult: TestResult{},
+	DocTypeJudgement:  Judgement{},
+}
+
+// Schema JSON Schema 文档，只包含本包规则用到的关键字
+type Schema struct {
+	Dialect     string             `json:"$schema,omitempty"`
+	ID          string             `json:"$id,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        string             `json:"type"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	MinLength   int                `json:"minLength,omitempty"`
+	MaxLength   int                `json:"maxLength,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Pattern     string             `json:"pattern,omitempty"`
+	Format      string             `json:"format,omitempty"`
+}
+
+// SchemaFor 根据 validate 标签生成记录的 JSON Schema，$id 为 <类型名>.schema.json
+// 规则只约束非空值，因此非必填字段的 Schema 允许空字符串：
+// enum 中加入空串，pattern 和 format 只在必填字段上直接使用
+func SchemaFor(record interface{}) (*Schema, error) {
+	t := reflect.Indirect(reflect.ValueOf(record)).Type()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("model: 不支持为 %T 生成 Schema", record)
+	}
+
+	schema := &Schema{
+		Dialect:    schemaDialect,
+		ID:         t.Name() + ".schema.json",
+		Title:      t.Name(),
+		Type:       "object",
+		Properties: make(map[string]*Schema),
+	}
+	for _, rule := range rulesFor(t) {
+		property := &Schema{Type: "string", Description: rule.description, MaxLength: rule.maxLen}
+		if rule.encrypted && rule.maxLen > 0 {
+			// 密文也要通过校验，明文长度只能在加密前检查
+			property.MaxLength = EncryptedMaxLen(rule.maxLen)
+			property.Description += fmt.Sprintf("（明文最多 %d 个字符，客户端加密后为 %s 开头的密文）", rule.maxLen, EnvelopePrefix)
+		}
+		if rule.required {
+			schema.Required = append(schema.Required, rule.name)
+			property.MinLength = 1
+		}
+
+		enum := rule.enum
+		if rule.hasRange {
+			for n := rule.rangeMin; n <= rule.rangeMax; n++ {
+				enum = append(enum, strconv.Itoa(n))
+			}
+		}
+		if enum != nil && !rule.required {
+			enum = append([]string{""}, enum...)
+		}
+		property.Enum = enum
+
+		if rule.numeric {
+			property.Pattern = numericPattern
+			if !rule.required {
+				property.Pattern = `^$|` + numericPattern
+			}
+		}
+		if rule.rfc3339 && rule.required {
+			property.Format = "date-time"
+		}
+		schema.Properties[rule.name] = property
+	}
+	return schema, nil
+}
+
+// MarshalSchema 生成格式化的 JSON Schema 文档
+func MarshalSchema(record interface{}) ([]byte, error) {
+	schema, err := SchemaFor(record)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
@@ -0,0 +1,387 @@
+// Package transcript 定义可离线验证的成绩证明包及其验证
+//
+// 证明包包含学生全部测评和测试记录，每条记录附带写入它的交易信封（含背书签名）和所在区块头，
+// 整体由学校组织身份签名。验证只需要标准库和学校的根CA证书，不需要连接 Fabric 网络：
+//
+//   - 签发者证书链到根CA，属于学校组织且带 registrar 或 admin 角色，证明包签名有效
+//   - 交易ID等于 SHA-256(nonce || creator)，与信封签名头一致
+//   - 背书签名有效且背书节点证书链到根CA，有效背书按背书者身份去重后计数
+//   - 背书结果的写集中包含记录的状态键，写入值与证明包中的记录一致，记录属于该学生
+//
+// 区块数据哈希覆盖区块内所有交易，证明包不包含其他学生的交易，因此无法据此核对；
+// 交易验证结果记在区块元数据中，不受任何签名保护，证明包因此不携带验证码。
+// 区块头只说明交易位置，交易已提交且验证通过由签发者担保：签发时只收录验证通过的交易。
+package transcript
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"edu/model"
+)
+
+// Version 证明包格式版本，版本 2 起不再携带交易验证码
+const Version = 2
+
+// Bundle 签名后的证明包，Payload 为 Transcript 的JSON
+// 签名覆盖压缩后的 Payload，文件被重新缩进不影响验证
+type Bundle struct {
+	Payload   json.RawMessage `json:"payload"`
+	Signature []byte          `json:"signature"` // 对 SHA-256(Payload) 的签名
+}
+
+// Transcript 学生成绩证明
+type Transcript struct {
+	Version   int       `json:"version"`
+	UserID    string    `json:"userId"`
+	Channel   string    `json:"channel"`
+	Chaincode string    `json:"chaincode"`
+	IssuedAt  time.Time `json:"issuedAt"`
+	Issuer    Issuer    `json:"issuer"`
+	Entries   []Entry   `json:"entries"`
+}
+
+// Issuer 签发证明包的学校组织身份
+type Issuer struct {
+	MSPID       string `json:"mspId"`
+	Certificate string `json:"certificate"` // PEM
+}
+
+// Entry 一条记录及写入它的交易
+type Entry struct {
+	DocType       string          `json:"docType"`
+	RecordID      string          `json:"recordId"`
+	Record        json.RawMessage `json:"record"` // 交易写入的状态值
+	TransactionID string          `json:"transactionId"`
+	BlockHeader   BlockHeader     `json:"blockHeader"`
+	Envelope      []byte          `json:"envelope"` // 交易信封（protobuf），含背书签名
+}
+
+// BlockHeader 交易所在区块的区块头
+type BlockHeader struct {
+	Number       uint64 `json:"number"`
+	PreviousHash []byte `json:"previousHash"`
+	DataHash     []byte `json:"dataHash"`
+}
+
+// Sign 序列化并签名，sign 对 SHA-256 摘要签名（与客户端的 Signer 一致）
+func Sign(t *Transcript, sign func(digest []byte) ([]byte, error)) (*Bundle, error) {
+	payload, err := json.Marshal(t)
+	if err != nil {
+		return nil, fmt.Errorf("序列化成绩证明失败: %v", err)
+	}
+	digest := sha256.Sum256(payload)
+	signature, err := sign(digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("签名失败: %v", err)
+	}
+	return &Bundle{Payload: payload, Signature: signature}, nil
+}
+
+// ===================== 验证 =====================
+
+// VerifyOptions 验证配置
+type VerifyOptions struct {
+	Roots           *x509.CertPool // 学校组织的根CA
+	Intermediates   *x509.CertPool // 中间CA，可为空
+	IssuerMSPID     string         // 签发者所属组织，默认 model.InstitutionMSPID
+	MinEndorsements int            // 每笔交易至少需要的不同背书者数，默认 1
+}
+
+// issuerRoles 可以签发成绩证明的角色
+var issuerRoles = []string{model.RoleRegistrar, model.RoleAdmin}
+
+// EntryResult 单条记录的验证结果
+type EntryResult struct {
+	DocType       string
+	RecordID      string
+	TransactionID string
+	BlockNumber   uint64
+	Timestamp     time.Time
+	Endorsers     []string // 有效背书的 MSP ID/证书CN，同一背书者只列一次
+	Err           error
+}
+
+// Verify 验证证明包签名和每条记录，签名无效时返回错误，记录的问题记在各自的结果中
+func Verify(bundle *Bundle, options VerifyOptions) (*Transcript, []EntryResult, error) {
+	if options.Roots == nil {
+		return nil, nil, fmt.Errorf("缺少根CA证书")
+	}
+	if options.MinEndorsements < 1 {
+		options.MinEndorsements = 1
+	}
+	if options.IssuerMSPID == "" {
+		options.IssuerMSPID = model.InstitutionMSPID
+	}
+
+	var payload bytes.Buffer
+	if err := json.Compact(&payload, bundle.Payload); err != nil {
+		return nil, nil, fmt.Errorf("解析证明包失败: %v", err)
+	}
+	var t Transcript
+	if err := json.Unmarshal(payload.Bytes(), &t); err != nil {
+		return nil, nil, fmt.Errorf("解析证明包失败: %v", err)
+	}
+	if t.Version != Version {
+		return nil, nil, fmt.Errorf("不支持的证明包版本 %d", t.Version)
+	}
+
+	issuer, err := parseCertificate([]byte(t.Issuer.Certificate))
+	if err != nil {
+		return nil, nil, fmt.Errorf("签发者证书无效: %v", err)
+	}
+	if err := verifyChain(issuer, t.IssuedAt, options); err != nil {
+		return nil, nil, fmt.Errorf("签发者证书不受信任: %v", err)
+	}
+	// 根CA只说明证书由学校CA签发，学生和教师的证书同样链到根CA，因此还要核对组织和角色
+	if t.Issuer.MSPID != options.IssuerMSPID {
+		return nil, nil, fmt.Errorf("签发者属于组织 %s，不是 %s", t.Issuer.MSPID, options.IssuerMSPID)
+	}
+	if role := model.CertRole(issuer); !hasRole(issuerRoles, role) {
+		return nil, nil, fmt.Errorf("签发者 %s 的角色 %q 无权签发成绩证明", issuer.Subject.CommonName, role)
+	}
+	digest := sha256.Sum256(payload.Bytes())
+	if err := verifyDigest(issuer, digest[:], bundle.Signature); err != nil {
+		return nil, nil, fmt.Errorf("证明包签名无效: %v", err)
+	}
+
+	results := make([]EntryResult, len(t.Entries))
+	for i, entry := range t.Entries {
+		results[i] = verifyEntry(&t, entry, options)
+	}
+	return &t, results, nil
+}
+
+// verifyEntry 验证交易ID、背书签名和写集
+func verifyEntry(t *Transcript, entry Entry, options VerifyOptions) EntryResult {
+	result := EntryResult{
+		DocType:       entry.DocType,
+		RecordID:      entry.RecordID,
+		TransactionID: entry.TransactionID,
+		BlockNumber:   entry.BlockHeader.Number,
+	}
+	fail := func(format string, args ...interface{}) EntryResult {
+		result.Err = fmt.Errorf(format, args...)
+		return result
+	}
+
+	var owner struct {
+		UserID string `json:"User_ID"`
+	}
+	if err := json.Unmarshal(entry.Record, &owner); err != nil {
+		return fail("记录格式错误: %v", err)
+	}
+	if owner.UserID != t.UserID {
+		return fail("记录属于用户 %s，不属于 %s", owner.UserID, t.UserID)
+	}
+
+	// 信封头：通道、交易ID、时间
+	payload, err := parsePath(entry.Envelope, 1)
+	if err != nil {
+		return fail("解析交易信封失败: %v", err)
+	}
+	header, err := parseMessage(payload.bytes(1))
+	if err != nil {
+		return fail("解析交易头失败: %v", err)
+	}
+	channelHeader, err := parseMessage(header.bytes(1))
+	if err != nil {
+		return fail("解析通道头失败: %v", err)
+	}
+	signatureHeader, err := parseMessage(header.bytes(2))
+	if err != nil {
+		return fail("解析签名头失败: %v", err)
+	}
+	if channel := string(channelHeader.bytes(4)); channel != t.Channel {
+		return fail("交易属于通道 %s", channel)
+	}
+	txID := string(channelHeader.bytes(5))
+	computed := sha256.Sum256(append(append([]byte{}, signatureHeader.bytes(2)...), signatureHeader.bytes(1)...))
+	if txID != entry.TransactionID || hex.EncodeToString(computed[:]) != txID {
+		return fail("交易ID与交易信封不一致")
+	}
+	if timestamp, err := parseMessage(channelHeader.bytes(3)); err == nil {
+		result.Timestamp = time.Unix(int64(timestamp.varint(1)), int64(timestamp.varint(2))).UTC()
+	}
+
+	// 背书结果和背书签名
+	transaction, err := parseMessage(payload.bytes(2))
+	if err != nil {
+		return fail("解析交易内容失败: %v", err)
+	}
+	key := entry.DocType + "-" + entry.RecordID
+	var written bool
+	endorsers := make(map[string]bool) // 背书者证书 -> 已计数
+	for _, action := range transaction.repeated(1) {
+		endorsed, err := parsePath(action, 2, 2)
+		if err != nil {
+			return fail("解析背书结果失败: %v", err)
+		}
+		responsePayload := endorsed.bytes(1)
+
+		value, found, err := findWrite(responsePayload, t.Chaincode, key)
+		if err != nil {
+			return fail("解析写集失败: %v", err)
+		}
+		if !found {
+			continue
+		}
+		if !jsonEqual(value, entry.Record) {
+			return fail("写集中的值与记录不一致")
+		}
+		written = true
+
+		for _, endorsement := range endorsed.repeated(2) {
+			endorser, identity, err := verifyEndorsement(responsePayload, endorsement, result.Timestamp, options)
+			if err != nil {
+				return fail("背书签名无效: %v", err)
+			}
+			// 同一背书者的多份背书不能凑足背书数
+			if endorsers[identity] {
+				continue
+			}
+			endorsers[identity] = true
+			result.Endorsers = append(result.Endorsers, endorser)
+		}
+	}
+	if !written {
+		return fail("交易没有写入 %s", key)
+	}
+	if len(result.Endorsers) < options.MinEndorsements {
+		return fail("有效背书来自 %d 个不同背书者，少于要求的 %d 个", len(result.Endorsers), options.MinEndorsements)
+	}
+	return result
+}
+
+// findWrite 在背书结果中查找链码命名空间下对 key 的写入，删除视为未写入
+func findWrite(responsePayload []byte, chaincode, key string) ([]byte, bool, error) {
+	action, err := parsePath(responsePayload, 2)
+	if err != nil {
+		return nil, false, err
+	}
+	rwset, err := parseMessage(action.bytes(1))
+	if err != nil {
+		return nil, false, err
+	}
+	for _, ns := range rwset.repeated(2) {
+		nsRWSet, err := parseMessage(ns)
+		if err != nil {
+			return nil, false, err
+		}
+		if string(nsRWSet.bytes(1)) != chaincode {
+			continue
+		}
+		kvRWSet, err := parseMessage(nsRWSet.bytes(2))
+		if err != nil {
+			return nil, false, err
+		}
+		for _, w := range kvRWSet.repeated(3) {
+			write, err := parseMessage(w)
+			if err != nil {
+				return nil, false, err
+			}
+			if string(write.bytes(1)) == key && write.varint(2) == 0 {
+				return write.bytes(3), true, nil
+			}
+		}
+	}
+	return nil, false, nil
+}
+
+// verifyEndorsement 背书签名覆盖 ProposalResponsePayload || endorser，
+// 返回背书者 MSP ID/证书CN 和用于去重的身份（MSP ID 与证书DER）
+func verifyEndorsement(responsePayload, endorsement []byte, at time.Time, options VerifyOptions) (name, identity string, err error) {
+	e, err := parseMessage(endorsement)
+	if err != nil {
+		return "", "", err
+	}
+	endorser := e.bytes(1)
+	serialized, err := parseMessage(endorser)
+	if err != nil {
+		return "", "", err
+	}
+	mspID := string(serialized.bytes(1))
+	cert, err := parseCertificate(serialized.bytes(2))
+	if err != nil {
+		return "", "", fmt.Errorf("背书者 %s 证书无效: %v", mspID, err)
+	}
+	if err := verifyChain(cert, at, options); err != nil {
+		return "", "", fmt.Errorf("背书者 %s/%s 不受信任: %v", mspID, cert.Subject.CommonName, err)
+	}
+	message := append(append([]byte{}, responsePayload...), endorser...)
+	if err := verifyMessage(cert, message, e.bytes(2)); err != nil {
+		return "", "", fmt.Errorf("背书者 %s/%s: %v", mspID, cert.Subject.CommonName, err)
+	}
+	// 同一证书的PEM编码可能不同，按证书DER去重
+	return mspID + "/" + cert.Subject.CommonName, mspID + "\x00" + string(cert.Raw), nil
+}
+
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCertificate(certPEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, fmt.Errorf("不是PEM格式证书")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// verifyChain 按签名时间验证证书链，证书可能在签发后已过期
+func verifyChain(cert *x509.Certificate, at time.Time, options VerifyOptions) error {
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:         options.Roots,
+		Intermediates: options.Intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
+
+// verifyMessage 验证 Fabric 签名：ECDSA 对消息的 SHA-256 签名，Ed25519 直接对消息签名
+func verifyMessage(cert *x509.Certificate, message, signature []byte) error {
+	if _, ok := cert.PublicKey.(ed25519.PublicKey); ok {
+		return verifyDigest(cert, message, signature)
+	}
+	digest := sha256.Sum256(message)
+	return verifyDigest(cert, digest[:], signature)
+}
+
+func verifyDigest(cert *x509.Certificate, digest, signature []byte) error {
+	switch key := cert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		if ecdsa.VerifyASN1(key, digest, signature) {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if ed25519.Verify(key, digest, signature) {
+			return nil
+		}
+	default:
+		return fmt.Errorf("不支持的公钥类型 %T", cert.PublicKey)
+	}
+	return fmt.Errorf("签名不匹配")
+}
+
+// jsonEqual 忽略空白比较两段JSON
+func jsonEqual(a, b []byte) bool {
+	var ca, cb bytes.Buffer
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return false
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
@@ -0,0 +1,320 @@
+package transcript
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"edu/model"
+)
+
+// testCA 测试用的根CA
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+// testIdentity 根CA签发的身份
+type testIdentity struct {
+	mspID   string
+	certPEM []byte
+	key     *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca.org1.example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// issue 签发身份，role 为空时证书不带角色属性
+func (ca *testCA) issue(t *testing.T, mspID, commonName, role string) *testIdentity {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if role != "" {
+		template.ExtraExtensions = []pkix.Extension{
+			{Id: model.AttrsExtensionOID, Value: []byte(`{"attrs":{"` + model.RoleAttribute + `":"` + role + `"}}`)},
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testIdentity{mspID: mspID, certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), key: key}
+}
+
+func (id *testIdentity) serialized() []byte {
+	return pbMessage(pbBytes(1, []byte(id.mspID)), pbBytes(2, id.certPEM))
+}
+
+func (id *testIdentity) sign(t *testing.T, digest []byte) []byte {
+	t.Helper()
+	signature, err := ecdsa.SignASN1(rand.Reader, id.key, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signature
+}
+
+// testEntry 构造写入 record 的交易信封，每个背书者对背书结果签名一次
+func testEntry(t *testing.T, creator *testIdentity, docType, recordID string, record []byte, endorsers ...*testIdentity) Entry {
+	t.Helper()
+	nonce := make([]byte, 24)
+	rand.Read(nonce)
+	creatorBytes := creator.serialized()
+	txHash := sha256.Sum256(append(append([]byte{}, nonce...), creatorBytes...))
+	txID := hex.EncodeToString(txHash[:])
+
+	now := time.Now()
+	channelHeader := pbMessage(
+		pbVarint(1, 3),
+		pbBytes(3, pbMessage(pbVarint(1, uint64(now.Unix())), pbVarint(2, uint64(now.Nanosecond())))),
+		pbBytes(4, []byte("mychannel")),
+		pbBytes(5, []byte(txID)),
+	)
+	header := pbMessage(pbBytes(1, channelHeader), pbBytes(2, pbMessage(pbBytes(1, creatorBytes), pbBytes(2, nonce))))
+
+	write := pbMessage(pbBytes(1, []byte(docType+"-"+recordID)), pbBytes(3, record))
+	rwset := pbMessage(pbBytes(2, pbMessage(pbBytes(1, []byte("basic")), pbBytes(2, pbMessage(pbBytes(3, write))))))
+	responsePayload := pbMessage(pbBytes(1, []byte("proposal-hash")), pbBytes(2, pbMessage(pbBytes(1, rwset))))
+
+	endorsed := pbBytes(1, responsePayload)
+	for _, e := range endorsers {
+		digest := sha256.Sum256(append(append([]byte{}, responsePayload...), e.serialized()...))
+		endorsed = append(endorsed, pbBytes(2, pbMessage(pbBytes(1, e.serialized()), pbBytes(2, e.sign(t, digest[:]))))...)
+	}
+	action := pbBytes(2, pbMessage(pbBytes(2, endorsed)))
+	transaction := pbBytes(1, action)
+	payload := pbMessage(pbBytes(1, header), pbBytes(2, transaction))
+
+	return Entry{
+		DocType:       docType,
+		RecordID:      recordID,
+		Record:        record,
+		TransactionID: txID,
+		BlockHeader:   BlockHeader{Number: 7},
+		Envelope:      pbMessage(pbBytes(1, payload)),
+	}
+}
+
+// testTranscript 学生 user_001 的证明，包含一条由 endorsers 背书的测评记录
+func testTranscript(t *testing.T, issuer *testIdentity, endorsers ...*testIdentity) *Transcript {
+	t.Helper()
+	record := []byte(`{"docType":"Evaluation","Evaluation_ID":"eval_001","User_ID":"user_001","Points_Degree":"A"}`)
+	return &Transcript{
+		Version:   Version,
+		UserID:    "user_001",
+		Channel:   "mychannel",
+		Chaincode: "basic",
+		IssuedAt:  time.Now().UTC(),
+		Issuer:    Issuer{MSPID: issuer.mspID, Certificate: string(issuer.certPEM)},
+		Entries:   []Entry{testEntry(t, issuer, "Evaluation", "eval_001", record, endorsers...)},
+	}
+}
+
+func signTranscript(t *testing.T, tr *Transcript, issuer *testIdentity) *Bundle {
+	t.Helper()
+	bundle, err := Sign(tr, func(digest []byte) ([]byte, error) { return issuer.sign(t, digest), nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bundle
+}
+
+func TestVerify(t *testing.T) {
+	ca := newTestCA(t)
+	registrar := ca.issue(t, model.InstitutionMSPID, "registrar", model.RoleRegistrar)
+	peer0 := ca.issue(t, model.InstitutionMSPID, "peer0.org1.example.com", "")
+	peer1 := ca.issue(t, model.InstitutionMSPID, "peer1.org1.example.com", "")
+
+	tests := []struct {
+		name      string
+		bundle    func() *Bundle
+		options   VerifyOptions
+		wantErr   string // 证明包级别的错误
+		entryErr  string // 记录级别的错误
+		endorsers int
+	}{
+		{"有效证明", func() *Bundle {
+			return signTranscript(t, testTranscript(t, registrar, peer0), registrar)
+		}, VerifyOptions{}, "", "", 1},
+		{"管理员签发", func() *Bundle {
+			admin := ca.issue(t, model.InstitutionMSPID, "admin", model.RoleAdmin)
+			return signTranscript(t, testTranscript(t, admin, peer0), admin)
+		}, VerifyOptions{}, "", "", 1},
+		{"重新缩进不影响签名", func() *Bundle {
+			bundle := signTranscript(t, testTranscript(t, registrar, peer0), registrar)
+			var indented bytes.Buffer
+			json.Indent(&indented, bundle.Payload, "", "  ")
+			bundle.Payload = indented.Bytes()
+			return bundle
+		}, VerifyOptions{}, "", "", 1},
+
+		// 篡改
+		{"签名后修改证明包", func() *Bundle {
+			bundle := signTranscript(t, testTranscript(t, registrar, peer0), registrar)
+			tampered := bytes.Replace(bundle.Payload, []byte(`"Points_Degree":"A"`), []byte(`"Points_Degree":"B"`), 1)
+			if bytes.Equal(tampered, bundle.Payload) {
+				t.Fatal("没有找到要修改的字段")
+			}
+			bundle.Payload = tampered
+			return bundle
+		}, VerifyOptions{}, "证明包签名无效", "", 0},
+		{"记录与写集不一致", func() *Bundle {
+			tr := testTranscript(t, registrar, peer0)
+			tr.Entries[0].Record = []byte(`{"docType":"Evaluation","Evaluation_ID":"eval_001","User_ID":"user_001","Points_Degree":"B"}`)
+			return signTranscript(t, tr, registrar)
+		}, VerifyOptions{}, "", "写集中的值与记录不一致", 0},
+		{"交易ID不一致", func() *Bundle {
+			tr := testTranscript(t, registrar, peer0)
+			tr.Entries[0].TransactionID = strings.Repeat("0", 64)
+			return signTranscript(t, tr, registrar)
+		}, VerifyOptions{}, "", "交易ID与交易信封不一致", 0},
+		{"记录不属于学生", func() *Bundle {
+			tr := testTranscript(t, registrar, peer0)
+			tr.UserID = "user_002"
+			return signTranscript(t, tr, registrar)
+		}, VerifyOptions{}, "", "不属于 user_002", 0},
+		{"通道不一致", func() *Bundle {
+			tr := testTranscript(t, registrar, peer0)
+			tr.Channel = "otherchannel"
+			return signTranscript(t, tr, registrar)
+		}, VerifyOptions{}, "", "交易属于通道 mychannel", 0},
+		{"旧版本证明包", func() *Bundle {
+			tr := testTranscript(t, registrar, peer0)
+			tr.Version = 1
+			return signTranscript(t, tr, registrar)
+		}, VerifyOptions{}, "不支持的证明包版本 1", "", 0},
+
+		// 签发者
+		{"学生签发", func() *Bundle {
+			student := ca.issue(t, model.InstitutionMSPID, "user_001", model.RoleStudent)
+			return signTranscript(t, testTranscript(t, student, peer0), student)
+		}, VerifyOptions{}, "无权签发成绩证明", "", 0},
+		{"教师签发", func() *Bundle {
+			teacher := ca.issue(t, model.InstitutionMSPID, "teacher_001", model.RoleTeacher)
+			return signTranscript(t, testTranscript(t, teacher, peer0), teacher)
+		}, VerifyOptions{}, "无权签发成绩证明", "", 0},
+		{"签发者不带角色", func() *Bundle {
+			service := ca.issue(t, model.InstitutionMSPID, "service", "")
+			return signTranscript(t, testTranscript(t, service, peer0), service)
+		}, VerifyOptions{}, "无权签发成绩证明", "", 0},
+		{"签发者声称属于其他组织", func() *Bundle {
+			other := ca.issue(t, "Org2MSP", "registrar", model.RoleRegistrar)
+			return signTranscript(t, testTranscript(t, other, peer0), other)
+		}, VerifyOptions{}, "签发者属于组织 Org2MSP", "", 0},
+		{"要求其他组织签发", func() *Bundle {
+			return signTranscript(t, testTranscript(t, registrar, peer0), registrar)
+		}, VerifyOptions{IssuerMSPID: "Org2MSP"}, "不是 Org2MSP", "", 0},
+		{"签发者证书来自其他CA", func() *Bundle {
+			forged := newTestCA(t).issue(t, model.InstitutionMSPID, "registrar", model.RoleRegistrar)
+			return signTranscript(t, testTranscript(t, forged, peer0), forged)
+		}, VerifyOptions{}, "签发者证书不受信任", "", 0},
+		{"签名与签发者证书不符", func() *Bundle {
+			return signTranscript(t, testTranscript(t, registrar, peer0), ca.issue(t, model.InstitutionMSPID, "registrar", model.RoleRegistrar))
+		}, VerifyOptions{}, "证明包签名无效", "", 0},
+
+		// 背书
+		{"没有背书", func() *Bundle {
+			return signTranscript(t, testTranscript(t, registrar), registrar)
+		}, VerifyOptions{}, "", "有效背书来自 0 个不同背书者", 0},
+		{"重复背书不能凑足背书数", func() *Bundle {
+			return signTranscript(t, testTranscript(t, registrar, peer0, peer0), registrar)
+		}, VerifyOptions{MinEndorsements: 2}, "", "有效背书来自 1 个不同背书者，少于要求的 2 个", 0},
+		{"重复背书只列一次", func() *Bundle {
+			return signTranscript(t, testTranscript(t, registrar, peer0, peer0), registrar)
+		}, VerifyOptions{}, "", "", 1},
+		{"两个不同背书者", func() *Bundle {
+			return signTranscript(t, testTranscript(t, registrar, peer0, peer1, peer0), registrar)
+		}, VerifyOptions{MinEndorsements: 2}, "", "", 2},
+		{"背书者来自其他CA", func() *Bundle {
+			forged := newTestCA(t).issue(t, model.InstitutionMSPID, "peer0.org1.example.com", "")
+			return signTranscript(t, testTranscript(t, registrar, forged), registrar)
+		}, VerifyOptions{}, "", "不受信任", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := tt.options
+			options.Roots = ca.pool
+			_, results, err := Verify(tt.bundle(), options)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Verify = %v，期望包含 %q 的错误", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify = %v", err)
+			}
+			if len(results) != 1 {
+				t.Fatalf("结果 %d 条，期望 1 条", len(results))
+			}
+			r := results[0]
+			if tt.entryErr != "" {
+				if r.Err == nil || !strings.Contains(r.Err.Error(), tt.entryErr) {
+					t.Fatalf("记录验证 = %v，期望包含 %q 的错误", r.Err, tt.entryErr)
+				}
+				return
+			}
+			if r.Err != nil {
+				t.Fatalf("记录验证 = %v", r.Err)
+			}
+			if len(r.Endorsers) != tt.endorsers {
+				t.Errorf("背书者 = %v，期望 %d 个", r.Endorsers, tt.endorsers)
+			}
+			if r.Timestamp.IsZero() || r.BlockNumber != 7 {
+				t.Errorf("交易时间 = %v，区块 = %d", r.Timestamp, r.BlockNumber)
+			}
+		})
+	}
+}
+
+func TestVerifyRequiresRoots(t *testing.T) {
+	if _, _, err := Verify(&Bundle{}, VerifyOptions{}); err == nil {
+		t.Error("缺少根CA时期望返回错误")
+	}
+}
@@ -0,0 +1,129 @@
+package transcript
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ===================== protobuf 解析 =====================
+
+// 验证器不依赖 Fabric 的 protobuf 定义，只按字段号读取需要的字段。
+// 字段号来自 fabric-protos：
+//
+//	Envelope                { 1 payload, 2 signature }
+//	Payload                 { 1 header, 2 data }
+//	Header                  { 1 channel_header, 2 signature_header }
+//	ChannelHeader           { 1 type, 3 timestamp, 4 channel_id, 5 tx_id }
+//	SignatureHeader         { 1 creator, 2 nonce }
+//	SerializedIdentity      { 1 mspid, 2 id_bytes }
+//	Transaction             { 1 actions }
+//	TransactionAction       { 1 header, 2 payload }
+//	ChaincodeActionPayload  { 1 chaincode_proposal_payload, 2 action }
+//	ChaincodeEndorsedAction { 1 proposal_response_payload, 2 endorsements }
+//	Endorsement             { 1 endorser, 2 signature }
+//	ProposalResponsePayload { 1 proposal_hash, 2 extension }
+//	ChaincodeAction         { 1 results }
+//	TxReadWriteSet          { 2 ns_rwset }
+//	NsReadWriteSet          { 1 namespace, 2 rwset }
+//	KVRWSet                 { 3 writes }
+//	KVWrite                 { 1 key, 2 is_delete, 3 value }
+
+// wire 类型
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("protobuf 数据不完整")
+
+// message 解析后的消息，字段号 -> 按出现顺序的值
+type message map[uint64][]field
+
+type field struct {
+	varint uint64
+	bytes  []byte
+}
+
+// parseMessage 解析一层 protobuf 消息，嵌套消息保持为字节
+func parseMessage(b []byte) (message, error) {
+	m := make(message)
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errTruncated
+		}
+		b = b[n:]
+		num, typ := key>>3, key&7
+
+		var f field
+		switch typ {
+		case wireVarint:
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				return nil, errTruncated
+			}
+			f.varint, b = v, b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return nil, errTruncated
+			}
+			f.varint, b = binary.LittleEndian.Uint64(b), b[8:]
+		case wireBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < length {
+				return nil, errTruncated
+			}
+			f.bytes, b = b[n:n+int(length)], b[n+int(length):]
+		case wireFixed32:
+			if len(b) < 4 {
+				return nil, errTruncated
+			}
+			f.varint, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		default:
+			return nil, fmt.Errorf("不支持的 protobuf 字段类型 %d", typ)
+		}
+		m[num] = append(m[num], f)
+	}
+	return m, nil
+}
+
+// bytes 返回字段的最后一个值（与 protobuf 合并规则一致），不存在时为 nil
+func (m message) bytes(num uint64) []byte {
+	values := m[num]
+	if len(values) == 0 {
+		return nil
+	}
+	return values[len(values)-1].bytes
+}
+
+func (m message) varint(num uint64) uint64 {
+	values := m[num]
+	if len(values) == 0 {
+		return 0
+	}
+	return values[len(values)-1].varint
+}
+
+// repeated 返回重复字段的全部值
+func (m message) repeated(num uint64) [][]byte {
+	values := make([][]byte, len(m[num]))
+	for i, f := range m[num] {
+		values[i] = f.bytes
+	}
+	return values
+}
+
+// parsePath 沿字段号逐层解析嵌套消息
+func parsePath(b []byte, nums ...uint64) (message, error) {
+	m, err := parseMessage(b)
+	for _, num := range nums {
+		if err != nil {
+			return nil, err
+		}
+		m, err = parseMessage(m.bytes(num))
+	}
+	return m, err
+}
@@ -0,0 +1,101 @@
+package transcript
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// 测试用的 protobuf 编码，只支持 varint 和 bytes 字段
+
+func pbVarint(num, v uint64) []byte {
+	b := binary.AppendUvarint(nil, num<<3|wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+func pbBytes(num uint64, v []byte) []byte {
+	b := binary.AppendUvarint(nil, num<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func pbMessage(fields ...[]byte) []byte {
+	var b []byte
+	for _, f := range fields {
+		b = append(b, f...)
+	}
+	return b
+}
+
+func TestParseMessage(t *testing.T) {
+	fixed64 := binary.LittleEndian.AppendUint64(binary.AppendUvarint(nil, 4<<3|wireFixed64), 1<<40)
+	fixed32 := binary.LittleEndian.AppendUint32(binary.AppendUvarint(nil, 5<<3|wireFixed32), 7)
+	m, err := parseMessage(pbMessage(
+		pbVarint(1, 300),
+		pbBytes(2, []byte("a")),
+		pbBytes(2, []byte("b")),
+		pbBytes(3, nil),
+		fixed64,
+		fixed32,
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.varint(1); got != 300 {
+		t.Errorf("varint(1) = %d，期望 300", got)
+	}
+	if got := string(m.bytes(2)); got != "b" {
+		t.Errorf("bytes(2) = %q，重复出现时期望取最后一个值 b", got)
+	}
+	if got := m.repeated(2); !reflect.DeepEqual(got, [][]byte{[]byte("a"), []byte("b")}) {
+		t.Errorf("repeated(2) = %q", got)
+	}
+	if got := m.bytes(3); len(got) != 0 {
+		t.Errorf("空字段 bytes(3) = %q", got)
+	}
+	if m.varint(4) != 1<<40 || m.varint(5) != 7 {
+		t.Errorf("定长字段 = %d, %d", m.varint(4), m.varint(5))
+	}
+	if m.bytes(9) != nil || m.varint(9) != 0 || len(m.repeated(9)) != 0 {
+		t.Error("不存在的字段应返回零值")
+	}
+}
+
+func TestParseMessageErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"字段键不完整", []byte{0x80}},
+		{"varint不完整", []byte{1 << 3, 0x80}},
+		{"长度超出数据", append(binary.AppendUvarint(nil, 1<<3|wireBytes), 5, 'a')},
+		{"fixed64不完整", append(binary.AppendUvarint(nil, 1<<3|wireFixed64), 1, 2, 3)},
+		{"fixed32不完整", append(binary.AppendUvarint(nil, 1<<3|wireFixed32), 1)},
+		{"不支持的字段类型", binary.AppendUvarint(nil, 1<<3|3)},
+	}
+	for _, tt := range tests {
+		if _, err := parseMessage(tt.data); err == nil {
+			t.Errorf("%s: 期望解析失败", tt.name)
+		}
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	inner := pbMessage(pbBytes(1, []byte("value")))
+	outer := pbMessage(pbBytes(2, pbMessage(pbBytes(3, inner))))
+
+	m, err := parsePath(outer, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(m.bytes(1)); got != "value" {
+		t.Errorf("bytes(1) = %q，期望 value", got)
+	}
+	// 路径上的字段不存在时得到空消息
+	if m, err := parsePath(outer, 5); err != nil || len(m) != 0 {
+		t.Errorf("parsePath(5) = %v, %v，期望空消息", m, err)
+	}
+	if _, err := parsePath(pbBytes(2, []byte{0x80}), 2); err == nil {
+		t.Error("嵌套消息损坏时期望返回错误")
+	}
+}
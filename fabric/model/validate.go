@@ -0,0 +1,209 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ===================== 字段规则 =====================
+
+// EnvelopePrefix 客户端信封加密的密文前缀
+const EnvelopePrefix = "env:v1:"
+
+// EncryptedMaxLen 明文最多 max 个字符的字段加密后的密文长度上限
+// 每个字符按 UTF-8 最多 4 字节，base64 膨胀 4/3，另留密钥范围和封装密钥的长度
+func EncryptedMaxLen(max int) int {
+	return 6*max + 512
+}
+
+// numericPattern 非负数字，同时用作 JSON Schema 的 pattern
+const numericPattern = `^[0-9]+(\.[0-9]+)?$`
+
+var numericRegexp = regexp.MustCompile(numericPattern)
+
+// fieldRules 单个字段的规则，由 validate 标签解析得到
+type fieldRules struct {
+	index       int    // 结构体中的字段序号
+	name        string // JSON 字段名
+	description string
+
+	required  bool
+	maxLen    int // 0 表示不限
+	enum      []string
+	rangeMin  int
+	rangeMax  int
+	hasRange  bool
+	numeric   bool
+	rfc3339   bool
+	encrypted bool
+}
+
+// typeRules 缓存已解析的结构体规则，标签错误属于编程错误，解析时直接 panic
+var typeRules sync.Map // reflect.Type -> []fieldRules
+
+func rulesFor(t reflect.Type) []fieldRules {
+	if cached, ok := typeRules.Load(t); ok {
+		return cached.([]fieldRules)
+	}
+	var rules []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		rule, err := parseRules(field.Tag.Get("validate"))
+		if err != nil {
+			panic(fmt.Sprintf("model: %s.%s 的 validate 标签错误: %v", t.Name(), field.Name, err))
+		}
+		rule.index, rule.name, rule.description = i, name, field.Tag.Get("desc")
+		rules = append(rules, rule)
+	}
+	typeRules.Store(t, rules)
+	return rules
+}
+
+func parseRules(tag string) (fieldRules, error) {
+	var rule fieldRules
+	if tag == "" {
+		return rule, nil
+	}
+	for _, item := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(item, "=")
+		switch key {
+		case "required":
+			rule.required = true
+		case "max":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return rule, fmt.Errorf("max 必须为正整数: %q", value)
+			}
+			rule.maxLen = n
+		case "enum":
+			if value == "" {
+				return rule, fmt.Errorf("enum 不能为空")
+			}
+			rule.enum = strings.Split(value, "|")
+		case "range":
+			low, high, ok := strings.Cut(value, ":")
+			lo, err1 := strconv.Atoi(low)
+			hi, err2 := strconv.Atoi(high)
+			if !ok || err1 != nil || err2 != nil || lo > hi {
+				return rule, fmt.Errorf("range 格式应为 min:max: %q", value)
+			}
+			rule.rangeMin, rule.rangeMax, rule.hasRange = lo, hi, true
+		case "numeric":
+			rule.numeric = true
+		case "rfc3339":
+			rule.rfc3339 = true
+		case "encrypted":
+			rule.encrypted = true
+		default:
+			return rule, fmt.Errorf("未知规则 %q", key)
+		}
+	}
+	return rule, nil
+}
+
+// ===================== 校验 =====================
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"` // JSON 字段名
+	Rule    string `json:"rule"`  // 未通过的规则，如 required / max
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// ValidationError 记录校验失败的全部字段
+type ValidationError struct {
+	Type   string       `json:"type"`
+	Fields []FieldError `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validate 按 validate 标签校验记录，record 为结构体或结构体指针
+// 全部字段都会检查，未通过时返回 *ValidationError
+func Validate(record interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(record))
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("model: 不支持校验 %T", record)
+	}
+
+	var fields []FieldError
+	for _, rule := range rulesFor(value.Type()) {
+		if err := rule.check(value.Field(rule.index).String()); err != nil {
+			fields = append(fields, *err)
+		}
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Type: value.Type().Name(), Fields: fields}
+	}
+	return nil
+}
+
+// check 空值只检查 required，其余规则只约束非空值
+func (r fieldRules) check(value string) *FieldError {
+	fail := func(rule, format string, args ...interface{}) *FieldError {
+		return &FieldError{Field: r.name, Rule: rule, Message: r.name + " " + fmt.Sprintf(format, args...)}
+	}
+
+	if value == "" {
+		if r.required {
+			return fail("required", "不能为空")
+		}
+		return nil
+	}
+	if r.encrypted && strings.HasPrefix(value, EnvelopePrefix) {
+		if r.maxLen > 0 && len(value) > EncryptedMaxLen(r.maxLen) {
+			return fail("max", "密文长度不能超过 %d 个字符", EncryptedMaxLen(r.maxLen))
+		}
+		return nil
+	}
+	if r.maxLen > 0 && utf8.RuneCountInString(value) > r.maxLen {
+		return fail("max", "长度不能超过 %d 个字符", r.maxLen)
+	}
+	if r.enum != nil && !contains(r.enum, value) {
+		return fail("enum", "必须是 %s 之一", strings.Join(r.enum, "/"))
+	}
+	if r.hasRange {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < r.rangeMin || n > r.rangeMax {
+			return fail("range", "必须是 %d 到 %d 之间的整数", r.rangeMin, r.rangeMax)
+		}
+	}
+	if r.numeric && !numericRegexp.MatchString(value) {
+		return fail("numeric", "必须是非负数字")
+	}
+	if r.rfc3339 {
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return fail("rfc3339", "必须是 RFC3339 格式的时间，如 2024-01-02T15:04:05+08:00")
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package model
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func validEvaluation() Evaluation {
+	return Evaluation{EvaluationID: "eval_001", UserID: "user_001", PointsDegree: "A", Feedback: "课堂表现积极"}
+}
+
+func validTestResult() TestResult {
+	return TestResult{TestID: "test_001", UserID: "user_001", ScoreSum: "87.5", PaperNumber: "paper_001", Answer: "答案"}
+}
+
+func validJudgement() Judgement {
+	return Judgement{JudgementID: "judge_001", UserID: "user_001", JudgementRating: "4", JudgementTime: "2024-01-02T15:04:05+08:00"}
+}
+
+func TestValidateRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		record func() interface{}
+		fields []string // 未通过的字段和规则，field:rule
+	}{
+		{"有效测评", func() interface{} { return validEvaluation() }, nil},
+		{"有效测试结果", func() interface{} { return validTestResult() }, nil},
+		{"有效评价", func() interface{} { return validJudgement() }, nil},
+		{"指针", func() interface{} { e := validEvaluation(); return &e }, nil},
+		{"可选字段为空", func() interface{} { r := validTestResult(); r.ScoreSum, r.PaperNumber, r.Answer = "", "", ""; return r }, nil},
+
+		{"缺少ID", func() interface{} { e := validEvaluation(); e.EvaluationID = ""; return e }, []string{"Evaluation_ID:required"}},
+		{"ID超长", func() interface{} { e := validEvaluation(); e.EvaluationID = strings.Repeat("a", 65); return e }, []string{"Evaluation_ID:max"}},
+		{"ID恰好64字符", func() interface{} { e := validEvaluation(); e.EvaluationID = strings.Repeat("a", 64); return e }, nil},
+		{"长度按字符计", func() interface{} { e := validEvaluation(); e.Feedback = strings.Repeat("好", 2000); return e }, nil},
+		{"反馈超长", func() interface{} { e := validEvaluation(); e.Feedback = strings.Repeat("好", 2001); return e }, []string{"Feedback:max"}},
+		{"等级不在枚举中", func() interface{} { e := validEvaluation(); e.PointsDegree = "E"; return e }, []string{"Points_Degree:enum"}},
+		{"等级大小写敏感", func() interface{} { e := validEvaluation(); e.PointsDegree = "a"; return e }, []string{"Points_Degree:enum"}},
+		{"擦除时间格式错误", func() interface{} { e := validEvaluation(); e.ErasedAt = "2024-01-02"; return e }, []string{"Erased_At:rfc3339"}},
+
+		{"总分为整数", func() interface{} { r := validTestResult(); r.ScoreSum = "98"; return r }, nil},
+		{"总分为负数", func() interface{} { r := validTestResult(); r.ScoreSum = "-1"; return r }, []string{"Score_Sum:numeric"}},
+		{"总分不是数字", func() interface{} { r := validTestResult(); r.ScoreSum = "九十"; return r }, []string{"Score_Sum:numeric"}},
+		{"总分小数点结尾", func() interface{} { r := validTestResult(); r.ScoreSum = "98."; return r }, []string{"Score_Sum:numeric"}},
+		{"总分超长", func() interface{} { r := validTestResult(); r.ScoreSum = strings.Repeat("9", 17); return r }, []string{"Score_Sum:max"}},
+
+		{"评分下限", func() interface{} { j := validJudgement(); j.JudgementRating = "1"; return j }, nil},
+		{"评分上限", func() interface{} { j := validJudgement(); j.JudgementRating = "5"; return j }, nil},
+		{"评分超出范围", func() interface{} { j := validJudgement(); j.JudgementRating = "6"; return j }, []string{"Judgement_Rating:range"}},
+		{"评分为零", func() interface{} { j := validJudgement(); j.JudgementRating = "0"; return j }, []string{"Judgement_Rating:range"}},
+		{"评分为小数", func() interface{} { j := validJudgement(); j.JudgementRating = "4.5"; return j }, []string{"Judgement_Rating:range"}},
+		{"评价时间缺少时区", func() interface{} { j := validJudgement(); j.JudgementTime = "2024-01-02T15:04:05"; return j }, []string{"Judgement_Time:rfc3339"}},
+		{"评价时间为UTC", func() interface{} { j := validJudgement(); j.JudgementTime = "2024-01-02T07:04:05Z"; return j }, nil},
+
+		{"密文按密文上限校验", func() interface{} {
+			j := validJudgement()
+			j.JudgementContent = EnvelopePrefix + strings.Repeat("x", EncryptedMaxLen(2000)-len(EnvelopePrefix))
+			return j
+		}, nil},
+		{"密文超长", func() interface{} {
+			j := validJudgement()
+			j.JudgementContent = EnvelopePrefix + strings.Repeat("x", EncryptedMaxLen(2000))
+			return j
+		}, []string{"Judgement_Content:max"}},
+		{"未标注 encrypted 的字段不接受密文长度", func() interface{} {
+			j := validJudgement()
+			j.JudgementObjection = EnvelopePrefix + strings.Repeat("x", 2001)
+			return j
+		}, []string{"Judgement_Objection:max"}},
+
+		{"报告全部未通过的字段", func() interface{} { return Judgement{JudgementRating: "9", JudgementTime: "昨天"} },
+			[]string{"Judgement_ID:required", "User_ID:required", "Judgement_Rating:range", "Judgement_Time:rfc3339"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.record())
+			if tt.fields == nil {
+				if err != nil {
+					t.Fatalf("Validate = %v，期望通过", err)
+				}
+				return
+			}
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("Validate = %v，期望 *ValidationError", err)
+			}
+			var got []string
+			for _, field := range invalid.Fields {
+				got = append(got, field.Field+":"+field.Rule)
+				if !strings.HasPrefix(field.Message, field.Field+" ") {
+					t.Errorf("错误信息 %q 应以字段名开头", field.Message)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("未通过的字段 = %v，期望 %v", got, tt.fields)
+			}
+		})
+	}
+}
+
+func TestValidateType(t *testing.T) {
+	var invalid *ValidationError
+	if err := Validate(Judgement{}); !errors.As(err, &invalid) || invalid.Type != "Judgement" {
+		t.Errorf("Validate = %v，期望类型为 Judgement 的校验错误", err)
+	}
+	if err := Validate("Evaluation"); err == nil || errors.As(err, &invalid) {
+		t.Errorf("校验非结构体 = %v，期望普通错误", err)
+	}
+	if err := (Evaluation{}).Validate(); !errors.As(err, &invalid) || invalid.Type != "Evaluation" {
+		t.Errorf("Evaluation.Validate = %v", err)
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want fieldRules
+		ok   bool
+	}{
+		{"", fieldRules{}, true},
+		{"required,max=64", fieldRules{required: true, maxLen: 64}, true},
+		{"enum=A|B", fieldRules{enum: []string{"A", "B"}}, true},
+		{"range=1:5", fieldRules{rangeMin: 1, rangeMax: 5, hasRange: true}, true},
+		{"numeric,rfc3339,encrypted", fieldRules{numeric: true, rfc3339: true, encrypted: true}, true},
+		{"max=0", fieldRules{}, false},
+		{"max=abc", fieldRules{}, false},
+		{"enum=", fieldRules{}, false},
+		{"range=5:1", fieldRules{}, false},
+		{"range=1", fieldRules{}, false},
+		{"min=1", fieldRules{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseRules(tt.tag)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseRules(%q) 错误 = %v，期望成功 %v", tt.tag, err, tt.ok)
+			continue
+		}
+		if tt.ok && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseRules(%q) = %+v，期望 %+v", tt.tag, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+)
+
+// ===================== 身份与角色 =====================
+
+// InstitutionMSPID 学校组织的 MSP ID
+// 学校组织中带 edu.role=admin 或 edu.role=teacher 证书属性的身份（管理员、教师、导入导出等服务）可读取全部记录；
+// 学生身份登记时须带 edu.role=student，登记ID即用户ID，只能读取自己的记录；
+// 其他身份（不带角色属性的学校组织身份、用人单位、其他学校）只能读取学生授权的记录
+const InstitutionMSPID = "Org1MSP"
+
+// 证书中的角色属性，由 Fabric CA 登记时以 edu.role=<角色>:ecert 写入
+const (
+	RoleAttribute = "edu.role"
+	RoleStudent   = "student"
+	RoleTeacher   = "teacher"
+	RoleAdmin     = "admin"
+	RoleRegistrar = "registrar" // 教务处，签发成绩证明；链码按不带角色的学校组织身份处理
+)
+
+// AttrsExtensionOID Fabric CA 写入证书属性的扩展，内容为 {"attrs":{"<属性名>":"<值>"}}
+var AttrsExtensionOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+// CertRole 读取证书中的 edu.role 属性，没有或无法解析时为空
+func CertRole(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(AttrsExtensionOID) {
+			continue
+		}
+		var attrs struct {
+			Attrs map[string]string `json:"attrs"`
+		}
+		if err := json.Unmarshal(ext.Value, &attrs); err != nil {
+			return ""
+		}
+		return attrs.Attrs[RoleAttribute]
+	}
+	return ""
+}
+
+// ===================== 瞬态数据 =====================
+// 客户端经提案的瞬态数据传给链码的内容，瞬态数据不会写入区块
+
+const (
+	LanguageTransient    = "lang"        // 调用者选择的错误信息语言（zh/en），未指定时为中文
+	RecordKeyTransient   = "recordKey"   // 写入交易所属学生的密钥（AES-256，32字节）
+	PersonalTransient    = "personal"    // 写入交易的个人内容，JSON 对象（字段名 -> 明文）
+	TraceparentTransient = "traceparent" // W3C Trace Context，链码据此输出与客户端相同的追踪ID
+)
+
+// PersonalFields 经 PersonalTransient 传给链码、以学生密钥加密的个人内容字段
+var PersonalFields = []string{"Feedback", "Answer"}
+
+// EncryptedPrefix 链码以学生密钥加密的字段前缀，其后为 base64(nonce || 密文)
+const EncryptedPrefix = "enc:v1:"
+
+// DocTypeUserData 擦除个人内容时发出的记录变更事件的文档类型
+const DocTypeUserData = "UserData"
+
+// ===================== 链码事件 =====================
+
+// RecordEventName 记录变更事件名称，每笔交易只能设置一个事件
+const RecordEventName = "RecordChanged"
+
+// RecordEvent 记录变更事件内容
+type RecordEvent struct {
+	DocType        string `json:"docType"`                  // 记录类型
+	Action         string `json:"action"`                   // Create / Modify / Delete / Erase
+	RecordID       string `json:"recordId"`                 // 记录ID
+	UserID         string `json:"userId"`                   // 变更后所属用户
+	PreviousUserID string `json:"previousUserId,omitempty"` // 修改前所属用户（与 UserID 相同时省略）
+	ObjectID       string `json:"objectId,omitempty"`       // 评价关联的对象（仅 Judgement）
+	Objection      bool   `json:"objection,omitempty"`      // 评价是否提出异议（仅 Judgement）
+}
+
+// ===================== 分页导出 =====================
+
+// ExportFilter 导出过滤条件，空字段表示不过滤
+// 试卷编号只有 TestResult 有，时间范围只适用于带评价时间的 Judgement；
+// 账本记录中没有课程字段，按课程导出时由客户端按试卷与课程的对应关系给出该课程的 PaperNumbers
+type ExportFilter struct {
+	UserID       string   `json:"userId,omitempty"`       // 用户ID
+	PaperNumber  string   `json:"paperNumber,omitempty"`  // 试卷编号
+	PaperNumbers []string `json:"paperNumbers,omitempty"` // 试卷编号之一，与 PaperNumber 同时指定时两者都须满足
+	From         string   `json:"from,omitempty"`         // 起始时间（RFC3339，含）
+	To           string   `json:"to,omitempty"`           // 截止时间（RFC3339，不含）
+}
+
+// ExportPage 分页导出结果
+type ExportPage struct {
+	Records  []string `json:"records"`  // 记录原始JSON
+	Bookmark string   `json:"bookmark"` // 下一页书签，本页不足 pageSize 条时表示已到末尾
+}
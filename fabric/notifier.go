@@ -0,0 +1,846 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"edu/model"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// 事件订阅中断后重新订阅的等待时间
+const notifyResubscribeDelay = 5 * time.Second
+
+// 通知方式
+const (
+	NotifyWebhook = "webhook"
+	NotifyEmail   = "email"
+)
+
+// Webhook 请求头，签名为 HMAC-SHA256(密钥, 时间戳 + "." + 请求体) 的十六进制，前缀 sha256=
+const (
+	webhookDeliveryHeader  = "X-Edu-Delivery"
+	webhookEventHeader     = "X-Edu-Event"
+	webhookTimestampHeader = "X-Edu-Timestamp"
+	webhookSignatureHeader = "X-Edu-Signature"
+)
+
+// ===================== 通知订阅 =====================
+
+// Subscription 通知订阅，按用户或角色保存
+// 用户订阅只接收该用户自己记录的变更；角色订阅（如教师）接收所有符合过滤条件的变更，角色成员由接收方维护
+type Subscription struct {
+	ID            string    `json:"id"`
+	UserID        string    `json:"userId,omitempty"`
+	Role          string    `json:"role,omitempty"`
+	DocTypes      []string  `json:"docTypes,omitempty"`      // 为空时不限类型
+	Actions       []string  `json:"actions,omitempty"`       // Create / Modify / Delete，为空时不限
+	ObjectionOnly bool      `json:"objectionOnly,omitempty"` // 只接收提出异议的评价
+	Channel       string    `json:"channel"`                 // webhook / email
+	Target        string    `json:"target"`                  // Webhook 地址或邮箱
+	Secret        string    `json:"secret,omitempty"`        // Webhook 签名密钥
+	Created       time.Time `json:"created"`
+}
+
+// matches 记录变更事件是否符合订阅条件；修改记录所属用户时，原用户也会收到通知
+func (sub *Subscription) matches(event *RecordEvent) bool {
+	if sub.UserID != "" && event.UserID != sub.UserID && event.PreviousUserID != sub.UserID {
+		return false
+	}
+	if len(sub.DocTypes) > 0 && !containsString(sub.DocTypes, event.DocType) {
+		return false
+	}
+	if len(sub.Actions) > 0 && !containsString(sub.Actions, event.Action) {
+		return false
+	}
+	return !sub.ObjectionOnly || event.Objection
+}
+
+// validate 检查订阅内容，Webhook 未指定密钥时生成随机密钥
+func (sub *Subscription) validate() error {
+	if (sub.UserID == "") == (sub.Role == "") {
+		return fmt.Errorf("订阅必须且只能指定用户或角色之一")
+	}
+	for _, docType := range sub.DocTypes {
+		if !containsString([]string{"Evaluation", "TestResult", "Judgement"}, docType) {
+			return fmt.Errorf("不支持的记录类型 %s", docType)
+		}
+	}
+	for _, action := range sub.Actions {
+		if !containsString([]string{"Create", "Modify", "Delete"}, action) {
+			return fmt.Errorf("不支持的操作 %s（可选 Create/Modify/Delete）", action)
+		}
+	}
+
+	switch sub.Channel {
+	case NotifyWebhook:
+		u, err := url.Parse(sub.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("Webhook 地址格式错误 %q", sub.Target)
+		}
+		if sub.Secret == "" {
+			secret, err := randomHex(32)
+			if err != nil {
+				return err
+			}
+			sub.Secret = secret
+		}
+	case NotifyEmail:
+		if !strings.Contains(sub.Target, "@") {
+			return fmt.Errorf("邮箱地址格式错误 %q", sub.Target)
+		}
+	default:
+		return fmt.Errorf("不支持的通知方式 %s（可选 webhook/email）", sub.Channel)
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成随机数失败: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// SubscriptionStore 订阅保存在JSON文件中（含签名密钥，权限 0600）
+// 通知服务运行期间文件被命令行修改时自动重新加载
+type SubscriptionStore struct {
+	path string
+
+	mu            sync.Mutex
+	modified      time.Time
+	subscriptions []*Subscription
+}
+
+// OpenSubscriptionStore 打开订阅文件，文件不存在时视为没有订阅
+func OpenSubscriptionStore(path string) (*SubscriptionStore, error) {
+	s := &SubscriptionStore{path: path}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load 在持有锁时调用，文件未变化时不重复读取
+func (s *SubscriptionStore) load() error {
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		s.subscriptions = nil
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取订阅文件失败: %v", err)
+	}
+	if info.ModTime().Equal(s.modified) {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("读取订阅文件失败: %v", err)
+	}
+	var subscriptions []*Subscription
+	if err := json.Unmarshal(data, &subscriptions); err != nil {
+		return fmt.Errorf("解析订阅文件失败: %v", err)
+	}
+	s.subscriptions = subscriptions
+	s.modified = info.ModTime()
+	return nil
+}
+
+func (s *SubscriptionStore) save() error {
+	data, err := json.MarshalIndent(s.subscriptions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化订阅失败: %v", err)
+	}
+	// 先写临时文件再改名，避免运行中的通知服务读到写了一半的文件
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入订阅文件失败: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("写入订阅文件失败: %v", err)
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modified = info.ModTime()
+	}
+	return nil
+}
+
+// Add 校验并保存订阅，返回带ID和密钥的订阅
+func (s *SubscriptionStore) Add(sub Subscription) (*Subscription, error) {
+	if err := sub.validate(); err != nil {
+		return nil, err
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+	sub.ID = id
+	sub.Created = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	s.subscriptions = append(s.subscriptions, &sub)
+	return &sub, s.save()
+}
+
+// Remove 删除订阅
+func (s *SubscriptionStore) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	for i, sub := range s.subscriptions {
+		if sub.ID == id {
+			s.subscriptions = append(s.subscriptions[:i], s.subscriptions[i+1:]...)
+			return s.save()
+		}
+	}
+	return fmt.Errorf("找不到订阅 %s", id)
+}
+
+// List 返回全部订阅的副本
+func (s *SubscriptionStore) List() ([]Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	subscriptions := make([]Subscription, len(s.subscriptions))
+	for i, sub := range s.subscriptions {
+		subscriptions[i] = *sub
+	}
+	return subscriptions, nil
+}
+
+// Match 返回符合事件的订阅，读取前检查文件是否被修改
+func (s *SubscriptionStore) Match(event *RecordEvent) ([]Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	var matched []Subscription
+	for _, sub := range s.subscriptions {
+		if sub.matches(event) {
+			matched = append(matched, *sub)
+		}
+	}
+	return matched, nil
+}
+
+// ===================== 通知方式 =====================
+
+// Notification 一次投递的内容，重试和重新订阅时ID不变，接收方据此去重
+type Notification struct {
+	ID             string      `json:"id"` // 交易ID-订阅ID
+	SubscriptionID string      `json:"subscriptionId"`
+	TransactionID  string      `json:"transactionId"`
+	BlockNumber    uint64      `json:"blockNumber"`
+	Event          RecordEvent `json:"event"`
+	Summary        string      `json:"summary"`
+}
+
+// Notifier 通知方式，返回 permanentError 时不再重试
+type Notifier interface {
+	Notify(ctx context.Context, sub *Subscription, notification *Notification) error
+}
+
+// permanentError 重试也不会成功的投递错误，如地址不存在或被接收方拒绝
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// notificationSummary 通知的文字说明
+func notificationSummary(event *RecordEvent) string {
+	kinds := map[string]string{"Evaluation": "测评记录", "TestResult": "测试成绩", "Judgement": "评价"}
+	actions := map[string]string{"Create": "新增", "Modify": "修改", "Delete": "删除"}
+	kind, action := kinds[event.DocType], actions[event.Action]
+	if kind == "" {
+		kind = event.DocType
+	}
+	if action == "" {
+		action = event.Action
+	}
+
+	summary := fmt.Sprintf("用户 %s 的%s %s 已%s", event.UserID, kind, event.RecordID, action)
+	if event.DocType == "Judgement" && event.Objection {
+		summary = fmt.Sprintf("用户 %s 对 %s 提出了异议（评价 %s）", event.UserID, event.ObjectID, event.RecordID)
+	}
+	if event.PreviousUserID != "" {
+		summary += fmt.Sprintf("，原所属用户 %s", event.PreviousUserID)
+	}
+	return summary
+}
+
+// WebhookNotifier 以 POST JSON 投递通知，请求带 HMAC-SHA256 签名
+type WebhookNotifier struct {
+	client *http.Client
+}
+
+// NewWebhookNotifier 创建 Webhook 通知方式，timeout 为单次请求超时
+func NewWebhookNotifier(timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{client: &http.Client{Timeout: timeout}}
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, sub *Subscription, notification *Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return &permanentError{fmt.Errorf("序列化通知失败: %v", err)}
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Target, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{fmt.Errorf("创建Webhook请求失败: %v", err)}
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(webhookDeliveryHeader, notification.ID)
+	request.Header.Set(webhookEventHeader, notification.Event.DocType+"."+notification.Event.Action)
+	request.Header.Set(webhookTimestampHeader, timestamp)
+	request.Header.Set(webhookSignatureHeader, webhookSignature(sub.Secret, timestamp, body))
+
+	response, err := w.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("Webhook请求失败: %v", err)
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(response.Body, 4096))
+
+	switch code := response.StatusCode; {
+	case code >= 200 && code < 300:
+		return nil
+	case code == http.StatusRequestTimeout, code == http.StatusTooManyRequests, code >= 500:
+		return fmt.Errorf("Webhook返回 %s", response.Status)
+	default:
+		return &permanentError{fmt.Errorf("Webhook返回 %s", response.Status)}
+	}
+}
+
+func webhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature 供接收方校验签名，maxAge 大于 0 时同时拒绝过旧的请求以防重放
+func VerifyWebhookSignature(secret string, header http.Header, body []byte, maxAge time.Duration) error {
+	timestamp := header.Get(webhookTimestampHeader)
+	if maxAge > 0 {
+		sent, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return fmt.Errorf("时间戳格式错误 %q", timestamp)
+		}
+		if age := time.Since(time.Unix(sent, 0)); age > maxAge || age < -maxAge {
+			return fmt.Errorf("请求时间戳超出允许范围")
+		}
+	}
+	expected := webhookSignature(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(header.Get(webhookSignatureHeader))) {
+		return fmt.Errorf("签名不匹配")
+	}
+	return nil
+}
+
+// SMTPNotifier 通过SMTP发送邮件通知，开发环境可指向本地邮件收集服务（如 MailHog 的 localhost:1025）
+// net/smtp 不支持 ctx，超时由服务器连接决定
+type SMTPNotifier struct {
+	Addr string
+	From string
+	Auth smtp.Auth // 本地服务不需要认证时为 nil
+}
+
+func (s *SMTPNotifier) Notify(ctx context.Context, sub *Subscription, notification *Notification) error {
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", s.From)
+	fmt.Fprintf(&message, "To: %s\r\n", sub.Target)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", "[教学评价] "+notification.Summary))
+	fmt.Fprintf(&message, "Message-ID: <%s@edu-ledger>\r\n", notification.ID)
+	message.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(&message, "%s\r\n\r\n", notification.Summary)
+	fmt.Fprintf(&message, "交易ID：%s\r\n区块：%d\r\n", notification.TransactionID, notification.BlockNumber)
+
+	if err := smtp.SendMail(s.Addr, s.Auth, s.From, []string{sub.Target}, message.Bytes()); err != nil {
+		var smtpErr *textproto.Error
+		if errors.As(err, &smtpErr) && smtpErr.Code >= 500 {
+			return &permanentError{fmt.Errorf("发送邮件失败: %v", err)}
+		}
+		return fmt.Errorf("发送邮件失败: %v", err)
+	}
+	return nil
+}
+
+// ===================== 通知服务 =====================
+
+// NotifyOptions 通知服务配置
+type NotifyOptions struct {
+	Workers       int           // 并发投递数
+	MaxAttempts   int           // 每条通知最多尝试次数
+	RetryDelay    time.Duration // 首次重试等待时间，之后每次翻倍
+	MaxRetryDelay time.Duration
+	DeadLetter    string // 重试耗尽的通知追加写入此文件（JSONL）
+	Checkpoint    string // 保存恢复订阅的区块号
+}
+
+func defaultNotifyOptions() NotifyOptions {
+	return NotifyOptions{
+		Workers:       4,
+		MaxAttempts:   5,
+		RetryDelay:    time.Second,
+		MaxRetryDelay: time.Minute,
+		DeadLetter:    "notify_dead_letter.jsonl",
+		Checkpoint:    "notify_checkpoint",
+	}
+}
+
+// deadLetter 死信记录，不含签名密钥
+type deadLetter struct {
+	Notification *Notification `json:"notification"`
+	Channel      string        `json:"channel"`
+	Target       string        `json:"target"`
+	Attempts     int           `json:"attempts"`
+	Error        string        `json:"error"`
+	FailedAt     time.Time     `json:"failedAt"`
+}
+
+// delivery 投递任务
+type delivery struct {
+	subscription Subscription
+	notification *Notification
+}
+
+// NotificationService 订阅链码事件，按订阅投递通知
+// 投递至少一次：重启后从最早一个仍有未完成投递的区块重新订阅，该区块内已投递的通知可能重复
+type NotificationService struct {
+	client    *Client
+	store     *SubscriptionStore
+	notifiers map[string]Notifier
+	options   NotifyOptions
+
+	mu         sync.Mutex
+	pending    map[uint64]int // 区块号 -> 未完成的投递数
+	current    uint64         // 最近收到事件的区块
+	checkpoint uint64         // 已写入检查点文件的区块
+
+	deadMu sync.Mutex // 串行追加死信文件
+}
+
+// NewNotificationService 创建通知服务，notifiers 以通知方式为键
+func NewNotificationService(c *Client, store *SubscriptionStore, notifiers map[string]Notifier, options NotifyOptions) *NotificationService {
+	defaults := defaultNotifyOptions()
+	if options.Workers <= 0 {
+		options.Workers = defaults.Workers
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = defaults.MaxAttempts
+	}
+	if options.RetryDelay <= 0 {
+		options.RetryDelay = defaults.RetryDelay
+	}
+	if options.MaxRetryDelay < options.RetryDelay {
+		options.MaxRetryDelay = options.RetryDelay
+	}
+	return &NotificationService{
+		client:    c,
+		store:     store,
+		notifiers: notifiers,
+		options:   options,
+		pending:   make(map[uint64]int),
+	}
+}
+
+// Run 从检查点开始订阅链码事件，连接中断时重新订阅，直到 ctx 取消
+// 未保存检查点时从当前区块开始，不补发历史事件
+func (s *NotificationService) Run(ctx context.Context) error {
+	var start []uint64
+	if s.options.Checkpoint != "" {
+		data, err := ioutil.ReadFile(s.options.Checkpoint)
+		switch {
+		case err == nil:
+			block, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+			if err != nil {
+				return fmt.Errorf("检查点文件格式错误: %v", err)
+			}
+			start = []uint64{block}
+			s.current, s.checkpoint = block, block
+			log.Printf("从区块 %d 恢复通知", block)
+		case !os.IsNotExist(err):
+			return fmt.Errorf("读取检查点失败: %v", err)
+		}
+	}
+
+	queue := make(chan delivery)
+	var workers sync.WaitGroup
+	for i := 0; i < s.options.Workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for d := range queue {
+				s.deliver(ctx, d)
+			}
+		}()
+	}
+	defer func() {
+		close(queue)
+		workers.Wait()
+	}()
+
+	for {
+		events, err := s.client.ChaincodeEvents(ctx, start...)
+		if err == nil {
+			for event := range events {
+				if err := s.dispatch(ctx, event, queue); err != nil {
+					log.Printf("处理交易 %s 的事件失败: %v", event.TransactionID, err)
+				}
+				start = []uint64{s.resumeBlock()}
+			}
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Printf("通知事件订阅中断，%v 后重新订阅: %v", notifyResubscribeDelay, err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(notifyResubscribeDelay):
+		}
+	}
+}
+
+// dispatch 为符合条件的订阅生成投递任务
+func (s *NotificationService) dispatch(ctx context.Context, event *client.ChaincodeEvent, queue chan<- delivery) error {
+	s.mu.Lock()
+	if event.BlockNumber > s.current {
+		s.current = event.BlockNumber
+	}
+	s.mu.Unlock()
+
+	if event.EventName != model.RecordEventName {
+		s.advance()
+		return nil
+	}
+	var recordEvent RecordEvent
+	if err := json.Unmarshal(event.Payload, &recordEvent); err != nil {
+		s.advance()
+		return fmt.Errorf("解析链码事件失败: %v", err)
+	}
+	subscriptions, err := s.store.Match(&recordEvent)
+	if err != nil {
+		return err
+	}
+
+	if len(subscriptions) > 0 {
+		s.mu.Lock()
+		s.pending[event.BlockNumber] += len(subscriptions)
+		s.mu.Unlock()
+	}
+	s.advance()
+
+	summary := notificationSummary(&recordEvent)
+	for _, sub := range subscriptions {
+		d := delivery{
+			subscription: sub,
+			notification: &Notification{
+				ID:             event.TransactionID + "-" + sub.ID,
+				SubscriptionID: sub.ID,
+				TransactionID:  event.TransactionID,
+				BlockNumber:    event.BlockNumber,
+				Event:          recordEvent,
+				Summary:        summary,
+			},
+		}
+		select {
+		case queue <- d:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+// deliver 投递一条通知，失败时按指数退避重试，重试耗尽或不可重试时写入死信
+// ctx 取消时放弃投递且不标记完成，重启后由检查点重新投递
+func (s *NotificationService) deliver(ctx context.Context, d delivery) {
+	notifier, ok := s.notifiers[d.subscription.Channel]
+	if !ok {
+		s.deadLetter(d, 0, fmt.Errorf("未配置通知方式 %s", d.subscription.Channel))
+		s.complete(d.notification.BlockNumber)
+		return
+	}
+
+	delay := s.options.RetryDelay
+	for attempt := 1; ; attempt++ {
+		err := notifier.Notify(ctx, &d.subscription, d.notification)
+		if err == nil {
+			s.complete(d.notification.BlockNumber)
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) || attempt >= s.options.MaxAttempts {
+			s.deadLetter(d, attempt, err)
+			s.complete(d.notification.BlockNumber)
+			return
+		}
+		log.Printf("通知 %s 第 %d 次投递失败，%v 后重试: %v", d.notification.ID, attempt, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > s.options.MaxRetryDelay {
+			delay = s.options.MaxRetryDelay
+		}
+	}
+}
+
+func (s *NotificationService) deadLetter(d delivery, attempts int, cause error) {
+	log.Printf("通知 %s 投递失败，写入死信: %v", d.notification.ID, cause)
+	if s.options.DeadLetter == "" {
+		return
+	}
+	data, err := json.Marshal(deadLetter{
+		Notification: d.notification,
+		Channel:      d.subscription.Channel,
+		Target:       d.subscription.Target,
+		Attempts:     attempts,
+		Error:        cause.Error(),
+		FailedAt:     time.Now(),
+	})
+	if err != nil {
+		log.Printf("序列化死信失败: %v", err)
+		return
+	}
+
+	s.deadMu.Lock()
+	defer s.deadMu.Unlock()
+	f, err := os.OpenFile(s.options.DeadLetter, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("打开死信文件失败: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		log.Printf("写入死信失败: %v", err)
+	}
+}
+
+// complete 一条投递结束（成功或写入死信）
+func (s *NotificationService) complete(block uint64) {
+	s.mu.Lock()
+	if s.pending[block]--; s.pending[block] <= 0 {
+		delete(s.pending, block)
+	}
+	s.mu.Unlock()
+	s.advance()
+}
+
+// resumeBlock 重新订阅的起始区块：最早仍有未完成投递的区块，没有时为最近收到事件的区块
+func (s *NotificationService) resumeBlock() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resumeBlockLocked()
+}
+
+func (s *NotificationService) resumeBlockLocked() uint64 {
+	block := s.current
+	for b := range s.pending {
+		if b < block {
+			block = b
+		}
+	}
+	return block
+}
+
+// advance 检查点变化时写入文件
+func (s *NotificationService) advance() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	block := s.resumeBlockLocked()
+	if block == s.checkpoint || s.options.Checkpoint == "" {
+		return
+	}
+	if err := ioutil.WriteFile(s.options.Checkpoint, []byte(strconv.FormatUint(block, 10)), 0644); err != nil {
+		log.Printf("保存通知检查点失败: %v", err)
+		return
+	}
+	s.checkpoint = block
+}
+
+// ===================== 命令行 =====================
+
+// runNotify 通知服务命令：run 启动服务，subscribe/unsubscribe/list 管理订阅
+func runNotify(args []string) error {
+	flags := flag.NewFlagSet("notify", flag.ExitOnError)
+	storePath := flags.String("store", "notify_subscriptions.json", "订阅文件")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		return fmt.Errorf("缺少子命令（run/subscribe/unsubscribe/list）")
+	}
+	store, err := OpenSubscriptionStore(*storePath)
+	if err != nil {
+		return err
+	}
+
+	switch sub, rest := flags.Arg(0), flags.Args()[1:]; sub {
+	case "run":
+		return runNotifyService(store, rest)
+	case "subscribe":
+		return runNotifySubscribe(store, rest)
+	case "unsubscribe":
+		if len(rest) == 0 {
+			return fmt.Errorf("缺少订阅ID")
+		}
+		for _, id := range rest {
+			if err := store.Remove(id); err != nil {
+				return err
+			}
+			fmt.Printf("已删除订阅 %s\n", id)
+		}
+		return nil
+	case "list":
+		subscriptions, err := store.List()
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		for _, s := range subscriptions {
+			s.Secret = ""
+			encoder.Encode(s)
+		}
+		return nil
+	default:
+		return fmt.Errorf("未知子命令 %s", sub)
+	}
+}
+
+func runNotifySubscribe(store *SubscriptionStore, args []string) error {
+	flags := flag.NewFlagSet("notify subscribe", flag.ExitOnError)
+	userID := flags.String("user", "", "订阅该用户记录的变更")
+	role := flags.String("role", "", "按角色订阅所有符合条件的变更")
+	types := flags.String("types", "", "记录类型，逗号分隔，默认全部")
+	actions := flags.String("actions", "", "操作，逗号分隔（Create/Modify/Delete），默认全部")
+	objectionOnly := flags.Bool("objection-only", false, "只通知提出异议的评价")
+	webhook := flags.String("webhook", "", "Webhook 地址")
+	secret := flags.String("secret", "", "Webhook 签名密钥，默认随机生成")
+	email := flags.String("email", "", "通知邮箱")
+	flags.Parse(args)
+
+	sub := Subscription{UserID: *userID, Role: *role, ObjectionOnly: *objectionOnly}
+	if *types != "" {
+		sub.DocTypes = strings.Split(*types, ",")
+	}
+	if *actions != "" {
+		sub.Actions = strings.Split(*actions, ",")
+	}
+	switch {
+	case *webhook != "" && *email != "":
+		return fmt.Errorf("-webhook 和 -email 只能指定一个")
+	case *webhook != "":
+		sub.Channel, sub.Target, sub.Secret = NotifyWebhook, *webhook, *secret
+	case *email != "":
+		sub.Channel, sub.Target = NotifyEmail, *email
+	default:
+		return fmt.Errorf("缺少 -webhook 或 -email 参数")
+	}
+
+	added, err := store.Add(sub)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("已添加订阅 %s\n", added.ID)
+	if added.Channel == NotifyWebhook && *secret == "" {
+		fmt.Printf("签名密钥（请妥善保存）: %s\n", added.Secret)
+	}
+	return nil
+}
+
+func runNotifyService(store *SubscriptionStore, args []string) error {
+	defaults := defaultNotifyOptions()
+	flags := flag.NewFlagSet("notify run", flag.ExitOnError)
+	workers := flags.Int("workers", defaults.Workers, "并发投递数")
+	attempts := flags.Int("attempts", defaults.MaxAttempts, "每条通知最多尝试次数")
+	deadLetterPath := flags.String("dead-letter", defaults.DeadLetter, "死信文件（JSONL）")
+	checkpoint := flags.String("checkpoint", defaults.Checkpoint, "检查点文件")
+	webhookTimeout := flags.Duration("webhook-timeout", 10*time.Second, "Webhook 请求超时")
+	smtpAddr := flags.String("smtp", "", "SMTP服务器地址，如本地收集服务 localhost:1025，为空时不发送邮件")
+	smtpFrom := flags.String("smtp-from", "noreply@edu.local", "发件人")
+	smtpUser := flags.String("smtp-user", "", "SMTP用户名，为空时不认证")
+	flags.Parse(args)
+
+	notifiers := map[string]Notifier{NotifyWebhook: NewWebhookNotifier(*webhookTimeout)}
+	if *smtpAddr != "" {
+		notifier := &SMTPNotifier{Addr: *smtpAddr, From: *smtpFrom}
+		if *smtpUser != "" {
+			host := strings.Split(*smtpAddr, ":")[0]
+			notifier.Auth = smtp.PlainAuth("", *smtpUser, os.Getenv("EDU_SMTP_PASSWORD"), host)
+		}
+		notifiers[NotifyEmail] = notifier
+	}
+
+	client, err := NewClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	service := NewNotificationService(client, store, notifiers, NotifyOptions{
+		Workers:       *workers,
+		MaxAttempts:   *attempts,
+		RetryDelay:    defaults.RetryDelay,
+		MaxRetryDelay: defaults.MaxRetryDelay,
+		DeadLetter:    *deadLetterPath,
+		Checkpoint:    *checkpoint,
+	})
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Printf("通知服务已启动")
+	return service.Run(ctx)
+}
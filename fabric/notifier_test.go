@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedNotifier 依次返回预设的投递结果，记录每次投递的时间
+type scriptedNotifier struct {
+	mu      sync.Mutex
+	results []error
+	calls   []time.Time
+}
+
+func (n *scriptedNotifier) Notify(ctx context.Context, sub *Subscription, notification *Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.calls = append(n.calls, time.Now())
+	if len(n.calls) > len(n.results) {
+		return nil
+	}
+	return n.results[len(n.calls)-1]
+}
+
+func (n *scriptedNotifier) attempts() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.calls)
+}
+
+// readDeadLetters 读取死信文件，文件不存在时返回空
+func readDeadLetters(t *testing.T, path string) []deadLetter {
+	t.Helper()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var letters []deadLetter
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var letter deadLetter
+		if err := json.Unmarshal([]byte(line), &letter); err != nil {
+			t.Fatal(err)
+		}
+		letters = append(letters, letter)
+	}
+	return letters
+}
+
+func TestNotificationDeliverRetries(t *testing.T) {
+	transient := errors.New("Webhook返回 503 Service Unavailable")
+	tests := []struct {
+		name         string
+		channel      string
+		results      []error
+		wantAttempts int
+		deadLetter   bool
+	}{
+		{"首次成功", NotifyWebhook, nil, 1, false},
+		{"重试后成功", NotifyWebhook, []error{transient, transient}, 3, false},
+		{"重试耗尽", NotifyWebhook, []error{transient, transient, transient, transient}, 3, true},
+		{"不可重试的错误", NotifyWebhook, []error{&permanentError{errors.New("Webhook返回 404 Not Found")}}, 1, true},
+		{"未配置的通知方式", NotifyEmail, nil, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier := &scriptedNotifier{results: tt.results}
+			deadLetterPath := filepath.Join(t.TempDir(), "dead_letter.jsonl")
+			s := NewNotificationService(nil, nil, map[string]Notifier{NotifyWebhook: notifier}, NotifyOptions{
+				MaxAttempts:   3,
+				RetryDelay:    time.Millisecond,
+				MaxRetryDelay: 2 * time.Millisecond,
+				DeadLetter:    deadLetterPath,
+			})
+			s.pending[7] = 1
+			d := delivery{
+				subscription: Subscription{ID: "sub1", UserID: "user_001", Channel: tt.channel, Target: "http://localhost/hook", Secret: "secret"},
+				notification: &Notification{ID: "tx1-sub1", BlockNumber: 7},
+			}
+
+			s.deliver(context.Background(), d)
+			if got := notifier.attempts(); got != tt.wantAttempts {
+				t.Errorf("投递 %d 次，期望 %d 次", got, tt.wantAttempts)
+			}
+			if len(s.pending) != 0 {
+				t.Errorf("投递结束后仍有未完成的区块: %v", s.pending)
+			}
+			letters := readDeadLetters(t, deadLetterPath)
+			if !tt.deadLetter {
+				if len(letters) != 0 {
+					t.Errorf("不应写入死信: %+v", letters)
+				}
+				return
+			}
+			if len(letters) != 1 || letters[0].Attempts != tt.wantAttempts || letters[0].Notification.ID != "tx1-sub1" || letters[0].Error == "" {
+				t.Fatalf("死信 = %+v", letters)
+			}
+			data, _ := ioutil.ReadFile(deadLetterPath)
+			if strings.Contains(string(data), "secret") {
+				t.Error("死信中不应包含签名密钥")
+			}
+		})
+	}
+}
+
+// 重试间隔每次翻倍，不超过 MaxRetryDelay
+func TestNotificationRetryBackoff(t *testing.T) {
+	transient := errors.New("连接被拒绝")
+	notifier := &scriptedNotifier{results: []error{transient, transient, transient, transient}}
+	s := NewNotificationService(nil, nil, map[string]Notifier{NotifyWebhook: notifier}, NotifyOptions{
+		MaxAttempts:   5,
+		RetryDelay:    10 * time.Millisecond,
+		MaxRetryDelay: 25 * time.Millisecond,
+	})
+	s.pending[1] = 1
+	s.deliver(context.Background(), delivery{
+		subscription: Subscription{Channel: NotifyWebhook},
+		notification: &Notification{ID: "tx1-sub1", BlockNumber: 1},
+	})
+
+	if len(notifier.calls) != 5 {
+		t.Fatalf("投递 %d 次，期望 5 次", len(notifier.calls))
+	}
+	for i, want := range []time.Duration{10, 20, 25, 25} {
+		if gap := notifier.calls[i+1].Sub(notifier.calls[i]); gap < want*time.Millisecond {
+			t.Errorf("第 %d 次重试间隔 %v，期望至少 %v", i+1, gap, want*time.Millisecond)
+		}
+	}
+}
+
+// ctx 取消时放弃重试，投递不标记完成，重启后由检查点重新投递
+func TestNotificationDeliverCancelled(t *testing.T) {
+	notifier := &scriptedNotifier{results: []error{errors.New("连接被拒绝")}}
+	deadLetterPath := filepath.Join(t.TempDir(), "dead_letter.jsonl")
+	s := NewNotificationService(nil, nil, map[string]Notifier{NotifyWebhook: notifier}, NotifyOptions{
+		MaxAttempts: 5,
+		RetryDelay:  time.Hour,
+		DeadLetter:  deadLetterPath,
+	})
+	s.pending[3] = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.deliver(ctx, delivery{subscription: Subscription{Channel: NotifyWebhook}, notification: &Notification{ID: "tx1-sub1", BlockNumber: 3}})
+		close(done)
+	}()
+	eventually(t, "首次投递", func() bool { return notifier.attempts() == 1 })
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("取消后仍在等待重试")
+	}
+	if s.pending[3] != 1 {
+		t.Errorf("取消的投递被标记为完成: %v", s.pending)
+	}
+	if letters := readDeadLetters(t, deadLetterPath); len(letters) != 0 {
+		t.Errorf("取消的投递写入了死信: %+v", letters)
+	}
+}
+
+func TestWebhookNotifierStatus(t *testing.T) {
+	tests := []struct {
+		status    int
+		wantErr   bool
+		permanent bool
+	}{
+		{http.StatusNoContent, false, false},
+		{http.StatusTooManyRequests, true, false},
+		{http.StatusRequestTimeout, true, false},
+		{http.StatusBadGateway, true, false},
+		{http.StatusBadRequest, true, true},
+		{http.StatusGone, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			sub := &Subscription{Channel: NotifyWebhook, Secret: "secret"}
+			notification := &Notification{ID: "tx1-sub1", Event: RecordEvent{DocType: "Evaluation", Action: "Create"}}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				if err := VerifyWebhookSignature(sub.Secret, r.Header, body, time.Minute); err != nil {
+					t.Errorf("签名校验失败: %v", err)
+				}
+				if r.Header.Get(webhookDeliveryHeader) != "tx1-sub1" || r.Header.Get(webhookEventHeader) != "Evaluation.Create" {
+					t.Errorf("请求头 = %v", r.Header)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+			sub.Target = server.URL
+
+			err := NewWebhookNotifier(time.Second).Notify(context.Background(), sub, notification)
+			var permanent *permanentError
+			if (err != nil) != tt.wantErr || errors.As(err, &permanent) != tt.permanent {
+				t.Errorf("错误 = %v，期望出错 %v、不可重试 %v", err, tt.wantErr, tt.permanent)
+			}
+		})
+	}
+
+	// 接收方拒绝签名不符或过旧的请求
+	header := http.Header{}
+	header.Set(webhookTimestampHeader, "1000")
+	header.Set(webhookSignatureHeader, webhookSignature("secret", "1000", []byte("{}")))
+	if err := VerifyWebhookSignature("secret", header, []byte("{}"), 0); err != nil {
+		t.Errorf("不检查时间时签名校验失败: %v", err)
+	}
+	if err := VerifyWebhookSignature("secret", header, []byte("{}"), time.Minute); err == nil {
+		t.Error("过旧的请求应被拒绝")
+	}
+	if err := VerifyWebhookSignature("other", header, []byte("{}"), 0); err == nil {
+		t.Error("密钥不符的签名应被拒绝")
+	}
+}
+
+// 服务从检查点重放事件，投递失败后重试成功，检查点前进到最新区块
+func TestNotificationServiceRetriesWebhook(t *testing.T) {
+	c := newTestEmbeddedClient(t)
+	dir := t.TempDir()
+	store, err := OpenSubscriptionStore(filepath.Join(dir, "subscriptions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var received []Notification
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if requests++; requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var notification Notification
+		json.NewDecoder(r.Body).Decode(&notification)
+		received = append(received, notification)
+	}))
+	defer server.Close()
+	if _, err := store.Add(Subscription{UserID: "user_001", Channel: NotifyWebhook, Target: server.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	receipt, err := c.UploadEvaluation(testEvaluation("eval_001", "user_001"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UploadEvaluation(testEvaluation("eval_002", "user_002")); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint := filepath.Join(dir, "checkpoint")
+	if err := ioutil.WriteFile(checkpoint, []byte("0"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewNotificationService(c, store, map[string]Notifier{NotifyWebhook: NewWebhookNotifier(time.Second)}, NotifyOptions{
+		MaxAttempts: 3,
+		RetryDelay:  time.Millisecond,
+		DeadLetter:  filepath.Join(dir, "dead_letter.jsonl"),
+		Checkpoint:  checkpoint,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	eventually(t, "通知送达", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 1
+	})
+	eventually(t, "检查点前进", func() bool {
+		data, _ := ioutil.ReadFile(checkpoint)
+		return strings.TrimSpace(string(data)) != "0"
+	})
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 2 {
+		t.Errorf("Webhook 收到 %d 次请求，期望失败一次后重试成功", requests)
+	}
+	if n := received[0]; n.TransactionID != receipt.TransactionID || n.Event.RecordID != "eval_001" || n.BlockNumber != receipt.BlockNumber {
+		t.Errorf("通知 = %+v，期望交易 %s", n, receipt.TransactionID)
+	}
+	if letters := readDeadLetters(t, filepath.Join(dir, "dead_letter.jsonl")); len(letters) != 0 {
+		t.Errorf("重试成功的通知写入了死信: %+v", letters)
+	}
+}
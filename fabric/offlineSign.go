@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-protos-go-apiv2/gateway"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+// ===================== 离线签名流程 =====================
+// 流程：PrepareProposal -> 签名 -> EndorseOffline -> 签名 -> SubmitOffline -> 签名 -> CommitStatusOffline
+// 每一步导出的摘要在持有私钥的机器上签名后再导入，客户端全程不接触私钥；
+// 签名前从请求内容重新计算摘要，导出的摘要被篡改时拒绝签名（见 VerifyOfflineRequest）
+
+// 离线请求所处阶段
+const (
+	offlineStageProposal    = "proposal"
+	offlineStageTransaction = "transaction"
+	offlineStageCommit      = "commit"
+)
+
+// OfflineRequest 待离线签名的请求，可序列化为JSON文件在机器间传递
+type OfflineRequest struct {
+	Stage         string     `json:"stage"`                 // proposal / transaction / commit
+	TransactionID string     `json:"transactionId"`         // 交易ID
+	Bytes         []byte     `json:"bytes"`                 // 序列化的提案/交易/提交状态请求
+	Digest        []byte     `json:"digest"`                // 需要签名的摘要
+	Signature     []byte     `json:"signature,omitempty"`   // 离线签名结果
+	Result        []byte     `json:"result,omitempty"`      // 背书后的交易执行结果
+	TxTimestamp   *time.Time `json:"txTimestamp,omitempty"` // 交易通道头中的时间戳，提交状态阶段用于生成回执
+}
+
+// PrepareProposal 创建未签名的交易提案
+func (c *Client) PrepareProposal(txName string, args ...string) (*OfflineRequest, error) {
+	return c.PrepareProposalWithContext(context.Background(), txName, args...)
+}
+
+// PrepareProposalWithContext 创建未签名的交易提案，提案携带 ctx 中的追踪上下文
+func (c *Client) PrepareProposalWithContext(ctx context.Context, txName string, args ...string) (request *OfflineRequest, err error) {
+	ctx, end, err := c.startCall(ctx, "PrepareProposal")
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	if args, err = c.sealArgs(ctx, txName, args); err != nil {
+		return nil, err
+	}
+	if ctx, args, err = c.withPersonalContent(ctx, txName, args); err != nil {
+		return nil, err
+	}
+
+	err = c.pool.do(ctx, func(peer *peerConn) error {
+		gw, err := c.pool.gatewayFor(peer, c.identity)
+		if err != nil {
+			return err
+		}
+		proposal, err := gw.contract.NewProposal(txName, proposalOptions(ctx, args)...)
+		if err != nil {
+			return fmt.Errorf("创建交易提案失败: %v", err)
+		}
+		proposalBytes, err := proposal.Bytes()
+		if err != nil {
+			return fmt.Errorf("序列化交易提案失败: %v", err)
+		}
+
+		request = &OfflineRequest{
+			Stage:         offlineStageProposal,
+			TransactionID: proposal.TransactionID(),
+			Bytes:         proposalBytes,
+			Digest:        proposal.Digest(),
+		}
+		return nil
+	})
+	return request, err
+}
+
+// EvaluateOffline 使用已签名的提案执行查询
+func (c *Client) EvaluateOffline(signed *OfflineRequest) ([]byte, error) {
+	return c.EvaluateOfflineWithContext(context.Background(), signed)
+}
+
+// EvaluateOfflineWithContext 使用已签名的提案执行查询，opts 可覆盖本次调用的超时
+func (c *Client) EvaluateOfflineWithContext(ctx context.Context, signed *OfflineRequest, opts ...CallOption) (result []byte, err error) {
+	ctx, end, err := c.startCall(ctx, "EvaluateOffline", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+	if err := checkOfflineRequest(signed, offlineStageProposal); err != nil {
+		return nil, err
+	}
+
+	err = c.pool.do(ctx, func(peer *peerConn) error {
+		gw, err := c.pool.gatewayFor(peer, c.identity)
+		if err != nil {
+			return err
+		}
+		proposal, err := gw.gateway.NewSignedProposal(signed.Bytes, signed.Signature)
+		if err != nil {
+			return fmt.Errorf("导入已签名提案失败: %v", err)
+		}
+		ctx, cancel := phaseContext(ctx, phaseEvaluate)
+		defer cancel()
+		result, err = proposal.EvaluateWithContext(ctx)
+		return err
+	})
+	return result, err
+}
+
+// EndorseOffline 使用已签名的提案背书，返回待签名的交易
+func (c *Client) EndorseOffline(signed *OfflineRequest) (*OfflineRequest, error) {
+	return c.EndorseOfflineWithContext(context.Background(), signed)
+}
+
+// EndorseOfflineWithContext 使用已签名的提案背书，opts 可覆盖本次调用的超时
+func (c *Client) EndorseOfflineWithContext(ctx context.Context, signed *OfflineRequest, opts ...CallOption) (request *OfflineRequest, err error) {
+	ctx, end, err := c.startCall(ctx, "EndorseOffline", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+	if err := checkOfflineRequest(signed, offlineStageProposal); err != nil {
+		return nil, err
+	}
+
+	err = c.pool.do(ctx, func(peer *peerConn) error {
+		gw, err := c.pool.gatewayFor(peer, c.identity)
+		if err != nil {
+			return err
+		}
+		proposal, err := gw.gateway.NewSignedProposal(signed.Bytes, signed.Signature)
+		if err != nil {
+			return fmt.Errorf("导入已签名提案失败: %v", err)
+		}
+		ctx, cancel := phaseContext(ctx, phaseEndorse)
+		defer cancel()
+		transaction, err := proposal.EndorseWithContext(ctx)
+		if err != nil {
+			return chaincodeError(err)
+		}
+		transactionBytes, err := transaction.Bytes()
+		if err != nil {
+			return fmt.Errorf("序列化交易失败: %v", err)
+		}
+
+		request = &OfflineRequest{
+			Stage:         offlineStageTransaction,
+			TransactionID: transaction.TransactionID(),
+			Bytes:         transactionBytes,
+			Digest:        transaction.Digest(),
+			Result:        transaction.Result(),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("背书失败: %w", err)
+	}
+	return request, nil
+}
+
+// SubmitOffline 提交已签名的交易给排序服务，返回待签名的提交状态请求
+func (c *Client) SubmitOffline(signed *OfflineRequest) (*OfflineRequest, error) {
+	return c.SubmitOfflineWithContext(context.Background(), signed)
+}
+
+// SubmitOfflineWithContext 提交已签名的交易给排序服务，opts 可覆盖本次调用的超时
+func (c *Client) SubmitOfflineWithContext(ctx context.Context, signed *OfflineRequest, opts ...CallOption) (_ *OfflineRequest, err error) {
+	ctx, end, err := c.startCall(ctx, "SubmitOffline", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+	if err := checkOfflineRequest(signed, offlineStageTransaction); err != nil {
+		return nil, err
+	}
+
+	gw, err := c.anyGateway(ctx)
+	if err != nil {
+		return nil, err
+	}
+	transaction, err := gw.gateway.NewSignedTransaction(signed.Bytes, signed.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("导入已签名交易失败: %v", err)
+	}
+	// 交易可能已到达排序服务，不做节点切换重试
+	submitCtx, cancel := phaseContext(ctx, phaseSubmit)
+	defer cancel()
+	commit, err := transaction.SubmitWithContext(submitCtx)
+	if err != nil {
+		return nil, fmt.Errorf("提交交易失败: %w", err)
+	}
+	commitBytes, err := commit.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("序列化提交状态请求失败: %v", err)
+	}
+	timestamp, err := transactionTimestamp(signed.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OfflineRequest{
+		Stage:         offlineStageCommit,
+		TransactionID: commit.TransactionID(),
+		Bytes:         commitBytes,
+		Digest:        commit.Digest(),
+		TxTimestamp:   &timestamp,
+	}, nil
+}
+
+// CommitStatusOffline 使用已签名的提交状态请求等待交易提交并返回回执
+func (c *Client) CommitStatusOffline(signed *OfflineRequest) (*TxReceipt, error) {
+	return c.CommitStatusOfflineWithContext(context.Background(), signed)
+}
+
+// CommitStatusOfflineWithContext 等待交易提交并返回回执，opts 可覆盖本次调用的超时
+func (c *Client) CommitStatusOfflineWithContext(ctx context.Context, signed *OfflineRequest, opts ...CallOption) (receipt *TxReceipt, err error) {
+	ctx, end, err := c.startCall(ctx, "CommitStatusOffline", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+	if err := checkOfflineRequest(signed, offlineStageCommit); err != nil {
+		return nil, err
+	}
+
+	err = c.pool.do(ctx, func(peer *peerConn) error {
+		gw, err := c.pool.gatewayFor(peer, c.identity)
+		if err != nil {
+			return err
+		}
+		commit, err := gw.gateway.NewSignedCommit(signed.Bytes, signed.Signature)
+		if err != nil {
+			return fmt.Errorf("导入已签名提交状态请求失败: %v", err)
+		}
+		var timestamp time.Time
+		if signed.TxTimestamp != nil {
+			timestamp = *signed.TxTimestamp
+		}
+		receipt, err = receiptFromCommit(ctx, &gatewayCommit{Commit: commit, timestamp: timestamp})
+		return err
+	})
+	return receipt, err
+}
+
+// anyGateway 返回任意可用节点上当前身份的Gateway
+func (c *Client) anyGateway(ctx context.Context) (*peerGateway, error) {
+	var gw *peerGateway
+	err := c.pool.do(ctx, func(peer *peerConn) error {
+		var err error
+		gw, err = c.pool.gatewayFor(peer, c.identity)
+		return err
+	})
+	return gw, err
+}
+
+func checkOfflineRequest(request *OfflineRequest, stage string) error {
+	if request.Stage != stage {
+		return fmt.Errorf("离线请求阶段错误: 期望 %s，实际 %s", stage, request.Stage)
+	}
+	if len(request.Signature) == 0 {
+		return fmt.Errorf("离线请求 %s 尚未签名", request.TransactionID)
+	}
+	return nil
+}
+
+// ===================== 离线签名工具 =====================
+
+// SignOfflineRequest 在持有私钥的机器上对导出的请求签名
+// 不信任请求中的摘要：先从请求内容重新计算，与导出的摘要不一致时拒绝签名
+func SignOfflineRequest(request *OfflineRequest, signer Signer) error {
+	if _, err := VerifyOfflineRequest(request); err != nil {
+		return err
+	}
+	signature, err := signer.Sign(request.Digest)
+	if err != nil {
+		return fmt.Errorf("签名失败: %v", err)
+	}
+	request.Signature = signature
+	return nil
+}
+
+// VerifyOfflineRequest 解析请求内容，重新计算需要签名的摘要并与导出的摘要比对，返回供签名人核对的请求说明
+// 摘要的计算与 fabric-gateway 一致：提案为 SHA-256(ProposalBytes)，交易为 SHA-256(Envelope.Payload)，
+// 提交状态请求为 SHA-256(Request)
+func VerifyOfflineRequest(request *OfflineRequest) (string, error) {
+	var signed []byte
+	var txID, summary string
+	switch request.Stage {
+	case offlineStageProposal:
+		var proposed gateway.ProposedTransaction
+		if err := proto.Unmarshal(request.Bytes, &proposed); err != nil {
+			return "", fmt.Errorf("解析离线提案失败: %v", err)
+		}
+		signed = proposed.GetProposal().GetProposalBytes()
+		var err error
+		if txID, summary, err = describeProposal(signed); err != nil {
+			return "", err
+		}
+	case offlineStageTransaction:
+		var prepared gateway.PreparedTransaction
+		if err := proto.Unmarshal(request.Bytes, &prepared); err != nil {
+			return "", fmt.Errorf("解析离线交易失败: %v", err)
+		}
+		signed = prepared.GetEnvelope().GetPayload()
+		var payload common.Payload
+		if err := proto.Unmarshal(signed, &payload); err != nil {
+			return "", fmt.Errorf("解析交易内容失败: %v", err)
+		}
+		header, err := channelHeader(payload.GetHeader().GetChannelHeader())
+		if err != nil {
+			return "", err
+		}
+		txID = header.GetTxId()
+		summary = fmt.Sprintf("提交交易 %s（通道 %s）", txID, header.GetChannelId())
+	case offlineStageCommit:
+		var signedRequest gateway.SignedCommitStatusRequest
+		if err := proto.Unmarshal(request.Bytes, &signedRequest); err != nil {
+			return "", fmt.Errorf("解析离线提交状态请求失败: %v", err)
+		}
+		signed = signedRequest.GetRequest()
+		var statusRequest gateway.CommitStatusRequest
+		if err := proto.Unmarshal(signed, &statusRequest); err != nil {
+			return "", fmt.Errorf("解析提交状态请求失败: %v", err)
+		}
+		txID = statusRequest.GetTransactionId()
+		summary = fmt.Sprintf("查询交易 %s 的提交状态（通道 %s）", txID, statusRequest.GetChannelId())
+	default:
+		return "", fmt.Errorf("未知的离线请求阶段 %q", request.Stage)
+	}
+
+	if len(signed) == 0 {
+		return "", fmt.Errorf("离线请求 %s 没有需要签名的内容", request.TransactionID)
+	}
+	digest := sha256.Sum256(signed)
+	if !bytes.Equal(digest[:], request.Digest) {
+		return "", fmt.Errorf("离线请求 %s 的摘要与请求内容不一致，拒绝签名", request.TransactionID)
+	}
+	if txID != request.TransactionID {
+		return "", fmt.Errorf("离线请求的交易ID %s 与请求内容中的 %s 不一致，拒绝签名", request.TransactionID, txID)
+	}
+	return summary, nil
+}
+
+// describeProposal 解析提案，返回交易ID和调用说明；瞬态数据可能含个人内容，只列出键名
+func describeProposal(proposalBytes []byte) (string, string, error) {
+	var proposal peer.Proposal
+	if err := proto.Unmarshal(proposalBytes, &proposal); err != nil {
+		return "", "", fmt.Errorf("解析提案内容失败: %v", err)
+	}
+	var header common.Header
+	if err := proto.Unmarshal(proposal.GetHeader(), &header); err != nil {
+		return "", "", fmt.Errorf("解析提案头失败: %v", err)
+	}
+	chHeader, err := channelHeader(header.GetChannelHeader())
+	if err != nil {
+		return "", "", err
+	}
+	var payload peer.ChaincodeProposalPayload
+	if err := proto.Unmarshal(proposal.GetPayload(), &payload); err != nil {
+		return "", "", fmt.Errorf("解析提案载荷失败: %v", err)
+	}
+	var invocation peer.ChaincodeInvocationSpec
+	if err := proto.Unmarshal(payload.GetInput(), &invocation); err != nil {
+		return "", "", fmt.Errorf("解析链码调用失败: %v", err)
+	}
+
+	spec := invocation.GetChaincodeSpec()
+	args := make([]string, 0, len(spec.GetInput().GetArgs()))
+	for _, arg := range spec.GetInput().GetArgs() {
+		args = append(args, string(arg))
+	}
+	summary := fmt.Sprintf("交易 %s：通道 %s，链码 %s，参数 %s", chHeader.GetTxId(), chHeader.GetChannelId(),
+		spec.GetChaincodeId().GetName(), strings.Join(args, " "))
+	if transient := payload.GetTransientMap(); len(transient) > 0 {
+		keys := make([]string, 0, len(transient))
+		for key := range transient {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		summary += "，瞬态数据 " + strings.Join(keys, ",")
+	}
+	return chHeader.GetTxId(), summary, nil
+}
+
+func channelHeader(data []byte) (*common.ChannelHeader, error) {
+	var header common.ChannelHeader
+	if err := proto.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("解析通道头失败: %v", err)
+	}
+	return &header, nil
+}
+
+// WriteOfflineRequest 导出离线请求到JSON文件
+func WriteOfflineRequest(path string, request *OfflineRequest) error {
+	data, err := json.MarshalIndent(request, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化离线请求失败: %v", err)
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// ReadOfflineRequest 从JSON文件导入离线请求
+func ReadOfflineRequest(path string) (*OfflineRequest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取离线请求失败: %v", err)
+	}
+	var request OfflineRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return nil, fmt.Errorf("解析离线请求失败: %v", err)
+	}
+	return &request, nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"edu/model"
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-protos-go-apiv2/gateway"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+const testOfflineTxID = "4f3c2a"
+
+func mustMarshal(t *testing.T, m proto.Message) []byte {
+	t.Helper()
+	data, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// offlineRequest 按 fabric-gateway 的格式生成离线请求，signed 为需要签名的部分
+func offlineRequest(t *testing.T, stage string, message proto.Message, signed []byte) *OfflineRequest {
+	t.Helper()
+	digest := sha256.Sum256(signed)
+	return &OfflineRequest{Stage: stage, TransactionID: testOfflineTxID, Bytes: mustMarshal(t, message), Digest: digest[:]}
+}
+
+func testProposalRequest(t *testing.T, args ...string) *OfflineRequest {
+	t.Helper()
+	chHeader := mustMarshal(t, &common.ChannelHeader{ChannelId: channelName, TxId: testOfflineTxID})
+	input := &peer.ChaincodeInput{}
+	for _, arg := range args {
+		input.Args = append(input.Args, []byte(arg))
+	}
+	invocation := mustMarshal(t, &peer.ChaincodeInvocationSpec{ChaincodeSpec: &peer.ChaincodeSpec{
+		ChaincodeId: &peer.ChaincodeID{Name: chaincodeID},
+		Input:       input,
+	}})
+	proposalBytes := mustMarshal(t, &peer.Proposal{
+		Header:  mustMarshal(t, &common.Header{ChannelHeader: chHeader}),
+		Payload: mustMarshal(t, &peer.ChaincodeProposalPayload{Input: invocation, TransientMap: map[string][]byte{model.PersonalTransient: []byte(`{"Feedback":"课堂表现积极"}`)}}),
+	})
+	return offlineRequest(t, offlineStageProposal, &gateway.ProposedTransaction{
+		TransactionId: testOfflineTxID,
+		Proposal:      &peer.SignedProposal{ProposalBytes: proposalBytes},
+	}, proposalBytes)
+}
+
+func testTransactionRequest(t *testing.T) *OfflineRequest {
+	t.Helper()
+	chHeader := mustMarshal(t, &common.ChannelHeader{ChannelId: channelName, TxId: testOfflineTxID})
+	payload := mustMarshal(t, &common.Payload{Header: &common.Header{ChannelHeader: chHeader}, Data: []byte("endorsed")})
+	return offlineRequest(t, offlineStageTransaction, &gateway.PreparedTransaction{
+		TransactionId: testOfflineTxID,
+		Envelope:      &common.Envelope{Payload: payload},
+	}, payload)
+}
+
+func testCommitRequest(t *testing.T) *OfflineRequest {
+	t.Helper()
+	request := mustMarshal(t, &gateway.CommitStatusRequest{TransactionId: testOfflineTxID, ChannelId: channelName})
+	return offlineRequest(t, offlineStageCommit, &gateway.SignedCommitStatusRequest{Request: request}, request)
+}
+
+func testOfflineSigner(t *testing.T) (*LocalKeySigner, *ecdsa.PublicKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err := identity.PrivateKeyToPEM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewLocalKeySigner(keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, &key.PublicKey
+}
+
+// 提案、交易、提交状态请求三个阶段依次签名，签名都针对从请求内容重新计算的摘要
+func TestSignOfflineRoundTrip(t *testing.T) {
+	signer, public := testOfflineSigner(t)
+	for _, request := range []*OfflineRequest{
+		testProposalRequest(t, "UploadEvaluation", `{"Evaluation_ID":"eval_001","User_ID":"user_001"}`),
+		testTransactionRequest(t),
+		testCommitRequest(t),
+	} {
+		t.Run(request.Stage, func(t *testing.T) {
+			summary, err := VerifyOfflineRequest(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(summary, testOfflineTxID) || !strings.Contains(summary, channelName) {
+				t.Errorf("请求说明 %q 缺少交易ID或通道", summary)
+			}
+			if err := SignOfflineRequest(request, signer); err != nil {
+				t.Fatal(err)
+			}
+			if !ecdsa.VerifyASN1(public, request.Digest, request.Signature) {
+				t.Error("签名无法用公钥验证")
+			}
+		})
+	}
+}
+
+func TestVerifyOfflineProposalSummary(t *testing.T) {
+	summary, err := VerifyOfflineRequest(testProposalRequest(t, "DeleteRecord", "Evaluation", "eval_001"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(summary, chaincodeID) || !strings.Contains(summary, "DeleteRecord Evaluation eval_001") {
+		t.Errorf("提案说明 %q 缺少链码或参数", summary)
+	}
+	// 瞬态数据只列键名，不显示个人内容
+	if !strings.Contains(summary, model.PersonalTransient) || strings.Contains(summary, "课堂表现积极") {
+		t.Errorf("提案说明 %q 应只列出瞬态数据键名", summary)
+	}
+}
+
+func TestSignOfflineRejectsTampering(t *testing.T) {
+	signer, _ := testOfflineSigner(t)
+	tests := []struct {
+		name    string
+		request func(t *testing.T) *OfflineRequest
+	}{
+		{"摘要被替换", func(t *testing.T) *OfflineRequest {
+			request := testProposalRequest(t, "UploadEvaluation", "{}")
+			other := testProposalRequest(t, "EraseUserData", "user_001")
+			request.Digest = other.Digest
+			return request
+		}},
+		{"提案内容被替换", func(t *testing.T) *OfflineRequest {
+			request := testProposalRequest(t, "UploadEvaluation", "{}")
+			request.Bytes = testProposalRequest(t, "EraseUserData", "user_001").Bytes
+			return request
+		}},
+		{"交易ID不一致", func(t *testing.T) *OfflineRequest {
+			request := testTransactionRequest(t)
+			request.TransactionID = "other"
+			return request
+		}},
+		{"提交状态请求摘要被篡改", func(t *testing.T) *OfflineRequest {
+			request := testCommitRequest(t)
+			request.Digest[0] ^= 0xff
+			return request
+		}},
+		{"阶段错误", func(t *testing.T) *OfflineRequest {
+			request := testCommitRequest(t)
+			request.Stage = offlineStageProposal
+			return request
+		}},
+		{"未知阶段", func(t *testing.T) *OfflineRequest {
+			request := testCommitRequest(t)
+			request.Stage = "unknown"
+			return request
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := tt.request(t)
+			if err := SignOfflineRequest(request, signer); err == nil {
+				t.Fatal("被篡改的请求应拒绝签名")
+			}
+			if request.Signature != nil {
+				t.Error("拒绝签名时不应写入签名")
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ===================== 运维HTTP服务 =====================
+
+// 收到退出信号后等待进行中请求结束的最长时间
+const opsShutdownTimeout = 10 * time.Second
+
+// readyResponse /readyz 的响应内容
+type readyResponse struct {
+	Ready bool         `json:"ready"`
+	Error string       `json:"error,omitempty"`
+	Peers []PeerStatus `json:"peers"`
+}
+
+// OperationsHandler 返回运维HTTP处理器：
+// /metrics 为Prometheus指标，/healthz 为存活检查，/readyz 实际查询网关节点判断是否可以处理请求
+func (c *Client) OperationsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(c.metrics.registry, promhttp.HandlerOpts{}))
+
+	// 存活检查只说明进程可以响应，不访问网关节点，避免节点故障导致进程被重启
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		resp := readyResponse{Ready: true}
+		if err := c.Ready(r.Context()); err != nil {
+			resp.Ready, resp.Error = false, err.Error()
+		}
+		resp.Peers = c.Status()
+
+		w.Header().Set("Content-Type", "application/json")
+		if !resp.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(&resp)
+	})
+	return mux
+}
+
+// ServeOperations 在 addr 上启动运维HTTP服务，返回实际监听地址和停止函数
+func (c *Client) ServeOperations(addr string) (string, func(context.Context) error, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", nil, fmt.Errorf("监听运维端口失败: %v", err)
+	}
+
+	server := &http.Server{Handler: c.OperationsHandler()}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("运维HTTP服务异常退出: %v", err)
+		}
+	}()
+	return listener.Addr().String(), server.Shutdown, nil
+}
+
+// runOps 连接网关并启动运维HTTP服务，直到收到中断信号
+func runOps(args []string) error {
+	flags := flag.NewFlagSet("ops", flag.ExitOnError)
+	addr := flags.String("addr", ":9464", "运维HTTP服务监听地址")
+	flags.Parse(args)
+
+	client, err := NewClient()
+	if err != nil {
+		return fmt.Errorf("创建客户端失败: %v", err)
+	}
+
+	listenAddr, stop, err := client.ServeOperations(*addr)
+	if err != nil {
+		client.Close()
+		return err
+	}
+	log.Printf("运维HTTP服务已启动: http://%s/metrics", listenAddr)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+
+	ctx, cancel := context.WithTimeout(context.Background(), opsShutdownTimeout)
+	defer cancel()
+	if err := stop(ctx); err != nil {
+		log.Printf("关闭运维HTTP服务失败: %v", err)
+	}
+	return client.Shutdown(ctx)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestReadyzHandler(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	tests := []struct {
+		name    string
+		errs    map[string]error
+		code    int
+		healthy map[string]bool
+	}{
+		{"节点可用", nil, http.StatusOK, map[string]bool{"peer0:7051": true, "peer1:7051": true}},
+		{"切换到可用节点", map[string]error{"peer0:7051": unavailable}, http.StatusOK, map[string]bool{"peer0:7051": false, "peer1:7051": true}},
+		{"全部不可用", map[string]error{"peer0:7051": unavailable, "peer1:7051": unavailable}, http.StatusServiceUnavailable, map[string]bool{"peer0:7051": false, "peer1:7051": false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, _ := newFakePool(t, RoundRobin, []string{"peer0:7051", "peer1:7051"}, tt.errs)
+			c := &Client{pool: pool}
+			c.metrics = newClientMetrics(c)
+
+			rec := httptest.NewRecorder()
+			c.OperationsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tt.code || rec.Header().Get("Content-Type") != "application/json" {
+				t.Fatalf("状态码 = %d，Content-Type = %q，期望 %d", rec.Code, rec.Header().Get("Content-Type"), tt.code)
+			}
+			var resp readyResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Ready != (tt.code == http.StatusOK) || (resp.Error == "") != resp.Ready {
+				t.Errorf("响应 = %+v", resp)
+			}
+			healthy := make(map[string]bool)
+			for _, peer := range resp.Peers {
+				healthy[peer.Endpoint] = peer.Healthy
+			}
+			if !reflect.DeepEqual(healthy, tt.healthy) {
+				t.Errorf("节点状态 = %v，期望 %v", healthy, tt.healthy)
+			}
+		})
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	c := newTestEmbeddedClient(t)
+	evaluation := testEvaluation("eval_001", "user_001")
+	if _, err := c.UploadEvaluation(evaluation); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UploadEvaluation(evaluation); err == nil {
+		t.Fatal("重复上传应当失败")
+	}
+
+	server := httptest.NewServer(c.OperationsHandler())
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`edu_fabric_client_request_duration_seconds_count{method="UploadEvaluation"} 2`,
+		`edu_fabric_client_errors_total{class="endorse",method="UploadEvaluation"} 1`,
+		`edu_fabric_client_in_flight_requests{method="UploadEvaluation"} 0`,
+		`edu_fabric_client_pending_transactions 0`,
+		`edu_fabric_certificate_expiry_timestamp_seconds{kind="identity",name="default"}`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("指标中缺少 %s", want)
+		}
+	}
+
+	// 存活检查不访问网关节点
+	resp, err = http.Get(server.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("/healthz 状态码 = %d", resp.StatusCode)
+	}
+}
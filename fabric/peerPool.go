@@ -0,0 +1,521 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+)
+
+// 连续失败达到该次数后丢弃旧连接并重新拨号
+const reconnectThreshold = 3
+
+// ===================== 网关节点池 =====================
+
+// PeerStatus 网关节点连接状态
+type PeerStatus struct {
+	Endpoint            string        `json:"endpoint"`
+	Healthy             bool          `json:"healthy"`
+	State               string        `json:"state"`   // gRPC连接状态
+	Latency             time.Duration `json:"latency"` // 最近健康检查延迟（指数平均）
+	LastCheck           time.Time     `json:"lastCheck"`
+	LastError           string        `json:"lastError,omitempty"`
+	ConsecutiveFailures int           `json:"consecutiveFailures"`
+}
+
+// gatewayIdentity 用于签名交易的身份，label 为空表示客户端默认身份
+// 证书续期后可原地更新，已创建的Gateway需通过 forgetIdentity 丢弃
+type gatewayIdentity struct {
+	label string
+
+	mu   sync.RWMutex
+	id   identity.Identity
+	sign identity.Sign
+}
+
+func (gid *gatewayIdentity) credentials() (identity.Identity, identity.Sign) {
+	gid.mu.RLock()
+	defer gid.mu.RUnlock()
+	return gid.id, gid.sign
+}
+
+func (gid *gatewayIdentity) update(id identity.Identity, sign identity.Sign) {
+	gid.mu.Lock()
+	defer gid.mu.Unlock()
+	gid.id, gid.sign = id, sign
+}
+
+// peerGateway 某个身份在某个节点连接上的Gateway
+type peerGateway struct {
+	gateway  *client.Gateway
+	network  *client.Network
+	contract *client.Contract
+}
+
+// peerConn 单个网关节点的连接及健康信息
+// 同一连接上按身份懒加载Gateway，多个身份共享gRPC连接
+type peerConn struct {
+	config PeerConfig
+
+	mu        sync.RWMutex
+	conn      *grpc.ClientConn
+	gateways  map[string]*peerGateway
+	healthy   bool
+	latency   time.Duration
+	lastCheck time.Time
+	lastErr   error
+	failures  int
+}
+
+// peerPool 管理多个网关节点，负责选择、故障转移和后台健康检查
+type peerPool struct {
+	options  *clientOptions
+	identity *gatewayIdentity // 默认身份，同时用于健康检查
+	peers    []*peerConn
+	next     uint32
+	metadata func(ctx context.Context, gw *peerGateway) error // 健康检查查询，测试中替换为假网关
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newPeerPool(options *clientOptions, defaultIdentity *gatewayIdentity) (*peerPool, error) {
+	if len(options.peers) == 0 {
+		return nil, fmt.Errorf("未配置网关节点")
+	}
+
+	pool := &peerPool{
+		options:  options,
+		identity: defaultIdentity,
+		metadata: evaluateMetadata,
+		stop:     make(chan struct{}),
+	}
+
+	// 单个节点连接失败不影响客户端创建，由健康检查负责重连
+	connected := 0
+	for _, cfg := range options.peers {
+		peer := &peerConn{config: cfg}
+		if err := pool.connect(peer); err != nil {
+			log.Printf("连接网关节点 %s 失败: %v", cfg.Endpoint, err)
+			peer.lastErr = err
+		} else {
+			peer.healthy = true
+			connected++
+		}
+		pool.peers = append(pool.peers, peer)
+	}
+	if connected == 0 {
+		pool.close()
+		return nil, fmt.Errorf("所有网关节点均连接失败")
+	}
+
+	pool.wg.Add(1)
+	go pool.healthLoop()
+	return pool, nil
+}
+
+// connect 为节点建立gRPC连接和默认身份的Gateway，替换已有连接
+func (p *peerPool) connect(peer *peerConn) error {
+	connection, err := newGrpcConnection(peer.config)
+	if err != nil {
+		return err
+	}
+
+	gw, err := newPeerGateway(connection, p.identity)
+	if err != nil {
+		connection.Close()
+		return err
+	}
+
+	peer.mu.Lock()
+	oldGateways, oldConn := peer.gateways, peer.conn
+	peer.conn = connection
+	peer.gateways = map[string]*peerGateway{p.identity.label: gw}
+	peer.mu.Unlock()
+
+	for _, old := range oldGateways {
+		old.gateway.Close()
+	}
+	if oldConn != nil {
+		oldConn.Close()
+	}
+	return nil
+}
+
+// gatewayFor 返回指定身份在节点上的Gateway，不存在时在现有连接上创建
+func (p *peerPool) gatewayFor(peer *peerConn, gid *gatewayIdentity) (*peerGateway, error) {
+	peer.mu.RLock()
+	gw, ok := peer.gateways[gid.label]
+	conn := peer.conn
+	peer.mu.RUnlock()
+	if ok {
+		return gw, nil
+	}
+	if conn == nil {
+		return nil, fmt.Errorf("网关节点 %s 未连接", peer.config.Endpoint)
+	}
+
+	gw, err := newPeerGateway(conn, gid)
+	if err != nil {
+		return nil, err
+	}
+
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+	// 期间连接可能已被替换，旧连接上的Gateway不再缓存
+	if peer.conn != conn {
+		gw.gateway.Close()
+		return nil, fmt.Errorf("网关节点 %s 正在重连", peer.config.Endpoint)
+	}
+	if existing, ok := peer.gateways[gid.label]; ok {
+		gw.gateway.Close()
+		return existing, nil
+	}
+	peer.gateways[gid.label] = gw
+	return gw, nil
+}
+
+// forgetIdentity 关闭并移除指定身份在所有节点上的Gateway
+func (p *peerPool) forgetIdentity(label string) {
+	for _, peer := range p.peers {
+		peer.mu.Lock()
+		gw, ok := peer.gateways[label]
+		delete(peer.gateways, label)
+		peer.mu.Unlock()
+		if ok {
+			gw.gateway.Close()
+		}
+	}
+}
+
+func newPeerGateway(connection *grpc.ClientConn, gid *gatewayIdentity) (*peerGateway, error) {
+	options := []client.ConnectOption{
+		client.WithClientConnection(connection),
+		client.WithEvaluateTimeout(defaultEvaluateTimeout),
+		client.WithEndorseTimeout(defaultEndorseTimeout),
+		client.WithSubmitTimeout(defaultSubmitTimeout),
+		client.WithCommitStatusTimeout(defaultCommitStatusTimeout),
+	}
+	// 离线签名模式下没有签名函数，需使用 NewSigned* 导入签名
+	id, sign := gid.credentials()
+	if sign != nil {
+		options = append(options, client.WithSign(sign))
+	}
+
+	gw, err := client.Connect(id, options...)
+	if err != nil {
+		return nil, fmt.Errorf("连接网关失败: %v", err)
+	}
+
+	network := gw.GetNetwork(channelName)
+	return &peerGateway{
+		gateway:  gw,
+		network:  network,
+		contract: network.GetContract(chaincodeID),
+	}, nil
+}
+
+// pick 按策略选择一个未尝试过的节点，优先健康节点
+func (p *peerPool) pick(tried map[*peerConn]bool) *peerConn {
+	var candidates, fallback []*peerConn
+	for _, peer := range p.peers {
+		if tried[peer] {
+			continue
+		}
+		peer.mu.RLock()
+		ready, healthy := peer.conn != nil, peer.healthy
+		peer.mu.RUnlock()
+		if !ready {
+			continue
+		}
+		if healthy {
+			candidates = append(candidates, peer)
+		} else {
+			fallback = append(fallback, peer)
+		}
+	}
+	// 没有健康节点时仍尝试其余节点，健康状态可能已过期
+	if len(candidates) == 0 {
+		candidates = fallback
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	if p.options.strategy == LeastLatency {
+		best := candidates[0]
+		for _, peer := range candidates[1:] {
+			if peer.currentLatency() < best.currentLatency() {
+				best = peer
+			}
+		}
+		return best
+	}
+
+	n := atomic.AddUint32(&p.next, 1)
+	return candidates[int(n-1)%len(candidates)]
+}
+
+// do 在选中的节点上执行调用，节点不可达时自动切换到下一个节点
+// ctx 已取消或到期时不再切换，超时也不计为节点故障
+func (p *peerPool) do(ctx context.Context, fn func(peer *peerConn) error) error {
+	tried := make(map[*peerConn]bool)
+	var lastErr error
+	for {
+		peer := p.pick(tried)
+		if peer == nil {
+			break
+		}
+		tried[peer] = true
+
+		err := fn(peer)
+		if err == nil {
+			return nil
+		}
+		if !isUnavailable(err) || ctx.Err() != nil {
+			return err
+		}
+		log.Printf("网关节点 %s 不可用，尝试切换: %v", peer.config.Endpoint, err)
+		peer.markFailure(err)
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		return fmt.Errorf("没有可用的网关节点")
+	}
+	return fmt.Errorf("所有网关节点均不可用: %w", lastErr)
+}
+
+// healthLoop 定期检查所有节点
+func (p *peerPool) healthLoop() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.options.healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			for _, peer := range p.peers {
+				p.check(peer)
+			}
+		}
+	}
+}
+
+// check 通过一次低开销的元数据查询检查节点可达性，必要时重新连接
+func (p *peerPool) check(peer *peerConn) {
+	peer.mu.RLock()
+	conn, failures := peer.conn, peer.failures
+	peer.mu.RUnlock()
+
+	if conn == nil || conn.GetState() == connectivity.Shutdown || failures >= reconnectThreshold {
+		if err := p.connect(peer); err != nil {
+			peer.markFailure(err)
+			return
+		}
+		peer.mu.RLock()
+		conn = peer.conn
+		peer.mu.RUnlock()
+	}
+	if conn.GetState() == connectivity.TransientFailure {
+		conn.Connect()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.options.healthCheckTimeout)
+	defer cancel()
+
+	latency, err := p.probe(ctx, peer)
+	if err != nil {
+		peer.markFailure(err)
+		return
+	}
+	peer.markHealthy(latency)
+}
+
+// probe 对节点执行一次低开销的元数据查询，返回耗时
+func (p *peerPool) probe(ctx context.Context, peer *peerConn) (time.Duration, error) {
+	peer.mu.RLock()
+	conn := peer.conn
+	peer.mu.RUnlock()
+	if conn == nil {
+		return 0, fmt.Errorf("网关节点 %s 未连接", peer.config.Endpoint)
+	}
+
+	// 离线签名模式无法签名查询提案，只根据连接状态判断
+	if _, sign := p.identity.credentials(); sign == nil {
+		if state := conn.GetState(); state != connectivity.Ready && state != connectivity.Idle {
+			return 0, fmt.Errorf("连接状态 %s", state)
+		}
+		return 0, nil
+	}
+
+	gw, err := p.gatewayFor(peer, p.identity)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	if err := p.metadata(ctx, gw); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// evaluateMetadata 查询链码元数据，不读取账本状态
+func evaluateMetadata(ctx context.Context, gw *peerGateway) error {
+	proposal, err := gw.contract.NewProposal("org.hyperledger.fabric:GetMetadata")
+	if err == nil {
+		_, err = proposal.EvaluateWithContext(ctx)
+	}
+	return err
+}
+
+// status 返回所有节点当前状态
+func (p *peerPool) status() []PeerStatus {
+	result := make([]PeerStatus, 0, len(p.peers))
+	for _, peer := range p.peers {
+		peer.mu.RLock()
+		s := PeerStatus{
+			Endpoint:            peer.config.Endpoint,
+			Healthy:             peer.healthy,
+			State:               connectivity.Shutdown.String(),
+			Latency:             peer.latency,
+			LastCheck:           peer.lastCheck,
+			ConsecutiveFailures: peer.failures,
+		}
+		if peer.conn != nil {
+			s.State = peer.conn.GetState().String()
+		}
+		if peer.lastErr != nil {
+			s.LastError = peer.lastErr.Error()
+		}
+		peer.mu.RUnlock()
+		result = append(result, s)
+	}
+	return result
+}
+
+// close 停止健康检查并关闭所有连接
+func (p *peerPool) close() {
+	close(p.stop)
+	p.wg.Wait()
+
+	for _, peer := range p.peers {
+		peer.mu.Lock()
+		for _, gw := range peer.gateways {
+			gw.gateway.Close()
+		}
+		if peer.conn != nil {
+			peer.conn.Close()
+		}
+		peer.gateways, peer.conn = nil, nil
+		peer.healthy = false
+		peer.mu.Unlock()
+	}
+}
+
+func (peer *peerConn) currentLatency() time.Duration {
+	peer.mu.RLock()
+	defer peer.mu.RUnlock()
+	return peer.latency
+}
+
+func (peer *peerConn) markHealthy(latency time.Duration) {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+
+	// 指数平均，避免单次抖动影响选择
+	if peer.latency == 0 {
+		peer.latency = latency
+	} else {
+		peer.latency = (peer.latency*7 + latency*3) / 10
+	}
+	peer.healthy = true
+	peer.failures = 0
+	peer.lastErr = nil
+	peer.lastCheck = time.Now()
+}
+
+func (peer *peerConn) markFailure(err error) {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+
+	peer.healthy = false
+	peer.failures++
+	peer.lastErr = err
+	peer.lastCheck = time.Now()
+}
+
+// isUnavailable 判断错误是否由节点不可达引起（可安全切换节点重试）
+func isUnavailable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// ===================== 客户端调用 =====================
+
+// evaluate 在可用节点上执行查询，每次尝试记录为 evaluate 子span
+func (c *Client) evaluate(ctx context.Context, txName string, args ...string) ([]byte, error) {
+	if c.embedded != nil {
+		return c.embedded.evaluate(ctx, c.identity, txName, args)
+	}
+
+	var result []byte
+	err := c.pool.do(ctx, func(peer *peerConn) error {
+		gw, err := c.pool.gatewayFor(peer, c.identity)
+		if err != nil {
+			return err
+		}
+
+		ctx, span := startPhase(ctx, "evaluate", attribute.String("fabric.peer", peer.config.Endpoint))
+		ctx, cancel := phaseContext(ctx, phaseEvaluate)
+		defer cancel()
+
+		proposal, err := gw.contract.NewProposal(txName, proposalOptions(ctx, args)...)
+		if err == nil {
+			result, err = proposal.EvaluateWithContext(ctx)
+		}
+		endSpan(span, err)
+		return err
+	})
+	return result, chaincodeError(err)
+}
+
+// Status 返回各网关节点的连接状态
+func (c *Client) Status() []PeerStatus {
+	return c.pool.status()
+}
+
+// Ready 检查是否至少有一个网关节点可以实际处理查询
+func (c *Client) Ready(ctx context.Context) error {
+	if c.embedded != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.pool.options.healthCheckTimeout)
+	defer cancel()
+
+	return c.pool.do(ctx, func(peer *peerConn) error {
+		latency, err := c.pool.probe(ctx, peer)
+		if err != nil {
+			return err
+		}
+		peer.markHealthy(latency)
+		return nil
+	})
+}
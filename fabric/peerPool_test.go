@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// fakeGateway 按节点地址返回预设错误并记录调用顺序，代替真实的网关节点
+type fakeGateway struct {
+	mu       sync.Mutex
+	errs     map[string]error
+	calls    []string
+	gateways map[*peerGateway]string
+}
+
+func (f *fakeGateway) call(endpoint string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, endpoint)
+	return f.errs[endpoint]
+}
+
+func (f *fakeGateway) metadata(ctx context.Context, gw *peerGateway) error {
+	return f.call(f.gateways[gw])
+}
+
+// newFakePool 创建连接到假网关的节点池，节点不拨号、不启动后台健康检查
+func newFakePool(t *testing.T, strategy SelectionStrategy, endpoints []string, errs map[string]error) (*peerPool, *fakeGateway) {
+	t.Helper()
+	fake := &fakeGateway{errs: errs, gateways: make(map[*peerGateway]string)}
+	options := defaultClientOptions()
+	options.strategy = strategy
+	pool := &peerPool{
+		options:  options,
+		identity: &gatewayIdentity{sign: func(digest []byte) ([]byte, error) { return nil, nil }},
+		metadata: fake.metadata,
+		stop:     make(chan struct{}),
+	}
+	for _, endpoint := range endpoints {
+		conn, err := grpc.NewClient("passthrough:///"+endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		gw := &peerGateway{}
+		fake.gateways[gw] = endpoint
+		pool.peers = append(pool.peers, &peerConn{
+			config:   PeerConfig{Endpoint: endpoint},
+			conn:     conn,
+			gateways: map[string]*peerGateway{"": gw},
+			healthy:  true,
+		})
+	}
+	t.Cleanup(func() {
+		for _, peer := range pool.peers {
+			peer.conn.Close()
+		}
+	})
+	return pool, fake
+}
+
+func peerHealth(pool *peerPool) map[string]bool {
+	health := make(map[string]bool)
+	for _, s := range pool.status() {
+		health[s.Endpoint] = s.Healthy
+	}
+	return health
+}
+
+// 轮询计数在同一次调用的每次切换中也递增，故障转移顺序跳过相邻节点
+func TestPeerPoolFailover(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	deadline := status.Error(codes.DeadlineExceeded, "context deadline exceeded")
+	endpoints := []string{"peer0:7051", "peer1:7051", "peer2:7051"}
+	tests := []struct {
+		name      string
+		strategy  SelectionStrategy
+		latency   map[string]time.Duration
+		unhealthy []string
+		errs      map[string]error
+		wantCalls []string
+		wantErr   string
+		healthy   map[string]bool
+	}{
+		{
+			name:      "首个节点可用",
+			wantCalls: []string{"peer0:7051"},
+			healthy:   map[string]bool{"peer0:7051": true, "peer1:7051": true, "peer2:7051": true},
+		},
+		{
+			name:      "节点不可达时切换",
+			errs:      map[string]error{"peer0:7051": unavailable},
+			wantCalls: []string{"peer0:7051", "peer2:7051"},
+			healthy:   map[string]bool{"peer0:7051": false, "peer1:7051": true, "peer2:7051": true},
+		},
+		{
+			name:      "节点超时时切换",
+			errs:      map[string]error{"peer0:7051": deadline, "peer2:7051": unavailable},
+			wantCalls: []string{"peer0:7051", "peer2:7051", "peer1:7051"},
+			healthy:   map[string]bool{"peer0:7051": false, "peer1:7051": true, "peer2:7051": false},
+		},
+		{
+			name:      "全部节点不可用",
+			errs:      map[string]error{"peer0:7051": unavailable, "peer1:7051": unavailable, "peer2:7051": deadline},
+			wantCalls: []string{"peer0:7051", "peer2:7051", "peer1:7051"},
+			wantErr:   "所有网关节点均不可用",
+			healthy:   map[string]bool{"peer0:7051": false, "peer1:7051": false, "peer2:7051": false},
+		},
+		{
+			name:      "链码错误不切换节点",
+			errs:      map[string]error{"peer0:7051": status.Error(codes.Aborted, "chaincode response 500")},
+			wantCalls: []string{"peer0:7051"},
+			wantErr:   "chaincode response 500",
+			healthy:   map[string]bool{"peer0:7051": true, "peer1:7051": true, "peer2:7051": true},
+		},
+		{
+			name:      "优先尝试健康节点",
+			unhealthy: []string{"peer0:7051", "peer1:7051"},
+			errs:      map[string]error{"peer2:7051": unavailable},
+			wantCalls: []string{"peer2:7051", "peer1:7051"},
+			healthy:   map[string]bool{"peer0:7051": false, "peer1:7051": false, "peer2:7051": false},
+		},
+		{
+			name:      "最低延迟优先",
+			strategy:  LeastLatency,
+			latency:   map[string]time.Duration{"peer0:7051": 30 * time.Millisecond, "peer1:7051": 20 * time.Millisecond, "peer2:7051": 10 * time.Millisecond},
+			errs:      map[string]error{"peer2:7051": unavailable},
+			wantCalls: []string{"peer2:7051", "peer1:7051"},
+			healthy:   map[string]bool{"peer0:7051": true, "peer1:7051": true, "peer2:7051": false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, fake := newFakePool(t, tt.strategy, endpoints, tt.errs)
+			for _, peer := range pool.peers {
+				peer.latency = tt.latency[peer.config.Endpoint]
+				for _, endpoint := range tt.unhealthy {
+					if peer.config.Endpoint == endpoint {
+						peer.healthy = false
+					}
+				}
+			}
+
+			err := pool.do(context.Background(), func(peer *peerConn) error {
+				return fake.call(peer.config.Endpoint)
+			})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("调用失败: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("错误 = %v，期望包含 %q", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(fake.calls, tt.wantCalls) {
+				t.Errorf("调用顺序 = %v，期望 %v", fake.calls, tt.wantCalls)
+			}
+			if got := peerHealth(pool); !reflect.DeepEqual(got, tt.healthy) {
+				t.Errorf("节点健康状态 = %v，期望 %v", got, tt.healthy)
+			}
+		})
+	}
+}
+
+// 轮询策略下连续调用依次落在不同节点
+func TestPeerPoolRoundRobin(t *testing.T) {
+	pool, fake := newFakePool(t, RoundRobin, []string{"peer0:7051", "peer1:7051"}, nil)
+	for i := 0; i < 4; i++ {
+		if err := pool.do(context.Background(), func(peer *peerConn) error { return fake.call(peer.config.Endpoint) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"peer0:7051", "peer1:7051", "peer0:7051", "peer1:7051"}
+	if !reflect.DeepEqual(fake.calls, want) {
+		t.Errorf("调用顺序 = %v，期望 %v", fake.calls, want)
+	}
+}
+
+// 调用方的 ctx 已取消或到期时不再切换节点
+func TestPeerPoolStopsFailoverOnContextDone(t *testing.T) {
+	pool, fake := newFakePool(t, RoundRobin, []string{"peer0:7051", "peer1:7051"}, map[string]error{
+		"peer0:7051": status.Error(codes.DeadlineExceeded, "context deadline exceeded"),
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	err := pool.do(ctx, func(peer *peerConn) error {
+		cancel()
+		return fake.call(peer.config.Endpoint)
+	})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("错误 = %v，期望原样返回 DeadlineExceeded", err)
+	}
+	if len(fake.calls) != 1 {
+		t.Errorf("调用顺序 = %v，期望只尝试一个节点", fake.calls)
+	}
+	if !peerHealth(pool)["peer0:7051"] {
+		t.Error("调用方超时不应计为节点故障")
+	}
+}
+
+func TestPeerPoolHealthProbe(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	tests := []struct {
+		name      string
+		errs      map[string]error
+		wantReady bool
+		wantCalls []string
+		healthy   map[string]bool
+	}{
+		{"全部可用", nil, true, []string{"peer0:7051"}, map[string]bool{"peer0:7051": true, "peer1:7051": true}},
+		{"切换到可用节点", map[string]error{"peer0:7051": unavailable}, true, []string{"peer0:7051", "peer1:7051"}, map[string]bool{"peer0:7051": false, "peer1:7051": true}},
+		{"全部不可用", map[string]error{"peer0:7051": unavailable, "peer1:7051": unavailable}, false, []string{"peer0:7051", "peer1:7051"}, map[string]bool{"peer0:7051": false, "peer1:7051": false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, fake := newFakePool(t, RoundRobin, []string{"peer0:7051", "peer1:7051"}, tt.errs)
+			c := &Client{pool: pool}
+
+			err := c.Ready(context.Background())
+			if (err == nil) != tt.wantReady {
+				t.Fatalf("Ready = %v，期望就绪 %v", err, tt.wantReady)
+			}
+			if !tt.wantReady && !errors.Is(err, unavailable) {
+				t.Errorf("错误 = %v，期望包含最后一个节点的错误", err)
+			}
+			if !reflect.DeepEqual(fake.calls, tt.wantCalls) {
+				t.Errorf("探测顺序 = %v，期望 %v", fake.calls, tt.wantCalls)
+			}
+			if got := peerHealth(pool); !reflect.DeepEqual(got, tt.healthy) {
+				t.Errorf("节点健康状态 = %v，期望 %v", got, tt.healthy)
+			}
+		})
+	}
+}
+
+// 后台健康检查逐个探测节点，恢复的节点重新标记为健康
+func TestPeerPoolCheckRecovers(t *testing.T) {
+	errs := map[string]error{"peer0:7051": status.Error(codes.Unavailable, "connection refused")}
+	pool, fake := newFakePool(t, RoundRobin, []string{"peer0:7051"}, errs)
+	peer := pool.peers[0]
+
+	pool.check(peer)
+	s := pool.status()[0]
+	if s.Healthy || s.ConsecutiveFailures != 1 || !strings.Contains(s.LastError, "connection refused") {
+		t.Fatalf("探测失败后的状态 = %+v", s)
+	}
+
+	fake.mu.Lock()
+	delete(fake.errs, "peer0:7051")
+	fake.mu.Unlock()
+	pool.check(peer)
+	s = pool.status()[0]
+	if !s.Healthy || s.ConsecutiveFailures != 0 || s.LastError != "" || s.LastCheck.IsZero() {
+		t.Errorf("恢复后的状态 = %+v", s)
+	}
+	if want := []string{"peer0:7051", "peer0:7051"}; !reflect.DeepEqual(fake.calls, want) {
+		t.Errorf("探测顺序 = %v，期望 %v", fake.calls, want)
+	}
+}
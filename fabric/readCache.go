@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"edu/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 事件订阅中断后重新订阅的等待时间
+const cacheResubscribeDelay = 5 * time.Second
+
+// ===================== 查询缓存 =====================
+
+// CacheStats 缓存命中统计
+type CacheStats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Expired       uint64 `json:"expired"`       // 因TTL到期未命中的次数
+	Invalidations uint64 `json:"invalidations"` // 被链码事件失效的条目数
+	Flushes       uint64 `json:"flushes"`       // 事件订阅中断导致的整体清空次数
+	Entries       int    `json:"entries"`
+}
+
+// cacheEntry 缓存的查询结果，保存原始JSON，每次读取重新解析以免调用方修改缓存
+type cacheEntry struct {
+	result  []byte
+	expires time.Time
+}
+
+// readCache 按用户和记录缓存查询结果，由链码事件精确失效
+// 列表查询的键：身份|类型|用户；单条查询的键：类型|记录 下再按 身份|用户 区分
+type readCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	lists   map[string]*cacheEntry
+	records map[string]map[string]*cacheEntry
+
+	hits, misses, expired, invalidations, flushes uint64
+
+	// epoch 每次失效或清空时递增。查询期间若发生过失效，结果可能已过期，不写入缓存
+	epoch uint64
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newReadCache(ttl time.Duration) *readCache {
+	return &readCache{
+		ttl:     ttl,
+		lists:   make(map[string]*cacheEntry),
+		records: make(map[string]map[string]*cacheEntry),
+	}
+}
+
+func listCacheKey(identityLabel, docType, userID string) string {
+	return identityLabel + "|" + docType + "|" + userID
+}
+
+func recordCacheKey(docType, recordID string) string {
+	return docType + "|" + recordID
+}
+
+// getList 读取列表查询缓存，同时返回当前 epoch 供未命中时回写使用
+func (rc *readCache) getList(key string) ([]byte, uint64, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	result, ok := rc.lookup(rc.lists, key)
+	return result, rc.epoch, ok
+}
+
+func (rc *readCache) putList(key string, result []byte, epoch uint64) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if epoch != rc.epoch {
+		return
+	}
+	rc.lists[key] = &cacheEntry{result: result, expires: time.Now().Add(rc.ttl)}
+}
+
+// getRecord 读取单条记录查询缓存
+func (rc *readCache) getRecord(recordKey, viewKey string) ([]byte, uint64, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	result, ok := rc.lookup(rc.records[recordKey], viewKey)
+	return result, rc.epoch, ok
+}
+
+func (rc *readCache) putRecord(recordKey, viewKey string, result []byte, epoch uint64) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if epoch != rc.epoch {
+		return
+	}
+	views, ok := rc.records[recordKey]
+	if !ok {
+		views = make(map[string]*cacheEntry)
+		rc.records[recordKey] = views
+	}
+	views[viewKey] = &cacheEntry{result: result, expires: time.Now().Add(rc.ttl)}
+}
+
+// lookup 调用方需持有锁
+func (rc *readCache) lookup(entries map[string]*cacheEntry, key string) ([]byte, bool) {
+	entry, ok := entries[key]
+	if !ok {
+		rc.misses++
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(entries, key)
+		rc.expired++
+		rc.misses++
+		return nil, false
+	}
+	rc.hits++
+	return entry.result, true
+}
+
+// invalidate 根据记录变更事件失效受影响用户的列表查询和该记录的单条查询
+// 授权变更影响该学生所有记录对被授权身份的可见性，擦除一次修改该学生的全部记录，都直接清空缓存
+func (rc *readCache) invalidate(event *RecordEvent) {
+	if event.DocType == model.DocTypeAccessGrant || event.DocType == model.DocTypeUserData {
+		rc.flush()
+		return
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.epoch++
+
+	users := map[string]bool{event.UserID: true}
+	if event.PreviousUserID != "" {
+		users[event.PreviousUserID] = true
+	}
+	// 列表键带身份前缀，需要遍历；单条记录键可直接定位
+	for key := range rc.lists {
+		for userID := range users {
+			if hasListSuffix(key, event.DocType, userID) {
+				delete(rc.lists, key)
+				rc.invalidations++
+			}
+		}
+	}
+
+	recordKey := recordCacheKey(event.DocType, event.RecordID)
+	rc.invalidations += uint64(len(rc.records[recordKey]))
+	delete(rc.records, recordKey)
+}
+
+func hasListSuffix(key, docType, userID string) bool {
+	suffix := "|" + docType + "|" + userID
+	return len(key) >= len(suffix) && key[len(key)-len(suffix):] == suffix
+}
+
+// flush 清空全部缓存，事件可能丢失时使用
+func (rc *readCache) flush() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.lists = make(map[string]*cacheEntry)
+	rc.records = make(map[string]map[string]*cacheEntry)
+	rc.flushes++
+	rc.epoch++
+}
+
+func (rc *readCache) stats() CacheStats {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	entries := len(rc.lists)
+	for _, views := range rc.records {
+		entries += len(views)
+	}
+	return CacheStats{
+		Hits:          rc.hits,
+		Misses:        rc.misses,
+		Expired:       rc.expired,
+		Invalidations: rc.invalidations,
+		Flushes:       rc.flushes,
+		Entries:       entries,
+	}
+}
+
+// ===================== 事件驱动失效 =====================
+
+// start 启动链码事件订阅。订阅中断期间可能漏掉事件，
+// 因此每次（重新）订阅前清空缓存，从当前区块开始接收事件即可保证不读到过期数据
+func (rc *readCache) start(c *Client) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rc.cancel = cancel
+	rc.done = make(chan struct{})
+
+	go func() {
+		defer close(rc.done)
+		for {
+			rc.flush()
+
+			events, err := c.ChaincodeEvents(ctx)
+			if err == nil {
+				for event := range events {
+					if event.EventName != model.RecordEventName {
+						continue
+					}
+					var recordEvent RecordEvent
+					if err := json.Unmarshal(event.Payload, &recordEvent); err != nil {
+						log.Printf("解析链码事件失败: %v", err)
+						continue
+					}
+					rc.invalidate(&recordEvent)
+				}
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("缓存失效事件订阅中断，%v 后重新订阅: %v", cacheResubscribeDelay, err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(cacheResubscribeDelay):
+			}
+		}
+	}()
+}
+
+// stop 停止事件订阅
+func (rc *readCache) stop() {
+	if rc.cancel != nil {
+		rc.cancel()
+		<-rc.done
+	}
+}
+
+// ===================== 客户端缓存查询 =====================
+
+// Consistent 返回绕过缓存的客户端视图，用于必须读取最新账本状态的查询
+func (c *Client) Consistent() *Client {
+	view := *c
+	view.bypassCache = true
+	return &view
+}
+
+// CacheStats 返回缓存命中统计，未启用缓存时返回零值
+func (c *Client) CacheStats() CacheStats {
+	if c.cache == nil {
+		return CacheStats{}
+	}
+	return c.cache.stats()
+}
+
+// cachedListQuery 按用户缓存的列表查询
+func (c *Client) cachedListQuery(ctx context.Context, docType, txName, userID string) ([]byte, error) {
+	if c.readsByGrant(userID) {
+		// 每次授权读取都要留下访问日志，不使用缓存
+		return c.submitRead(ctx, txName, userID)
+	}
+	if c.cache == nil || c.bypassCache {
+		return c.evaluate(ctx, txName, userID)
+	}
+
+	key := listCacheKey(c.identity.label, docType, userID)
+	result, epoch, ok := c.cache.getList(key)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("cache.hit", ok))
+	if ok {
+		return result, nil
+	}
+	result, err := c.evaluate(ctx, txName, userID)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.putList(key, result, epoch)
+	return result, nil
+}
+
+// cachedRecordQuery 按记录缓存的单条查询，args 为链码方法的完整参数
+func (c *Client) cachedRecordQuery(ctx context.Context, docType, recordID, userID, txName string, args ...string) ([]byte, error) {
+	if c.readsByGrant(userID) {
+		return c.submitRead(ctx, txName, args...)
+	}
+	if c.cache == nil || c.bypassCache {
+		return c.evaluate(ctx, txName, args...)
+	}
+
+	recordKey := recordCacheKey(docType, recordID)
+	viewKey := c.identity.label + "|" + userID
+	result, epoch, ok := c.cache.getRecord(recordKey, viewKey)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("cache.hit", ok))
+	if ok {
+		return result, nil
+	}
+	result, err := c.evaluate(ctx, txName, args...)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.putRecord(recordKey, viewKey, result, epoch)
+	return result, nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"edu/model"
+)
+
+// cachedKeys 返回缓存中的列表键和单条记录键（类型|记录|身份|用户）
+func cachedKeys(rc *readCache) []string {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	var keys []string
+	for key := range rc.lists {
+		keys = append(keys, key)
+	}
+	for recordKey, views := range rc.records {
+		for viewKey := range views {
+			keys = append(keys, recordKey+"|"+viewKey)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestReadCacheInvalidate(t *testing.T) {
+	all := []string{
+		"Evaluation|eval_001|admin|user_001",
+		"Evaluation|eval_002|admin|user_002",
+		"Judgement|judge_001|admin|user_001",
+		"admin|Evaluation|user_001",
+		"admin|Evaluation|user_002",
+		"admin|Judgement|user_001",
+		"teacher|Evaluation|user_001",
+	}
+	tests := []struct {
+		name          string
+		event         RecordEvent
+		want          []string
+		invalidations uint64
+		flushes       uint64
+	}{
+		{
+			name:          "修改记录",
+			event:         RecordEvent{DocType: "Evaluation", Action: "Modify", RecordID: "eval_001", UserID: "user_001"},
+			want:          []string{"Evaluation|eval_002|admin|user_002", "Judgement|judge_001|admin|user_001", "admin|Evaluation|user_002", "admin|Judgement|user_001"},
+			invalidations: 3,
+		},
+		{
+			name:          "记录转移到其他用户",
+			event:         RecordEvent{DocType: "Evaluation", Action: "Modify", RecordID: "eval_002", UserID: "user_001", PreviousUserID: "user_002"},
+			want:          []string{"Evaluation|eval_001|admin|user_001", "Judgement|judge_001|admin|user_001", "admin|Judgement|user_001"},
+			invalidations: 4,
+		},
+		{
+			name:          "新建记录只失效列表",
+			event:         RecordEvent{DocType: "Judgement", Action: "Create", RecordID: "judge_002", UserID: "user_001"},
+			want:          []string{"Evaluation|eval_001|admin|user_001", "Evaluation|eval_002|admin|user_002", "Judgement|judge_001|admin|user_001", "admin|Evaluation|user_001", "admin|Evaluation|user_002", "teacher|Evaluation|user_001"},
+			invalidations: 1,
+		},
+		{
+			name:    "授权变更清空缓存",
+			event:   RecordEvent{DocType: model.DocTypeAccessGrant, Action: "Create", RecordID: "tx1", UserID: "user_001"},
+			flushes: 1,
+		},
+		{
+			name:    "擦除清空缓存",
+			event:   RecordEvent{DocType: model.DocTypeUserData, Action: "Modify", RecordID: "user_001", UserID: "user_001"},
+			flushes: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := newReadCache(time.Minute)
+			rc.putList(listCacheKey("admin", "Evaluation", "user_001"), []byte("[]"), 0)
+			rc.putList(listCacheKey("admin", "Evaluation", "user_002"), []byte("[]"), 0)
+			rc.putList(listCacheKey("admin", "Judgement", "user_001"), []byte("[]"), 0)
+			rc.putList(listCacheKey("teacher", "Evaluation", "user_001"), []byte("[]"), 0)
+			rc.putRecord(recordCacheKey("Evaluation", "eval_001"), "admin|user_001", []byte("{}"), 0)
+			rc.putRecord(recordCacheKey("Evaluation", "eval_002"), "admin|user_002", []byte("{}"), 0)
+			rc.putRecord(recordCacheKey("Judgement", "judge_001"), "admin|user_001", []byte("{}"), 0)
+			if got := cachedKeys(rc); !reflect.DeepEqual(got, all) {
+				t.Fatalf("缓存键 = %v", got)
+			}
+
+			rc.invalidate(&tt.event)
+			if got := cachedKeys(rc); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("失效后缓存键 = %v，期望 %v", got, tt.want)
+			}
+			stats := rc.stats()
+			if stats.Invalidations != tt.invalidations || stats.Flushes != tt.flushes || stats.Entries != len(tt.want) {
+				t.Errorf("统计 = %+v，期望失效 %d 条、清空 %d 次", stats, tt.invalidations, tt.flushes)
+			}
+		})
+	}
+}
+
+// 查询期间发生失效时，查询结果不写入缓存
+func TestReadCacheSkipsStaleWrite(t *testing.T) {
+	rc := newReadCache(time.Minute)
+	key := listCacheKey("admin", "Evaluation", "user_001")
+	_, epoch, ok := rc.getList(key)
+	if ok {
+		t.Fatal("空缓存不应命中")
+	}
+	rc.invalidate(&RecordEvent{DocType: "Evaluation", RecordID: "eval_001", UserID: "user_001"})
+	rc.putList(key, []byte("[]"), epoch)
+	if _, _, ok := rc.getList(key); ok {
+		t.Error("失效前开始的查询结果不应写入缓存")
+	}
+
+	_, epoch, _ = rc.getRecord(recordCacheKey("Evaluation", "eval_001"), "admin|user_001")
+	rc.flush()
+	rc.putRecord(recordCacheKey("Evaluation", "eval_001"), "admin|user_001", []byte("{}"), epoch)
+	if stats := rc.stats(); stats.Entries != 0 {
+		t.Errorf("清空前开始的查询结果写入了缓存: %+v", stats)
+	}
+}
+
+func TestReadCacheExpiry(t *testing.T) {
+	rc := newReadCache(time.Millisecond)
+	key := listCacheKey("admin", "Evaluation", "user_001")
+	rc.putList(key, []byte("[]"), 0)
+	time.Sleep(5 * time.Millisecond)
+	if _, _, ok := rc.getList(key); ok {
+		t.Fatal("TTL 到期后不应命中")
+	}
+	if stats := rc.stats(); stats.Expired != 1 || stats.Misses != 1 || stats.Entries != 0 {
+		t.Errorf("统计 = %+v，期望到期 1 次", stats)
+	}
+}
+
+// eventually 在限定时间内反复检查条件，缓存由异步推送的链码事件失效
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待%s超时", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReadCacheInvalidatedByEvents(t *testing.T) {
+	c := newTestEmbeddedClient(t, WithReadCache(time.Minute))
+	evaluation := testEvaluation("eval_001", "user_001")
+	if _, err := c.UploadEvaluation(evaluation); err != nil {
+		t.Fatal(err)
+	}
+
+	// 上传事件可能在首次查询后才到达并使其失效，读到命中为止
+	eventually(t, "查询命中缓存", func() bool {
+		before := c.CacheStats().Hits
+		if _, err := c.GetEvaluationByID("eval_001", "user_001"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.GetEvaluationByUser("user_001"); err != nil {
+			t.Fatal(err)
+		}
+		return c.CacheStats().Hits == before+2
+	})
+	if stats := c.CacheStats(); stats.Entries != 2 {
+		t.Fatalf("统计 = %+v，期望缓存 2 条", stats)
+	}
+
+	modified := evaluation
+	modified.Feedback = "期末复习安排合理，进步明显"
+	if _, err := c.ModifyEvaluation("eval_001", modified); err != nil {
+		t.Fatal(err)
+	}
+	// TTL 远未到期，读到新内容说明缓存已被修改事件失效
+	eventually(t, "单条查询读到修改", func() bool {
+		got, err := c.GetEvaluationByID("eval_001", "user_001")
+		return err == nil && got.Feedback == modified.Feedback
+	})
+	eventually(t, "列表查询读到修改", func() bool {
+		list, err := c.GetEvaluationByUser("user_001")
+		return err == nil && len(list) == 1 && list[0].Feedback == modified.Feedback
+	})
+	if stats := c.CacheStats(); stats.Invalidations < 2 {
+		t.Errorf("统计 = %+v，期望修改事件失效 2 条", stats)
+	}
+
+	// Consistent 视图不读写缓存
+	before := c.CacheStats()
+	if _, err := c.Consistent().GetEvaluationByUser("user_001"); err != nil {
+		t.Fatal(err)
+	}
+	if after := c.CacheStats(); after.Hits != before.Hits || after.Misses != before.Misses {
+		t.Errorf("Consistent 读取改变了缓存统计: %+v -> %+v", before, after)
+	}
+}
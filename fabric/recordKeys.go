@@ -0,0 +1,577 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"edu/model"
+)
+
+// ===================== 学生密钥 =====================
+// 测评反馈和测试答案由链码以学生密钥加密后写入账本（见链码“记录加密”）。
+// 客户端把个人内容从交易参数中移出，和所属学生的密钥一起放在写入交易的瞬态数据中，读取时解密，调用方看到的始终是明文；
+// 擦除时提交擦除交易并销毁密钥，区块历史中的密文从此无法解密。
+// MemoryBackend 不加密，只用于开发和演示
+
+const recordKeySize = 32 // AES-256
+
+// 密钥服务地址的环境变量：http(s) 地址使用 HTTPKeyService，其他值为 FileKeyService 的文件路径
+const (
+	keyServiceEnv  = "EDU_KEY_SERVICE"
+	defaultKeyFile = "edu_record_keys.json"
+)
+
+var (
+	ErrKeyNotFound  = errors.New("找不到学生密钥")
+	ErrKeyDestroyed = errors.New("学生密钥已销毁")
+)
+
+// KeyService 链下密钥服务，按学生保存记录加密密钥
+// 同一学生在所有客户端必须取得相同的密钥，链码会拒绝与首次登记不一致的密钥
+type KeyService interface {
+	// Key 返回学生密钥，create 为 true 且不存在时生成新密钥；已销毁时返回 ErrKeyDestroyed
+	Key(ctx context.Context, userID string, create bool) ([]byte, error)
+	// Destroy 销毁学生密钥，之后 Key 返回 ErrKeyDestroyed；密钥不存在时同样留下销毁标记
+	Destroy(ctx context.Context, userID string) error
+}
+
+// newKeyServiceFromEnv 按 EDU_KEY_SERVICE 创建密钥服务，未设置时使用当前目录下的密钥文件
+// 多台机器写入同一学生的记录时必须配置共享的 HTTP 密钥服务
+func newKeyServiceFromEnv() KeyService {
+	location := os.Getenv(keyServiceEnv)
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		return NewHTTPKeyService(location)
+	}
+	if location == "" {
+		location = defaultKeyFile
+	}
+	return NewFileKeyService(location)
+}
+
+// ===================== 文件密钥服务 =====================
+
+// FileKeyService 将密钥保存在本地 JSON 文件中，销毁后保留无密钥的销毁标记
+type FileKeyService struct {
+	path string
+	mu   sync.Mutex
+}
+
+type fileKeyEntry struct {
+	Key         []byte `json:"key,omitempty"`
+	DestroyedAt string `json:"destroyedAt,omitempty"`
+}
+
+func NewFileKeyService(path string) *FileKeyService {
+	return &FileKeyService{path: path}
+}
+
+func (s *FileKeyService) Key(ctx context.Context, userID string, create bool) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	if entry, ok := entries[userID]; ok {
+		if entry.DestroyedAt != "" {
+			return nil, ErrKeyDestroyed
+		}
+		return entry.Key, nil
+	}
+	if !create {
+		return nil, ErrKeyNotFound
+	}
+
+	key := make([]byte, recordKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("生成学生密钥失败: %v", err)
+	}
+	entries[userID] = &fileKeyEntry{Key: key}
+	if err := s.save(entries); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (s *FileKeyService) Destroy(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load()
+	if err != nil {
+		return err
+	}
+	if entry, ok := entries[userID]; ok && entry.DestroyedAt != "" {
+		return nil
+	}
+	entries[userID] = &fileKeyEntry{DestroyedAt: time.Now().UTC().Format(time.RFC3339)}
+	return s.save(entries)
+}
+
+func (s *FileKeyService) load() (map[string]*fileKeyEntry, error) {
+	entries := make(map[string]*fileKeyEntry)
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取密钥文件失败: %v", err)
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("解析密钥文件失败: %v", err)
+	}
+	return entries, nil
+}
+
+// save 先写临时文件再改名，避免写入中断留下损坏的密钥文件
+func (s *FileKeyService) save(entries map[string]*fileKeyEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化密钥文件失败: %v", err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), ".keys-*")
+	if err != nil {
+		return fmt.Errorf("写入密钥文件失败: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入密钥文件失败: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入密钥文件失败: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("写入密钥文件失败: %v", err)
+	}
+	return nil
+}
+
+// ===================== HTTP密钥服务 =====================
+
+// HTTPKeyService 通过HTTP调用密钥服务：
+// GET <url>/keys/<用户ID> 读取，POST 读取或创建，DELETE 销毁；响应为 {"key": "<base64>"}
+// 404 表示密钥不存在，410 表示已销毁
+type HTTPKeyService struct {
+	url        string
+	httpClient *http.Client
+}
+
+func NewHTTPKeyService(url string) *HTTPKeyService {
+	return &HTTPKeyService{
+		url:        strings.TrimSuffix(url, "/"),
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+type keyResponse struct {
+	Key   []byte `json:"key"`
+	Error string `json:"error,omitempty"`
+}
+
+func (s *HTTPKeyService) Key(ctx context.Context, userID string, create bool) ([]byte, error) {
+	method := http.MethodGet
+	if create {
+		method = http.MethodPost
+	}
+	resp, err := s.do(ctx, method, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Key) != recordKeySize {
+		return nil, fmt.Errorf("密钥服务返回的学生密钥长度错误: %d", len(resp.Key))
+	}
+	return resp.Key, nil
+}
+
+func (s *HTTPKeyService) Destroy(ctx context.Context, userID string) error {
+	_, err := s.do(ctx, http.MethodDelete, userID)
+	if err == ErrKeyDestroyed {
+		return nil
+	}
+	return err
+}
+
+func (s *HTTPKeyService) do(ctx context.Context, method, userID string) (*keyResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.url+"/keys/"+url.PathEscape(userID), bytes.NewReader(nil))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求密钥服务失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, ErrKeyNotFound
+	case http.StatusGone:
+		return nil, ErrKeyDestroyed
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取密钥服务响应失败: %v", err)
+	}
+	var keyResp keyResponse
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &keyResp); err != nil {
+			return nil, fmt.Errorf("解析密钥服务响应失败（HTTP %d）: %v", resp.StatusCode, err)
+		}
+	}
+	if keyResp.Error != "" {
+		return nil, fmt.Errorf("密钥服务返回错误: %s", keyResp.Error)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("密钥服务返回 HTTP %d", resp.StatusCode)
+	}
+	return &keyResp, nil
+}
+
+// ===================== 写入时附带密钥 =====================
+// 交易参数会原样写入区块，个人内容因此不能放在参数中：客户端把它从记录 JSON 中移出，
+// 与学生密钥一起经瞬态数据交给链码，链码加密后写入账本
+
+type (
+	recordKeyContextKey struct{}
+	personalContextKey  struct{}
+)
+
+// proposalTransient 提案的瞬态数据：追踪上下文、写入交易的个人内容和所属学生的密钥、链码错误信息的语言
+func proposalTransient(ctx context.Context) map[string][]byte {
+	transient := traceTransient(ctx)
+	set := func(key string, value []byte) {
+		if transient == nil {
+			transient = make(map[string][]byte, 3)
+		}
+		transient[key] = value
+	}
+	if key, ok := ctx.Value(recordKeyContextKey{}).([]byte); ok {
+		set(model.RecordKeyTransient, key)
+	}
+	if personal, ok := ctx.Value(personalContextKey{}).([]byte); ok {
+		set(model.PersonalTransient, personal)
+	}
+	if lang := callOptionsFrom(ctx).language; lang != "" {
+		set(model.LanguageTransient, []byte(lang))
+	}
+	return transient
+}
+
+// withPersonalContent 把写入交易的个人内容从参数中移出，连同记录所属学生的密钥放入 ctx 供提案附带
+// 其他交易和没有个人内容的记录原样返回；记录无法解析时原样返回，由链码返回解析错误；
+// 密钥已销毁时不附带密钥，由链码返回错误
+func (c *Client) withPersonalContent(ctx context.Context, txName string, args []string) (context.Context, []string, error) {
+	index := 0
+	switch {
+	case (txName == "UploadEvaluation" || txName == "UploadTestResult") && len(args) == 1:
+	case txName == "ModifyEvaluation" && len(args) == 2:
+		index = 1
+	default:
+		return ctx, args, nil
+	}
+
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(args[index]), &record); err != nil {
+		return ctx, args, nil
+	}
+	personal := make(map[string]string)
+	for _, field := range model.PersonalFields {
+		raw, ok := record[field]
+		if !ok {
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return ctx, args, nil
+		}
+		if value != "" {
+			personal[field] = value
+		}
+		delete(record, field)
+	}
+	if len(personal) == 0 {
+		return ctx, args, nil
+	}
+
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return nil, nil, fmt.Errorf("序列化记录失败: %v", err)
+	}
+	personalJSON, err := json.Marshal(personal)
+	if err != nil {
+		return nil, nil, fmt.Errorf("序列化个人内容失败: %v", err)
+	}
+	args = append([]string(nil), args...)
+	args[index] = string(recordJSON)
+	ctx = context.WithValue(ctx, personalContextKey{}, personalJSON)
+
+	var userID string
+	if err := json.Unmarshal(record["User_ID"], &userID); err != nil || userID == "" {
+		return ctx, args, nil
+	}
+	key, err := c.keys.Key(ctx, userID, true)
+	if err == ErrKeyDestroyed {
+		return ctx, args, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取用户 %s 的学生密钥失败: %w", userID, err)
+	}
+	return context.WithValue(ctx, recordKeyContextKey{}, key), args, nil
+}
+
+// ===================== 读取时解密 =====================
+
+// Sealed 返回不解密的客户端视图，记录与账本中保存的值一致，用于摘要和比对链上内容
+func (c *Client) Sealed() *Client {
+	view := *c
+	view.sealed = true
+	return &view
+}
+
+// recordOpener 解密一次查询返回的记录，同一学生的密钥和同一范围版本的主密钥只读取一次
+type recordOpener struct {
+	client    *Client
+	keys      map[string][]byte // 密钥已销毁的学生为 nil
+	scopeKeys map[string][]byte // <范围>#<版本> -> 主密钥，无权读取的为 nil
+}
+
+func (c *Client) newRecordOpener() *recordOpener {
+	return &recordOpener{client: c, keys: make(map[string][]byte), scopeKeys: make(map[string][]byte)}
+}
+
+// open 先解开链码的学生密钥加密，再解开客户端的信封加密（见 envelope.go）
+func (o *recordOpener) open(ctx context.Context, userID, recordKey, field, value string) (string, error) {
+	if o.client.sealed {
+		return value, nil
+	}
+	value, err := o.openStudent(ctx, userID, recordKey, field, value)
+	if err != nil {
+		return "", err
+	}
+	return o.openEnvelope(ctx, recordKey, field, value)
+}
+
+// openStudent 解密链码写入的字段；不带密文前缀的值（加密启用前写入的记录）原样返回，密钥已销毁时返回空串
+func (o *recordOpener) openStudent(ctx context.Context, userID, recordKey, field, value string) (string, error) {
+	if !strings.HasPrefix(value, model.EncryptedPrefix) {
+		return value, nil
+	}
+	key, ok := o.keys[userID]
+	if !ok {
+		var err error
+		key, err = o.client.keys.Key(ctx, userID, false)
+		switch {
+		case err == ErrKeyDestroyed:
+			key = nil
+		case err != nil:
+			return "", fmt.Errorf("读取用户 %s 的学生密钥失败: %w", userID, err)
+		}
+		o.keys[userID] = key
+	}
+	if key == nil {
+		return "", nil
+	}
+	return openField(key, recordKey, field, value)
+}
+
+func (o *recordOpener) evaluation(ctx context.Context, e *Evaluation) (err error) {
+	e.Feedback, err = o.open(ctx, e.UserID, "Evaluation-"+e.EvaluationID, "Feedback", e.Feedback)
+	return err
+}
+
+func (o *recordOpener) testResult(ctx context.Context, t *TestResult) (err error) {
+	t.Answer, err = o.open(ctx, t.UserID, "TestResult-"+t.TestID, "Answer", t.Answer)
+	return err
+}
+
+func (o *recordOpener) evaluations(ctx context.Context, evaluations []Evaluation) error {
+	for i := range evaluations {
+		if err := o.evaluation(ctx, &evaluations[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *recordOpener) testResults(ctx context.Context, tests []TestResult) error {
+	for i := range tests {
+		if err := o.testResult(ctx, &tests[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openResult 解密 REST 接口查询到的测评记录、测试结果和评价记录，其他查询结果原样返回
+func (c *Client) openResult(ctx context.Context, txName string, result []byte) ([]byte, error) {
+	opener := c.newRecordOpener()
+	var records interface{}
+	switch txName {
+	case "GetEvaluationByID":
+		records = &Evaluation{}
+	case "GetEvaluationByUser", "GetAllEvaluations":
+		records = &[]Evaluation{}
+	case "GetTestResultsByID", "GetTestResultsByTestID":
+		records = &TestResult{}
+	case "GetTestResultsByUser":
+		records = &[]TestResult{}
+	case "GetJudgementByID", "GetJudgementByJudgementID":
+		records = &Judgement{}
+	case "GetJudgementByUser":
+		records = &[]Judgement{}
+	default:
+		return result, nil
+	}
+	if len(result) == 0 {
+		return result, nil
+	}
+	if err := json.Unmarshal(result, records); err != nil {
+		return nil, fmt.Errorf("解析结果失败: %v", err)
+	}
+
+	var err error
+	switch r := records.(type) {
+	case *Evaluation:
+		err = opener.evaluation(ctx, r)
+	case *[]Evaluation:
+		err = opener.evaluations(ctx, *r)
+	case *TestResult:
+		err = opener.testResult(ctx, r)
+	case *[]TestResult:
+		err = opener.testResults(ctx, *r)
+	case *Judgement:
+		err = opener.judgement(ctx, r)
+	case *[]Judgement:
+		err = opener.judgements(ctx, *r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(records)
+}
+
+// openField 解密链码写入的字段，附加数据为状态键和字段名
+func openField(key []byte, recordKey, field, value string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, model.EncryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("%s 的 %s 密文格式错误: %v", recordKey, field, err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", fmt.Errorf("创建解密器失败: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", fmt.Errorf("创建解密器失败: %v", err)
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("%s 的 %s 密文格式错误", recordKey, field)
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(recordKey+"|"+field))
+	if err != nil {
+		return "", fmt.Errorf("解密 %s 的 %s 失败，学生密钥与写入时不一致", recordKey, field)
+	}
+	return string(plaintext), nil
+}
+
+// ===================== 数据擦除 =====================
+
+// EraseUserData 擦除学生的个人内容：提交擦除交易清空测评反馈和测试答案，再销毁学生密钥和按学生划分的主密钥
+// 调用者须为学生本人或学校组织的管理员或教师；重复调用是安全的，已擦除时只确保密钥已销毁
+func (c *Client) EraseUserData(userID string) (*TxReceipt, error) {
+	return c.EraseUserDataWithContext(context.Background(), userID)
+}
+
+// EraseUserDataWithContext 擦除学生的个人内容，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+// 已擦除时返回的回执为 nil
+func (c *Client) EraseUserDataWithContext(ctx context.Context, userID string, opts ...CallOption) (receipt *TxReceipt, err error) {
+	ctx, end, err := c.startCall(ctx, "EraseUserData", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	return c.eraseUserData(ctx, userID)
+}
+
+func (c *Client) eraseUserData(ctx context.Context, userID string) (*TxReceipt, error) {
+	// 先提交擦除交易：交易失败时密钥仍在，记录仍可读取，可以重试
+	receipt, err := c.submit(ctx, "EraseUserData", userID)
+	if err != nil && errorKey(err) != "user_data.erased" {
+		return nil, err
+	}
+	if err := c.keys.Destroy(ctx, userID); err != nil {
+		return receipt, fmt.Errorf("擦除交易已提交，但销毁学生密钥失败，请重试: %w", err)
+	}
+	// 评价内容只有客户端的信封加密，还要销毁按学生划分的主密钥（按课程划分的范围见 CourseScope）
+	if c.envelope != nil {
+		if err := c.envelope.ring.Destroy(ctx, studentScopePrefix+userID); err != nil {
+			return receipt, fmt.Errorf("擦除交易已提交，但销毁密钥范围 %s%s 失败，请重试: %w", studentScopePrefix, userID, err)
+		}
+	}
+	return receipt, nil
+}
+
+// ===================== 命令行 =====================
+
+// runErase 擦除学生的个人内容，-as 指定钱包中的学生身份（学生本人申请擦除时）
+func runErase(args []string) error {
+	flags := flag.NewFlagSet("erase", flag.ExitOnError)
+	walletDir := flags.String("wallet", "wallet", "身份钱包目录")
+	as := flags.String("as", "", "以钱包中该用户的身份调用")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("用法: erase [-wallet <钱包目录>] [-as <用户ID>] <学生用户ID>")
+	}
+	userID := flags.Arg(0)
+
+	var opts []ClientOption
+	if *as != "" {
+		wallet, err := NewFileSystemWallet(*walletDir)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, WithWallet(wallet))
+	}
+	base, err := NewClient(opts...)
+	if err != nil {
+		return err
+	}
+	defer base.Close()
+	client := base
+	if *as != "" {
+		if client, err = base.As(*as); err != nil {
+			return err
+		}
+	}
+
+	receipt, err := client.EraseUserData(userID)
+	if err != nil {
+		return err
+	}
+	if receipt == nil {
+		fmt.Printf("用户 %s 的数据此前已擦除，学生密钥已销毁\n", userID)
+		return nil
+	}
+	fmt.Printf("已擦除用户 %s 的个人内容并销毁学生密钥（交易 %s，区块 %d）\n", userID, receipt.TransactionID, receipt.BlockNumber)
+	return nil
+}
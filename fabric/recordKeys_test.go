@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"edu/chaincode/atcc"
+	"edu/model"
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+)
+
+// argsRecorder 记录链码收到的全部交易参数，参数即区块中交易的内容
+type argsRecorder struct {
+	shim.Chaincode
+	mu   sync.Mutex
+	args [][]byte
+}
+
+func (r *argsRecorder) Invoke(stub shim.ChaincodeStubInterface) *peer.Response {
+	r.mu.Lock()
+	r.args = append(r.args, stub.GetArgs()...)
+	r.mu.Unlock()
+	return r.Chaincode.Invoke(stub)
+}
+
+func TestPersonalContentNotInArgsOrState(t *testing.T) {
+	t.Setenv(keyRingEnv, "")
+	cc, err := atcc.NewChaincode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &argsRecorder{Chaincode: cc}
+	c, err := NewEmbeddedClient(recorder, WithKeyService(NewFileKeyService(filepath.Join(t.TempDir(), "keys.json"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	evaluation := testEvaluation("eval_001", "user_001")
+	modified := evaluation
+	modified.Feedback = "期末复习安排合理，进步明显"
+	test := TestResult{TestID: "test_001", UserID: "user_001", ScoreSum: "95", Answer: "第一题选B，第二题见附页"}
+	if _, err := c.UploadEvaluation(evaluation); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ModifyEvaluation("eval_001", modified); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UploadTestResult(test); err != nil {
+		t.Fatal(err)
+	}
+
+	plaintexts := []string{evaluation.Feedback, modified.Feedback, test.Answer}
+	for _, arg := range recorder.args {
+		for _, plaintext := range plaintexts {
+			if bytes.Contains(arg, []byte(plaintext)) {
+				t.Errorf("交易参数 %s 中出现明文 %q", arg, plaintext)
+			}
+		}
+	}
+	for key, value := range c.embedded.state {
+		for _, plaintext := range plaintexts {
+			if bytes.Contains(value.value, []byte(plaintext)) {
+				t.Errorf("账本状态 %s 中出现明文 %q", key, plaintext)
+			}
+		}
+	}
+
+	// 读取时解密，调用方看到的仍是明文
+	got, err := c.GetEvaluationByID("eval_001", "user_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Feedback != modified.Feedback {
+		t.Errorf("反馈 = %q，期望 %q", got.Feedback, modified.Feedback)
+	}
+	tests, err := c.GetTestResultsByUser("user_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tests) != 1 || tests[0].Answer != test.Answer {
+		t.Errorf("测试结果 = %+v，期望答案 %q", tests, test.Answer)
+	}
+}
+
+// 绕过客户端直接把个人内容放在参数中提交时，链码拒绝交易
+func TestPersonalContentInArgsRejected(t *testing.T) {
+	c := newTestEmbeddedClient(t)
+	data, _ := json.Marshal(testEvaluation("eval_001", "user_001"))
+	_, _, err := c.embedded.endorseAndSubmit(context.Background(), c.identity, "UploadEvaluation", []string{string(data)})
+	if errorKey(err) != "field.in_args" || !errors.Is(err, model.CodeInvalidInput) {
+		t.Fatalf("参数中带个人内容的错误 = %v，期望 field.in_args", err)
+	}
+	if len(c.embedded.state) != 0 {
+		t.Errorf("被拒绝的交易写入了 %d 个键", len(c.embedded.state))
+	}
+}
+
+func TestEraseUserDataDestroysKey(t *testing.T) {
+	keys := NewFileKeyService(filepath.Join(t.TempDir(), "keys.json"))
+	c := newTestEmbeddedClient(t, WithKeyService(keys))
+	if _, err := c.UploadEvaluation(testEvaluation("eval_001", "user_001")); err != nil {
+		t.Fatal(err)
+	}
+
+	receipt, err := c.EraseUserData("user_001")
+	if err != nil {
+		t.Fatalf("擦除失败: %v", err)
+	}
+	if receipt == nil || !receipt.Successful {
+		t.Fatalf("擦除回执 = %+v，期望验证通过", receipt)
+	}
+	if _, err := keys.Key(context.Background(), "user_001", true); err != ErrKeyDestroyed {
+		t.Errorf("擦除后读取密钥的错误 = %v，期望 ErrKeyDestroyed", err)
+	}
+
+	got, err := c.GetEvaluationByID("eval_001", "user_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Feedback != "" || got.ErasedAt == "" || got.PointsDegree != "A" {
+		t.Errorf("擦除后的记录 = %+v，期望只清除个人内容", got)
+	}
+
+	// 重复擦除不报错，不再提交交易
+	if receipt, err := c.EraseUserData("user_001"); err != nil || receipt != nil {
+		t.Errorf("重复擦除 = %+v, %v，期望 nil, nil", receipt, err)
+	}
+}
+
+func TestFileKeyServiceDestroy(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	keys := NewFileKeyService(path)
+
+	if _, err := keys.Key(ctx, "user_001", false); err != ErrKeyNotFound {
+		t.Fatalf("读取不存在的密钥 = %v，期望 ErrKeyNotFound", err)
+	}
+	key, err := keys.Key(ctx, "user_001", true)
+	if err != nil || len(key) != recordKeySize {
+		t.Fatalf("创建密钥 = %d 字节, %v", len(key), err)
+	}
+	again, err := keys.Key(ctx, "user_001", true)
+	if err != nil || !bytes.Equal(again, key) {
+		t.Fatalf("再次读取的密钥不一致: %v", err)
+	}
+
+	if err := keys.Destroy(ctx, "user_001"); err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Destroy(ctx, "user_001"); err != nil {
+		t.Errorf("重复销毁 = %v，期望成功", err)
+	}
+	// 不存在的密钥同样留下销毁标记，之后不能再创建
+	if err := keys.Destroy(ctx, "user_002"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := NewFileKeyService(path)
+	for _, userID := range []string{"user_001", "user_002"} {
+		if _, err := reopened.Key(ctx, userID, true); err != ErrKeyDestroyed {
+			t.Errorf("%s 销毁后读取 = %v，期望 ErrKeyDestroyed", userID, err)
+		}
+	}
+	data, _ := ioutil.ReadFile(path)
+	if bytes.Contains(data, []byte(`"key"`)) {
+		t.Errorf("密钥文件中仍有密钥: %s", data)
+	}
+}
+
+func TestHTTPKeyServiceDestroy(t *testing.T) {
+	var mu sync.Mutex
+	stored := map[string][]byte{}
+	destroyed := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		userID := strings.TrimPrefix(r.URL.Path, "/keys/")
+		if destroyed[userID] {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			if stored[userID] == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+		case http.MethodPost:
+			if stored[userID] == nil {
+				stored[userID] = bytes.Repeat([]byte{byte(len(stored) + 1)}, recordKeySize)
+			}
+		case http.MethodDelete:
+			delete(stored, userID)
+			destroyed[userID] = true
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		json.NewEncoder(w).Encode(keyResponse{Key: stored[userID]})
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	keys := NewHTTPKeyService(server.URL + "/")
+	if _, err := keys.Key(ctx, "user_001", false); err != ErrKeyNotFound {
+		t.Fatalf("读取不存在的密钥 = %v，期望 ErrKeyNotFound", err)
+	}
+	key, err := keys.Key(ctx, "user_001", true)
+	if err != nil || len(key) != recordKeySize {
+		t.Fatalf("创建密钥 = %d 字节, %v", len(key), err)
+	}
+	if err := keys.Destroy(ctx, "user_001"); err != nil {
+		t.Fatal(err)
+	}
+	// 已销毁时服务返回 410，Destroy 视为成功
+	if err := keys.Destroy(ctx, "user_001"); err != nil {
+		t.Errorf("重复销毁 = %v，期望成功", err)
+	}
+	if _, err := keys.Key(ctx, "user_001", true); !errors.Is(err, ErrKeyDestroyed) {
+		t.Errorf("销毁后读取 = %v，期望 ErrKeyDestroyed", err)
+	}
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"edu/model"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/skip2/go-qrcode"
+)
+
+// ===================== 成绩单 =====================
+
+// 成绩单模板，可用 report -template 替换
+//
+//go:embed templates/reportCard.html
+var reportCardTemplate string
+
+// 二维码默认内容前缀，可用 report -verify-url 换成校验页面地址
+const defaultReportVerifyURL = "edu-ledger://verify"
+
+// 二维码图片边长（像素）
+const reportQRCodeSize = 256
+
+// ReportTerm 学期，按记录最后一次写入的区块时间归属，To 为空表示至今
+type ReportTerm struct {
+	Name string
+	From time.Time
+	To   time.Time
+}
+
+// contains 区块时间是否在学期内，区间左闭右开
+func (t ReportTerm) contains(at time.Time) bool {
+	if !t.From.IsZero() && at.Before(t.From) {
+		return false
+	}
+	return t.To.IsZero() || at.Before(t.To)
+}
+
+// ReportItem 成绩单中的一条记录
+type ReportItem struct {
+	DocType     string
+	RecordID    string
+	Title       string
+	Value       string
+	Feedback    string // 教师评语
+	Digest      string // 链上记录JSON的SHA-256
+	TxID        string // 写入当前值的交易
+	BlockNumber uint64
+	CommittedAt time.Time
+	Verified    bool   // 索引内容与链上当前值一致，交易ID可用于核对
+	VerifyURL   string // 二维码内容
+	QRCode      []byte // PNG，未通过核对的记录没有二维码
+}
+
+// ReportCard 学生一个学期的成绩单
+type ReportCard struct {
+	UserID      string
+	Term        ReportTerm
+	GeneratedAt time.Time
+	Channel     string
+	Chaincode   string
+	Evaluations []ReportItem
+	TestResults []ReportItem
+	Unindexed   int // 链上存在但索引中没有的记录数
+}
+
+// reportProvenance 索引中记录的来源交易
+type reportProvenance struct {
+	txID        string
+	blockNumber uint64
+	committedAt time.Time
+	record      interface{} // 索引中保存的字段，用于与链上当前值比对
+}
+
+// BuildReportCard 汇总学生在学期内的测评和测试成绩
+// 记录内容以账本查询结果为准，交易ID、区块号和学期归属来自链下索引（账本状态中不保存交易ID）；
+// 索引落后于账本时，内容不一致的记录不生成二维码，索引中没有的记录只计数
+func BuildReportCard(ctx context.Context, backend LedgerBackend, ix *Indexer, userID string, term ReportTerm, verifyURL string) (*ReportCard, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("用户ID不能为空")
+	}
+	if verifyURL == "" {
+		verifyURL = defaultReportVerifyURL
+	}
+	card := &ReportCard{
+		UserID:      userID,
+		Term:        term,
+		GeneratedAt: time.Now(),
+		Channel:     channelName,
+		Chaincode:   chaincodeID,
+	}
+
+	// 索引和二维码摘要针对账本中保存的密文，评语在显示前解密
+	var opener *recordOpener
+	if client, ok := backend.(*Client); ok {
+		opener = client.newRecordOpener()
+		backend = client.Sealed()
+	}
+
+	evaluations, err := backend.GetEvaluationByUserWithContext(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询测评记录失败: %w", err)
+	}
+	indexed, err := ix.evaluationProvenance(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range evaluations {
+		item := ReportItem{
+			DocType:  model.DocTypeEvaluation,
+			RecordID: e.EvaluationID,
+			Title:    fmt.Sprintf("测评 %s", e.EvaluationID),
+			Value:    e.PointsDegree,
+			Feedback: e.Feedback,
+			Digest:   reportDigest(e),
+		}
+		if opener != nil {
+			if item.Feedback, err = opener.open(ctx, e.UserID, "Evaluation-"+e.EvaluationID, "Feedback", e.Feedback); err != nil {
+				return nil, err
+			}
+		}
+		record := e
+		record.DocType, record.ErasedAt = "", ""
+		if card.addItem(&card.Evaluations, item, indexed[e.EvaluationID], record, verifyURL) {
+			card.Unindexed++
+		}
+	}
+
+	tests, err := backend.GetTestResultsByUserWithContext(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询测试成绩失败: %w", err)
+	}
+	indexed, err = ix.testResultProvenance(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tests {
+		title := fmt.Sprintf("测试 %s", t.TestID)
+		if t.PaperNumber != "" {
+			title = fmt.Sprintf("试卷 %s（测试 %s）", t.PaperNumber, t.TestID)
+		}
+		item := ReportItem{
+			DocType:  model.DocTypeTestResult,
+			RecordID: t.TestID,
+			Title:    title,
+			Value:    t.ScoreSum + " 分",
+			Digest:   reportDigest(t),
+		}
+		record := t
+		record.DocType, record.Answer, record.ErasedAt = "", "", ""
+		if card.addItem(&card.TestResults, item, indexed[t.TestID], record, verifyURL) {
+			card.Unindexed++
+		}
+	}
+
+	for _, items := range [][]ReportItem{card.Evaluations, card.TestResults} {
+		sort.SliceStable(items, func(i, j int) bool { return items[i].BlockNumber < items[j].BlockNumber })
+	}
+	return card, nil
+}
+
+// addItem 按索引补全交易信息并生成二维码，返回记录是否未被索引
+// record 为链上当前值中索引也保存的字段，用于判断索引是否落后
+func (card *ReportCard) addItem(items *[]ReportItem, item ReportItem, provenance *reportProvenance, record interface{}, verifyURL string) bool {
+	if provenance == nil {
+		return true
+	}
+	if !card.Term.contains(provenance.committedAt) {
+		return false
+	}
+	item.TxID = provenance.txID
+	item.BlockNumber = provenance.blockNumber
+	item.CommittedAt = provenance.committedAt
+	item.Verified = provenance.record == record
+	if item.Verified {
+		var err error
+		item.VerifyURL = reportVerifyURL(verifyURL, item)
+		if item.QRCode, err = qrcode.Encode(item.VerifyURL, qrcode.Medium, reportQRCodeSize); err != nil {
+			// 内容过长时无法编码，成绩单中仍保留交易ID
+			log.Printf("生成 %s-%s 的二维码失败: %v", item.DocType, item.RecordID, err)
+		}
+	}
+	*items = append(*items, item)
+	return false
+}
+
+// reportDigest 记录JSON的SHA-256，链码写入状态时同样以 json.Marshal 序列化
+func reportDigest(record interface{}) string {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// reportVerifyURL 二维码内容：通道、链码、交易、状态键和记录摘要
+// 核对时在该交易的写集中找到状态键，比对写入值的摘要
+func reportVerifyURL(base string, item ReportItem) string {
+	query := url.Values{
+		"channel":   {channelName},
+		"chaincode": {chaincodeID},
+		"tx":        {item.TxID},
+		"block":     {strconv.FormatUint(item.BlockNumber, 10)},
+		"key":       {item.DocType + "-" + item.RecordID},
+		"sha256":    {item.Digest},
+	}
+	return base + "?" + query.Encode()
+}
+
+// evaluationProvenance 读取用户测评记录的来源交易，以测评ID为键
+func (ix *Indexer) evaluationProvenance(userID string) (map[string]*reportProvenance, error) {
+	rows, err := ix.db.Query(`SELECT evaluation_id, points_degree, feedback, COALESCE(teacher_id, ''), tx_id, block_number, updated_at
+		FROM evaluations WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询执行失败: %v", err)
+	}
+	defer rows.Close()
+
+	provenance := make(map[string]*reportProvenance)
+	for rows.Next() {
+		var (
+			e         = Evaluation{UserID: userID}
+			p         reportProvenance
+			updatedAt string
+		)
+		if err := rows.Scan(&e.EvaluationID, &e.PointsDegree, &e.Feedback, &e.TeacherID, &p.txID, &p.blockNumber, &updatedAt); err != nil {
+			return nil, fmt.Errorf("结果读取失败: %v", err)
+		}
+		if p.committedAt, err = time.Parse(time.RFC3339, updatedAt); err != nil {
+			return nil, fmt.Errorf("索引时间格式错误 %q: %v", updatedAt, err)
+		}
+		p.record = e
+		provenance[e.EvaluationID] = &p
+	}
+	return provenance, rows.Err()
+}
+
+// testResultProvenance 读取用户测试成绩的来源交易，以测试ID为键；答案内容不参与比对
+func (ix *Indexer) testResultProvenance(userID string) (map[string]*reportProvenance, error) {
+	rows, err := ix.db.Query(`SELECT test_id, score_sum, paper_number, tx_id, block_number, updated_at
+		FROM test_results WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询执行失败: %v", err)
+	}
+	defer rows.Close()
+
+	provenance := make(map[string]*reportProvenance)
+	for rows.Next() {
+		var (
+			t         = TestResult{UserID: userID}
+			p         reportProvenance
+			updatedAt string
+		)
+		if err := rows.Scan(&t.TestID, &t.ScoreSum, &t.PaperNumber, &p.txID, &p.blockNumber, &updatedAt); err != nil {
+			return nil, fmt.Errorf("结果读取失败: %v", err)
+		}
+		if p.committedAt, err = time.Parse(time.RFC3339, updatedAt); err != nil {
+			return nil, fmt.Errorf("索引时间格式错误 %q: %v", updatedAt, err)
+		}
+		p.record = t
+		provenance[t.TestID] = &p
+	}
+	return provenance, rows.Err()
+}
+
+// ===================== 输出格式 =====================
+
+// WriteReportCardHTML 用模板渲染HTML成绩单，tmpl 为空时使用内置模板
+// 模板中可用 dataURI 把二维码PNG转为 img 的 src
+func WriteReportCardHTML(w io.Writer, card *ReportCard, tmpl string) error {
+	if tmpl == "" {
+		tmpl = reportCardTemplate
+	}
+	t, err := template.New("reportCard").Funcs(template.FuncMap{
+		"dataURI": func(png []byte) template.URL {
+			return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png))
+		},
+	}).Parse(tmpl)
+	if err != nil {
+		return fmt.Errorf("解析成绩单模板失败: %v", err)
+	}
+	if err := t.Execute(w, card); err != nil {
+		return fmt.Errorf("渲染成绩单失败: %v", err)
+	}
+	return nil
+}
+
+// WriteReportCardPDF 生成A4版式的PDF成绩单
+// PDF内置字体不含中文，fontFile 须为支持中文的TTF字体
+func WriteReportCardPDF(w io.Writer, card *ReportCard, fontFile string) error {
+	if fontFile == "" {
+		return fmt.Errorf("生成PDF需要指定支持中文的TTF字体")
+	}
+	font, err := ioutil.ReadFile(fontFile)
+	if err != nil {
+		return fmt.Errorf("读取字体文件失败: %v", err)
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes("report", "", font)
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.AddPage()
+	pageWidth, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	contentWidth := pageWidth - left - right
+	const qrSize = 28.0
+
+	pdf.SetFont("report", "", 20)
+	pdf.CellFormat(contentWidth, 12, "学生成绩单", "", 1, "C", false, 0, "")
+	pdf.SetFont("report", "", 10)
+	meta := fmt.Sprintf("学生：%s", card.UserID)
+	if card.Term.Name != "" {
+		meta += fmt.Sprintf("    学期：%s", card.Term.Name)
+	}
+	pdf.CellFormat(contentWidth, 6, meta, "", 1, "L", false, 0, "")
+	pdf.CellFormat(contentWidth, 6, "生成时间："+card.GeneratedAt.Format("2006-01-02 15:04:05"), "", 1, "L", false, 0, "")
+
+	section := func(title, empty string, items []ReportItem) {
+		pdf.Ln(4)
+		pdf.SetFont("report", "", 14)
+		pdf.CellFormat(contentWidth, 9, title, "B", 1, "L", false, 0, "")
+		pdf.SetFont("report", "", 10)
+		if len(items) == 0 {
+			pdf.CellFormat(contentWidth, 8, empty, "", 1, "L", false, 0, "")
+			return
+		}
+		for _, item := range items {
+			// 条目不跨页，高度按文字行数估算
+			textWidth := contentWidth - qrSize - 4
+			lines := 3 + len(pdf.SplitLines([]byte(item.Feedback), textWidth))
+			if !item.Verified {
+				lines++
+			}
+			height := float64(lines) * 5
+			if height < qrSize {
+				height = qrSize
+			}
+			_, pageHeight := pdf.GetPageSize()
+			_, _, _, bottom := pdf.GetMargins()
+			if pdf.GetY()+height > pageHeight-bottom {
+				pdf.AddPage()
+			}
+
+			top := pdf.GetY() + 2
+			pdf.SetXY(left, top)
+			pdf.MultiCell(textWidth, 5, fmt.Sprintf("%s    %s", item.Title, item.Value), "", "L", false)
+			if item.Feedback != "" {
+				pdf.MultiCell(textWidth, 5, item.Feedback, "", "L", false)
+			}
+			pdf.SetFont("report", "", 8)
+			pdf.MultiCell(textWidth, 5, fmt.Sprintf("交易ID：%s", item.TxID), "", "L", false)
+			pdf.MultiCell(textWidth, 5, fmt.Sprintf("区块 %d · %s", item.BlockNumber, item.CommittedAt.Format("2006-01-02 15:04:05")), "", "L", false)
+			if !item.Verified {
+				pdf.SetTextColor(176, 0, 0)
+				pdf.MultiCell(textWidth, 5, "索引中的内容与链上当前值不一致，请重新同步索引后再核对。", "", "L", false)
+				pdf.SetTextColor(0, 0, 0)
+			}
+			pdf.SetFont("report", "", 10)
+			textBottom := pdf.GetY()
+
+			if len(item.QRCode) > 0 {
+				name := item.DocType + "-" + item.RecordID
+				options := gofpdf.ImageOptions{ImageType: "PNG"}
+				pdf.RegisterImageOptionsReader(name, options, bytes.NewReader(item.QRCode))
+				pdf.ImageOptions(name, left+contentWidth-qrSize, top, qrSize, qrSize, false, options, 0, "")
+			}
+			if textBottom < top+qrSize {
+				textBottom = top + qrSize
+			}
+			pdf.SetY(textBottom + 2)
+			pdf.Line(left, pdf.GetY(), left+contentWidth, pdf.GetY())
+		}
+	}
+	section("测评与教师评语", "本学期没有测评记录。", card.Evaluations)
+	section("测试成绩", "本学期没有测试成绩。", card.TestResults)
+
+	pdf.Ln(4)
+	pdf.SetFont("report", "", 8)
+	pdf.MultiCell(contentWidth, 4, fmt.Sprintf("每条记录下方为写入该记录的交易ID，扫描二维码可在通道 %s 的链码 %s 上核对。", card.Channel, card.Chaincode), "", "L", false)
+	if card.Unindexed > 0 {
+		pdf.MultiCell(contentWidth, 4, fmt.Sprintf("另有 %d 条链上记录尚未同步到索引，无法确定所属学期，未列入本成绩单。", card.Unindexed), "", "L", false)
+	}
+
+	if err := pdf.Error(); err != nil {
+		return fmt.Errorf("生成PDF失败: %v", err)
+	}
+	return pdf.Output(w)
+}
+
+// ===================== 命令行 =====================
+
+// runReport 生成学生成绩单，记录来自当前账本后端，交易信息来自链下索引
+func runReport(args []string) error {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	userID := flags.String("user", "", "学生用户ID")
+	termName := flags.String("term", "", "学期名称，显示在成绩单上")
+	from := flags.String("from", "", "学期开始时间，RFC3339")
+	to := flags.String("to", "", "学期结束时间（不含），RFC3339")
+	dbPath := flags.String("db", "edu_index.db", "SQLite索引数据库文件")
+	format := flags.String("format", "html", "输出格式（html/pdf/both）")
+	tmplFile := flags.String("template", "", "HTML模板文件，默认使用内置模板")
+	fontFile := flags.String("font", "", "PDF使用的中文TTF字体文件")
+	out := flags.String("out", "", "输出文件名（不含扩展名），默认为 report-<用户ID>")
+	verifyURL := flags.String("verify-url", defaultReportVerifyURL, "二维码中的校验地址")
+	flags.Parse(args)
+
+	if *userID == "" {
+		return fmt.Errorf("缺少 -user 参数")
+	}
+	term := ReportTerm{Name: *termName}
+	for _, t := range []struct {
+		value string
+		to    *time.Time
+	}{{*from, &term.From}, {*to, &term.To}} {
+		if t.value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, t.value)
+		if err != nil {
+			return fmt.Errorf("时间格式错误 %q，应为RFC3339: %v", t.value, err)
+		}
+		*t.to = parsed
+	}
+	var html, pdf bool
+	switch *format {
+	case "html":
+		html = true
+	case "pdf":
+		pdf = true
+	case "both":
+		html, pdf = true, true
+	default:
+		return fmt.Errorf("不支持的输出格式 %s（可选 html/pdf/both）", *format)
+	}
+	if pdf && *fontFile == "" {
+		return fmt.Errorf("生成PDF需要 -font 指定支持中文的TTF字体")
+	}
+	var tmpl string
+	if *tmplFile != "" {
+		data, err := ioutil.ReadFile(*tmplFile)
+		if err != nil {
+			return fmt.Errorf("读取模板文件失败: %v", err)
+		}
+		tmpl = string(data)
+	}
+	if *out == "" {
+		*out = "report-" + *userID
+	}
+
+	ix, err := OpenIndexer(nil, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer ix.Close()
+	backend, err := NewLedgerBackend()
+	if err != nil {
+		return fmt.Errorf("创建客户端失败: %v", err)
+	}
+	defer backend.Close()
+
+	card, err := BuildReportCard(context.Background(), backend, ix, *userID, term, *verifyURL)
+	if err != nil {
+		return err
+	}
+	if card.Unindexed > 0 {
+		log.Printf("警告: %d 条记录尚未同步到索引，未列入成绩单", card.Unindexed)
+	}
+
+	write := func(path string, render func(io.Writer) error) error {
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("创建输出文件失败: %v", err)
+		}
+		if err := render(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("写入输出文件失败: %v", err)
+		}
+		log.Printf("成绩单已生成: %s（测评 %d 条，测试 %d 条）", path, len(card.Evaluations), len(card.TestResults))
+		return nil
+	}
+	if html {
+		if err := write(*out+".html", func(w io.Writer) error { return WriteReportCardHTML(w, card, tmpl) }); err != nil {
+			return err
+		}
+	}
+	if pdf {
+		if err := write(*out+".pdf", func(w io.Writer) error { return WriteReportCardPDF(w, card, *fontFile) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// indexRecordAt 以指定区块号和区块时间把记录写入索引
+func indexRecordAt(t *testing.T, ix *Indexer, block uint64, at time.Time, key string, record interface{}) {
+	t.Helper()
+	value, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := ix.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	w := blockWrite{BlockNumber: block, TxID: fmt.Sprintf("tx%d", block), Timestamp: at, Key: key, Value: value}
+	if err := applyWrite(tx, w); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuildReportCard(t *testing.T) {
+	ctx := context.Background()
+	c := newTestEmbeddedClient(t)
+	first := testEvaluation("eval_001", "user_001")
+	first.TeacherID = "teacher_001"
+	for _, e := range []Evaluation{first, testEvaluation("eval_002", "user_001"), testEvaluation("eval_003", "user_001"), testEvaluation("eval_004", "user_002")} {
+		if _, err := c.UploadEvaluation(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, r := range []TestResult{
+		{TestID: "test_001", UserID: "user_001", ScoreSum: "87.5", PaperNumber: "paper_001", Answer: "答案"},
+		{TestID: "test_002", UserID: "user_001", ScoreSum: "90"},
+	} {
+		if _, err := c.UploadTestResult(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 索引保存账本中的密文；test_002 尚未同步，eval_002 的索引落后于账本
+	sealed := c.Sealed()
+	evaluations, err := sealed.GetEvaluationByUser("user_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests, err := sealed.GetTestResultsByUser("user_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ix, err := OpenIndexer(nil, filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+	inTerm := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	sealedRecords := make(map[string]interface{})
+	for _, e := range evaluations {
+		sealedRecords[e.EvaluationID] = e
+		switch e.EvaluationID {
+		case "eval_001":
+			indexRecordAt(t, ix, 3, inTerm, "Evaluation-eval_001", e)
+		case "eval_002":
+			stale := e
+			stale.PointsDegree = "C"
+			indexRecordAt(t, ix, 1, inTerm, "Evaluation-eval_002", stale)
+		case "eval_003":
+			indexRecordAt(t, ix, 4, inTerm.AddDate(1, 0, 0), "Evaluation-eval_003", e)
+		}
+	}
+	for _, r := range tests {
+		sealedRecords[r.TestID] = r
+		if r.TestID == "test_001" {
+			indexRecordAt(t, ix, 2, inTerm, "TestResult-test_001", r)
+		}
+	}
+
+	term := ReportTerm{Name: "2024春季学期", From: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)}
+	card, err := BuildReportCard(ctx, c, ix, "user_001", term, "https://edu.example.com/verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if card.Unindexed != 1 {
+		t.Errorf("未索引记录数 = %d，期望 1", card.Unindexed)
+	}
+
+	sections := []struct {
+		items    []ReportItem
+		ids      []string // 按区块号排序
+		verified []bool
+	}{
+		{card.Evaluations, []string{"eval_002", "eval_001"}, []bool{false, true}},
+		{card.TestResults, []string{"test_001"}, []bool{true}},
+	}
+	for _, tt := range sections {
+		if len(tt.items) != len(tt.ids) {
+			t.Fatalf("成绩单记录 = %+v，期望 %v", tt.items, tt.ids)
+		}
+		for i, item := range tt.items {
+			if item.RecordID != tt.ids[i] || item.Verified != tt.verified[i] {
+				t.Errorf("第 %d 条 = %s（核对 %v），期望 %s（核对 %v）", i, item.RecordID, item.Verified, tt.ids[i], tt.verified[i])
+			}
+			if item.Digest != reportDigest(sealedRecords[item.RecordID]) {
+				t.Errorf("%s 的摘要不是链上记录的摘要", item.RecordID)
+			}
+			if !item.Verified {
+				if item.QRCode != nil || item.VerifyURL != "" {
+					t.Errorf("%s 未通过核对但生成了二维码", item.RecordID)
+				}
+				continue
+			}
+			verify, err := url.Parse(item.VerifyURL)
+			if err != nil || len(item.QRCode) == 0 {
+				t.Fatalf("%s 的校验链接 = %q, %v", item.RecordID, item.VerifyURL, err)
+			}
+			query := verify.Query()
+			if verify.Host != "edu.example.com" || query.Get("tx") != item.TxID || query.Get("key") != item.DocType+"-"+item.RecordID || query.Get("sha256") != item.Digest {
+				t.Errorf("%s 的校验链接 = %s", item.RecordID, item.VerifyURL)
+			}
+		}
+	}
+	if got := card.Evaluations[1]; got.Feedback != first.Feedback || got.TxID != "tx3" || got.BlockNumber != 3 {
+		t.Errorf("eval_001 = %+v，期望解密后的评语和索引中的交易", got)
+	}
+	if got := card.TestResults[0]; got.Value != "87.5 分" || !strings.Contains(got.Title, "paper_001") {
+		t.Errorf("test_001 = %+v", got)
+	}
+
+	if _, err := BuildReportCard(ctx, c, ix, "", term, ""); err == nil {
+		t.Error("空用户ID应当失败")
+	}
+}
+
+func TestWriteReportCard(t *testing.T) {
+	card := &ReportCard{
+		UserID:      "user_001",
+		Term:        ReportTerm{Name: "2024春季学期"},
+		GeneratedAt: time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC),
+		Channel:     channelName,
+		Chaincode:   chaincodeID,
+		Evaluations: []ReportItem{
+			{DocType: "Evaluation", RecordID: "eval_001", Title: "测评 eval_001", Value: "A", Feedback: "<b>继续保持</b>",
+				TxID: "tx1", BlockNumber: 1, Verified: true, QRCode: []byte{0x89, 'P', 'N', 'G'}},
+			{DocType: "Evaluation", RecordID: "eval_002", Title: "测评 eval_002", Value: "B", TxID: "tx2", BlockNumber: 2},
+		},
+		Unindexed: 2,
+	}
+
+	var out bytes.Buffer
+	if err := WriteReportCardHTML(&out, card, ""); err != nil {
+		t.Fatal(err)
+	}
+	html := out.String()
+	for _, want := range []string{
+		"2024春季学期", "交易ID：tx1", "&lt;b&gt;继续保持&lt;/b&gt;", "data:image/png;base64,iVBORw==",
+		"索引中的内容与链上当前值不一致", "另有 2 条链上记录尚未同步到索引", "本学期没有测试成绩",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML中缺少 %q", want)
+		}
+	}
+	if strings.Count(html, "<img") != 1 {
+		t.Errorf("只有通过核对的记录应带二维码")
+	}
+
+	out.Reset()
+	if err := WriteReportCardHTML(&out, card, `{{.UserID}}：{{len .Evaluations}}`); err != nil || out.String() != "user_001：2" {
+		t.Errorf("自定义模板 = %q, %v", out.String(), err)
+	}
+	if err := WriteReportCardHTML(&out, card, `{{.UserID`); err == nil || !strings.Contains(err.Error(), "解析成绩单模板失败") {
+		t.Errorf("模板语法错误 = %v", err)
+	}
+	if err := WriteReportCardHTML(&out, card, `{{.Missing}}`); err == nil || !strings.Contains(err.Error(), "渲染成绩单失败") {
+		t.Errorf("模板字段错误 = %v", err)
+	}
+
+	if err := WriteReportCardPDF(&out, card, ""); err == nil || !strings.Contains(err.Error(), "字体") {
+		t.Errorf("未指定字体 = %v", err)
+	}
+	if err := WriteReportCardPDF(&out, card, filepath.Join(t.TempDir(), "missing.ttf")); err == nil {
+		t.Error("字体文件不存在时应当失败")
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+	"google.golang.org/grpc"
+)
+
+// ===================== 签名器 =====================
+
+// Signer 对交易摘要签名，私钥可以在本地，也可以在独立的签名服务中
+type Signer interface {
+	Sign(digest []byte) ([]byte, error)
+}
+
+// LocalKeySigner 使用本地私钥签名
+type LocalKeySigner struct {
+	sign identity.Sign
+}
+
+// NewLocalKeySigner 从PEM格式私钥创建本地签名器
+func NewLocalKeySigner(keyPEM []byte) (*LocalKeySigner, error) {
+	privateKey, err := identity.PrivateKeyFromPEM(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("解析私钥失败: %v", err)
+	}
+	sign, err := identity.NewPrivateKeySign(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalKeySigner{sign: sign}, nil
+}
+
+func (s *LocalKeySigner) Sign(digest []byte) ([]byte, error) {
+	return s.sign(digest)
+}
+
+// signRequest 远程签名请求，[]byte 字段在JSON中为base64编码
+type signRequest struct {
+	KeyID  string `json:"keyId"`
+	Digest []byte `json:"digest"`
+}
+
+type signResponse struct {
+	Signature []byte `json:"signature"`
+	Error     string `json:"error,omitempty"`
+}
+
+// checkSignature 检查签名服务返回的是DER编码的ECDSA签名，
+// 空签名或格式错误的签名提交后才会在背书时失败，错误信息难以定位到签名服务
+func checkSignature(sig []byte) error {
+	if len(sig) == 0 {
+		return fmt.Errorf("签名服务返回空签名")
+	}
+	var parsed struct{ R, S *big.Int }
+	rest, err := asn1.Unmarshal(sig, &parsed)
+	if err != nil || len(rest) > 0 || parsed.R.Sign() <= 0 || parsed.S.Sign() <= 0 {
+		return fmt.Errorf("签名服务返回的签名格式错误")
+	}
+	return nil
+}
+
+// ===================== HTTP远程签名 =====================
+
+// HTTPSigner 通过HTTP调用签名服务：POST <url>/sign
+type HTTPSigner struct {
+	url        string
+	keyID      string
+	httpClient *http.Client
+}
+
+func NewHTTPSigner(url, keyID string) *HTTPSigner {
+	return &HTTPSigner{
+		url:        strings.TrimSuffix(url, "/"),
+		keyID:      keyID,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (s *HTTPSigner) Sign(digest []byte) ([]byte, error) {
+	body, err := json.Marshal(signRequest{KeyID: s.keyID, Digest: digest})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.httpClient.Post(s.url+"/sign", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("请求签名服务失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("读取签名服务响应失败: %v", err)
+	}
+	var signResp signResponse
+	if resp.StatusCode != http.StatusOK {
+		// 错误响应可能不是JSON（如网关返回的页面），只在能解析时附上错误信息
+		if json.Unmarshal(data, &signResp) == nil && signResp.Error != "" {
+			return nil, fmt.Errorf("签名服务返回 HTTP %d: %s", resp.StatusCode, signResp.Error)
+		}
+		return nil, fmt.Errorf("签名服务返回 HTTP %d", resp.StatusCode)
+	}
+	if err := json.Unmarshal(data, &signResp); err != nil {
+		return nil, fmt.Errorf("解析签名服务响应失败: %v", err)
+	}
+	if signResp.Error != "" {
+		return nil, fmt.Errorf("签名服务返回错误: %s", signResp.Error)
+	}
+	if err := checkSignature(signResp.Signature); err != nil {
+		return nil, err
+	}
+	return signResp.Signature, nil
+}
+
+// ===================== gRPC远程签名 =====================
+
+// 签名服务的gRPC方法，消息使用JSON编码，无需额外的proto定义
+const signerGRPCService = "edu.signer.v1.Signer"
+
+// jsonCodec gRPC的JSON编解码器
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                               { return "json" }
+
+// GRPCSigner 通过gRPC调用签名服务
+type GRPCSigner struct {
+	conn    *grpc.ClientConn
+	keyID   string
+	timeout time.Duration
+}
+
+func NewGRPCSigner(conn *grpc.ClientConn, keyID string) *GRPCSigner {
+	return &GRPCSigner{conn: conn, keyID: keyID, timeout: 5 * time.Second}
+}
+
+func (s *GRPCSigner) Sign(digest []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	var resp signResponse
+	err := s.conn.Invoke(ctx, "/"+signerGRPCService+"/Sign", &signRequest{KeyID: s.keyID, Digest: digest}, &resp, grpc.ForceCodec(jsonCodec{}))
+	if err != nil {
+		return nil, fmt.Errorf("调用签名服务失败: %v", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("签名服务返回错误: %s", resp.Error)
+	}
+	if err := checkSignature(resp.Signature); err != nil {
+		return nil, err
+	}
+	return resp.Signature, nil
+}
+
+// ===================== 本地替身签名服务 =====================
+
+// SigningService 持有私钥的签名服务替身，同时提供HTTP和gRPC接口
+// 仅用于开发和测试环境
+type SigningService struct {
+	mu   sync.RWMutex
+	keys map[string]*ecdsa.PrivateKey
+}
+
+func NewSigningService() *SigningService {
+	return &SigningService{keys: make(map[string]*ecdsa.PrivateKey)}
+}
+
+// AddKey 以 keyID 保存PEM格式ECDSA私钥
+func (s *SigningService) AddKey(keyID string, keyPEM []byte) error {
+	key, err := parseECPrivateKeyPEM(string(keyPEM))
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[keyID] = key
+	return nil
+}
+
+func (s *SigningService) sign(req *signRequest) *signResponse {
+	s.mu.RLock()
+	key, ok := s.keys[req.KeyID]
+	s.mu.RUnlock()
+	if !ok {
+		return &signResponse{Error: fmt.Sprintf("未知的密钥 %s", req.KeyID)}
+	}
+
+	sig, err := signLowS(key, req.Digest)
+	if err != nil {
+		return &signResponse{Error: err.Error()}
+	}
+	return &signResponse{Signature: sig}
+}
+
+// Handler 返回处理 POST /sign 的HTTP处理器
+func (s *SigningService) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sign", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req signRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&signResponse{Error: "请求格式错误"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.sign(&req))
+	})
+	return mux
+}
+
+// signingServiceServer gRPC服务注册所需的处理器类型
+type signingServiceServer interface {
+	sign(req *signRequest) *signResponse
+}
+
+// signingServiceDesc 签名服务的gRPC服务描述
+var signingServiceDesc = grpc.ServiceDesc{
+	ServiceName: signerGRPCService,
+	HandlerType: (*signingServiceServer)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Sign",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+			var req signRequest
+			if err := dec(&req); err != nil {
+				return nil, err
+			}
+			return srv.(signingServiceServer).sign(&req), nil
+		},
+	}},
+}
+
+// RegisterGRPC 在gRPC服务上注册签名方法，服务需使用 grpc.ForceServerCodec(jsonCodec{})
+func (s *SigningService) RegisterGRPC(server *grpc.Server) {
+	server.RegisterService(&signingServiceDesc, s)
+}
+
+// NewSigningGRPCServer 创建使用JSON编解码的gRPC服务并注册签名方法
+func NewSigningGRPCServer(service *SigningService) *grpc.Server {
+	server := grpc.NewServer(grpc.ForceServerCodec(jsonCodec{}))
+	service.RegisterGRPC(server)
+	return server
+}
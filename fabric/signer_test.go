@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func newTestSigningService(t *testing.T) (*SigningService, *ecdsa.PublicKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err := identity.PrivateKeyToPEM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := NewSigningService()
+	if err := service.AddKey("key1", keyPEM); err != nil {
+		t.Fatal(err)
+	}
+	return service, &key.PublicKey
+}
+
+// testSignerDigest 待签名的交易摘要
+var testSignerDigest = func() []byte { d := sha256.Sum256([]byte("proposal")); return d[:] }()
+
+// signerResponseTests 签名服务的异常HTTP响应，HTTPSigner 都应返回错误
+var signerResponseTests = []struct {
+	name    string
+	status  int
+	body    string
+	errPart string
+}{
+	{"服务返回错误", http.StatusOK, `{"error":"密钥已禁用"}`, "密钥已禁用"},
+	{"空签名", http.StatusOK, `{"signature":""}`, "空签名"},
+	{"缺少签名", http.StatusOK, `{}`, "空签名"},
+	{"签名不是DER编码", http.StatusOK, `{"signature":"AQID"}`, "签名格式错误"},
+	{"签名后有多余字节", http.StatusOK, `{"signature":"MAYCAQECAQEA"}`, "签名格式错误"},
+	{"签名值为零", http.StatusOK, `{"signature":"MAYCAQACAQE="}`, "签名格式错误"},
+	{"响应不是JSON", http.StatusOK, `ok`, "解析签名服务响应失败"},
+	{"HTTP错误带错误信息", http.StatusForbidden, `{"error":"无权使用密钥"}`, "HTTP 403: 无权使用密钥"},
+	{"HTTP错误不是JSON", http.StatusBadGateway, `<html>bad gateway</html>`, "HTTP 502"},
+	{"HTTP错误带签名", http.StatusInternalServerError, `{"signature":"MAYCAQECAQE="}`, "HTTP 500"},
+}
+
+func TestHTTPSigner(t *testing.T) {
+	service, public := newTestSigningService(t)
+	server := httptest.NewServer(service.Handler())
+	defer server.Close()
+
+	sig, err := NewHTTPSigner(server.URL+"/", "key1").Sign(testSignerDigest)
+	if err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+	if !ecdsa.VerifyASN1(public, testSignerDigest, sig) {
+		t.Error("签名验证失败")
+	}
+	if _, err := NewHTTPSigner(server.URL, "key2").Sign(testSignerDigest); err == nil || !strings.Contains(err.Error(), "未知的密钥") {
+		t.Errorf("未知密钥 错误 = %v", err)
+	}
+
+	for _, tt := range signerResponseTests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/sign" || r.Method != http.MethodPost {
+					t.Errorf("请求 = %s %s", r.Method, r.URL.Path)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			sig, err := NewHTTPSigner(server.URL, "key1").Sign(testSignerDigest)
+			if err == nil || !strings.Contains(err.Error(), tt.errPart) {
+				t.Errorf("Sign = %x, %v，期望包含 %q 的错误", sig, err, tt.errPart)
+			}
+		})
+	}
+}
+
+// scriptedSigningServer 返回固定响应的gRPC签名服务
+type scriptedSigningServer struct {
+	resp *signResponse
+}
+
+func (s scriptedSigningServer) sign(*signRequest) *signResponse { return s.resp }
+
+// startTestSigningGRPC 在本地端口启动gRPC签名服务并返回连接
+func startTestSigningGRPC(t *testing.T, register func(*grpc.Server)) *grpc.ClientConn {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(grpc.ForceServerCodec(jsonCodec{}))
+	register(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///"+listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestGRPCSigner(t *testing.T) {
+	service, public := newTestSigningService(t)
+	conn := startTestSigningGRPC(t, service.RegisterGRPC)
+
+	sig, err := NewGRPCSigner(conn, "key1").Sign(testSignerDigest)
+	if err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+	if !ecdsa.VerifyASN1(public, testSignerDigest, sig) {
+		t.Error("签名验证失败")
+	}
+	if _, err := NewGRPCSigner(conn, "key2").Sign(testSignerDigest); err == nil || !strings.Contains(err.Error(), "未知的密钥") {
+		t.Errorf("未知密钥 错误 = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		resp    *signResponse
+		errPart string
+	}{
+		{"服务返回错误", &signResponse{Error: "密钥已禁用"}, "密钥已禁用"},
+		{"空签名", &signResponse{}, "空签名"},
+		{"签名不是DER编码", &signResponse{Signature: []byte{1, 2, 3}}, "签名格式错误"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := startTestSigningGRPC(t, func(server *grpc.Server) {
+				server.RegisterService(&signingServiceDesc, scriptedSigningServer{tt.resp})
+			})
+			sig, err := NewGRPCSigner(conn, "key1").Sign(testSignerDigest)
+			if err == nil || !strings.Contains(err.Error(), tt.errPart) {
+				t.Errorf("Sign = %x, %v，期望包含 %q 的错误", sig, err, tt.errPart)
+			}
+		})
+	}
+
+	// 服务未注册签名方法
+	conn = startTestSigningGRPC(t, func(*grpc.Server) {})
+	if _, err := NewGRPCSigner(conn, "key1").Sign(testSignerDigest); err == nil || !strings.Contains(err.Error(), "调用签名服务失败") {
+		t.Errorf("未注册的服务 错误 = %v", err)
+	}
+}
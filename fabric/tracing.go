@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer 使用全局 TracerProvider，未调用 SetupTracing 时不产生任何开销
+var tracer = otel.Tracer("edu-eval/fabric-client")
+
+// ===================== 追踪配置 =====================
+
+// TracingConfig 链路追踪导出配置
+type TracingConfig struct {
+	Exporter    string // otlp / file / none
+	FilePath    string // Exporter 为 file 时写入的文件，每行一个span的JSON
+	ServiceName string
+}
+
+// tracingConfigFromEnv 从环境变量读取追踪配置：
+// OTEL_TRACES_EXPORTER=otlp|file|none，OTLP地址使用 OTEL_EXPORTER_OTLP_ENDPOINT，
+// 文件导出路径使用 EDU_TRACE_FILE（默认 traces.jsonl）
+func tracingConfigFromEnv() TracingConfig {
+	cfg := TracingConfig{
+		Exporter:    os.Getenv("OTEL_TRACES_EXPORTER"),
+		FilePath:    os.Getenv("EDU_TRACE_FILE"),
+		ServiceName: os.Getenv("OTEL_SERVICE_NAME"),
+	}
+	if cfg.FilePath == "" {
+		cfg.FilePath = "traces.jsonl"
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "edu-eval-fabric-client"
+	}
+	return cfg
+}
+
+// SetupTracing 按配置安装全局 TracerProvider，返回的函数在退出前调用以导出剩余span
+func SetupTracing(cfg TracingConfig) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var closeFile func() error
+
+	switch cfg.Exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		e, err := otlptracegrpc.New(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("创建OTLP导出器失败: %v", err)
+		}
+		exporter = e
+	case "file":
+		file, err := os.OpenFile(cfg.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("打开追踪文件失败: %v", err)
+		}
+		e, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("创建文件导出器失败: %v", err)
+		}
+		exporter, closeFile = e, file.Close
+	default:
+		return nil, fmt.Errorf("不支持的追踪导出方式 %s", cfg.Exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closeFile != nil {
+			closeFile()
+		}
+		return err
+	}, nil
+}
+
+// ===================== 调用追踪 =====================
+
+// startCall 开始一次客户端调用：登记进行中的调用、应用单次调用配置、创建调用span并记录指标
+// 客户端关闭后返回 ErrClientClosed。用法：
+// ctx, end, err := c.startCall(ctx, "方法名", opts...); if err != nil {...}; defer end(&err)
+func (c *Client) startCall(ctx context.Context, method string, opts ...CallOption) (context.Context, func(*error), error) {
+	if !c.life.acquire() {
+		return ctx, nil, ErrClientClosed
+	}
+	ctx = withCallOptions(ctx, opts)
+	if options := callOptionsFrom(ctx); options.language == "" {
+		options.language = c.language
+	}
+	cancel := context.CancelFunc(func() {})
+	if timeout := callOptionsFrom(ctx).timeout; timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	ctx, span := tracer.Start(ctx, method, trace.WithSpanKind(trace.SpanKindClient))
+	if c.identity.label != "" {
+		span.SetAttributes(attribute.String("fabric.identity", c.identity.label))
+	}
+	observe := c.metrics.observe(method)
+	return ctx, func(errp *error) {
+		observe(errp)
+		endSpan(span, *errp)
+		cancel()
+		c.life.release()
+	}, nil
+}
+
+// startPhase 开始调用内的一个阶段（如 endorse/submit/commit）
+func startPhase(ctx context.Context, phase string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, phase, trace.WithAttributes(attrs...))
+}
+
+// endSpan 记录错误并结束span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(attribute.String("error.class", errorClass(err)))
+	}
+	span.End()
+}
+
+// marshalArg 序列化链码参数，单独记录序列化耗时
+func marshalArg(ctx context.Context, v interface{}) (string, error) {
+	_, span := startPhase(ctx, "marshal")
+	data, err := json.Marshal(v)
+	endSpan(span, err)
+	return string(data), err
+}
+
+// traceTransient 将当前追踪上下文按 W3C Trace Context 编码为瞬态数据，
+// 键为 traceparent / tracestate，链码从 model.TraceparentTransient 中取出相同的追踪ID
+func traceTransient(ctx context.Context) map[string][]byte {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	transient := make(map[string][]byte, len(carrier))
+	for key, value := range carrier {
+		transient[key] = []byte(value)
+	}
+	return transient
+}
+
+// detachedContext 保留追踪上下文和调用配置但不继承取消，用于在调用返回后继续执行的后台阶段
+func detachedContext(ctx context.Context) context.Context {
+	detached := trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx))
+	return context.WithValue(detached, callOptionsKey{}, callOptionsFrom(ctx))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// 调用span带有各阶段子span，链码日志中的追踪ID与客户端span一致
+// 全局 TracerProvider 只能生效一次，追踪相关的断言都放在这个测试中
+func TestCallTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	defer provider.Shutdown(context.Background())
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	c := newTestEmbeddedClient(t)
+	evaluation := testEvaluation("eval_001", "user_001")
+	if _, err := c.UploadEvaluation(evaluation); err != nil {
+		t.Fatal(err)
+	}
+	_, dupErr := c.UploadEvaluation(evaluation)
+	if dupErr == nil {
+		t.Fatal("重复上传应当失败")
+	}
+
+	var calls []sdktrace.ReadOnlySpan
+	children := make(map[string][]string)
+	for _, span := range recorder.Ended() {
+		if span.Name() == "UploadEvaluation" {
+			calls = append(calls, span)
+			continue
+		}
+		parent := span.Parent().SpanID().String()
+		children[parent] = append(children[parent], span.Name())
+	}
+	if len(calls) != 2 {
+		t.Fatalf("UploadEvaluation span 数 = %d，期望 2", len(calls))
+	}
+
+	ok, failed := calls[0], calls[1]
+	if ok.Status().Code == codes.Error || failed.Status().Code != codes.Error {
+		t.Errorf("span 状态 = %v / %v，期望第二次调用失败", ok.Status(), failed.Status())
+	}
+	// 背书失败的调用不再提交
+	for span, want := range map[sdktrace.ReadOnlySpan][]string{
+		ok:     {"marshal", "endorse", "submit", "commit"},
+		failed: {"marshal", "endorse"},
+	} {
+		if got := children[span.SpanContext().SpanID().String()]; !reflect.DeepEqual(got, want) {
+			t.Errorf("阶段span = %v，期望 %v", got, want)
+		}
+	}
+	var class string
+	for _, attr := range failed.Attributes() {
+		if attr.Key == "error.class" {
+			class = attr.Value.AsString()
+		}
+	}
+	if class != "endorse" {
+		t.Errorf("失败span的 error.class = %q，期望 endorse", class)
+	}
+	traceID := ok.SpanContext().TraceID().String()
+	if !strings.Contains(logs.String(), "[trace="+traceID+" ") {
+		t.Errorf("链码日志中没有追踪ID %s:\n%s", traceID, logs.String())
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"time"
+
+	"edu/model"
+	"edu/model/transcript"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+// ===================== 成绩证明 =====================
+
+// TranscriptIssuer 签发成绩证明的学校组织身份，接收方用该组织的根CA验证
+type TranscriptIssuer struct {
+	MSPID       string
+	Certificate []byte // PEM
+	Signer      Signer
+}
+
+// BuildTranscript 为学生生成可离线验证的成绩证明包
+func (c *Client) BuildTranscript(ix *Indexer, userID string, issuer TranscriptIssuer) (*transcript.Bundle, error) {
+	return c.BuildTranscriptWithContext(context.Background(), ix, userID, issuer)
+}
+
+// BuildTranscriptWithContext 生成成绩证明包，ctx 取消或到期时中止调用，opts 可覆盖本次调用的超时
+// 记录列表以账本查询为准，写入每条记录的交易ID来自链下索引（账本状态中不保存交易ID）；
+// 索引缺少记录或落后于账本时返回错误，保证证明包包含学生的全部记录且是最新值
+func (c *Client) BuildTranscriptWithContext(ctx context.Context, ix *Indexer, userID string, issuer TranscriptIssuer, opts ...CallOption) (bundle *transcript.Bundle, err error) {
+	ctx, end, err := c.startCall(ctx, "BuildTranscript", opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer end(&err)
+
+	if c.embedded != nil {
+		return nil, fmt.Errorf("进程内链码模式没有区块和背书，不支持成绩证明")
+	}
+	if userID == "" {
+		return nil, fmt.Errorf("用户ID不能为空")
+	}
+	if issuer.Signer == nil || len(issuer.Certificate) == 0 {
+		return nil, fmt.Errorf("缺少签发者证书或签名器")
+	}
+	// 接收方只接受学校组织中教务处或管理员签发的证明，提前检查以免签发后无法通过验证
+	cert, err := identity.CertificateFromPEM(issuer.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("解析签发者证书失败: %v", err)
+	}
+	if role := model.CertRole(cert); role != model.RoleRegistrar && role != model.RoleAdmin {
+		return nil, fmt.Errorf("签发者证书的角色 %q 无权签发成绩证明（需要 %s 或 %s）", role, model.RoleRegistrar, model.RoleAdmin)
+	}
+
+	t := &transcript.Transcript{
+		Version:   transcript.Version,
+		UserID:    userID,
+		Channel:   channelName,
+		Chaincode: chaincodeID,
+		IssuedAt:  time.Now().UTC(),
+		Issuer:    transcript.Issuer{MSPID: issuer.MSPID, Certificate: string(issuer.Certificate)},
+	}
+
+	result, err := c.read(ctx, "GetEvaluationByUser", userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询测评记录失败: %w", err)
+	}
+	var evaluations []Evaluation
+	if err := json.Unmarshal(result, &evaluations); err != nil {
+		return nil, fmt.Errorf("解析结果失败: %v", err)
+	}
+	provenance, err := ix.evaluationProvenance(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range evaluations {
+		entry, err := c.transcriptEntry(ctx, model.DocTypeEvaluation, e.EvaluationID, provenance[e.EvaluationID], e)
+		if err != nil {
+			return nil, err
+		}
+		t.Entries = append(t.Entries, *entry)
+	}
+
+	result, err = c.read(ctx, "GetTestResultsByUser", userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询测试成绩失败: %w", err)
+	}
+	var tests []TestResult
+	if err := json.Unmarshal(result, &tests); err != nil {
+		return nil, fmt.Errorf("解析结果失败: %v", err)
+	}
+	if provenance, err = ix.testResultProvenance(userID); err != nil {
+		return nil, err
+	}
+	for _, test := range tests {
+		entry, err := c.transcriptEntry(ctx, model.DocTypeTestResult, test.TestID, provenance[test.TestID], test)
+		if err != nil {
+			return nil, err
+		}
+		t.Entries = append(t.Entries, *entry)
+	}
+
+	return transcript.Sign(t, issuer.Signer.Sign)
+}
+
+// transcriptEntry 取出写入记录当前值的交易信封和区块头，current 为账本中的当前值
+func (c *Client) transcriptEntry(ctx context.Context, docType, recordID string, provenance *reportProvenance, current interface{}) (*transcript.Entry, error) {
+	if provenance == nil {
+		return nil, fmt.Errorf("%s %s 尚未同步到索引，请等待索引同步后重试", docType, recordID)
+	}
+	block, err := c.blockByTxID(ctx, provenance.txID)
+	if err != nil {
+		return nil, err
+	}
+	return blockTranscriptEntry(block, docType, recordID, provenance.txID, current)
+}
+
+// blockTranscriptEntry 在区块中找到交易 txID，核对写入值后生成证明包中的记录
+// 证明包不携带验证码，接收方无法核对交易是否验证通过，因此只收录验证通过的交易
+func blockTranscriptEntry(block *common.Block, docType, recordID, txID string, current interface{}) (*transcript.Entry, error) {
+	var filter []byte
+	if metadata := block.GetMetadata().GetMetadata(); len(metadata) > int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		filter = metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	}
+	key := docType + "-" + recordID
+	for i, envelope := range block.GetData().GetData() {
+		channelHeader, _, transaction, err := unmarshalEndorserTransaction(envelope)
+		if err != nil {
+			return nil, fmt.Errorf("解析交易 %s 失败: %v", txID, err)
+		}
+		if channelHeader.GetTxId() != txID || transaction == nil {
+			continue
+		}
+
+		var value []byte
+		for _, action := range transaction.GetActions() {
+			writes, err := chaincodeWrites(action, chaincodeID)
+			if err != nil {
+				return nil, fmt.Errorf("解析交易 %s 写集失败: %v", txID, err)
+			}
+			for _, w := range writes {
+				if w.GetKey() == key && !w.GetIsDelete() {
+					value = w.GetValue()
+				}
+			}
+		}
+		if value == nil || !sameRecord(value, current) {
+			return nil, fmt.Errorf("%s %s 的索引落后于账本，请等待索引同步后重试", docType, recordID)
+		}
+
+		if i >= len(filter) {
+			return nil, fmt.Errorf("区块 %d 缺少交易验证结果", block.GetHeader().GetNumber())
+		}
+		if code := peer.TxValidationCode(filter[i]); code != peer.TxValidationCode_VALID {
+			return nil, fmt.Errorf("交易 %s 验证未通过（%s），不能写入成绩证明", txID, code)
+		}
+		header := block.GetHeader()
+		return &transcript.Entry{
+			DocType:       docType,
+			RecordID:      recordID,
+			Record:        value,
+			TransactionID: txID,
+			BlockHeader: transcript.BlockHeader{
+				Number:       header.GetNumber(),
+				PreviousHash: header.GetPreviousHash(),
+				DataHash:     header.GetDataHash(),
+			},
+			Envelope: envelope,
+		}, nil
+	}
+	return nil, fmt.Errorf("区块 %d 中找不到交易 %s", block.GetHeader().GetNumber(), txID)
+}
+
+// sameRecord 交易写入的值与账本当前值是否一致
+func sameRecord(written []byte, current interface{}) bool {
+	decoded := reflect.New(reflect.TypeOf(current))
+	if err := json.Unmarshal(written, decoded.Interface()); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(decoded.Elem().Interface(), current)
+}
+
+// blockByTxID 通过 qscc 查询交易所在的区块
+func (c *Client) blockByTxID(ctx context.Context, txID string) (*common.Block, error) {
+	var result []byte
+	err := c.pool.do(ctx, func(peer *peerConn) error {
+		gw, err := c.pool.gatewayFor(peer, c.identity)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := phaseContext(ctx, phaseEvaluate)
+		defer cancel()
+
+		proposal, err := gw.network.GetContract("qscc").NewProposal("GetBlockByTxID", client.WithArguments(channelName, txID))
+		if err == nil {
+			result, err = proposal.EvaluateWithContext(ctx)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("查询交易 %s 所在区块失败: %w", txID, err)
+	}
+
+	block := &common.Block{}
+	if err := proto.Unmarshal(result, block); err != nil {
+		return nil, fmt.Errorf("解析区块失败: %v", err)
+	}
+	return block, nil
+}
+
+// ===================== 命令行 =====================
+
+// runTranscript 生成成绩证明包，接收方用 model/cmd/transcriptverify 离线验证
+// 默认以客户端配置的组织身份签发，-cert/-key 可指定学校专用的签发身份，-sign-url 使用签名服务
+func runTranscript(args []string) error {
+	flags := flag.NewFlagSet("transcript", flag.ExitOnError)
+	userID := flags.String("user", "", "学生用户ID")
+	dbPath := flags.String("db", "edu_index.db", "SQLite索引数据库文件")
+	certFile := flags.String("cert", certPath, "签发者证书PEM文件，须带 edu.role=registrar 或 admin 属性")
+	keyFile := flags.String("key", "", "签发者私钥PEM文件，默认使用客户端私钥目录中的私钥")
+	signURL := flags.String("sign-url", "", "签名服务地址，指定后不读取本地私钥")
+	keyID := flags.String("key-id", "", "签名服务中的密钥ID")
+	issuerMSP := flags.String("msp", mspID, "签发者 MSP ID")
+	out := flags.String("out", "", "输出文件，默认为 transcript-<用户ID>.json")
+	flags.Parse(args)
+
+	if *userID == "" {
+		return fmt.Errorf("缺少 -user 参数")
+	}
+	if *out == "" {
+		*out = "transcript-" + *userID + ".json"
+	}
+	cert, err := ioutil.ReadFile(*certFile)
+	if err != nil {
+		return fmt.Errorf("读取证书文件失败: %v", err)
+	}
+	var signer Signer
+	switch {
+	case *signURL != "":
+		signer = NewHTTPSigner(*signURL, *keyID)
+	case *keyFile != "":
+		keyPEM, err := ioutil.ReadFile(*keyFile)
+		if err != nil {
+			return fmt.Errorf("读取私钥文件失败: %v", err)
+		}
+		if signer, err = NewLocalKeySigner(keyPEM); err != nil {
+			return err
+		}
+	default:
+		sign, err := newSign()
+		if err != nil {
+			return err
+		}
+		signer = &LocalKeySigner{sign: sign}
+	}
+
+	ix, err := OpenIndexer(nil, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer ix.Close()
+	client, err := NewClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	bundle, err := client.BuildTranscript(ix, *userID, TranscriptIssuer{MSPID: *issuerMSP, Certificate: cert, Signer: signer})
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化证明包失败: %v", err)
+	}
+	if err := ioutil.WriteFile(*out, data, 0644); err != nil {
+		return fmt.Errorf("写入证明包失败: %v", err)
+	}
+	log.Printf("成绩证明已生成: %s", *out)
+	fmt.Fprintf(os.Stderr, "验证: go run ./cmd/transcriptverify -ca <学校根CA证书> %s（在 model 目录下）\n", *out)
+	return nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"edu/model"
+	"edu/model/transcript"
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go-apiv2/msp"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// transcriptCA 签发证明包测试身份的根CA
+type transcriptCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+// transcriptIdentity 根CA签发的身份，serialized 为交易中的 SerializedIdentity
+type transcriptIdentity struct {
+	certPEM    []byte
+	key        *ecdsa.PrivateKey
+	serialized []byte
+}
+
+func newTranscriptCA(t *testing.T) *transcriptCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca.org1.example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &transcriptCA{cert: cert, key: key, pool: pool}
+}
+
+func (ca *transcriptCA) issue(t *testing.T, commonName, role string) *transcriptIdentity {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if role != "" {
+		template.ExtraExtensions = []pkix.Extension{
+			{Id: model.AttrsExtensionOID, Value: []byte(`{"attrs":{"` + model.RoleAttribute + `":"` + role + `"}}`)},
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	serialized := mustMarshal(t, &msp.SerializedIdentity{Mspid: model.InstitutionMSPID, IdBytes: certPEM})
+	return &transcriptIdentity{certPEM: certPEM, key: key, serialized: serialized}
+}
+
+func (id *transcriptIdentity) sign(digest []byte) ([]byte, error) {
+	return ecdsa.SignASN1(rand.Reader, id.key, digest)
+}
+
+func mustJSON(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// transcriptBlock 区块中只有一笔写入 key 的交易，背书者依次签名，返回区块和交易ID
+func transcriptBlock(t *testing.T, creator *transcriptIdentity, code peer.TxValidationCode, key string, value []byte, endorsers ...*transcriptIdentity) (*common.Block, string) {
+	t.Helper()
+	nonce := make([]byte, 24)
+	rand.Read(nonce)
+	hash := sha256.Sum256(append(append([]byte{}, nonce...), creator.serialized...))
+	txID := hex.EncodeToString(hash[:])
+
+	results := mustMarshal(t, &rwset.TxReadWriteSet{NsRwset: []*rwset.NsReadWriteSet{
+		{Namespace: chaincodeID, Rwset: mustMarshal(t, &kvrwset.KVRWSet{Writes: []*kvrwset.KVWrite{{Key: key, Value: value}}})},
+	}})
+	responsePayload := mustMarshal(t, &peer.ProposalResponsePayload{
+		ProposalHash: []byte("proposal-hash"),
+		Extension:    mustMarshal(t, &peer.ChaincodeAction{Results: results}),
+	})
+	action := &peer.ChaincodeEndorsedAction{ProposalResponsePayload: responsePayload}
+	for _, e := range endorsers {
+		digest := sha256.Sum256(append(append([]byte{}, responsePayload...), e.serialized...))
+		signature, err := e.sign(digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		action.Endorsements = append(action.Endorsements, &peer.Endorsement{Endorser: e.serialized, Signature: signature})
+	}
+
+	payload := mustMarshal(t, &common.Payload{
+		Header: &common.Header{
+			ChannelHeader: mustMarshal(t, &common.ChannelHeader{
+				Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+				ChannelId: channelName,
+				TxId:      txID,
+				Timestamp: timestamppb.Now(),
+			}),
+			SignatureHeader: mustMarshal(t, &common.SignatureHeader{Creator: creator.serialized, Nonce: nonce}),
+		},
+		Data: mustMarshal(t, &peer.Transaction{Actions: []*peer.TransactionAction{
+			{Payload: mustMarshal(t, &peer.ChaincodeActionPayload{Action: action})},
+		}}),
+	})
+	block := &common.Block{
+		Header:   &common.BlockHeader{Number: 12, PreviousHash: []byte("previous"), DataHash: []byte("data")},
+		Data:     &common.BlockData{Data: [][]byte{mustMarshal(t, &common.Envelope{Payload: payload})}},
+		Metadata: &common.BlockMetadata{Metadata: make([][]byte, common.BlockMetadataIndex_TRANSACTIONS_FILTER+1)},
+	}
+	block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = []byte{byte(code)}
+	return block, txID
+}
+
+// 客户端以 fabric-protos 生成的区块构造证明包，验证器只按字段号解析，二者必须一致
+func TestTranscriptRoundTrip(t *testing.T) {
+	ca := newTranscriptCA(t)
+	registrar := ca.issue(t, "registrar", model.RoleRegistrar)
+	teacher := ca.issue(t, "teacher_001", model.RoleTeacher)
+	peer0 := ca.issue(t, "peer0.org1.example.com", "")
+	peer1 := ca.issue(t, "peer1.org1.example.com", "")
+
+	evaluation := testEvaluation("eval_001", "user_001")
+	value := mustJSON(t, evaluation)
+	key := model.DocTypeEvaluation + "-eval_001"
+
+	tests := []struct {
+		name            string
+		issuer          *transcriptIdentity
+		endorsers       []*transcriptIdentity
+		minEndorsements int
+		tamper          func(*transcript.Transcript)
+		wantErr         string // 证明包级别的错误
+		entryErr        string // 记录级别的错误
+		wantEndorsers   int
+	}{
+		{name: "有效证明", issuer: registrar, endorsers: []*transcriptIdentity{peer0, peer1}, minEndorsements: 2, wantEndorsers: 2},
+		{name: "同一节点重复背书", issuer: registrar, endorsers: []*transcriptIdentity{peer0, peer0}, minEndorsements: 2,
+			entryErr: "有效背书来自 1 个不同背书者"},
+		{name: "教师签发", issuer: teacher, endorsers: []*transcriptIdentity{peer0}, wantErr: "无权签发成绩证明"},
+		{name: "修改记录后重新签名", issuer: registrar, endorsers: []*transcriptIdentity{peer0},
+			tamper: func(tr *transcript.Transcript) {
+				tr.Entries[0].Record = []byte(strings.Replace(string(tr.Entries[0].Record), `"Points_Degree":"A"`, `"Points_Degree":"B"`, 1))
+			},
+			entryErr: "写集中的值与记录不一致"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block, txID := transcriptBlock(t, registrar, peer.TxValidationCode_VALID, key, value, tt.endorsers...)
+			entry, err := blockTranscriptEntry(block, model.DocTypeEvaluation, "eval_001", txID, evaluation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr := &transcript.Transcript{
+				Version:   transcript.Version,
+				UserID:    "user_001",
+				Channel:   channelName,
+				Chaincode: chaincodeID,
+				IssuedAt:  time.Now().UTC(),
+				Issuer:    transcript.Issuer{MSPID: model.InstitutionMSPID, Certificate: string(tt.issuer.certPEM)},
+				Entries:   []transcript.Entry{*entry},
+			}
+			if tt.tamper != nil {
+				tt.tamper(tr)
+			}
+			bundle, err := transcript.Sign(tr, tt.issuer.sign)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, results, err := transcript.Verify(bundle, transcript.VerifyOptions{Roots: ca.pool, MinEndorsements: tt.minEndorsements})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Verify = %v，期望包含 %q 的错误", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := results[0]
+			if tt.entryErr != "" {
+				if r.Err == nil || !strings.Contains(r.Err.Error(), tt.entryErr) {
+					t.Fatalf("记录验证 = %v，期望包含 %q 的错误", r.Err, tt.entryErr)
+				}
+				return
+			}
+			if r.Err != nil {
+				t.Fatalf("记录验证 = %v", r.Err)
+			}
+			if len(r.Endorsers) != tt.wantEndorsers || r.BlockNumber != 12 || r.Timestamp.IsZero() {
+				t.Errorf("验证结果 = %+v", r)
+			}
+		})
+	}
+}
+
+func TestBlockTranscriptEntry(t *testing.T) {
+	ca := newTranscriptCA(t)
+	creator := ca.issue(t, "admin", model.RoleAdmin)
+	peer0 := ca.issue(t, "peer0.org1.example.com", "")
+	evaluation := testEvaluation("eval_001", "user_001")
+	key := model.DocTypeEvaluation + "-eval_001"
+
+	tests := []struct {
+		name    string
+		block   func() (*common.Block, string)
+		wantErr string
+	}{
+		{"验证通过", func() (*common.Block, string) {
+			return transcriptBlock(t, creator, peer.TxValidationCode_VALID, key, mustJSON(t, evaluation), peer0)
+		}, ""},
+		{"验证未通过的交易", func() (*common.Block, string) {
+			return transcriptBlock(t, creator, peer.TxValidationCode_MVCC_READ_CONFLICT, key, mustJSON(t, evaluation), peer0)
+		}, "验证未通过（MVCC_READ_CONFLICT）"},
+		{"缺少验证结果", func() (*common.Block, string) {
+			block, txID := transcriptBlock(t, creator, peer.TxValidationCode_VALID, key, mustJSON(t, evaluation), peer0)
+			block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = nil
+			return block, txID
+		}, "缺少交易验证结果"},
+		{"写入值不是当前值", func() (*common.Block, string) {
+			stale := evaluation
+			stale.PointsDegree = "C"
+			return transcriptBlock(t, creator, peer.TxValidationCode_VALID, key, mustJSON(t, stale), peer0)
+		}, "索引落后于账本"},
+		{"区块中没有该交易", func() (*common.Block, string) {
+			block, _ := transcriptBlock(t, creator, peer.TxValidationCode_VALID, key, mustJSON(t, evaluation), peer0)
+			return block, "tx-missing"
+		}, "找不到交易 tx-missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block, txID := tt.block()
+			entry, err := blockTranscriptEntry(block, model.DocTypeEvaluation, "eval_001", txID, evaluation)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("blockTranscriptEntry = %v，期望包含 %q 的错误", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry.TransactionID != txID || entry.BlockHeader.Number != 12 || string(entry.BlockHeader.DataHash) != "data" {
+				t.Errorf("记录 = %+v", entry)
+			}
+		})
+	}
+}
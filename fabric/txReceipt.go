@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-protos-go-apiv2/gateway"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// ===================== 交易回执 =====================
+
+// TxReceipt 交易回执
+// 记录交易ID、所在区块、验证结果以及交易时间
+type TxReceipt struct {
+	TransactionID string    `json:"transactionId"` // 交易ID
+	BlockNumber   uint64    `json:"blockNumber"`   // 交易所在区块号
+	Status        string    `json:"status"`        // 验证码，如 VALID / MVCC_READ_CONFLICT
+	Successful    bool      `json:"successful"`    // 交易是否验证通过
+	Timestamp     time.Time `json:"timestamp"`     // 交易时间，取自交易的通道头，与链码中 GetTxTimestamp 的时间一致
+}
+
+// PendingTx 已提交但尚未确认的异步交易
+// 创建后在后台等待提交状态，调用方可以阻塞等待或轮询结果
+type PendingTx struct {
+	transactionID string
+	done          chan struct{}
+
+	mu      sync.Mutex
+	receipt *TxReceipt
+	err     error
+}
+
+// newPendingTx 创建异步交易并启动后台提交状态等待，onDone 在状态确定后调用（可为 nil）
+// ctx 只用于延续追踪上下文，调用方返回后等待仍会继续
+func newPendingTx(ctx context.Context, commit submittedTx, onDone func(error)) *PendingTx {
+	ctx = detachedContext(ctx)
+	pending := &PendingTx{
+		transactionID: commit.TransactionID(),
+		done:          make(chan struct{}),
+	}
+
+	go func() {
+		defer close(pending.done)
+
+		receipt, err := receiptFromCommit(ctx, commit)
+		pending.mu.Lock()
+		pending.receipt, pending.err = receipt, err
+		pending.mu.Unlock()
+		if onDone != nil {
+			onDone(err)
+		}
+	}()
+
+	return pending
+}
+
+// TransactionID 返回交易ID，提交后即可获得
+func (p *PendingTx) TransactionID() string {
+	return p.transactionID
+}
+
+// Done 返回在提交状态确定后关闭的通道
+func (p *PendingTx) Done() <-chan struct{} {
+	return p.done
+}
+
+// Wait 阻塞直到交易提交状态确定
+func (p *PendingTx) Wait() (*TxReceipt, error) {
+	<-p.done
+	return p.result()
+}
+
+// Await 等待交易提交状态，ctx 取消时提前返回（交易本身不会被撤回）
+func (p *PendingTx) Await(ctx context.Context) (*TxReceipt, error) {
+	select {
+	case <-p.done:
+		return p.result()
+	case <-ctx.Done():
+		return nil, fmt.Errorf("等待交易 %s 提交状态中断: %v", p.transactionID, ctx.Err())
+	}
+}
+
+// Poll 非阻塞查询提交状态，第二个返回值表示状态是否已确定
+func (p *PendingTx) Poll() (*TxReceipt, bool, error) {
+	select {
+	case <-p.done:
+		receipt, err := p.result()
+		return receipt, true, err
+	default:
+		return nil, false, nil
+	}
+}
+
+func (p *PendingTx) result() (*TxReceipt, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.receipt, p.err
+}
+
+// ===================== 提交工具函数 =====================
+
+// submittedTx 已提交给排序服务的交易，gatewayCommit 或进程内模式的 embeddedCommit
+type submittedTx interface {
+	TransactionID() string
+	StatusWithContext(ctx context.Context, opts ...grpc.CallOption) (*client.Status, error)
+	// TxTimestamp 交易通道头中的时间戳
+	TxTimestamp() time.Time
+}
+
+// gatewayCommit 通过网关提交的交易，附带从交易内容中解析出的时间戳
+type gatewayCommit struct {
+	*client.Commit
+	timestamp time.Time
+}
+
+func (g *gatewayCommit) TxTimestamp() time.Time {
+	return g.timestamp
+}
+
+// transactionTimestamp 从序列化的待提交交易（Transaction.Bytes）中读取通道头的时间戳
+func transactionTimestamp(transactionBytes []byte) (time.Time, error) {
+	var prepared gateway.PreparedTransaction
+	if err := proto.Unmarshal(transactionBytes, &prepared); err != nil {
+		return time.Time{}, fmt.Errorf("解析交易失败: %v", err)
+	}
+	var payload common.Payload
+	if err := proto.Unmarshal(prepared.GetEnvelope().GetPayload(), &payload); err != nil {
+		return time.Time{}, fmt.Errorf("解析交易内容失败: %v", err)
+	}
+	var header common.ChannelHeader
+	if err := proto.Unmarshal(payload.GetHeader().GetChannelHeader(), &header); err != nil {
+		return time.Time{}, fmt.Errorf("解析交易通道头失败: %v", err)
+	}
+	return header.GetTimestamp().AsTime(), nil
+}
+
+// submit 同步提交交易并等待提交状态，ctx 为调用span所在的上下文
+func (c *Client) submit(ctx context.Context, txName string, args ...string) (*TxReceipt, error) {
+	commit, _, err := c.endorseAndSubmit(ctx, txName, args...)
+	if err != nil {
+		return nil, err
+	}
+	return receiptFromCommit(ctx, commit)
+}
+
+// submitAsync 背书并提交交易给排序服务，不等待区块提交
+// method 为调用方法名，提交等待的耗时和结果记录在该方法下
+func (c *Client) submitAsync(ctx context.Context, method, txName string, args ...string) (*PendingTx, error) {
+	commit, _, err := c.endorseAndSubmit(ctx, txName, args...)
+	if err != nil {
+		return nil, err
+	}
+	// 提交等待在调用返回后继续，单独登记以便 Shutdown 等待其结束
+	observe := c.metrics.observeCommit(method)
+	c.life.retain()
+	return newPendingTx(ctx, commit, func(err error) {
+		observe(err)
+		c.life.release()
+	}), nil
+}
+
+// endorseAndSubmit 背书并提交交易，返回已提交的交易和背书时链码的返回值，背书和提交分别记录为子span
+// 只有背书阶段节点不可达时才会切换节点重试；提交阶段交易可能已到达排序服务，不能重试
+func (c *Client) endorseAndSubmit(ctx context.Context, txName string, args ...string) (submittedTx, []byte, error) {
+	args, err := c.sealArgs(ctx, txName, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, args, err = c.withPersonalContent(ctx, txName, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.embedded != nil {
+		return c.embedded.endorseAndSubmit(ctx, c.identity, txName, args)
+	}
+
+	var transaction *client.Transaction
+	err = c.pool.do(ctx, func(peer *peerConn) error {
+		gw, err := c.pool.gatewayFor(peer, c.identity)
+		if err != nil {
+			return err
+		}
+
+		ctx, span := startPhase(ctx, "endorse", attribute.String("fabric.peer", peer.config.Endpoint))
+		ctx, cancel := phaseContext(ctx, phaseEndorse)
+		defer cancel()
+
+		proposal, err := gw.contract.NewProposal(txName, proposalOptions(ctx, args)...)
+		if err == nil {
+			transaction, err = proposal.EndorseWithContext(ctx)
+		}
+		endSpan(span, err)
+		return err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("提交交易失败: %w", chaincodeError(err))
+	}
+
+	transactionBytes, err := transaction.Bytes()
+	if err != nil {
+		return nil, nil, fmt.Errorf("序列化交易失败: %v", err)
+	}
+	timestamp, err := transactionTimestamp(transactionBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, span := startPhase(ctx, "submit", attribute.String("fabric.tx_id", transaction.TransactionID()))
+	submitCtx, cancel := phaseContext(ctx, phaseSubmit)
+	commit, err := transaction.SubmitWithContext(submitCtx)
+	cancel()
+	endSpan(span, err)
+	if err != nil {
+		return nil, nil, fmt.Errorf("提交交易失败: %w", err)
+	}
+	return &gatewayCommit{Commit: commit, timestamp: timestamp}, transaction.Result(), nil
+}
+
+// proposalOptions 链码参数及携带追踪上下文和学生密钥的瞬态数据
+func proposalOptions(ctx context.Context, args []string) []client.ProposalOption {
+	options := []client.ProposalOption{client.WithArguments(args...)}
+	if transient := proposalTransient(ctx); transient != nil {
+		options = append(options, client.WithTransient(transient))
+	}
+	return options
+}
+
+// receiptFromCommit 等待提交状态并生成回执，验证失败时同时返回回执和错误
+func receiptFromCommit(ctx context.Context, commit submittedTx) (receipt *TxReceipt, err error) {
+	ctx, span := startPhase(ctx, "commit", attribute.String("fabric.tx_id", commit.TransactionID()))
+	defer func() {
+		if receipt != nil {
+			span.SetAttributes(
+				attribute.Int64("fabric.block_number", int64(receipt.BlockNumber)),
+				attribute.String("fabric.validation_code", receipt.Status),
+			)
+		}
+		endSpan(span, err)
+	}()
+
+	ctx, cancel := phaseContext(ctx, phaseCommitStatus)
+	defer cancel()
+
+	status, err := commit.StatusWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取交易 %s 提交状态失败: %w", commit.TransactionID(), err)
+	}
+
+	receipt = &TxReceipt{
+		TransactionID: status.TransactionID,
+		BlockNumber:   status.BlockNumber,
+		Status:        status.Code.String(),
+		Successful:    status.Successful,
+		Timestamp:     commit.TxTimestamp(),
+	}
+	if !status.Successful {
+		return receipt, &CommitFailedError{Receipt: receipt}
+	}
+	return receipt, nil
+}
+
+// CommitFailedError 交易已写入区块但未通过验证（如 MVCC_READ_CONFLICT）
+type CommitFailedError struct {
+	Receipt *TxReceipt
+}
+
+func (e *CommitFailedError) Error() string {
+	return fmt.Sprintf("交易 %s 验证失败: %s", e.Receipt.TransactionID, e.Receipt.Status)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"edu/model"
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-protos-go-apiv2/gateway"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestTransactionTimestamp(t *testing.T) {
+	want := time.Date(2024, 6, 1, 8, 30, 15, 123000000, time.UTC)
+	chHeader := mustMarshal(t, &common.ChannelHeader{ChannelId: channelName, TxId: testOfflineTxID, Timestamp: timestamppb.New(want)})
+	payload := mustMarshal(t, &common.Payload{Header: &common.Header{ChannelHeader: chHeader}})
+	transactionBytes := mustMarshal(t, &gateway.PreparedTransaction{TransactionId: testOfflineTxID, Envelope: &common.Envelope{Payload: payload}})
+
+	got, err := transactionTimestamp(transactionBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(want) {
+		t.Errorf("交易时间 = %v，期望 %v", got, want)
+	}
+	if _, err := transactionTimestamp([]byte("not a transaction")); err == nil {
+		t.Error("无法解析的交易应返回错误")
+	}
+}
+
+// 回执时间与链码看到的交易时间一致：授权记录的创建时间取自链码的 GetTxTimestamp
+func TestReceiptTimestampIsTransactionTime(t *testing.T) {
+	c := newTestEmbeddedClient(t)
+	if _, err := c.UploadEvaluation(testEvaluation("eval_001", "user_001")); err != nil {
+		t.Fatal(err)
+	}
+	student := withTestIdentity(t, c, model.InstitutionMSPID, "user_001", model.RoleStudent)
+
+	time.Sleep(10 * time.Millisecond)
+	receipt, err := student.GrantAccess("Org2MSP/employer", AccessScope{DocTypes: []string{"Evaluation"}}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	grants, err := student.GetAccessGrants("user_001")
+	if err != nil || len(grants) != 1 {
+		t.Fatalf("授权 = %+v, %v", grants, err)
+	}
+	if got := receipt.Timestamp.UTC().Format(time.RFC3339); got != grants[0].CreatedAt {
+		t.Errorf("回执时间 %s 与链码中的交易时间 %s 不一致", got, grants[0].CreatedAt)
+	}
+	if receipt.Timestamp.After(time.Now()) {
+		t.Errorf("回执时间 %v 晚于当前时间", receipt.Timestamp)
+	}
+}
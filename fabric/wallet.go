@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+)
+
+// ===================== 身份钱包 =====================
+
+// WalletIdentity 钱包中保存的一个身份
+type WalletIdentity struct {
+	MSPID       string `json:"mspId"`
+	Certificate string `json:"certificate"` // PEM格式证书
+	PrivateKey  string `json:"privateKey"`  // PEM格式私钥
+}
+
+// Wallet 身份钱包，按标签（通常为用户ID）存取身份
+type Wallet interface {
+	Put(label string, id *WalletIdentity) error
+	Get(label string) (*WalletIdentity, error)
+	List() ([]string, error)
+	Remove(label string) error
+}
+
+// gatewayIdentity 转换为Gateway使用的身份和签名函数
+func (w *WalletIdentity) gatewayIdentity(label string) (*gatewayIdentity, error) {
+	cert, err := identity.CertificateFromPEM([]byte(w.Certificate))
+	if err != nil {
+		return nil, fmt.Errorf("解析身份 %s 证书失败: %v", label, err)
+	}
+	id, err := identity.NewX509Identity(w.MSPID, cert)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := identity.PrivateKeyFromPEM([]byte(w.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("解析身份 %s 私钥失败: %v", label, err)
+	}
+	sign, err := identity.NewPrivateKeySign(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gatewayIdentity{label: label, id: id, sign: sign}, nil
+}
+
+// ===================== 文件系统钱包 =====================
+
+// FileSystemWallet 每个身份保存为目录下的 <label>.id JSON文件
+type FileSystemWallet struct {
+	dir string
+}
+
+func NewFileSystemWallet(dir string) (*FileSystemWallet, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("创建钱包目录失败: %v", err)
+	}
+	return &FileSystemWallet{dir: dir}, nil
+}
+
+func (w *FileSystemWallet) Put(label string, id *WalletIdentity) error {
+	path, err := w.path(label)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(id, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化身份失败: %v", err)
+	}
+
+	// 先写临时文件再改名，避免写入中断留下损坏的身份文件
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入身份文件失败: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func (w *FileSystemWallet) Get(label string) (*WalletIdentity, error) {
+	path, err := w.path(label)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("钱包中不存在身份 %s", label)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取身份文件失败: %v", err)
+	}
+
+	var id WalletIdentity
+	if err := json.Unmarshal(data, &id); err != nil {
+		return nil, fmt.Errorf("解析身份文件失败: %v", err)
+	}
+	return &id, nil
+}
+
+func (w *FileSystemWallet) List() ([]string, error) {
+	files, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取钱包目录失败: %v", err)
+	}
+
+	var labels []string
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".id") {
+			labels = append(labels, strings.TrimSuffix(f.Name(), ".id"))
+		}
+	}
+	return labels, nil
+}
+
+func (w *FileSystemWallet) Remove(label string) error {
+	path, err := w.path(label)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除身份文件失败: %v", err)
+	}
+	return nil
+}
+
+func (w *FileSystemWallet) path(label string) (string, error) {
+	if label == "" || strings.ContainsAny(label, `/\`) || label == "." || label == ".." {
+		return "", fmt.Errorf("非法的身份标签: %q", label)
+	}
+	return filepath.Join(w.dir, label+".id"), nil
+}
+
+// ===================== 内存钱包 =====================
+
+// InMemoryWallet 仅保存在内存中的钱包，进程退出后丢失
+type InMemoryWallet struct {
+	mu         sync.RWMutex
+	identities map[string]*WalletIdentity
+}
+
+func NewInMemoryWallet() *InMemoryWallet {
+	return &InMemoryWallet{identities: make(map[string]*WalletIdentity)}
+}
+
+func (w *InMemoryWallet) Put(label string, id *WalletIdentity) error {
+	if label == "" {
+		return fmt.Errorf("身份标签不能为空")
+	}
+	copied := *id
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.identities[label] = &copied
+	return nil
+}
+
+func (w *InMemoryWallet) Get(label string) (*WalletIdentity, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	id, ok := w.identities[label]
+	if !ok {
+		return nil, fmt.Errorf("钱包中不存在身份 %s", label)
+	}
+	copied := *id
+	return &copied, nil
+}
+
+func (w *InMemoryWallet) List() ([]string, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	labels := make([]string, 0, len(w.identities))
+	for label := range w.identities {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels, nil
+}
+
+func (w *InMemoryWallet) Remove(label string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.identities, label)
+	return nil
+}
+
+// ===================== 按身份调用 =====================
+
+// As 返回以钱包中 userID 身份签名的客户端视图，与原客户端共享节点连接
+func (c *Client) As(userID string) (*Client, error) {
+	if c.wallet == nil {
+		return nil, fmt.Errorf("客户端未配置钱包")
+	}
+
+	walletID, err := c.wallet.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	gid, err := walletID.gatewayIdentity(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	view := *c
+	view.identity = gid
+	return &view, nil
+}
+
+// ReloadIdentity 钱包中的身份更新（如重新登记）后丢弃已缓存的Gateway
+func (c *Client) ReloadIdentity(userID string) {
+	c.pool.forgetIdentity(userID)
+}
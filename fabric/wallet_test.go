@@ -0,0 +1,122 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// testWalletIdentity 从本地替身CA登记 user_001 的身份
+func testWalletIdentity(t *testing.T) *WalletIdentity {
+	t.Helper()
+	_, client := newTestCA(t)
+	id, err := client.Enroll("user_001", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestWallets(t *testing.T) {
+	fsWallet, err := NewFileSystemWallet(filepath.Join(t.TempDir(), "wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := testWalletIdentity(t)
+	for name, wallet := range map[string]Wallet{"文件系统": fsWallet, "内存": NewInMemoryWallet()} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := wallet.Get("user_001"); err == nil {
+				t.Error("读取不存在的身份应返回错误")
+			}
+			for _, label := range []string{"user_002", "user_001"} {
+				if err := wallet.Put(label, id); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := wallet.Get("user_001")
+			if err != nil || !reflect.DeepEqual(got, id) {
+				t.Fatalf("读取身份 = %+v, %v", got, err)
+			}
+			// 返回的是副本，修改不影响钱包中的身份
+			got.MSPID = "Org2MSP"
+			if again, _ := wallet.Get("user_001"); again.MSPID != mspID {
+				t.Errorf("修改读取结果改变了钱包中的身份: %s", again.MSPID)
+			}
+
+			labels, err := wallet.List()
+			sort.Strings(labels)
+			if err != nil || !reflect.DeepEqual(labels, []string{"user_001", "user_002"}) {
+				t.Errorf("身份列表 = %v, %v", labels, err)
+			}
+			if err := wallet.Remove("user_002"); err != nil {
+				t.Fatal(err)
+			}
+			if err := wallet.Remove("user_002"); err != nil {
+				t.Errorf("重复删除 = %v，期望成功", err)
+			}
+			if labels, _ := wallet.List(); len(labels) != 1 {
+				t.Errorf("删除后身份列表 = %v", labels)
+			}
+			if err := wallet.Put("", id); err == nil {
+				t.Error("空标签应返回错误")
+			}
+		})
+	}
+}
+
+func TestFileSystemWalletLabels(t *testing.T) {
+	dir := t.TempDir()
+	wallet, err := NewFileSystemWallet(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := testWalletIdentity(t)
+	for _, label := range []string{"../user_001", `a\b`, ".", ".."} {
+		if err := wallet.Put(label, id); err == nil {
+			t.Errorf("非法标签 %q 应被拒绝", label)
+		}
+	}
+	if err := wallet.Put("user_001", id); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "user_001.id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("身份文件权限 = %v，期望 0600", info.Mode().Perm())
+	}
+	// 其他文件不出现在身份列表中
+	ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0600)
+	if labels, _ := wallet.List(); !reflect.DeepEqual(labels, []string{"user_001"}) {
+		t.Errorf("身份列表 = %v", labels)
+	}
+}
+
+func TestClientAs(t *testing.T) {
+	wallet := NewInMemoryWallet()
+	if err := wallet.Put("user_001", testWalletIdentity(t)); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestEmbeddedClient(t, WithWallet(wallet))
+
+	view, err := c.As("user_001")
+	if err != nil {
+		t.Fatalf("切换身份失败: %v", err)
+	}
+	if view.identity.label != "user_001" || view.identity.id.MspID() != mspID {
+		t.Errorf("切换后的身份 = %s/%s", view.identity.id.MspID(), view.identity.label)
+	}
+	if c.identity.label == "user_001" {
+		t.Error("As 不应修改原客户端的身份")
+	}
+	if _, err := c.As("user_002"); err == nil {
+		t.Error("钱包中不存在的身份应返回错误")
+	}
+	if _, err := newTestEmbeddedClient(t).As("user_001"); err == nil {
+		t.Error("未配置钱包时应返回错误")
+	}
+}